@@ -3,12 +3,16 @@ package main
 import (
 	"context"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"anoa.com/telkomalumiforum/internal/agent"
 	"anoa.com/telkomalumiforum/internal/handler"
+	"anoa.com/telkomalumiforum/internal/jobs"
 	"anoa.com/telkomalumiforum/internal/middleware"
 	"anoa.com/telkomalumiforum/internal/model"
 	"anoa.com/telkomalumiforum/internal/repository"
@@ -39,6 +43,16 @@ func main() {
 	if err := migrate(db); err != nil {
 		log.Fatalf("migration failed: %v", err)
 	}
+
+	// One-off maintenance: go run ./cmd/server recount-replies
+	if len(os.Args) > 1 && os.Args[1] == "recount-replies" {
+		fixed, err := repository.NewThreadRepository(db).RecountReplies(context.Background())
+		if err != nil {
+			log.Fatalf("recount failed: %v", err)
+		}
+		log.Printf("Recounted replies, %d threads fixed", fixed)
+		return
+	}
 	if err := seedRoles(db); err != nil {
 		log.Fatalf("failed to seed roles: %v", err)
 	}
@@ -54,10 +68,12 @@ func main() {
 	}
 
 	userRepo := repository.NewUserRepository(db)
-	imageStorage, err := storage.NewCloudinaryStorage()
-	if err != nil {
-		log.Fatalf("failed to initialize cloudinary storage: %v", err)
-	}
+	threadRepo := repository.NewThreadRepository(db)
+	postRepo := repository.NewPostRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+
+	jobRepo := repository.NewJobRepository(db)
+	jobManager := jobs.NewManager(redisClient, jobRepo)
 
 	// Initialize Meilisearch
 	meiliHost := os.Getenv("MEILISEARCH_HOST")
@@ -72,51 +88,98 @@ func main() {
 
 	meiliClient := meilisearch.New(meiliHost, meilisearch.WithAPIKey(os.Getenv("MEILI_MASTER_KEY")))
 
-	meiliService := service.NewMeiliSearchService(meiliClient)
+	meiliService := service.NewMeiliSearchService(meiliClient, threadRepo, postRepo, userRepo, categoryRepo, jobManager)
+
+	// One-off maintenance: go run ./cmd/server reindex-search
+	if len(os.Args) > 1 && os.Args[1] == "reindex-search" {
+		if err := meiliService.Reindex(context.Background()); err != nil {
+			log.Fatalf("reindex failed: %v", err)
+		}
+		log.Println("Search indexes rebuilt")
+		return
+	}
 
-	authService := service.NewAuthService(userRepo, imageStorage, meiliService)
+	imageStorage, err := storage.NewFromEnv()
+	if err != nil {
+		log.Fatalf("failed to initialize %s storage: %v", storage.BackendFromEnv(), err)
+	}
+
+	blockRepo := repository.NewBlockRepository(db)
+	blockService := service.NewBlockService(blockRepo, userRepo)
+	blockHandler := handler.NewBlockHandler(blockService)
+
+	searchService := service.NewSearchService(meiliService, userRepo, blockRepo, repository.NewSearchLogRepository(db), jobManager)
+
+	authService := service.NewAuthService(userRepo, imageStorage, searchService)
 	authHandler := handler.NewAuthHandler(authService)
 
-	adminService := service.NewAdminService(userRepo, imageStorage)
+	uploadValidator := service.NewUploadValidator()
+
+	adminService := service.NewAdminService(userRepo, imageStorage, uploadValidator)
 	adminHandler := handler.NewAdminHandler(adminService)
 
-	profileService := service.NewProfileService(userRepo, imageStorage)
+	profileService := service.NewProfileService(userRepo, imageStorage, uploadValidator)
 	profileHandler := handler.NewProfileHandler(profileService)
 
-	categoryRepo := repository.NewCategoryRepository(db)
 	categoryService := service.NewCategoryService(categoryRepo)
 	categoryHandler := handler.NewCategoryHandler(categoryService)
 
+	visibility := service.NewVisibilityPolicy(userRepo, threadRepo, postRepo)
+
 	attachmentRepo := repository.NewAttachmentRepository(db)
-	attachmentService := service.NewAttachmentService(attachmentRepo, imageStorage)
+	attachmentService := service.NewAttachmentService(attachmentRepo, userRepo, imageStorage, uploadValidator, visibility)
 	attachmentHandler := handler.NewAttachmentHandler(attachmentService)
 
+	// One-off maintenance: STORAGE_BACKEND=local|s3 go run ./cmd/server migrate-storage
+	if len(os.Args) > 1 && os.Args[1] == "migrate-storage" {
+		if storage.BackendFromEnv() == storage.BackendCloudinary {
+			log.Fatalf("migrate-storage copies from Cloudinary, set STORAGE_BACKEND to the new backend")
+		}
+		migrated, err := attachmentService.MigrateAttachments(context.Background(), storage.CloudinaryURLPrefix)
+		if err != nil {
+			log.Fatalf("storage migration failed after %d attachments: %v", migrated, err)
+		}
+		log.Printf("Migrated %d attachments to %s storage", migrated, storage.BackendFromEnv())
+		return
+	}
+
 	notificationRepo := repository.NewNotificationRepository(db)
-	notificationService := service.NewNotificationService(notificationRepo, redisClient)
+	notificationService := service.NewNotificationService(notificationRepo, redisClient, visibility)
 	notificationHandler := handler.NewNotificationHandler(notificationService, redisClient)
 
-	threadRepo := repository.NewThreadRepository(db)
-	postRepo := repository.NewPostRepository(db)
+	jobHandler := handler.NewJobHandler(jobManager)
 
 	likeRepo := repository.NewLikeRepository(db)
-	likeService := service.NewLikeService(redisClient, likeRepo, threadRepo, postRepo, notificationService)
+	likeService := service.NewLikeService(redisClient, likeRepo, threadRepo, postRepo, userRepo, notificationService, visibility, jobManager)
 	likeHandler := handler.NewLikeHandler(likeService)
 
-	threadService := service.NewThreadService(threadRepo, categoryRepo, userRepo, attachmentRepo, likeService, imageStorage, redisClient, meiliService)
+	subscriptionRepo := repository.NewSubscriptionRepository(db)
+	subscriptionService := service.NewSubscriptionService(redisClient, subscriptionRepo, threadRepo, postRepo, userRepo, notificationService, visibility, jobManager)
+	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionService)
+
+	readRepo := repository.NewReadRepository(db)
+	readService := service.NewReadService(redisClient, readRepo, categoryRepo, visibility, jobManager)
+	readHandler := handler.NewReadHandler(readService)
+
+	viewService := service.NewViewService(redisClient, threadRepo, meiliService, jobManager)
+	trendingService := service.NewTrendingService(redisClient, threadRepo, jobManager)
+
+	threadService := service.NewThreadService(threadRepo, categoryRepo, userRepo, attachmentRepo, likeService, imageStorage, redisClient, meiliService, subscriptionService, readService, viewService, trendingService, visibility)
 	threadHandler := handler.NewThreadHandler(threadService)
 
-	viewService := service.NewViewService(redisClient, threadRepo)
-	if redisClient != nil {
-		go viewService.StartViewSyncWorker(context.Background())
-	}
+	pollRepo := repository.NewPollRepository(db)
+	pollService := service.NewPollService(redisClient, pollRepo, userRepo, visibility, jobManager)
+	pollHandler := handler.NewPollHandler(pollService)
 
-	postService := service.NewPostService(postRepo, threadRepo, userRepo, attachmentRepo, likeService, imageStorage, redisClient, notificationService, meiliService)
+	bookmarkRepo := repository.NewBookmarkRepository(db)
+	bookmarkService := service.NewBookmarkService(bookmarkRepo, threadRepo, postRepo, userRepo, visibility)
+	bookmarkHandler := handler.NewBookmarkHandler(bookmarkService)
+
+	postService := service.NewPostService(postRepo, threadRepo, userRepo, attachmentRepo, likeService, imageStorage, redisClient, notificationService, subscriptionService, readService, visibility)
 	postHandler := handler.NewPostHandler(postService)
 
-	// Start Like Worker
-	if redisClient != nil {
-		go likeService.StartWorker(context.Background())
-	}
+	searchHandler := handler.NewSearchHandler(searchService)
+	searchIndexer := service.NewSearchIndexer(repository.NewSearchOutboxRepository(db), threadRepo, userRepo, categoryRepo, meiliService)
 
 	statService := service.NewStatService(userRepo)
 	statHandler := handler.NewStatHandler(statService, threadService)
@@ -127,7 +190,7 @@ func main() {
 
 	// Start AI Agent
 	if redisClient != nil {
-		aiAgent := agent.NewAgent(threadService, userRepo, categoryRepo, redisClient)
+		aiAgent := agent.NewAgent(threadService, userRepo, categoryRepo, redisClient, jobManager)
 		aiAgent.Start()
 	}
 
@@ -157,6 +220,14 @@ func main() {
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// Files of the local storage backend, outside /api as they are opened
+	// without a token
+	if files, ok := imageStorage.(http.Handler); ok {
+		serveFiles := gin.WrapH(http.StripPrefix(storage.LocalFilesRoute, files))
+		router.GET(storage.LocalFilesRoute+"/*path", serveFiles)
+		router.HEAD(storage.LocalFilesRoute+"/*path", serveFiles)
+	}
+
 	api := router.Group("/api")
 	{
 		auth := api.Group("/auth")
@@ -177,15 +248,35 @@ func main() {
 			admin.DELETE("/users/:id", adminHandler.DeleteUser)
 			admin.POST("/categories", categoryHandler.CreateCategory)
 			admin.DELETE("/categories/:id", categoryHandler.DeleteCategory)
+			admin.POST("/likes/resync", likeHandler.ResyncLikes)
+			admin.GET("/likes/drift", likeHandler.GetLikeDrift)
+			admin.GET("/jobs", jobHandler.GetJobs)
+			admin.GET("/jobs/runs", jobHandler.GetJobRuns)
+			admin.POST("/jobs/:name/run", jobHandler.TriggerJob)
+			admin.GET("/jobs/:name/dead", jobHandler.GetDeadLetters)
+			admin.POST("/jobs/:name/dead/replay", jobHandler.ReplayDeadLetters)
+			admin.POST("/search/reindex", searchHandler.Reindex)
+			admin.GET("/search/consistency", searchHandler.GetConsistency)
+			admin.POST("/search/tokens/revoke", searchHandler.RevokeTokens)
+			admin.GET("/search/analytics", searchHandler.GetAnalytics)
 		}
 
 		api.GET("/users/count", statHandler.GetTotalUsers)
 		api.GET("/threads/trending", statHandler.GetTrendingThreads)
+		api.GET("/search", searchHandler.Search)
+		api.POST("/search/token", searchHandler.GetToken)
+		api.POST("/search/clicks", searchHandler.RecordClick)
+
+		api.GET("/users/blocked", blockHandler.GetBlockedUsers)
+		api.POST("/users/:username/block", blockHandler.BlockUser)
+		api.DELETE("/users/:username/block", blockHandler.UnblockUser)
 
 		api.GET("/categories", categoryHandler.GetAllCategories)
+		api.POST("/categories/:id/read", readHandler.MarkCategoryRead)
 
 		api.POST("/threads", threadHandler.CreateThread)
 		api.GET("/threads", threadHandler.GetAllThreads)
+		api.POST("/threads/similar", threadHandler.GetSimilarThreads)
 		api.GET("/threads/me", threadHandler.GetMyThreads)
 		api.GET("/threads/user/:username", threadHandler.GetThreadsByUsername)
 		api.GET("/threads/slug/:slug", threadHandler.GetThreadBySlug)
@@ -195,8 +286,10 @@ func main() {
 		api.POST("/threads/:thread_id/posts", postHandler.CreatePost)
 		api.GET("/threads/:thread_id/posts", postHandler.GetPostsByThreadID)
 		api.GET("/posts/:post_id", postHandler.GetPostByID)
+		api.GET("/posts/:post_id/replies", postHandler.GetReplies)
 		api.PUT("/posts/:post_id", postHandler.UpdatePost)
 		api.DELETE("/posts/:post_id", postHandler.DeletePost)
+		api.POST("/posts/:post_id/restore", postHandler.RestorePost)
 
 		api.POST("/threads/:thread_id/like", likeHandler.LikeThread)
 		api.GET("/threads/:thread_id/like", likeHandler.CheckThreadLike)
@@ -205,6 +298,38 @@ func main() {
 		api.GET("/posts/:post_id/like", likeHandler.CheckPostLike)
 		api.DELETE("/posts/:post_id/like", likeHandler.UnlikePost)
 
+		api.GET("/reactions", likeHandler.GetAvailableReactions)
+		api.POST("/threads/:thread_id/reactions", likeHandler.ReactThread)
+		api.GET("/threads/:thread_id/reactions", likeHandler.GetThreadReactions)
+		api.DELETE("/threads/:thread_id/reactions", likeHandler.UnreactThread)
+		api.GET("/threads/:thread_id/reactions/:reaction/users", likeHandler.GetThreadReactors)
+		api.POST("/posts/:post_id/reactions", likeHandler.ReactPost)
+		api.GET("/posts/:post_id/reactions", likeHandler.GetPostReactions)
+		api.DELETE("/posts/:post_id/reactions", likeHandler.UnreactPost)
+		api.GET("/posts/:post_id/reactions/:reaction/users", likeHandler.GetPostReactors)
+
+		api.POST("/threads/:thread_id/read", readHandler.MarkThreadRead)
+
+		api.GET("/threads/:thread_id/subscription", subscriptionHandler.GetSubscription)
+		api.PUT("/threads/:thread_id/subscription", subscriptionHandler.Watch)
+		api.DELETE("/threads/:thread_id/subscription", subscriptionHandler.Unwatch)
+
+		api.GET("/threads/:thread_id/poll", pollHandler.GetThreadPoll)
+		api.POST("/polls/:poll_id/vote", pollHandler.Vote)
+		api.POST("/polls/:poll_id/close", pollHandler.ClosePoll)
+
+		bookmarks := api.Group("/bookmarks")
+		{
+			bookmarks.GET("", bookmarkHandler.GetMyBookmarks)
+			bookmarks.POST("", bookmarkHandler.CreateBookmark)
+			bookmarks.GET("/collections", bookmarkHandler.GetMyCollections)
+			bookmarks.POST("/collections", bookmarkHandler.CreateCollection)
+			bookmarks.PUT("/collections/:id", bookmarkHandler.UpdateCollection)
+			bookmarks.DELETE("/collections/:id", bookmarkHandler.DeleteCollection)
+			bookmarks.PUT("/:id", bookmarkHandler.UpdateBookmark)
+			bookmarks.DELETE("/:id", bookmarkHandler.DeleteBookmark)
+		}
+
 		profile := api.Group("/profile")
 		{
 			profile.GET("/:username", profileHandler.GetProfileByUsername)
@@ -213,6 +338,7 @@ func main() {
 		}
 
 		api.POST("/upload", attachmentHandler.UploadAttachment)
+		api.GET("/attachments/:id", attachmentHandler.GetAttachment)
 
 		notifications := api.Group("/notifications")
 		{
@@ -230,34 +356,80 @@ func main() {
 		}
 	}
 
-	// Start Orphan Cleanup Job (Background)
+	// Orphan attachment cleanup, every 12 hours
+	jobManager.Schedule("attachments.cleanup", "@every 12h", time.Hour, attachmentService.CleanupOrphanAttachments)
+
+	// Queue consumers drain in-flight tasks on shutdown, so they run on workerCtx
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	jobManager.Start(workerCtx)
+	indexerDone := make(chan struct{})
 	go func() {
-		// Run every 12 hours
-		ticker := time.NewTicker(12 * time.Hour)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			log.Println("🧹 Running orphan attachment cleanup...")
-			if err := attachmentService.CleanupOrphanAttachments(context.Background()); err != nil {
-				log.Printf("❌ Error cleaning up orphan attachments: %v", err)
-			} else {
-				log.Println("✅ Orphan attachment cleanup completed.")
-			}
-		}
+		searchIndexer.Run(workerCtx)
+		close(indexerDone)
 	}()
+	if redisClient != nil {
+		if err := jobManager.Trigger(context.Background(), service.LikeWarmUpJob); err != nil {
+			log.Printf("Failed to start like warm-up: %v", err)
+		}
+		if err := jobManager.Trigger(context.Background(), service.TrendingJob); err != nil {
+			log.Printf("Failed to start trending refresh: %v", err)
+		}
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("server exited with error: %v", err)
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server exited with error: %v", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		jobManager.Wait()
+		<-indexerDone
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		log.Println("Timed out waiting for jobs; unfinished tasks are recovered on next start")
+	}
+
+	if err := readService.Flush(context.Background()); err != nil {
+		log.Printf("Failed to flush read positions: %v", err)
 	}
 }
 
 func migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	// Existing participants are subscribed once, when subscriptions are introduced
+	backfillSubscriptions := !db.Migrator().HasTable(&model.ThreadSubscription{})
+	// The last reply of existing threads is filled once, when it is introduced
+	backfillLastReply := !db.Migrator().HasColumn(&model.Thread{}, "last_reply_at")
+	// Ballots of votes cast before they existed are filled once
+	backfillBallots := !db.Migrator().HasTable(&model.PollBallot{})
+
+	if err := db.AutoMigrate(
 		&model.Role{},
 		&model.User{},
 		&model.Profile{},
@@ -269,8 +441,94 @@ func migrate(db *gorm.DB) error {
 		&model.ThreadLike{},
 		&model.PostLike{},
 		&model.Notification{},
+		&model.NotificationActor{},
 		&model.Menfess{},
-	)
+		&model.Poll{},
+		&model.PollOption{},
+		&model.PollVote{},
+		&model.PollBallot{},
+		&model.BookmarkCollection{},
+		&model.Bookmark{},
+		&model.ThreadSubscription{},
+		&model.ThreadRead{},
+		&model.CategoryRead{},
+		&model.JobRun{},
+		&model.SearchOutbox{},
+		&model.UserBlock{},
+		&model.SearchLog{},
+		&model.SearchClick{},
+	); err != nil {
+		return err
+	}
+
+	// Backs the "activity" sort of the thread list
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_threads_activity ON threads ((COALESCE(last_reply_at, created_at)) DESC, id DESC)").Error; err != nil {
+		return err
+	}
+
+	// One bookmark per thread and per post of each user. Duplicates left by
+	// concurrent requests before the indexes existed are dropped, keeping the
+	// oldest; bookmark ids are time ordered.
+	if !db.Migrator().HasIndex(&model.Bookmark{}, "idx_bookmarks_user_thread") {
+		if err := db.Exec(`DELETE FROM bookmarks b USING bookmarks o
+			WHERE b.user_id = o.user_id AND b.thread_id = o.thread_id
+			AND b.post_id IS NOT DISTINCT FROM o.post_id AND b.id > o.id`).Error; err != nil {
+			return err
+		}
+	}
+	for _, stmt := range []string{
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmarks_user_thread ON bookmarks (user_id, thread_id) WHERE post_id IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmarks_user_post ON bookmarks (user_id, thread_id, post_id) WHERE post_id IS NOT NULL",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	// One unread reaction notification per recipient, entity and type.
+	// Duplicates left by concurrent reactions before the index existed are
+	// marked read, keeping the newest.
+	if !db.Migrator().HasIndex(&model.Notification{}, "idx_notifications_aggregate") {
+		if err := db.Exec(`UPDATE notifications n SET is_read = true FROM notifications o
+			WHERE n.user_id = o.user_id AND n.entity_id = o.entity_id AND n.type = o.type
+			AND n.type IN ('like_thread', 'like_post') AND NOT n.is_read AND NOT o.is_read
+			AND (n.created_at, n.id) < (o.created_at, o.id)`).Error; err != nil {
+			return err
+		}
+	}
+	if err := db.Exec(repository.NotificationAggregateIndex).Error; err != nil {
+		return err
+	}
+
+	// Keyset pagination of the other listings
+	for _, stmt := range []string{
+		"CREATE INDEX IF NOT EXISTS idx_threads_newest ON threads (created_at DESC, id DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_threads_popular ON threads (views DESC, created_at DESC, id DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_threads_user_newest ON threads (user_id, created_at DESC, id DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_notifications_user_newest ON notifications (user_id, created_at DESC, id DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_menfesses_newest ON menfesses (created_at DESC, id DESC)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	if backfillSubscriptions {
+		if err := repository.NewSubscriptionRepository(db).BackfillParticipants(context.Background()); err != nil {
+			return err
+		}
+	}
+	if backfillLastReply {
+		if _, err := repository.NewThreadRepository(db).RecountReplies(context.Background()); err != nil {
+			return err
+		}
+	}
+	if backfillBallots {
+		if err := repository.NewPollRepository(db).BackfillBallots(context.Background()); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func seedRoles(db *gorm.DB) error {
@@ -7,26 +7,26 @@ import (
 	"time"
 
 	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
 	"anoa.com/telkomalumiforum/internal/repository"
 	"anoa.com/telkomalumiforum/internal/service"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
-	"github.com/robfig/cron/v3"
 )
 
+const NewsJob = "agent.news"
+
 type Agent struct {
-	cron          *cron.Cron
+	jobs          *jobs.Manager
 	threadService service.ThreadService
 	userRepo      repository.UserRepository
 	categoryRepo  repository.CategoryRepository
 	redis         *redis.Client
 }
 
-func NewAgent(threadService service.ThreadService, userRepo repository.UserRepository, categoryRepo repository.CategoryRepository, redis *redis.Client) *Agent {
-	// Initialize cron with seconds precision if needed, but standard minute precision is fine.
-	// Standard cron is minute-based.
+func NewAgent(threadService service.ThreadService, userRepo repository.UserRepository, categoryRepo repository.CategoryRepository, redis *redis.Client, jobManager *jobs.Manager) *Agent {
 	return &Agent{
-		cron:          cron.New(),
+		jobs:          jobManager,
 		threadService: threadService,
 		userRepo:      userRepo,
 		categoryRepo:  categoryRepo,
@@ -35,32 +35,24 @@ func NewAgent(threadService service.ThreadService, userRepo repository.UserRepos
 }
 
 func (a *Agent) Start() {
-	// Run at 7 AM and 7 PM
-	_, err := a.cron.AddFunc("0 7,19 * * *", func() {
+	// Run at 7 AM and 7 PM, on one replica only
+	a.jobs.Schedule(NewsJob, "0 7,19 * * *", 30*time.Minute, func(ctx context.Context) error {
 		log.Println("🤖 Agent waking up to check news...")
-		if err := a.RunJob(); err != nil {
-			log.Printf("❌ Agent job failed: %v", err)
-		}
+		return a.RunJob(ctx)
 	})
-	if err != nil {
-		log.Printf("Failed to schedule agent job: %v", err)
-	}
-	a.cron.Start()
 	log.Println("🤖 Agent started with schedule: 0 7,19 * * *")
 
 	// Immediate run for testing
 	go func() {
 		log.Println("🚀 Triggering immediate agent run for testing...")
 		time.Sleep(10 * time.Second) // Wait for other services to initialize
-		if err := a.RunJob(); err != nil {
+		if err := a.jobs.Trigger(context.Background(), NewsJob); err != nil {
 			log.Printf("❌ Immediate agent job failed: %v", err)
 		}
 	}()
 }
 
-func (a *Agent) RunJob() error {
-	ctx := context.Background()
-
+func (a *Agent) RunJob(ctx context.Context) error {
 	// 1. Initialize LLM
 	llm, err := NewLLMClient(ctx)
 	if err != nil {
@@ -18,11 +18,12 @@ type LoginInput struct {
 }
 
 type AuthResponse struct {
-	AccessToken string         `json:"access_token"`
-	TokenType   string         `json:"token_type"`
-	ExpiresIn   int64          `json:"expires_in"`
-	User        *model.User    `json:"user"`
-	Role        *model.Role    `json:"role"`
-	Profile     *model.Profile `json:"profile"`
-	SearchToken string         `json:"search_token,omitempty"`
+	AccessToken          string         `json:"access_token"`
+	TokenType            string         `json:"token_type"`
+	ExpiresIn            int64          `json:"expires_in"`
+	User                 *model.User    `json:"user"`
+	Role                 *model.Role    `json:"role"`
+	Profile              *model.Profile `json:"profile"`
+	SearchToken          string         `json:"search_token,omitempty"`
+	SearchTokenExpiresAt int64          `json:"search_token_expires_at,omitempty"` // Unix seconds; refresh through /api/search/token
 }
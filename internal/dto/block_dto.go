@@ -0,0 +1,9 @@
+package dto
+
+import "time"
+
+type BlockedUserResponse struct {
+	Username  string    `json:"username"`
+	AvatarURL *string   `json:"avatar_url"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
@@ -0,0 +1,70 @@
+package dto
+
+import "github.com/google/uuid"
+
+type CreateBookmarkRequest struct {
+	ThreadID     string `json:"thread_id" binding:"required,uuid"`
+	PostID       string `json:"post_id" binding:"omitempty,uuid"`
+	CollectionID string `json:"collection_id" binding:"omitempty,uuid"`
+	Note         string `json:"note" binding:"max=1000"`
+}
+
+type UpdateBookmarkRequest struct {
+	CollectionID string `json:"collection_id" binding:"omitempty,uuid"` // Empty moves the bookmark out of its collection
+	Note         string `json:"note" binding:"max=1000"`
+}
+
+type BookmarkFilter struct {
+	CollectionID string `form:"collection_id" binding:"omitempty,uuid"`
+	Page         int    `form:"page" binding:"min=0"`
+	Limit        int    `form:"limit" binding:"min=0,max=50"`
+}
+
+// Bookmark status values. Content is only included when the status is available.
+const (
+	BookmarkStatusAvailable  = "available"
+	BookmarkStatusDeleted    = "deleted"
+	BookmarkStatusRestricted = "restricted"
+)
+
+type BookmarkThreadResponse struct {
+	Title        string         `json:"title"`
+	Slug         string         `json:"slug"`
+	CategoryName string         `json:"category_name"`
+	Author       AuthorResponse `json:"author"`
+}
+
+type BookmarkPostResponse struct {
+	Content string         `json:"content"`
+	Author  AuthorResponse `json:"author"`
+}
+
+type BookmarkResponse struct {
+	ID           uuid.UUID               `json:"id"`
+	CollectionID *uuid.UUID              `json:"collection_id"`
+	ThreadID     uuid.UUID               `json:"thread_id"`
+	PostID       *uuid.UUID              `json:"post_id,omitempty"`
+	Note         string                  `json:"note"`
+	Status       string                  `json:"status"`
+	Thread       *BookmarkThreadResponse `json:"thread,omitempty"`
+	Post         *BookmarkPostResponse   `json:"post,omitempty"`
+	CreatedAt    string                  `json:"created_at"`
+}
+
+type PaginatedBookmarkResponse struct {
+	Data []BookmarkResponse `json:"data"`
+	Meta PaginationMeta     `json:"meta"`
+}
+
+type BookmarkCollectionRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+type BookmarkCollectionResponse struct {
+	ID             uuid.UUID `json:"id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	BookmarksCount int64     `json:"bookmarks_count"`
+	CreatedAt      string    `json:"created_at"`
+}
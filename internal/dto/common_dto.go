@@ -2,7 +2,6 @@ package dto
 
 import "github.com/google/uuid"
 
-
 type AuthorResponse struct {
 	Username  string  `json:"username"`
 	AvatarURL *string `json:"avatar_url"`
@@ -12,13 +11,36 @@ type CategoryFilter struct {
 	Search string `form:"search"`
 }
 
+type TrendingFilter struct {
+	Variant    string `form:"variant" binding:"omitempty,oneof=hot rising"` // Default "hot"
+	CategoryID string `form:"category_id"`
+	Audience   string `form:"audience" binding:"omitempty,oneof=semua guru siswa"`
+	Limit      int    `form:"limit"`
+}
+
 type ThreadFilter struct {
 	CategoryID string `form:"category_id"`
 	Search     string `form:"search"`
 	Audience   string `form:"audience"`
-	SortBy     string `form:"sort_by"` // "newest", "popular"
+	SortBy     string `form:"sort_by"` // "newest", "popular", "activity"
 	Page       int    `form:"page" binding:"min=1"`
 	Limit      int    `form:"limit" binding:"min=1,max=20"`
+	CursorFilter
+}
+
+// CursorFilter switches a listing from page numbers to keyset pagination.
+// Sending a cursor implies pagination=cursor.
+type CursorFilter struct {
+	Pagination   string `form:"pagination" binding:"omitempty,oneof=offset cursor"`
+	Cursor       string `form:"cursor"`
+	IncludeTotal bool   `form:"include_total"` // Cursor mode skips the COUNT unless asked
+}
+
+// ListFilter pages the simple listings (notifications, menfess).
+type ListFilter struct {
+	Page  int `form:"page" binding:"omitempty,min=1"`
+	Limit int `form:"limit" binding:"omitempty,min=1,max=50"`
+	CursorFilter
 }
 
 type PaginationMeta struct {
@@ -28,9 +50,19 @@ type PaginationMeta struct {
 	Limit       int   `json:"limit"`
 }
 
+// CursorMeta describes a keyset page. NextCursor is null on the last page.
+type CursorMeta struct {
+	NextCursor *string `json:"next_cursor"`
+	Limit      int     `json:"limit"`
+	TotalItems *int64  `json:"total_items,omitempty"`
+}
+
+// PaginatedThreadResponse carries Meta for page numbers and Cursor for
+// keyset pagination, never both.
 type PaginatedThreadResponse struct {
-	Data []ThreadResponse `json:"data"`
-	Meta PaginationMeta   `json:"meta"`
+	Data   []ThreadResponse `json:"data"`
+	Meta   PaginationMeta   `json:"meta,omitzero"`
+	Cursor *CursorMeta      `json:"cursor,omitempty"`
 }
 
 type PaginatedCategoryResponse struct {
@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/pkg/queue"
+)
+
+type JobMetrics struct {
+	Runs          int64   `json:"runs"`
+	Failures      int64   `json:"failures"`
+	Dead          int64   `json:"dead"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+	LastStatus    string  `json:"last_status,omitempty"`
+	LastRunAt     string  `json:"last_run_at,omitempty"`
+	LastError     string  `json:"last_error,omitempty"`
+}
+
+type JobInfo struct {
+	Name     string       `json:"name"`
+	Kind     string       `json:"kind"`
+	Schedule string       `json:"schedule,omitempty"`
+	Workers  int          `json:"workers,omitempty"`
+	Queue    *queue.Stats `json:"queue,omitempty"`
+	Metrics  JobMetrics   `json:"metrics"`
+}
+
+type JobRunFilter struct {
+	Job    string `form:"job"`
+	Status string `form:"status" binding:"omitempty,oneof=running succeeded failed dead"`
+	Page   int    `form:"page"`
+	Limit  int    `form:"limit" binding:"max=100"`
+}
+
+type PaginatedJobRunResponse struct {
+	Data []*model.JobRun `json:"data"`
+	Meta PaginationMeta  `json:"meta"`
+}
+
+type ReplayDeadLettersRequest struct {
+	IDs []string `json:"ids"` // Empty replays every dead task
+}
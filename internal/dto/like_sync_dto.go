@@ -0,0 +1,21 @@
+package dto
+
+import "github.com/google/uuid"
+
+type LikeDrift struct {
+	Type       string    `json:"type"` // "thread" or "post"
+	TargetID   uuid.UUID `json:"target_id"`
+	RedisCount int64     `json:"redis_count"`
+	DBCount    int64     `json:"db_count"`
+}
+
+type LikeSyncReport struct {
+	Repair         bool        `json:"repair"`
+	TargetsChecked int         `json:"targets_checked"`
+	Drifted        int         `json:"drifted"`
+	Repaired       int         `json:"repaired"`
+	Skipped        int         `json:"skipped"` // Touched during the grace period, left alone
+	Drift          []LikeDrift `json:"drift"`   // First entries only
+	StartedAt      string      `json:"started_at"`
+	Duration       string      `json:"duration"`
+}
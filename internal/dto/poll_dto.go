@@ -0,0 +1,43 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreatePollRequest struct {
+	Question              string     `json:"question" binding:"required,max=255"`
+	Options               []string   `json:"options" binding:"required,min=2,max=10,dive,required,max=100"`
+	MultipleChoice        bool       `json:"multiple_choice"`
+	Anonymous             bool       `json:"anonymous"`
+	ShowResultsBeforeVote bool       `json:"show_results_before_vote"`
+	Deadline              *time.Time `json:"deadline"`
+}
+
+type VotePollRequest struct {
+	OptionIDs []string `json:"option_ids" binding:"required,min=1,dive,uuid"`
+}
+
+type PollOptionResponse struct {
+	ID         uuid.UUID        `json:"id"`
+	Text       string           `json:"text"`
+	VotesCount *int64           `json:"votes_count,omitempty"`
+	Voters     []AuthorResponse `json:"voters,omitempty"`
+}
+
+type PollResponse struct {
+	ID                    uuid.UUID            `json:"id"`
+	ThreadID              uuid.UUID            `json:"thread_id"`
+	Question              string               `json:"question"`
+	MultipleChoice        bool                 `json:"multiple_choice"`
+	Anonymous             bool                 `json:"anonymous"`
+	ShowResultsBeforeVote bool                 `json:"show_results_before_vote"`
+	Deadline              *string              `json:"deadline,omitempty"`
+	IsClosed              bool                 `json:"is_closed"`
+	HasVoted              bool                 `json:"has_voted"`
+	MyVotes               []uuid.UUID          `json:"my_votes,omitempty"`
+	ResultsVisible        bool                 `json:"results_visible"`
+	TotalVoters           *int64               `json:"total_voters,omitempty"`
+	Options               []PollOptionResponse `json:"options"`
+}
@@ -15,24 +15,54 @@ type UpdatePostRequest struct {
 }
 
 type PostResponse struct {
-	ID          uuid.UUID            `json:"id"`
-	ThreadID    uuid.UUID            `json:"thread_id"`
-	ParentID    *uuid.UUID           `json:"parent_id,omitempty"`
-	Content     string               `json:"content"`
-	Author      AuthorResponse       `json:"author"`
-	Attachments []AttachmentResponse `json:"attachments,omitempty"`
-	LikesCount  int64                `json:"likes_count"`
-	Replies     []*PostResponse     `json:"replies,omitempty"`
-	CreatedAt   string               `json:"created_at"`
-	UpdatedAt   string               `json:"updated_at"`
+	ID           uuid.UUID            `json:"id"`
+	ThreadID     uuid.UUID            `json:"thread_id"`
+	ParentID     *uuid.UUID           `json:"parent_id,omitempty"`
+	Content      string               `json:"content"`
+	Author       AuthorResponse       `json:"author"`
+	Attachments  []AttachmentResponse `json:"attachments,omitempty"`
+	LikesCount   int64                `json:"likes_count"`
+	LikedByMe    bool                 `json:"liked_by_me"`
+	RepliesCount int64                `json:"replies_count"` // Direct replies, loaded or not
+	Replies      []*PostResponse      `json:"replies,omitempty"`
+	MoreReplies  *MoreRepliesCursor   `json:"more_replies,omitempty"` // Set when not every direct reply is in Replies
+	CreatedAt    string               `json:"created_at"`
+	UpdatedAt    string               `json:"updated_at"`
 }
 
 type PostFilter struct {
-	Page  int `form:"page"`
-	Limit int `form:"limit"`
+	Page  int    `form:"page" binding:"omitempty,min=1"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=50"`
+	Jump  string `form:"jump" binding:"omitempty,oneof=first_unread"` // "first_unread" opens the page holding the first unread post
+	Mode  string `form:"mode" binding:"omitempty,oneof=tree flat"`    // "tree" (default) paginates root posts, "flat" all posts chronologically
+	Depth int    `form:"depth" binding:"omitempty,min=1"`             // Reply levels to load in tree mode, capped by POST_TREE_MAX_DEPTH
+}
+
+// MoreRepliesCursor is passed to GET /posts/:post_id/replies to load the rest of a branch.
+type MoreRepliesCursor struct {
+	Cursor    string `json:"cursor"`
+	Remaining int64  `json:"remaining"`
+}
+
+type RepliesFilter struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=50"`
+	Depth  int    `form:"depth" binding:"omitempty,min=1"`
+}
+
+type PaginatedRepliesResponse struct {
+	Data       []PostResponse `json:"data"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+// FirstUnreadCursor points at the oldest post the user has not read yet.
+type FirstUnreadCursor struct {
+	PostID uuid.UUID `json:"post_id"`
+	Page   int       `json:"page"`
 }
 
 type PaginatedPostResponse struct {
-	Data []PostResponse `json:"data"`
-	Meta PaginationMeta `json:"meta"`
+	Data        []PostResponse     `json:"data"`
+	Meta        PaginationMeta     `json:"meta"`
+	FirstUnread *FirstUnreadCursor `json:"first_unread"`
 }
@@ -10,6 +10,10 @@ type UpdateProfileInput struct {
 	Username *string `json:"username" form:"username"`
 	Password *string `json:"password" form:"password"`
 	Bio      *string `json:"bio" form:"bio"`
+
+	// Privacy
+	ShowFullName   *bool `json:"show_full_name" form:"show_full_name"`
+	HideFromSearch *bool `json:"hide_from_search" form:"hide_from_search"`
 }
 
 type UpdateProfileResponse struct {
@@ -19,6 +23,7 @@ type UpdateProfileResponse struct {
 
 type PublicProfileResponse struct {
 	Username   string    `json:"username"`
+	FullName   *string   `json:"full_name,omitempty"` // Only when the user shows it
 	Role       string    `json:"role"`
 	AvatarURL  *string   `json:"avatar_url,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
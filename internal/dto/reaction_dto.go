@@ -0,0 +1,22 @@
+package dto
+
+type ReactRequest struct {
+	Reaction string `json:"reaction" binding:"required"`
+}
+
+type ReactionOption struct {
+	Key   string `json:"key"`
+	Emoji string `json:"emoji"`
+}
+
+type ReactionCount struct {
+	Reaction string `json:"reaction"`
+	Emoji    string `json:"emoji"`
+	Count    int64  `json:"count"`
+}
+
+type ReactionSummary struct {
+	Total      int64           `json:"total"`
+	Reactions  []ReactionCount `json:"reactions"`
+	MyReaction *string         `json:"my_reaction"`
+}
@@ -0,0 +1,94 @@
+package dto
+
+import "time"
+
+type SearchFilter struct {
+	Query      string `form:"q"`
+	Types      string `form:"types"` // Comma separated: "threads", "posts", "users", "categories". Default all
+	CategoryID string `form:"category_id" binding:"omitempty,uuid"`
+	Author     string `form:"author"` // Username
+	From       string `form:"from" binding:"omitempty,datetime=2006-01-02"`
+	To         string `form:"to" binding:"omitempty,datetime=2006-01-02"` // Inclusive
+	Strict     bool   `form:"strict"`                                     // Every word must match, instead of dropping the last ones
+	Highlight  *bool  `form:"highlight"`                                  // Default true
+	Page       int    `form:"page" binding:"omitempty,min=1"`
+	Limit      int    `form:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+// SearchHit is one thread, post, user or category. Title and Content hold
+// the highlighted, cropped text when highlighting is on, with matches wrapped
+// in <mark>. A category's name is its title and its description the content;
+// a user's bio is the content.
+type SearchHit struct {
+	Type        string          `json:"type"` // "thread", "post", "user" or "category"
+	ID          string          `json:"id"`
+	Title       string          `json:"title,omitempty"`
+	Slug        string          `json:"slug,omitempty"`
+	Content     string          `json:"content"`
+	Audience    string          `json:"audience,omitempty"`
+	CategoryID  string          `json:"category_id,omitempty"`
+	Category    string          `json:"category,omitempty"`
+	ThreadID    string          `json:"thread_id,omitempty"`
+	ThreadSlug  string          `json:"thread_slug,omitempty"`
+	ThreadTitle string          `json:"thread_title,omitempty"`
+	Author      *AuthorResponse `json:"author,omitempty"`
+	Username    string          `json:"username,omitempty"`
+	FullName    string          `json:"full_name,omitempty"` // Only when the user shows it
+	AvatarURL   *string         `json:"avatar_url,omitempty"`
+	Role        string          `json:"role,omitempty"`
+	ClassGrade  string          `json:"class_grade,omitempty"`
+	Views       int             `json:"views,omitempty"`
+	CreatedAt   int64           `json:"created_at"` // Unix seconds
+}
+
+// SearchTokenResponse is a tenant token for querying Meilisearch directly.
+type SearchTokenResponse struct {
+	SearchToken string `json:"search_token"`
+	ExpiresAt   int64  `json:"expires_at"` // Unix seconds
+}
+
+type SearchResponse struct {
+	SearchID         string                    `json:"search_id,omitempty"` // First page only; reported back with clicks
+	Query            string                    `json:"query"`
+	Hits             []SearchHit               `json:"hits"`
+	EstimatedTotal   int64                     `json:"estimated_total_hits"`
+	Page             int                       `json:"page"`
+	Limit            int                       `json:"limit"`
+	Facets           map[string]map[string]int `json:"facets"`
+	ProcessingTimeMs int64                     `json:"processing_time_ms"`
+}
+
+// SearchClickInput reports that a search result was opened. Results of
+// later pages use the search_id of the first.
+type SearchClickInput struct {
+	SearchID string `json:"search_id" binding:"required,uuid"`
+	Type     string `json:"type" binding:"required,oneof=thread post user category"`
+	ID       string `json:"id" binding:"required,uuid"`
+	Position int    `json:"position" binding:"required,min=1"` // 1-based rank across pages
+}
+
+type SearchAnalyticsFilter struct {
+	Days  int `form:"days" binding:"omitempty,min=1,max=365"`  // Default 30
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"` // Default 20
+}
+
+// SearchQueryReport groups the searches of one normalized query. The click
+// through rate is the share of searches with at least one click.
+type SearchQueryReport struct {
+	Query            string    `json:"query"`
+	Searches         int64     `json:"searches"`
+	AvgResults       float64   `json:"avg_results"`
+	ClickThroughRate float64   `json:"click_through_rate"`
+	LastSearchedAt   time.Time `json:"last_searched_at"`
+}
+
+type SearchAnalyticsReport struct {
+	From              time.Time           `json:"from"`
+	To                time.Time           `json:"to"`
+	Searches          int64               `json:"searches"`
+	ZeroResults       int64               `json:"zero_results"`
+	ZeroResultRate    float64             `json:"zero_result_rate"`
+	ClickThroughRate  float64             `json:"click_through_rate"`
+	TopQueries        []SearchQueryReport `json:"top_queries"`
+	ZeroResultQueries []SearchQueryReport `json:"zero_result_queries"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+// SearchIndexReport compares one Meilisearch index with its table.
+type SearchIndexReport struct {
+	Index   string `json:"index"`
+	Checked int    `json:"checked"` // Rows in the database
+	Missing int    `json:"missing"`
+	Stale   int    `json:"stale"` // updated_at differs from the row
+	Extra   int    `json:"extra"` // Documents whose row no longer exists
+}
+
+type SearchSyncReport struct {
+	Repair    bool                `json:"repair"`
+	Indexes   []SearchIndexReport `json:"indexes"`
+	StartedAt string              `json:"started_at"`
+	Duration  string              `json:"duration"`
+}
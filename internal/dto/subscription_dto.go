@@ -0,0 +1,13 @@
+package dto
+
+import "github.com/google/uuid"
+
+type WatchThreadRequest struct {
+	Mode string `json:"mode" binding:"omitempty,oneof=all direct muted"`
+}
+
+type SubscriptionResponse struct {
+	ThreadID uuid.UUID `json:"thread_id"`
+	Watching bool      `json:"watching"`
+	Mode     string    `json:"mode,omitempty"`
+}
@@ -5,11 +5,12 @@ import (
 )
 
 type CreateThreadRequest struct {
-	CategoryID    string `json:"category_id" binding:"required,uuid"`
-	Title         string `json:"title" binding:"required,max=120"`
-	Content       string `json:"content" binding:"required,max=10000"`
-	Audience      string `json:"audience" binding:"required,oneof=semua guru siswa"`
-	AttachmentIDs []uint `json:"attachment_ids"`
+	CategoryID    string             `json:"category_id" binding:"required,uuid"`
+	Title         string             `json:"title" binding:"required,max=120"`
+	Content       string             `json:"content" binding:"required,max=10000"`
+	Audience      string             `json:"audience" binding:"required,oneof=semua guru siswa"`
+	AttachmentIDs []uint             `json:"attachment_ids"`
+	Poll          *CreatePollRequest `json:"poll"`
 }
 
 type UpdateThreadRequest struct {
@@ -21,15 +22,41 @@ type UpdateThreadRequest struct {
 }
 
 type ThreadResponse struct {
-	ID           uuid.UUID            `json:"id"`
-	CategoryName string               `json:"category_name"`
-	Title        string               `json:"title"`
-	Slug         string               `json:"slug"`
-	Content      string               `json:"content"`
-	Audience     string               `json:"audience"`
-	Views        int                  `json:"views"`
-	Author       AuthorResponse       `json:"author"`
-	Attachments  []AttachmentResponse `json:"attachments,omitempty"`
-	LikesCount   int64                `json:"likes_count"`
-	CreatedAt    string               `json:"created_at"`
+	ID            uuid.UUID               `json:"id"`
+	CategoryName  string                  `json:"category_name"`
+	Title         string                  `json:"title"`
+	Slug          string                  `json:"slug"`
+	Content       string                  `json:"content"`
+	Audience      string                  `json:"audience"`
+	Views         int                     `json:"views"`
+	Author        AuthorResponse          `json:"author"`
+	Attachments   []AttachmentResponse    `json:"attachments,omitempty"`
+	LikesCount    int64                   `json:"likes_count"`
+	LikedByMe     bool                    `json:"liked_by_me"`
+	RepliesCount  int                     `json:"replies_count"`
+	LastReplyAt   *string                 `json:"last_reply_at"`
+	LastReplyBy   *AuthorResponse         `json:"last_reply_by"`
+	PollID        *uuid.UUID              `json:"poll_id,omitempty"`
+	UnreadReplies int64                   `json:"unread_replies"`    // Replies by others since the user's last read position
+	IsNew         bool                    `json:"is_new"`            // Created since the user's last visit and never opened
+	Related       []SimilarThreadResponse `json:"related,omitempty"` // Only on the thread page
+	CreatedAt     string                  `json:"created_at"`
+}
+
+// SimilarThreadsRequest is a thread being drafted, to warn about threads
+// that already ask the same.
+type SimilarThreadsRequest struct {
+	Title   string `json:"title" binding:"max=255"`
+	Content string `json:"content"`
+	Limit   int    `json:"limit" binding:"omitempty,min=1,max=10"` // Default 5
+}
+
+type SimilarThreadResponse struct {
+	ID           uuid.UUID `json:"id"`
+	Title        string    `json:"title"`
+	Slug         string    `json:"slug"`
+	CategoryName string    `json:"category_name"`
+	Audience     string    `json:"audience"`
+	Score        float64   `json:"score"` // Meilisearch ranking score, 0 to 1
+	CreatedAt    string    `json:"created_at"`
 }
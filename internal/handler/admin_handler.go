@@ -42,6 +42,10 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 
 	res, err := h.adminService.CreateUser(c.Request.Context(), input, avatar)
 	if err != nil {
+		if status, ok := uploadErrorStatus(err); ok {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -94,6 +98,10 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 
 	res, err := h.adminService.UpdateUser(c.Request.Context(), id, input, avatar)
 	if err != nil {
+		if status, ok := uploadErrorStatus(err); ok {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
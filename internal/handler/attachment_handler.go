@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"anoa.com/telkomalumiforum/internal/service"
 	"github.com/gin-gonic/gin"
@@ -35,11 +37,70 @@ func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.UploadAttachment(c.Request.Context(), userID, file)
+	// Required, an attachment only fits the thread or post it was uploaded for
+	uploadContext := c.PostForm("context")
+	if uploadContext == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "context is required: thread or post"})
+		return
+	}
+	resp, err := h.service.UploadAttachment(c.Request.Context(), userID, uploadContext, file)
 	if err != nil {
+		if status, ok := uploadErrorStatus(err); ok {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusCreated, resp)
 }
+
+func (h *AttachmentHandler) GetAttachment(c *gin.Context) {
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	attachmentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attachment id"})
+		return
+	}
+
+	resp, err := h.service.GetAttachment(c.Request.Context(), userID, uint(attachmentID))
+	if err != nil {
+		if errors.Is(err, service.ErrAttachmentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// uploadErrorStatus maps a rejected upload to its status; ok is false for
+// errors that are not the file's fault.
+func uploadErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, service.ErrUploadTooLarge):
+		return http.StatusRequestEntityTooLarge, true
+	case errors.Is(err, service.ErrUploadType):
+		return http.StatusUnsupportedMediaType, true
+	case errors.Is(err, service.ErrUploadDimensions):
+		return http.StatusUnprocessableEntity, true
+	case errors.Is(err, service.ErrUploadInvalid), errors.Is(err, service.ErrUploadPolyglot),
+		errors.Is(err, service.ErrUploadActiveContent), errors.Is(err, service.ErrUploadContext):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type BookmarkHandler struct {
+	service service.BookmarkService
+}
+
+func NewBookmarkHandler(service service.BookmarkService) *BookmarkHandler {
+	return &BookmarkHandler{service: service}
+}
+
+func (h *BookmarkHandler) CreateBookmark(c *gin.Context) {
+	var req dto.CreateBookmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	bookmark, err := h.service.CreateBookmark(c.Request.Context(), userID, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, bookmark)
+}
+
+func (h *BookmarkHandler) GetMyBookmarks(c *gin.Context) {
+	var filter dto.BookmarkFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	bookmarks, err := h.service.GetMyBookmarks(c.Request.Context(), userID, filter)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bookmarks)
+}
+
+func (h *BookmarkHandler) UpdateBookmark(c *gin.Context) {
+	bookmarkID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bookmark id"})
+		return
+	}
+
+	var req dto.UpdateBookmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	bookmark, err := h.service.UpdateBookmark(c.Request.Context(), userID, bookmarkID, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bookmark)
+}
+
+func (h *BookmarkHandler) DeleteBookmark(c *gin.Context) {
+	bookmarkID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bookmark id"})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	if err := h.service.DeleteBookmark(c.Request.Context(), userID, bookmarkID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "bookmark deleted"})
+}
+
+func (h *BookmarkHandler) CreateCollection(c *gin.Context) {
+	var req dto.BookmarkCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	collection, err := h.service.CreateCollection(c.Request.Context(), userID, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, collection)
+}
+
+func (h *BookmarkHandler) GetMyCollections(c *gin.Context) {
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	collections, err := h.service.GetMyCollections(c.Request.Context(), userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": collections})
+}
+
+func (h *BookmarkHandler) UpdateCollection(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection id"})
+		return
+	}
+
+	var req dto.BookmarkCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	collection, err := h.service.UpdateCollection(c.Request.Context(), userID, collectionID, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+func (h *BookmarkHandler) DeleteCollection(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection id"})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	if err := h.service.DeleteCollection(c.Request.Context(), userID, collectionID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "collection deleted"})
+}
+
+func (h *BookmarkHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrBookmarkNotFound),
+		errors.Is(err, service.ErrCollectionNotFound),
+		errors.Is(err, service.ErrBookmarkTargetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrBookmarkExists),
+		errors.Is(err, service.ErrCollectionNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
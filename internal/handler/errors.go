@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"anoa.com/telkomalumiforum/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// respondNotFound answers 404 for threads and posts that are missing or
+// hidden from the user, and reports whether it did.
+func respondNotFound(c *gin.Context, err error) bool {
+	if errors.Is(err, service.ErrThreadNotFound) || errors.Is(err, service.ErrPostNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return true
+	}
+	return false
+}
+
+// respondBadAttachments answers 400 when a thread or post names attachments
+// it cannot use, and reports whether it did.
+func respondBadAttachments(c *gin.Context, err error) bool {
+	if errors.Is(err, service.ErrAttachmentUnavailable) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	manager *jobs.Manager
+}
+
+func NewJobHandler(manager *jobs.Manager) *JobHandler {
+	return &JobHandler{manager: manager}
+}
+
+func (h *JobHandler) GetJobs(c *gin.Context) {
+	infos, err := h.manager.Jobs(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": infos})
+}
+
+func (h *JobHandler) GetJobRuns(c *gin.Context) {
+	var filter dto.JobRunFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	runs, err := h.manager.History(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+func (h *JobHandler) TriggerJob(c *gin.Context) {
+	if err := h.manager.Trigger(c.Request.Context(), c.Param("name")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "job triggered"})
+}
+
+func (h *JobHandler) GetDeadLetters(c *gin.Context) {
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	tasks, err := h.manager.DeadLetters(c.Request.Context(), c.Param("name"), limit)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tasks})
+}
+
+func (h *JobHandler) ReplayDeadLetters(c *gin.Context) {
+	var req dto.ReplayDeadLettersRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	replayed, err := h.manager.Replay(c.Request.Context(), c.Param("name"), req.IDs)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"replayed": replayed})
+}
+
+func (h *JobHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, jobs.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, jobs.ErrNotQueued), errors.Is(err, jobs.ErrNotScheduled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
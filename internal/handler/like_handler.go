@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
+	"anoa.com/telkomalumiforum/internal/dto"
 	"anoa.com/telkomalumiforum/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -32,7 +34,7 @@ func (h *LikeHandler) LikeThread(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr.(string))
 
 	if err := h.service.LikeThread(c.Request.Context(), userID, threadID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.handleReactError(c, err)
 		return
 	}
 
@@ -55,6 +57,9 @@ func (h *LikeHandler) UnlikeThread(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr.(string))
 
 	if err := h.service.UnlikeThread(c.Request.Context(), userID, threadID); err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -78,7 +83,7 @@ func (h *LikeHandler) LikePost(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr.(string))
 
 	if err := h.service.LikePost(c.Request.Context(), userID, postID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.handleReactError(c, err)
 		return
 	}
 
@@ -101,6 +106,9 @@ func (h *LikeHandler) UnlikePost(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr.(string))
 
 	if err := h.service.UnlikePost(c.Request.Context(), userID, postID); err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -125,6 +133,9 @@ func (h *LikeHandler) CheckThreadLike(c *gin.Context) {
 
 	liked, err := h.service.CheckUserLikedThread(c.Request.Context(), userID, threadID)
 	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -149,9 +160,261 @@ func (h *LikeHandler) CheckPostLike(c *gin.Context) {
 
 	liked, err := h.service.CheckUserLikedPost(c.Request.Context(), userID, postID)
 	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"liked": liked})
 }
+
+func (h *LikeHandler) GetAvailableReactions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": h.service.GetAvailableReactions()})
+}
+
+func (h *LikeHandler) ReactThread(c *gin.Context) {
+	threadIDStr := c.Param("thread_id")
+	threadID, err := uuid.Parse(threadIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid thread id"})
+		return
+	}
+
+	var req dto.ReactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	if err := h.service.ReactThread(c.Request.Context(), userID, threadID, req.Reaction); err != nil {
+		h.handleReactError(c, err)
+		return
+	}
+
+	summary, err := h.service.GetThreadReactions(c.Request.Context(), userID, threadID)
+	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func (h *LikeHandler) UnreactThread(c *gin.Context) {
+	h.UnlikeThread(c)
+}
+
+func (h *LikeHandler) GetThreadReactions(c *gin.Context) {
+	threadIDStr := c.Param("thread_id")
+	threadID, err := uuid.Parse(threadIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid thread id"})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	summary, err := h.service.GetThreadReactions(c.Request.Context(), userID, threadID)
+	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func (h *LikeHandler) GetThreadReactors(c *gin.Context) {
+	threadIDStr := c.Param("thread_id")
+	threadID, err := uuid.Parse(threadIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid thread id"})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	users, err := h.service.GetThreadReactors(c.Request.Context(), userID, threadID, c.Param("reaction"))
+	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidReaction) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": users})
+}
+
+func (h *LikeHandler) ReactPost(c *gin.Context) {
+	postIDStr := c.Param("post_id")
+	postID, err := uuid.Parse(postIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		return
+	}
+
+	var req dto.ReactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	if err := h.service.ReactPost(c.Request.Context(), userID, postID, req.Reaction); err != nil {
+		h.handleReactError(c, err)
+		return
+	}
+
+	summary, err := h.service.GetPostReactions(c.Request.Context(), userID, postID)
+	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func (h *LikeHandler) UnreactPost(c *gin.Context) {
+	h.UnlikePost(c)
+}
+
+func (h *LikeHandler) GetPostReactions(c *gin.Context) {
+	postIDStr := c.Param("post_id")
+	postID, err := uuid.Parse(postIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	summary, err := h.service.GetPostReactions(c.Request.Context(), userID, postID)
+	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func (h *LikeHandler) GetPostReactors(c *gin.Context) {
+	postIDStr := c.Param("post_id")
+	postID, err := uuid.Parse(postIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	users, err := h.service.GetPostReactors(c.Request.Context(), userID, postID, c.Param("reaction"))
+	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidReaction) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": users})
+}
+
+// ResyncLikes rewrites every drifted like set in Redis from the database.
+func (h *LikeHandler) ResyncLikes(c *gin.Context) {
+	report, err := h.service.Resync(c.Request.Context())
+	if err != nil {
+		h.handleSyncError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetLikeDrift reports like sets that differ from the database without
+// touching them.
+func (h *LikeHandler) GetLikeDrift(c *gin.Context) {
+	report, err := h.service.Reconcile(c.Request.Context(), false)
+	if err != nil {
+		h.handleSyncError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *LikeHandler) handleSyncError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrLikeSyncRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func (h *LikeHandler) handleReactError(c *gin.Context, err error) {
+	if respondNotFound(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrAlreadyLiked), errors.Is(err, service.ErrAlreadyReacted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidReaction):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"anoa.com/telkomalumiforum/internal/service"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// The fakes embed the repository interfaces and implement only what the
+// reaction endpoints use.
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[string]*model.User
+}
+
+func (r *fakeUserRepo) FindByID(ctx context.Context, id string) (*model.User, error) {
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type fakeThreadRepo struct {
+	repository.ThreadRepository
+	threads map[uuid.UUID]*model.Thread
+}
+
+func (r *fakeThreadRepo) FindByID(ctx context.Context, id uuid.UUID) (*model.Thread, error) {
+	if thread, ok := r.threads[id]; ok {
+		copied := *thread
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type fakePostRepo struct {
+	repository.PostRepository
+	posts map[uuid.UUID]*model.Post
+}
+
+func (r *fakePostRepo) FindByID(ctx context.Context, id uuid.UUID) (*model.Post, error) {
+	if post, ok := r.posts[id]; ok {
+		copied := *post
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// emptyLikeRepo has no stored likes.
+type emptyLikeRepo struct {
+	repository.LikeRepository
+}
+
+func (emptyLikeRepo) FindThreadLikes(ctx context.Context, threadIDs []uuid.UUID) ([]model.ThreadLike, error) {
+	return nil, nil
+}
+
+func (emptyLikeRepo) FindPostLikes(ctx context.Context, postIDs []uuid.UUID) ([]model.PostLike, error) {
+	return nil, nil
+}
+
+type nopJobRepo struct {
+	repository.JobRepository
+}
+
+// newTestLikeRouter serves the like and reaction endpoints as user, over a
+// thread and a post in it that the user may see. Tasks are queued in Redis
+// and not processed.
+func newTestLikeRouter(t *testing.T) (router *gin.Engine, threadID, postID uuid.UUID) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	user := &model.User{ID: uuid.New(), Role: model.Role{Name: "siswa"}}
+	thread := &model.Thread{ID: uuid.New(), UserID: user.ID, Audience: "semua"}
+	post := &model.Post{ID: uuid.New(), ThreadID: thread.ID, UserID: user.ID}
+	userRepo := &fakeUserRepo{users: map[string]*model.User{user.ID.String(): user}}
+	threadRepo := &fakeThreadRepo{threads: map[uuid.UUID]*model.Thread{thread.ID: thread}}
+	postRepo := &fakePostRepo{posts: map[uuid.UUID]*model.Post{post.ID: post}}
+	visibility := service.NewVisibilityPolicy(userRepo, threadRepo, postRepo)
+
+	likes := service.NewLikeService(client, emptyLikeRepo{}, threadRepo, postRepo, userRepo, nil, visibility, jobs.NewManager(client, nopJobRepo{}))
+	h := NewLikeHandler(likes)
+
+	router = gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", user.ID.String())
+	})
+	router.POST("/threads/:thread_id/like", h.LikeThread)
+	router.POST("/threads/:thread_id/reactions", h.ReactThread)
+	router.POST("/posts/:post_id/like", h.LikePost)
+	router.POST("/posts/:post_id/reactions", h.ReactPost)
+	return router, thread.ID, post.ID
+}
+
+func TestReactionEndpoints(t *testing.T) {
+	router, threadID, postID := newTestLikeRouter(t)
+	threadURL := "/threads/" + threadID.String()
+	postURL := "/posts/" + postID.String()
+
+	// Steps run in order against the same thread and post
+	steps := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantMine   string           // Reaction in the summary, for 200 on /reactions
+		wantCounts map[string]int64 // Non-zero reaction counts
+	}{
+		{"react", threadURL + "/reactions", `{"reaction":"love"}`, http.StatusOK, "love", map[string]int64{"love": 1}},
+		{"same reaction again", threadURL + "/reactions", `{"reaction":"love"}`, http.StatusConflict, "", nil},
+		{"replace the reaction", threadURL + "/reactions", `{"reaction":"haha"}`, http.StatusOK, "haha", map[string]int64{"haha": 1}},
+		{"move to the default reaction", threadURL + "/reactions", `{"reaction":"like"}`, http.StatusOK, "like", map[string]int64{"like": 1}},
+		{"like when already liked", threadURL + "/like", "", http.StatusConflict, "", nil},
+		{"back to another reaction", threadURL + "/reactions", `{"reaction":"sad"}`, http.StatusOK, "sad", map[string]int64{"sad": 1}},
+		{"unknown reaction", threadURL + "/reactions", `{"reaction":"angry"}`, http.StatusBadRequest, "", nil},
+		{"missing reaction", threadURL + "/reactions", `{}`, http.StatusBadRequest, "", nil},
+		{"missing thread", "/threads/" + uuid.NewString() + "/reactions", `{"reaction":"love"}`, http.StatusNotFound, "", nil},
+		{"invalid thread id", "/threads/nope/reactions", `{"reaction":"love"}`, http.StatusBadRequest, "", nil},
+
+		{"like a post", postURL + "/like", "", http.StatusOK, "", nil},
+		{"replace the post like", postURL + "/reactions", `{"reaction":"wow"}`, http.StatusOK, "wow", map[string]int64{"wow": 1}},
+		{"same post reaction again", postURL + "/reactions", `{"reaction":"wow"}`, http.StatusConflict, "", nil},
+		{"missing post", "/posts/" + uuid.NewString() + "/like", "", http.StatusNotFound, "", nil},
+	}
+
+	for _, step := range steps {
+		req := httptest.NewRequest(http.MethodPost, step.path, strings.NewReader(step.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != step.wantStatus {
+			t.Fatalf("%s: status %d (%s), want %d", step.name, w.Code, w.Body, step.wantStatus)
+		}
+		if step.wantMine == "" {
+			continue
+		}
+
+		var summary dto.ReactionSummary
+		if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		mine := ""
+		if summary.MyReaction != nil {
+			mine = *summary.MyReaction
+		}
+		if mine != step.wantMine {
+			t.Errorf("%s: my reaction %q, want %q", step.name, mine, step.wantMine)
+		}
+		// One user, so one reaction in total whatever it moved from
+		if summary.Total != 1 {
+			t.Errorf("%s: total %d, want 1", step.name, summary.Total)
+		}
+		for _, rc := range summary.Reactions {
+			if rc.Count != step.wantCounts[rc.Reaction] {
+				t.Errorf("%s: %s count %d, want %d", step.name, rc.Reaction, rc.Count, step.wantCounts[rc.Reaction])
+			}
+		}
+	}
+}
@@ -1,8 +1,8 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
 
 	"anoa.com/telkomalumiforum/internal/dto"
 	"anoa.com/telkomalumiforum/internal/repository"
@@ -70,8 +70,8 @@ func (h *MenfessHandler) GetMenfesses(c *gin.Context) {
 	}
 
 	// TODO: Performance Improvement
-    // Saat ini kita query DB untuk cek role. Untuk skala besar, 
-    // sebaiknya role disimpan di JWT Claims context untuk menghindari DB call
+	// Saat ini kita query DB untuk cek role. Untuk skala besar,
+	// sebaiknya role disimpan di JWT Claims context untuk menghindari DB call
 	user, err := h.userRepo.FindByID(c.Request.Context(), userIDStr.(string))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
@@ -82,11 +82,36 @@ func (h *MenfessHandler) GetMenfesses(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "guru cannot view menfess"})
 		return
 	}
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	offset := (page - 1) * limit
 
-	menfesses, total, err := h.service.GetMenfesses(c.Request.Context(), offset, limit)
+	var filter dto.ListFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 10
+	}
+
+	if filter.Pagination == "cursor" || filter.Cursor != "" {
+		menfesses, meta, err := h.service.GetMenfessPage(c.Request.Context(), filter.CursorFilter, filter.Limit)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": menfesses, "cursor": meta})
+		return
+	}
+
+	offset := (filter.Page - 1) * filter.Limit
+	menfesses, total, err := h.service.GetMenfesses(c.Request.Context(), offset, filter.Limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -95,7 +120,7 @@ func (h *MenfessHandler) GetMenfesses(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"data":  menfesses,
 		"total": total,
-		"page":  page,
-		"limit": limit,
+		"page":  filter.Page,
+		"limit": filter.Limit,
 	})
 }
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
+	"anoa.com/telkomalumiforum/internal/dto"
 	"anoa.com/telkomalumiforum/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -42,11 +44,35 @@ func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 		return
 	}
 
-	limit := 20
-	offset := 0
-	// TODO: Add query params for limit/offset
+	var filter dto.ListFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	if filter.Pagination == "cursor" || filter.Cursor != "" {
+		notifications, meta, err := h.service.GetNotificationPage(c.Request.Context(), userID, filter.Cursor, filter.Limit)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 
-	notifications, err := h.service.GetNotifications(userID, limit, offset)
+		c.JSON(http.StatusOK, gin.H{"data": notifications, "cursor": meta})
+		return
+	}
+
+	offset := (filter.Page - 1) * filter.Limit
+	notifications, err := h.service.GetNotifications(c.Request.Context(), userID, filter.Limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -95,7 +121,7 @@ func (h *NotificationHandler) UnreadCount(c *gin.Context) {
 		return
 	}
 
-	count, err := h.service.UnreadCount(userID)
+	count, err := h.service.UnreadCount(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -116,9 +142,9 @@ func (h *NotificationHandler) HandleWebSocket(c *gin.Context) {
 		// NOTE: In a real app, don't reimplement auth logic here.
 		// We assume the route is protected by middleware.
 		// If middleware fails for WS due to missing headers, the request won't reach here.
-		// So we advise client to pass token in header (some libs allow it) or 
+		// So we advise client to pass token in header (some libs allow it) or
 		// we modify middleware to support query param.
-		
+
 		// For now, assuming middleware put user_id in context.
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
@@ -142,13 +168,13 @@ func (h *NotificationHandler) HandleWebSocket(c *gin.Context) {
 	channel := fmt.Sprintf("user_notifications:%s", userIDStr)
 	pubsub := h.redisClient.Subscribe(c.Request.Context(), channel)
 	defer pubsub.Close()
-	
+
 	// Wait for confirmation that subscription is created
 	_, err = pubsub.Receive(c.Request.Context())
 	if err != nil {
-         log.Printf("Failed to subscribe to redis channel: %v", err)
-         return
-    }
+		log.Printf("Failed to subscribe to redis channel: %v", err)
+		return
+	}
 
 	ch := pubsub.Channel()
 
@@ -174,10 +200,10 @@ func (h *NotificationHandler) HandleWebSocket(c *gin.Context) {
 			// Msg from Redis
 			// payload is JSON string of Notification
 			// We can just forward it directly
-			
+
 			// Optional: Unmarshal to verify/manipulate?
 			// For performance, just writing message is faster if format is already JSON
-			
+
 			err := conn.WriteMessage(websocket.TextMessage, []byte(msg.Payload))
 			if err != nil {
 				log.Printf("Failed to write message to websocket: %v", err)
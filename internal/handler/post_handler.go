@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -46,6 +47,9 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 
 	resp, err := h.service.CreatePost(c.Request.Context(), userID, req)
 	if err != nil {
+		if respondNotFound(c, err) || respondBadAttachments(c, err) {
+			return
+		}
 		if rateLimitErr, ok := err.(*service.RateLimitError); ok {
 			c.Header("Retry-After", fmt.Sprintf("%.0f", rateLimitErr.RetryAfter.Seconds()))
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": rateLimitErr.Message})
@@ -72,8 +76,18 @@ func (h *PostHandler) GetPostsByThreadID(c *gin.Context) {
 		return
 	}
 
-	posts, err := h.service.GetPostsByThreadID(c.Request.Context(), threadID, filter)
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	posts, err := h.service.GetPostsByThreadID(c.Request.Context(), userID, threadID, filter)
 	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -81,6 +95,48 @@ func (h *PostHandler) GetPostsByThreadID(c *gin.Context) {
 	c.JSON(http.StatusOK, posts)
 }
 
+func (h *PostHandler) GetReplies(c *gin.Context) {
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	postIDStr := c.Param("post_id")
+	postID, err := uuid.Parse(postIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		return
+	}
+
+	var filter dto.RepliesFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	replies, err := h.service.GetReplies(c.Request.Context(), userID, postID, filter)
+	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, replies)
+}
+
 func (h *PostHandler) UpdatePost(c *gin.Context) {
 	postIDStr := c.Param("post_id")
 	postID, err := uuid.Parse(postIDStr)
@@ -104,7 +160,10 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 
 	resp, err := h.service.UpdatePost(c.Request.Context(), userID, postID, req)
 	if err != nil {
-		// Differentiate errors (forbidden vs internal)
+		// Differentiate errors (not found vs forbidden vs internal)
+		if respondNotFound(c, err) || respondBadAttachments(c, err) {
+			return
+		}
 		if err.Error() == "unauthorized: you can only update your own post" {
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			return
@@ -132,6 +191,9 @@ func (h *PostHandler) DeletePost(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr.(string))
 
 	if err := h.service.DeletePost(c.Request.Context(), userID, postID); err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
 		if err.Error() == "unauthorized: you can only delete your own post unless you are an admin" {
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			return
@@ -143,7 +205,53 @@ func (h *PostHandler) DeletePost(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "post deleted successfully"})
 }
 
+func (h *PostHandler) RestorePost(c *gin.Context) {
+	postID, err := uuid.Parse(c.Param("post_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	resp, err := h.service.RestorePost(c.Request.Context(), userID, postID)
+	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrPostParentDeleted) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err.Error() == "unauthorized: you can only restore your own post unless you are an admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 func (h *PostHandler) GetPostByID(c *gin.Context) {
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+		return
+	}
+
 	postIDStr := c.Param("post_id")
 	postID, err := uuid.Parse(postIDStr)
 	if err != nil {
@@ -151,8 +259,11 @@ func (h *PostHandler) GetPostByID(c *gin.Context) {
 		return
 	}
 
-	post, err := h.service.GetPostByID(c.Request.Context(), postID)
+	post, err := h.service.GetPostByID(c.Request.Context(), userID, postID)
 	if err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
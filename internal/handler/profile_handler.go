@@ -80,6 +80,10 @@ func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
 
 	res, err := h.profileService.UpdateProfile(c.Request.Context(), userID.(string), input, avatar)
 	if err != nil {
+		if status, ok := uploadErrorStatus(err); ok {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
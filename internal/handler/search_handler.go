@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"anoa.com/telkomalumiforum/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SearchHandler struct {
+	service service.SearchService
+}
+
+func NewSearchHandler(service service.SearchService) *SearchHandler {
+	return &SearchHandler{service: service}
+}
+
+func (h *SearchHandler) Search(c *gin.Context) {
+	var filter dto.SearchFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	result, err := h.service.Search(c.Request.Context(), userID, filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSearchType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Search failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "search is unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RecordClick logs that a result of a search was opened, for the click
+// through rate in the analytics.
+func (h *SearchHandler) RecordClick(c *gin.Context) {
+	var input dto.SearchClickInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.RecordClick(c.Request.Context(), input); err != nil {
+		if errors.Is(err, service.ErrSearchNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "click recorded"})
+}
+
+// GetAnalytics reports top queries, queries without results and the click
+// through rate.
+func (h *SearchHandler) GetAnalytics(c *gin.Context) {
+	var filter dto.SearchAnalyticsFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.Analytics(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetToken issues a fresh search token; the frontend calls it before the
+// current one expires.
+func (h *SearchHandler) GetToken(c *gin.Context) {
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	token, err := h.service.Token(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		log.Printf("Search token failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "search is unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// RevokeTokens invalidates every search token by replacing the signing key;
+// users get a new one on their next refresh.
+func (h *SearchHandler) RevokeTokens(c *gin.Context) {
+	if err := h.service.RevokeTokens(c.Request.Context()); err != nil {
+		log.Printf("Search token revocation failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "search is unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "search tokens revoked"})
+}
+
+// Reindex rebuilds the search indexes from the database in the background;
+// progress shows up in the job runs of search.reindex.
+func (h *SearchHandler) Reindex(c *gin.Context) {
+	if err := h.service.Reindex(c.Request.Context()); err != nil {
+		if errors.Is(err, jobs.ErrJobRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "reindex started"})
+}
+
+// GetConsistency compares the search indexes with the database without
+// repairing them.
+func (h *SearchHandler) GetConsistency(c *gin.Context) {
+	report, err := h.service.CheckConsistency(c.Request.Context())
+	if err != nil {
+		log.Printf("Search consistency check failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "search is unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -2,20 +2,21 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 
+	"anoa.com/telkomalumiforum/internal/dto"
 	"anoa.com/telkomalumiforum/internal/service"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type StatHandler struct {
-	statService service.StatService
+	statService   service.StatService
 	threadService service.ThreadService
 }
 
 func NewStatHandler(statService service.StatService, threadService service.ThreadService) *StatHandler {
 	return &StatHandler{
-		statService: statService,
+		statService:   statService,
 		threadService: threadService,
 	}
 }
@@ -33,15 +34,24 @@ func (h *StatHandler) GetTotalUsers(c *gin.Context) {
 }
 
 func (h *StatHandler) GetTrendingThreads(c *gin.Context) {
-	limitStr := c.Query("limit")
-	limit := 10
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
+	var filter dto.TrendingFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.CategoryID != "" {
+		if _, err := uuid.Parse(filter.CategoryID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category id"})
+			return
 		}
 	}
 
-	threads, err := h.threadService.GetTrendingThreads(c.Request.Context(), limit)
+	var userID uuid.UUID
+	if userIDStr, exists := c.Get("user_id"); exists {
+		userID, _ = uuid.Parse(userIDStr.(string))
+	}
+
+	threads, err := h.threadService.GetTrendingThreads(c.Request.Context(), userID, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
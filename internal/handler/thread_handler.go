@@ -2,7 +2,9 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 
 	"anoa.com/telkomalumiforum/internal/dto"
@@ -39,6 +41,9 @@ func (h *ThreadHandler) CreateThread(c *gin.Context) {
 	}
 
 	if err := h.service.CreateThread(c.Request.Context(), userID, req); err != nil {
+		if respondBadAttachments(c, err) {
+			return
+		}
 		if rateLimitErr, ok := err.(*service.RateLimitError); ok {
 			c.Header("Retry-After", fmt.Sprintf("%.0f", rateLimitErr.RetryAfter.Seconds()))
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": rateLimitErr.Message})
@@ -80,6 +85,10 @@ func (h *ThreadHandler) GetAllThreads(c *gin.Context) {
 
 	threads, err := h.service.GetAllThreads(c.Request.Context(), userID, filter)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -113,8 +122,12 @@ func (h *ThreadHandler) GetMyThreads(c *gin.Context) {
 		return
 	}
 
-	threads, err := h.service.GetMyThreads(c.Request.Context(), userID, filter.Page, filter.Limit)
+	threads, err := h.service.GetMyThreads(c.Request.Context(), userID, filter.Page, filter.Limit, filter.CursorFilter)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -143,6 +156,9 @@ func (h *ThreadHandler) DeleteThread(c *gin.Context) {
 	}
 
 	if err := h.service.DeleteThread(c.Request.Context(), userID, threadID); err != nil {
+		if respondNotFound(c, err) {
+			return
+		}
 		// Basic error string matching, ideally should use custom errors or checks
 		if err.Error() == "unauthorized: you can only delete your own threads unless you are an admin" {
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
@@ -177,6 +193,9 @@ func (h *ThreadHandler) UpdateThread(c *gin.Context) {
 	userID, _ := uuid.Parse(userIDStr.(string))
 
 	if err := h.service.UpdateThread(c.Request.Context(), userID, threadID, req); err != nil {
+		if respondNotFound(c, err) || respondBadAttachments(c, err) {
+			return
+		}
 		if err.Error() == "unauthorized: you can only update your own thread" {
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			return
@@ -204,9 +223,12 @@ func (h *ThreadHandler) GetThreadBySlug(c *gin.Context) {
 	}
 
 	// Get thread first
-	thread, err := h.service.GetThreadBySlug(c.Request.Context(), slug)
+	thread, err := h.service.GetThreadBySlug(c.Request.Context(), userID, slug)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"thread not found": err.Error()})
+		if respondNotFound(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -246,15 +268,45 @@ func (h *ThreadHandler) GetThreadsByUsername(c *gin.Context) {
 		return
 	}
 
-	threads, err := h.service.GetThreadsByUsername(c.Request.Context(), userID, username, filter.Page, filter.Limit)
+	threads, err := h.service.GetThreadsByUsername(c.Request.Context(), userID, username, filter.Page, filter.Limit, filter.CursorFilter)
 	if err != nil {
 		if err.Error() == "user not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 			return
 		}
+		if errors.Is(err, service.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, threads)
 }
+
+// GetSimilarThreads is called while a thread is drafted, to show threads
+// that may already answer it.
+func (h *ThreadHandler) GetSimilarThreads(c *gin.Context) {
+	var req dto.SimilarThreadsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, _ := uuid.Parse(userIDStr.(string))
+
+	threads, err := h.service.GetSimilarThreads(c.Request.Context(), userID, req)
+	if err != nil {
+		log.Printf("Similar threads failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "search is unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": threads})
+}
@@ -0,0 +1,407 @@
+// Package jobs runs all background work: queued jobs consumed from durable
+// Redis queues and scheduled jobs fired by cron. Scheduled jobs take a Redis
+// lock so that only one replica runs each tick, every failure is recorded in
+// job_runs and per-job counters are kept in Redis.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"anoa.com/telkomalumiforum/pkg/queue"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	ErrUnknownJob   = errors.New("job not found")
+	ErrJobRunning   = errors.New("job is already running")
+	ErrNotQueued    = errors.New("job has no queue")
+	ErrNotScheduled = errors.New("job is not scheduled")
+)
+
+// Options configure a queued job.
+type Options struct {
+	Queue       string // Redis list, default "jobs:<name>"
+	Workers     int    // Default 1
+	MaxAttempts int    // Default 5
+	History     bool   // Record successful runs too; failures are always recorded
+}
+
+type job struct {
+	name    string
+	kind    string
+	opts    Options
+	handler queue.Handler
+	queue   *queue.Queue
+
+	spec     string
+	timeout  time.Duration
+	cooldown time.Duration
+	run      func(ctx context.Context) error
+}
+
+type Manager struct {
+	redisClient *redis.Client
+	jobRepo     repository.JobRepository
+	cron        *cron.Cron
+	host        string
+
+	mu    sync.RWMutex
+	jobs  map[string]*job
+	order []string
+
+	consumers sync.WaitGroup
+	running   sync.WaitGroup // Runs started outside of cron and the queues
+}
+
+// releaseScript deletes a lock only if this replica still owns it.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// NewManager creates the job manager. Without Redis, queued jobs run in a
+// goroutine on enqueue and scheduled jobs run without locking.
+func NewManager(redisClient *redis.Client, jobRepo repository.JobRepository) *Manager {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "local"
+	}
+
+	m := &Manager{
+		redisClient: redisClient,
+		jobRepo:     jobRepo,
+		cron:        cron.New(),
+		host:        host,
+		jobs:        make(map[string]*job),
+	}
+
+	retention := 30 * 24 * time.Hour
+	if d, err := time.ParseDuration(os.Getenv("JOB_HISTORY_RETENTION")); err == nil && d > 0 {
+		retention = d
+	}
+	m.Schedule("jobs.prune", "@daily", time.Hour, func(ctx context.Context) error {
+		deleted, err := jobRepo.DeleteBefore(ctx, time.Now().Add(-retention))
+		if err == nil && deleted > 0 {
+			log.Printf("Pruned %d job runs", deleted)
+		}
+		return err
+	})
+
+	return m
+}
+
+// Register adds a queued job whose payload is decoded into T. Payloads that
+// do not decode are dead-lettered without retries.
+func Register[T any](m *Manager, name string, opts Options, handle func(ctx context.Context, payload T) error) {
+	m.add(&job{
+		name: name,
+		kind: model.JobKindQueued,
+		opts: opts,
+		handler: func(ctx context.Context, raw []byte) error {
+			var payload T
+			if err := json.Unmarshal(raw, &payload); err != nil {
+				return queue.Permanent(fmt.Errorf("invalid %s payload: %w", name, err))
+			}
+			return handle(ctx, payload)
+		},
+	})
+}
+
+func (m *Manager) add(j *job) {
+	if j.kind == model.JobKindQueued {
+		if j.opts.Queue == "" {
+			j.opts.Queue = "jobs:" + j.name
+		}
+		if j.opts.Workers < 1 {
+			j.opts.Workers = 1
+		}
+		if m.redisClient != nil {
+			j.queue = queue.New(m.redisClient, j.opts.Queue, queue.Options{
+				MaxAttempts: j.opts.MaxAttempts,
+				OnDone: func(res queue.Result) {
+					m.recordQueued(j, res)
+				},
+			})
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.jobs[j.name]; exists {
+		panic(fmt.Sprintf("jobs: %s registered twice", j.name))
+	}
+	m.jobs[j.name] = j
+	m.order = append(m.order, j.name)
+}
+
+func (m *Manager) get(name string) (*job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	j, ok := m.jobs[name]
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	return j, nil
+}
+
+// Enqueue queues payload for the named job.
+func (m *Manager) Enqueue(ctx context.Context, name string, payload interface{}) error {
+	j, err := m.get(name)
+	if err != nil {
+		return err
+	}
+	if j.kind != model.JobKindQueued {
+		return ErrNotQueued
+	}
+
+	if j.queue != nil {
+		return j.queue.Push(ctx, payload)
+	}
+
+	// No Redis: best effort, in the background
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		start := time.Now()
+		err := safeCall(func() error { return j.handler(context.Background(), raw) })
+		m.recordQueued(j, queue.Result{
+			Envelope: queue.Envelope{ID: uuid.NewString(), Payload: raw},
+			Attempt:  1,
+			Err:      err,
+			Dead:     err != nil,
+			Duration: time.Since(start),
+		})
+	}()
+	return nil
+}
+
+// Schedule adds a job fired on the cron spec (standard five fields or
+// descriptors like "@every 1m"). timeout bounds a single run.
+func (m *Manager) Schedule(name string, spec string, timeout time.Duration, run func(ctx context.Context) error) {
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		panic(fmt.Sprintf("jobs: invalid schedule %q for %s: %v", spec, name, err))
+	}
+
+	// Replicas fire the same tick at slightly different moments; the first
+	// one claims the tick for half an interval
+	now := time.Now()
+	next := sched.Next(now)
+	cooldown := sched.Next(next).Sub(next) / 2
+	if cooldown < time.Second {
+		cooldown = time.Second
+	}
+
+	j := &job{
+		name:     name,
+		kind:     model.JobKindScheduled,
+		spec:     spec,
+		timeout:  timeout,
+		cooldown: cooldown,
+		run:      run,
+	}
+	m.add(j)
+
+	m.cron.Schedule(sched, cron.FuncJob(func() {
+		if m.redisClient != nil {
+			claimed, err := m.redisClient.SetNX(context.Background(), "jobs:tick:"+name, m.host, cooldown).Result()
+			if err != nil {
+				log.Printf("Job %s: failed to claim tick: %v", name, err)
+				return
+			}
+			if !claimed {
+				return
+			}
+		}
+		if err := m.runScheduled(j, "schedule"); err != nil && !errors.Is(err, ErrJobRunning) {
+			log.Printf("Job %s failed: %v", name, err)
+		}
+	}))
+}
+
+// Manual adds a job that has no schedule and only runs through Trigger, with
+// the same locking and history as scheduled jobs.
+func (m *Manager) Manual(name string, timeout time.Duration, run func(ctx context.Context) error) {
+	m.add(&job{
+		name:    name,
+		kind:    model.JobKindScheduled,
+		timeout: timeout,
+		run:     run,
+	})
+}
+
+// Trigger runs a scheduled job now, in the background.
+func (m *Manager) Trigger(ctx context.Context, name string) error {
+	j, err := m.get(name)
+	if err != nil {
+		return err
+	}
+	if j.kind != model.JobKindScheduled {
+		return ErrNotScheduled
+	}
+
+	if m.redisClient != nil {
+		n, err := m.redisClient.Exists(ctx, lockKey(name)).Result()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrJobRunning
+		}
+	}
+
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		if err := m.runScheduled(j, "manual"); err != nil && !errors.Is(err, ErrJobRunning) {
+			log.Printf("Job %s failed: %v", name, err)
+		}
+	}()
+	return nil
+}
+
+func lockKey(name string) string {
+	return "jobs:lock:" + name
+}
+
+// runScheduled runs j while holding its lock, so a run never overlaps with
+// another one on any replica.
+func (m *Manager) runScheduled(j *job, trigger string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), j.timeout)
+	defer cancel()
+
+	if m.redisClient != nil {
+		token := uuid.NewString()
+		locked, err := m.redisClient.SetNX(ctx, lockKey(j.name), token, j.timeout).Result()
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return ErrJobRunning
+		}
+		defer releaseScript.Run(context.Background(), m.redisClient, []string{lockKey(j.name)}, token)
+	}
+
+	run := &model.JobRun{
+		Job:       j.name,
+		Kind:      model.JobKindScheduled,
+		Status:    model.JobStatusRunning,
+		Trigger:   trigger,
+		Attempt:   1,
+		Host:      m.host,
+		StartedAt: time.Now(),
+	}
+	if err := m.jobRepo.Create(ctx, run); err != nil {
+		log.Printf("Job %s: failed to record run: %v", j.name, err)
+	}
+
+	err := safeCall(func() error { return j.run(ctx) })
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	run.Status = model.JobStatusSucceeded
+	if err != nil {
+		run.Status = model.JobStatusFailed
+		run.Error = err.Error()
+	}
+	if run.ID != uuid.Nil {
+		if uerr := m.jobRepo.Update(context.Background(), run); uerr != nil {
+			log.Printf("Job %s: failed to record run: %v", j.name, uerr)
+		}
+	}
+
+	m.recordMetrics(j.name, run.Status, err, finished.Sub(run.StartedAt))
+	return err
+}
+
+func (m *Manager) recordQueued(j *job, res queue.Result) {
+	status := model.JobStatusSucceeded
+	switch {
+	case res.Dead:
+		status = model.JobStatusDead
+	case res.Err != nil:
+		status = model.JobStatusFailed
+	}
+	m.recordMetrics(j.name, status, res.Err, res.Duration)
+
+	if res.Err == nil && !j.opts.History {
+		return
+	}
+
+	finished := time.Now()
+	run := &model.JobRun{
+		Job:        j.name,
+		Kind:       model.JobKindQueued,
+		Status:     status,
+		Trigger:    "queue",
+		Attempt:    res.Attempt,
+		Payload:    string(res.Envelope.Payload),
+		Host:       m.host,
+		StartedAt:  finished.Add(-res.Duration),
+		FinishedAt: &finished,
+		DurationMs: res.Duration.Milliseconds(),
+	}
+	if res.Err != nil {
+		run.Error = res.Err.Error()
+	}
+	if err := m.jobRepo.Create(context.Background(), run); err != nil {
+		log.Printf("Job %s: failed to record run: %v", j.name, err)
+	}
+}
+
+func safeCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// Start launches the queue consumers and the scheduler. Cancelling ctx stops
+// them; Wait blocks until in-flight work is done.
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, name := range m.order {
+		j := m.jobs[name]
+		if j.queue == nil {
+			continue
+		}
+		m.consumers.Add(1)
+		go func() {
+			defer m.consumers.Done()
+			j.queue.Run(ctx, j.opts.Workers, j.handler)
+		}()
+	}
+
+	m.cron.Start()
+	log.Printf("⚙️ Job manager started with %d jobs", len(m.order))
+}
+
+// Wait stops the scheduler and waits for running jobs and queue consumers.
+// Call it after cancelling the context given to Start.
+func (m *Manager) Wait() {
+	<-m.cron.Stop().Done()
+	m.consumers.Wait()
+	m.running.Wait()
+}
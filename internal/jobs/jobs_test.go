@@ -0,0 +1,292 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// memoryJobRepo keeps the last saved state of each run in memory.
+type memoryJobRepo struct {
+	mu   sync.Mutex
+	runs []model.JobRun
+}
+
+func (r *memoryJobRepo) Create(ctx context.Context, run *model.JobRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+	r.runs = append(r.runs, *run)
+	return nil
+}
+
+func (r *memoryJobRepo) Update(ctx context.Context, run *model.JobRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.runs {
+		if r.runs[i].ID == run.ID {
+			r.runs[i] = *run
+		}
+	}
+	return nil
+}
+
+func (r *memoryJobRepo) FindAll(ctx context.Context, job string, status string, offset, limit int) ([]*model.JobRun, int64, error) {
+	return nil, 0, nil
+}
+
+func (r *memoryJobRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *memoryJobRepo) recorded(job string) []model.JobRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var runs []model.JobRun
+	for _, run := range r.runs {
+		if run.Job == job {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// waitFor polls cond until it holds or the deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// blockingJob returns a run that signals started and blocks until release
+// is closed.
+func blockingJob() (run func(ctx context.Context) error, started chan struct{}, release chan struct{}) {
+	started = make(chan struct{}, 10)
+	release = make(chan struct{})
+	run = func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	return run, started, release
+}
+
+func TestLockPreventsConcurrentRuns(t *testing.T) {
+	client := newTestRedis(t)
+	repo := &memoryJobRepo{}
+	// Two replicas sharing Redis
+	m := NewManager(client, repo)
+	other := NewManager(client, repo)
+
+	run, started, release := blockingJob()
+	m.Manual("reindex", time.Minute, run)
+	other.Manual("reindex", time.Minute, run)
+
+	ctx := context.Background()
+	if err := m.Trigger(ctx, "reindex"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	<-started
+
+	if err := m.Trigger(ctx, "reindex"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("second Trigger = %v, want ErrJobRunning", err)
+	}
+	if err := other.Trigger(ctx, "reindex"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Trigger on another replica = %v, want ErrJobRunning", err)
+	}
+	// A scheduled tick that gets past the trigger check still loses the lock
+	j, _ := other.get("reindex")
+	if err := other.runScheduled(j, "schedule"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("runScheduled = %v, want ErrJobRunning", err)
+	}
+
+	close(release)
+	m.Wait()
+	other.Wait()
+
+	if runs := repo.recorded("reindex"); len(runs) != 1 {
+		t.Errorf("%d runs recorded, want 1", len(runs))
+	}
+	// The lock is released after the run
+	if n, _ := client.Exists(ctx, lockKey("reindex")).Result(); n != 0 {
+		t.Error("lock left behind after the run")
+	}
+	if err := m.Trigger(ctx, "reindex"); err != nil {
+		t.Errorf("Trigger after the run: %v", err)
+	}
+	<-started
+	m.Wait()
+}
+
+func TestFailedRunRecorded(t *testing.T) {
+	client := newTestRedis(t)
+	repo := &memoryJobRepo{}
+	m := NewManager(client, repo)
+
+	m.Manual("digest", time.Minute, func(ctx context.Context) error {
+		return errors.New("smtp down")
+	})
+	m.Manual("crash", time.Minute, func(ctx context.Context) error {
+		panic("nil map")
+	})
+
+	ctx := context.Background()
+	for _, name := range []string{"digest", "crash"} {
+		if err := m.Trigger(ctx, name); err != nil {
+			t.Fatalf("Trigger %s: %v", name, err)
+		}
+	}
+	m.Wait()
+
+	tests := []struct {
+		job string
+		err string
+	}{
+		{"digest", "smtp down"},
+		{"crash", "panic: nil map"},
+	}
+	for _, tt := range tests {
+		runs := repo.recorded(tt.job)
+		if len(runs) != 1 {
+			t.Fatalf("%s: %d runs recorded, want 1", tt.job, len(runs))
+		}
+		run := runs[0]
+		if run.Status != model.JobStatusFailed || run.Error != tt.err || run.Trigger != "manual" || run.FinishedAt == nil {
+			t.Errorf("%s: recorded %+v, want a finished manual run failed with %q", tt.job, run, tt.err)
+		}
+
+		metrics := parseMetrics(client.HGetAll(ctx, metricsKey(tt.job)).Val())
+		if metrics.Runs != 1 || metrics.Failures != 1 || metrics.LastStatus != model.JobStatusFailed || metrics.LastError != tt.err {
+			t.Errorf("%s: metrics %+v, want one failure", tt.job, metrics)
+		}
+	}
+}
+
+func TestFailedQueuedRunRecorded(t *testing.T) {
+	client := newTestRedis(t)
+	repo := &memoryJobRepo{}
+	m := NewManager(client, repo)
+
+	Register(m, "email", Options{MaxAttempts: 1}, func(ctx context.Context, payload struct{ To string }) error {
+		if payload.To == "" {
+			return errors.New("no recipient")
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Start(ctx)
+	for _, to := range []string{"a@example.com", ""} {
+		if err := m.Enqueue(ctx, "email", struct{ To string }{to}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "the dead letter", func() bool { return len(repo.recorded("email")) > 0 })
+	cancel()
+	m.Wait()
+
+	// Only the failure is recorded, without History
+	runs := repo.recorded("email")
+	if len(runs) != 1 {
+		t.Fatalf("%d runs recorded, want 1", len(runs))
+	}
+	if run := runs[0]; run.Status != model.JobStatusDead || run.Error != "no recipient" || run.Payload != `{"To":""}` {
+		t.Errorf("recorded %+v, want the dead payload", run)
+	}
+
+	metrics := parseMetrics(client.HGetAll(context.Background(), metricsKey("email")).Val())
+	if metrics.Runs != 2 || metrics.Failures != 1 || metrics.Dead != 1 {
+		t.Errorf("metrics %+v, want two runs with one dead", metrics)
+	}
+}
+
+func TestWaitForInFlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		redis bool
+		start func(t *testing.T, m *Manager, run func(ctx context.Context) error)
+	}{
+		{"manual", true, func(t *testing.T, m *Manager, run func(ctx context.Context) error) {
+			m.Manual("job", time.Minute, run)
+			if err := m.Trigger(context.Background(), "job"); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"queued", true, func(t *testing.T, m *Manager, run func(ctx context.Context) error) {
+			Register(m, "job", Options{}, func(ctx context.Context, payload int) error { return run(ctx) })
+			if err := m.Enqueue(context.Background(), "job", 1); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"queued without Redis", false, func(t *testing.T, m *Manager, run func(ctx context.Context) error) {
+			Register(m, "job", Options{}, func(ctx context.Context, payload int) error { return run(ctx) })
+			if err := m.Enqueue(context.Background(), "job", 1); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"scheduled", true, func(t *testing.T, m *Manager, run func(ctx context.Context) error) {
+			m.Schedule("job", "@every 1s", time.Minute, run)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var client *redis.Client
+			if tt.redis {
+				client = newTestRedis(t)
+			}
+			m := NewManager(client, &memoryJobRepo{})
+			run, started, release := blockingJob()
+			ctx, cancel := context.WithCancel(context.Background())
+
+			tt.start(t, m, run)
+			m.Start(ctx)
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("job did not start")
+			}
+			cancel()
+
+			waited := make(chan struct{})
+			go func() {
+				m.Wait()
+				close(waited)
+			}()
+			select {
+			case <-waited:
+				t.Fatal("Wait returned with a job in flight")
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			close(release)
+			select {
+			case <-waited:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Wait did not return after the job finished")
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/pkg/queue"
+)
+
+func metricsKey(name string) string {
+	return "jobs:metrics:" + name
+}
+
+// recordMetrics updates the job's counters, shared by all replicas.
+func (m *Manager) recordMetrics(name string, status string, err error, took time.Duration) {
+	if m.redisClient == nil {
+		return
+	}
+
+	ctx := context.Background()
+	key := metricsKey(name)
+	pipe := m.redisClient.Pipeline()
+	pipe.HIncrBy(ctx, key, "runs", 1)
+	pipe.HIncrBy(ctx, key, "duration_ms", took.Milliseconds())
+	pipe.HSet(ctx, key, "last_status", status, "last_run_at", time.Now().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		pipe.HIncrBy(ctx, key, "failures", 1)
+		pipe.HSet(ctx, key, "last_error", err.Error())
+	}
+	if status == model.JobStatusDead {
+		pipe.HIncrBy(ctx, key, "dead", 1)
+	}
+	_, _ = pipe.Exec(ctx)
+}
+
+// Jobs lists every registered job with its counters and queue depth.
+func (m *Manager) Jobs(ctx context.Context) ([]dto.JobInfo, error) {
+	m.mu.RLock()
+	jobs := make([]*job, 0, len(m.order))
+	for _, name := range m.order {
+		jobs = append(jobs, m.jobs[name])
+	}
+	m.mu.RUnlock()
+
+	infos := make([]dto.JobInfo, 0, len(jobs))
+	for _, j := range jobs {
+		info := dto.JobInfo{
+			Name:     j.name,
+			Kind:     j.kind,
+			Schedule: j.spec,
+		}
+		if j.kind == model.JobKindQueued {
+			info.Workers = j.opts.Workers
+		}
+
+		if m.redisClient != nil {
+			values, err := m.redisClient.HGetAll(ctx, metricsKey(j.name)).Result()
+			if err != nil {
+				return nil, err
+			}
+			info.Metrics = parseMetrics(values)
+		}
+		if j.queue != nil {
+			stats, err := j.queue.Stats(ctx)
+			if err != nil {
+				return nil, err
+			}
+			info.Queue = stats
+		}
+
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func parseMetrics(values map[string]string) dto.JobMetrics {
+	parse := func(field string) int64 {
+		n, _ := strconv.ParseInt(values[field], 10, 64)
+		return n
+	}
+
+	metrics := dto.JobMetrics{
+		Runs:       parse("runs"),
+		Failures:   parse("failures"),
+		Dead:       parse("dead"),
+		LastStatus: values["last_status"],
+		LastRunAt:  values["last_run_at"],
+		LastError:  values["last_error"],
+	}
+	if metrics.Runs > 0 {
+		metrics.AvgDurationMs = float64(parse("duration_ms")) / float64(metrics.Runs)
+	}
+	return metrics
+}
+
+// History lists recorded runs, newest first.
+func (m *Manager) History(ctx context.Context, filter dto.JobRunFilter) (*dto.PaginatedJobRunResponse, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 20
+	}
+
+	offset := (filter.Page - 1) * filter.Limit
+	runs, total, err := m.jobRepo.FindAll(ctx, filter.Job, filter.Status, offset, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / filter.Limit
+	if int(total)%filter.Limit != 0 {
+		totalPages++
+	}
+
+	return &dto.PaginatedJobRunResponse{
+		Data: runs,
+		Meta: dto.PaginationMeta{
+			CurrentPage: filter.Page,
+			TotalPages:  totalPages,
+			TotalItems:  total,
+			Limit:       filter.Limit,
+		},
+	}, nil
+}
+
+func (m *Manager) queueOf(name string) (*queue.Queue, error) {
+	j, err := m.get(name)
+	if err != nil {
+		return nil, err
+	}
+	if j.queue == nil {
+		return nil, ErrNotQueued
+	}
+	return j.queue, nil
+}
+
+// DeadLetters returns up to limit dead tasks of a queued job, newest first.
+func (m *Manager) DeadLetters(ctx context.Context, name string, limit int) ([]queue.Envelope, error) {
+	q, err := m.queueOf(name)
+	if err != nil {
+		return nil, err
+	}
+	return q.DeadLetters(ctx, limit)
+}
+
+// Replay moves dead tasks of a queued job back onto its queue. Empty ids
+// replays all of them.
+func (m *Manager) Replay(ctx context.Context, name string, ids []string) (int, error) {
+	q, err := m.queueOf(name)
+	if err != nil {
+		return 0, err
+	}
+	return q.Replay(ctx, ids)
+}
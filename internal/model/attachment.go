@@ -13,5 +13,6 @@ type Attachment struct {
 	PostID    *uuid.UUID `gorm:"type:uuid" json:"post_id,omitempty"`
 	FileURL   string     `gorm:"type:text;not null" json:"file_url"`
 	FileType  string     `gorm:"size:50" json:"file_type"`
+	Context   string     `gorm:"size:20" json:"context"` // What it was uploaded for, "thread" or "post"; empty before upload contexts
 	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
 }
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserBlock hides the blocked user's threads, posts and profile from the
+// user's search results.
+type UserBlock struct {
+	UserID    uuid.UUID `gorm:"primaryKey;type:uuid" json:"user_id"`
+	BlockedID uuid.UUID `gorm:"primaryKey;type:uuid;index" json:"blocked_id"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Blocked   User      `gorm:"foreignKey:BlockedID;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BookmarkCollection struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bookmark_collection_user_name" json:"user_id"`
+	User        User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex:idx_bookmark_collection_user_name" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (c *BookmarkCollection) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == uuid.Nil {
+		c.ID, err = uuid.NewV7()
+	}
+	return
+}
+
+// Bookmark points at a thread, or at a single post inside it when PostID is set.
+// ThreadID and PostID deliberately have no foreign keys: a bookmark outlives the
+// content it points to so the owner can see that it was deleted.
+// A user bookmarks a thread or a post at most once; partial unique indexes,
+// created with the migrations as GORM tags cannot express them, enforce it.
+type Bookmark struct {
+	ID           uuid.UUID           `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID       uuid.UUID           `gorm:"type:uuid;not null;index" json:"user_id"`
+	User         User                `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	CollectionID *uuid.UUID          `gorm:"type:uuid;index" json:"collection_id"`
+	Collection   *BookmarkCollection `gorm:"constraint:OnDelete:SET NULL" json:"-"`
+	ThreadID     uuid.UUID           `gorm:"type:uuid;not null;index" json:"thread_id"`
+	PostID       *uuid.UUID          `gorm:"type:uuid" json:"post_id,omitempty"`
+	Note         string              `gorm:"type:text" json:"note"`
+	CreatedAt    time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (b *Bookmark) BeforeCreate(tx *gorm.DB) (err error) {
+	if b.ID == uuid.Nil {
+		b.ID, err = uuid.NewV7()
+	}
+	return
+}
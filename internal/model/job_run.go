@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Job kinds
+const (
+	JobKindQueued    = "queued"    // Runs once per enqueued payload
+	JobKindScheduled = "scheduled" // Runs on a cron schedule on one replica at a time
+)
+
+// Job run statuses
+const (
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed" // Will be retried
+	JobStatusDead      = "dead"   // Out of attempts, moved to the dead-letter list
+)
+
+// JobRun is one execution of a background job. Queued jobs only record
+// failures unless they opt into full history.
+type JobRun struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Job        string     `gorm:"size:100;not null;index:idx_job_runs_job_started,priority:1" json:"job"`
+	Kind       string     `gorm:"size:20;not null" json:"kind"`
+	Status     string     `gorm:"size:20;not null;index" json:"status"`
+	Trigger    string     `gorm:"size:20;not null" json:"trigger"` // queue, schedule or manual
+	Attempt    int        `gorm:"not null" json:"attempt"`
+	Payload    string     `gorm:"type:text" json:"payload,omitempty"`
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	Host       string     `gorm:"size:255" json:"host"`
+	StartedAt  time.Time  `gorm:"not null;index:idx_job_runs_job_started,priority:2;index" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	DurationMs int64      `json:"duration_ms"`
+}
+
+func (r *JobRun) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID, err = uuid.NewV7()
+	}
+	return
+}
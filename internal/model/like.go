@@ -9,11 +9,13 @@ import (
 type ThreadLike struct {
 	UserID    uuid.UUID `gorm:"primaryKey;type:uuid" json:"user_id"`
 	ThreadID  uuid.UUID `gorm:"primaryKey;type:uuid" json:"thread_id"`
+	Reaction  string    `gorm:"size:20;not null;default:like" json:"reaction"` // Existing likes default to 'like' (👍)
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 type PostLike struct {
 	UserID    uuid.UUID `gorm:"primaryKey;type:uuid" json:"user_id"`
 	PostID    uuid.UUID `gorm:"primaryKey;type:uuid" json:"post_id"`
+	Reaction  string    `gorm:"size:20;not null;default:like" json:"reaction"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
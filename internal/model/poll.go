@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Poll struct {
+	ID                    uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	ThreadID              uuid.UUID    `gorm:"type:uuid;uniqueIndex;not null" json:"thread_id"`
+	Question              string       `gorm:"size:255;not null" json:"question"`
+	MultipleChoice        bool         `gorm:"default:false" json:"multiple_choice"`
+	Anonymous             bool         `gorm:"default:false" json:"anonymous"`
+	ShowResultsBeforeVote bool         `gorm:"default:false" json:"show_results_before_vote"`
+	Deadline              *time.Time   `json:"deadline,omitempty"`
+	ClosedAt              *time.Time   `json:"closed_at,omitempty"`
+	Options               []PollOption `gorm:"constraint:OnDelete:CASCADE" json:"options"`
+	CreatedAt             time.Time    `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (p *Poll) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == uuid.Nil {
+		p.ID, err = uuid.NewV7()
+	}
+	return
+}
+
+// IsClosed reports whether the poll was closed manually or its deadline has passed.
+func (p *Poll) IsClosed(now time.Time) bool {
+	if p.ClosedAt != nil {
+		return true
+	}
+	return p.Deadline != nil && !now.Before(*p.Deadline)
+}
+
+type PollOption struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	PollID   uuid.UUID `gorm:"type:uuid;not null;index" json:"poll_id"`
+	Text     string    `gorm:"size:100;not null" json:"text"`
+	Position int       `gorm:"not null" json:"position"`
+}
+
+func (o *PollOption) BeforeCreate(tx *gorm.DB) (err error) {
+	if o.ID == uuid.Nil {
+		o.ID, err = uuid.NewV7()
+	}
+	return
+}
+
+type PollVote struct {
+	PollID    uuid.UUID  `gorm:"primaryKey;type:uuid" json:"poll_id"`
+	UserID    uuid.UUID  `gorm:"primaryKey;type:uuid" json:"user_id"`
+	OptionID  uuid.UUID  `gorm:"primaryKey;type:uuid" json:"option_id"`
+	Poll      Poll       `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Option    PollOption `gorm:"foreignKey:OptionID;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// PollBallot is the one ballot a user casts on a poll, whatever the number of
+// options chosen. Its key keeps a user from voting twice.
+type PollBallot struct {
+	PollID    uuid.UUID `gorm:"primaryKey;type:uuid" json:"poll_id"`
+	UserID    uuid.UUID `gorm:"primaryKey;type:uuid" json:"user_id"`
+	Poll      Poll      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -9,16 +9,18 @@ import (
 
 type Post struct {
 	ID          uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
-	ThreadID    uuid.UUID    `gorm:"type:uuid;not null" json:"thread_id"`
+	ThreadID    uuid.UUID    `gorm:"type:uuid;not null;index:idx_posts_thread_created,priority:1" json:"thread_id"`
 	Thread      Thread       `gorm:"constraint:OnDelete:CASCADE" json:"thread,omitempty"`
-	ParentID    *uuid.UUID   `gorm:"type:uuid" json:"parent_id,omitempty"`
+	ParentID    *uuid.UUID   `gorm:"type:uuid;index" json:"parent_id,omitempty"`
 	Parent      *Post        `gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE" json:"parent,omitempty"` // For nested replies
 	UserID      uuid.UUID    `gorm:"type:uuid;not null" json:"user_id"`
 	User        User         `gorm:"constraint:OnDelete:CASCADE" json:"user,omitempty"`
 	Content     string       `gorm:"type:text;not null" json:"content"`
 	Attachments []Attachment `gorm:"foreignKey:PostID" json:"attachments,omitempty"`
-	CreatedAt   time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	CreatedAt   time.Time    `gorm:"autoCreateTime;index:idx_posts_thread_created,priority:2" json:"created_at"`
 	UpdatedAt   time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+	// Deleted posts are kept with their replies so they can be restored
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (p *Post) BeforeCreate(tx *gorm.DB) (err error) {
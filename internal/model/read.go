@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ThreadRead is the last-read position of a user in a thread: every post
+// created up to LastReadAt counts as read.
+type ThreadRead struct {
+	UserID     uuid.UUID `gorm:"primaryKey;type:uuid" json:"user_id"`
+	ThreadID   uuid.UUID `gorm:"primaryKey;type:uuid" json:"thread_id"`
+	User       User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Thread     Thread    `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	LastReadAt time.Time `gorm:"not null" json:"last_read_at"`
+}
+
+// CategoryRead marks every thread and post in a category created up to
+// LastReadAt as read. CategoryID uuid.Nil applies to all categories.
+type CategoryRead struct {
+	UserID     uuid.UUID `gorm:"primaryKey;type:uuid" json:"user_id"`
+	CategoryID uuid.UUID `gorm:"primaryKey;type:uuid" json:"category_id"`
+	User       User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	LastReadAt time.Time `gorm:"not null" json:"last_read_at"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SearchLog is one search from /api/search, kept for analytics. It holds
+// no user id, so searches cannot be traced back to who made them. Only the
+// first page of a search is logged.
+type SearchLog struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Query       string    `gorm:"size:200;not null;index" json:"query"` // Lowercased, with collapsed whitespace
+	Filters     string    `gorm:"type:text" json:"filters,omitempty"`   // The other parameters, URL-encoded
+	ResultCount int64     `gorm:"not null" json:"result_count"`         // Estimated total hits
+	CreatedAt   time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+func (l *SearchLog) BeforeCreate(tx *gorm.DB) (err error) {
+	if l.ID == uuid.Nil {
+		l.ID, err = uuid.NewV7()
+	}
+	return
+}
+
+// SearchClick records that a result of a search was opened.
+type SearchClick struct {
+	SearchID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"search_id"`
+	ResultID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"result_id"`
+	Search     SearchLog `gorm:"foreignKey:SearchID;constraint:OnDelete:CASCADE" json:"-"`
+	ResultType string    `gorm:"size:20;not null" json:"result_type"`
+	Position   int       `gorm:"not null" json:"position"` // 1-based rank in the results
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
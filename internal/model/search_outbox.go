@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Search outbox entities
+const (
+	SearchEntityThread   = "thread"
+	SearchEntityPost     = "post"
+	SearchEntityUser     = "user"
+	SearchEntityCategory = "category"
+)
+
+// SearchOutbox records that an entity changed and its search documents need
+// to be brought up to date. Rows are written in the same transaction as the
+// change and deleted once delivered to Meilisearch.
+type SearchOutbox struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Entity      string    `gorm:"size:20;not null" json:"entity"`
+	EntityID    uuid.UUID `gorm:"type:uuid;not null" json:"entity_id"`
+	Attempts    int       `gorm:"not null;default:0" json:"attempts"`
+	LastError   string    `gorm:"type:text" json:"last_error,omitempty"`
+	AvailableAt time.Time `gorm:"not null;default:now();index" json:"available_at"` // Claimed or backing off until then
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (o *SearchOutbox) BeforeCreate(tx *gorm.DB) (err error) {
+	if o.ID == uuid.Nil {
+		o.ID, err = uuid.NewV7()
+	}
+	return
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Thread subscription modes
+const (
+	SubscriptionModeAll    = "all"    // Every new reply in the thread
+	SubscriptionModeDirect = "direct" // Only replies to the user's own posts and mentions
+	SubscriptionModeMuted  = "muted"  // Nothing, not even direct replies or mentions
+)
+
+type ThreadSubscription struct {
+	UserID    uuid.UUID `gorm:"primaryKey;type:uuid" json:"user_id"`
+	ThreadID  uuid.UUID `gorm:"primaryKey;type:uuid;index" json:"thread_id"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Thread    Thread    `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Mode      string    `gorm:"size:20;not null" json:"mode"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
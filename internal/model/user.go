@@ -23,6 +23,7 @@ type User struct {
 	Role         Role      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"role"`
 	AvatarURL    *string   `gorm:"type:text" json:"avatar_url,omitempty"`
 	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 	Profile      *Profile  `gorm:"constraint:OnDelete:CASCADE" json:"profile,omitempty"`
 }
 
@@ -39,5 +40,7 @@ type Profile struct {
 	IdentityNumber *string   `gorm:"size:50" json:"identity_number,omitempty"`
 	ClassGrade     *string   `gorm:"size:20" json:"class_grade,omitempty"`
 	Bio            *string   `gorm:"type:text" json:"bio,omitempty"`
+	ShowFullName   bool      `gorm:"not null;default:false" json:"show_full_name"`   // Full name on the public profile and in people search
+	HideFromSearch bool      `gorm:"not null;default:false" json:"hide_from_search"` // Left out of people search
 	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
@@ -2,7 +2,7 @@ package repository
 
 import (
 	"context"
-
+	"errors"
 	"time"
 
 	"anoa.com/telkomalumiforum/internal/model"
@@ -10,12 +10,17 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrAttachmentUnavailable rejects attachment ids of a thread or post that
+// are not the user's own uploads for that context, or already used elsewhere.
+var ErrAttachmentUnavailable = errors.New("attachments must be your own unused uploads with the matching context")
+
 type AttachmentRepository interface {
 	Create(ctx context.Context, attachment *model.Attachment) error
-	UpdateThreadID(ctx context.Context, attachmentIDs []uint, threadID uuid.UUID, userID uuid.UUID) error
-	UpdatePostID(ctx context.Context, attachmentIDs []uint, postID uuid.UUID, userID uuid.UUID) error
+	FindByID(ctx context.Context, id uint) (*model.Attachment, error)
 	FindOrphans(ctx context.Context, cutoffTime time.Time) ([]model.Attachment, error)
 	Delete(ctx context.Context, id uint) error
+	FindByURLPrefix(ctx context.Context, prefix string, afterID uint, limit int) ([]model.Attachment, error)
+	UpdateFileURL(ctx context.Context, id uint, fileURL string) error
 }
 
 type attachmentRepository struct {
@@ -30,26 +35,62 @@ func (r *attachmentRepository) Create(ctx context.Context, attachment *model.Att
 	return r.db.WithContext(ctx).Create(attachment).Error
 }
 
-func (r *attachmentRepository) UpdateThreadID(ctx context.Context, attachmentIDs []uint, threadID uuid.UUID, userID uuid.UUID) error {
-	// Only allow updating if:
-	// 1. Owned by user (user_id = ?)
-	// 2. Not attached to another thread (thread_id IS NULL OR thread_id = ?)
-	// 3. Not attached to a post (post_id IS NULL)
-	return r.db.WithContext(ctx).Model(&model.Attachment{}).
-		Where("id IN ? AND user_id = ?", attachmentIDs, userID).
-		Where("(thread_id IS NULL OR thread_id = ?) AND post_id IS NULL", threadID).
-		Update("thread_id", threadID).Error
+func (r *attachmentRepository) FindByID(ctx context.Context, id uint) (*model.Attachment, error) {
+	var attachment model.Attachment
+	if err := r.db.WithContext(ctx).First(&attachment, id).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
 }
 
-func (r *attachmentRepository) UpdatePostID(ctx context.Context, attachmentIDs []uint, postID uuid.UUID, userID uuid.UUID) error {
-	// Only allow updating if:
-	// 1. Owned by user
-	// 2. Not attached to a thread (thread_id IS NULL)
-	// 3. Not attached to another post (post_id IS NULL OR post_id = ?)
-	return r.db.WithContext(ctx).Model(&model.Attachment{}).
-		Where("id IN ? AND user_id = ?", attachmentIDs, userID).
-		Where("thread_id IS NULL AND (post_id IS NULL OR post_id = ?)", postID).
-		Update("post_id", postID).Error
+// threadAttachable selects the attachments that may be attached to the
+// thread:
+// 1. Owned by user (user_id = ?)
+// 2. Not attached to another thread (thread_id IS NULL OR thread_id = ?)
+// 3. Not attached to a post (post_id IS NULL)
+// 4. Uploaded for a thread, under the thread allowlist
+func threadAttachable(attachmentIDs []uint, threadID uuid.UUID, userID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ? AND user_id = ?", attachmentIDs, userID).
+			Where("(thread_id IS NULL OR thread_id = ?) AND post_id IS NULL", threadID).
+			Where("context IN ?", []string{"thread", ""})
+	}
+}
+
+// postAttachable selects the attachments that may be attached to the post:
+// 1. Owned by user
+// 2. Not attached to a thread (thread_id IS NULL)
+// 3. Not attached to another post (post_id IS NULL OR post_id = ?)
+// 4. Uploaded for a post, under the post allowlist
+func postAttachable(attachmentIDs []uint, postID uuid.UUID, userID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ? AND user_id = ?", attachmentIDs, userID).
+			Where("thread_id IS NULL AND (post_id IS NULL OR post_id = ?)", postID).
+			Where("context IN ?", []string{"post", ""})
+	}
+}
+
+// attach links the attachments selected by scope to the thread or post, in
+// the caller's transaction, and fails with ErrAttachmentUnavailable unless
+// every distinct id was linked.
+func attach(tx *gorm.DB, attachmentIDs []uint, scope func(db *gorm.DB) *gorm.DB, column string, ownerID uuid.UUID) error {
+	if len(attachmentIDs) == 0 {
+		return nil
+	}
+
+	result := tx.Model(&model.Attachment{}).Scopes(scope).Update(column, ownerID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	distinct := make(map[uint]bool, len(attachmentIDs))
+	for _, id := range attachmentIDs {
+		distinct[id] = true
+	}
+	if result.RowsAffected < int64(len(distinct)) {
+		return ErrAttachmentUnavailable
+	}
+	return nil
 }
 
 func (r *attachmentRepository) FindOrphans(ctx context.Context, cutoffTime time.Time) ([]model.Attachment, error) {
@@ -63,3 +104,21 @@ func (r *attachmentRepository) FindOrphans(ctx context.Context, cutoffTime time.
 func (r *attachmentRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&model.Attachment{}, id).Error
 }
+
+// FindByURLPrefix pages, by id, through the attachments stored under a URL
+// prefix, i.e. on one storage backend.
+func (r *attachmentRepository) FindByURLPrefix(ctx context.Context, prefix string, afterID uint, limit int) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	err := r.db.WithContext(ctx).
+		Where("id > ? AND starts_with(file_url, ?)", afterID, prefix).
+		Order("id").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *attachmentRepository) UpdateFileURL(ctx context.Context, id uint, fileURL string) error {
+	return r.db.WithContext(ctx).Model(&model.Attachment{}).
+		Where("id = ?", id).
+		Update("file_url", fileURL).Error
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestCreateWithAttachments(t *testing.T) {
+	ctx := context.Background()
+	author, other := uuid.New(), uuid.New()
+	usedThread := uuid.New()
+
+	tests := []struct {
+		name    string
+		upload  model.Attachment
+		wantErr bool
+	}{
+		{"own upload", model.Attachment{UserID: author, Context: "thread"}, false},
+		{"legacy upload without context", model.Attachment{UserID: author}, false},
+		{"another user's upload", model.Attachment{UserID: other, Context: "thread"}, true},
+		{"upload for a post", model.Attachment{UserID: author, Context: "post"}, true},
+		{"upload used by another thread", model.Attachment{UserID: author, Context: "thread", ThreadID: &usedThread}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &model.Thread{}, &model.Attachment{})
+			threads := NewThreadRepository(db)
+			upload := tt.upload
+			upload.FileURL = "https://files.test/a.png"
+			if err := db.Create(&upload).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			thread := &model.Thread{UserID: author, Title: "t", Slug: "t", Content: "c", Audience: "semua"}
+			err := threads.Create(ctx, thread, []uint{upload.ID, upload.ID})
+			if tt.wantErr {
+				if !errors.Is(err, ErrAttachmentUnavailable) {
+					t.Fatalf("Create: error %v, want %v", err, ErrAttachmentUnavailable)
+				}
+				if n := countRows(t, db, &model.Thread{}); n != 0 {
+					t.Errorf("a bad attachment left %d threads behind", n)
+				}
+				if n := countRows(t, db, &model.SearchOutbox{}); n != 0 {
+					t.Errorf("a bad attachment left %d outbox rows behind", n)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			var got model.Attachment
+			db.First(&got, upload.ID)
+			if got.ThreadID == nil || *got.ThreadID != thread.ID {
+				t.Errorf("attachment thread = %v, want %s", got.ThreadID, thread.ID)
+			}
+		})
+	}
+}
+
+func TestCreatePostWithBadAttachment(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, &model.Thread{}, &model.Post{}, &model.Attachment{})
+	author := uuid.New()
+
+	thread := &model.Thread{UserID: author, Title: "t", Slug: "t", Content: "c", Audience: "semua"}
+	if err := NewThreadRepository(db).Create(ctx, thread, nil); err != nil {
+		t.Fatal(err)
+	}
+	upload := model.Attachment{UserID: author, Context: "thread", FileURL: "https://files.test/a.png"}
+	if err := db.Create(&upload).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	post := &model.Post{ThreadID: thread.ID, UserID: author, Content: "reply"}
+	err := NewPostRepository(db).Create(ctx, post, []uint{upload.ID})
+	if !errors.Is(err, ErrAttachmentUnavailable) {
+		t.Fatalf("Create: error %v, want %v", err, ErrAttachmentUnavailable)
+	}
+	if n := countRows(t, db, &model.Post{}); n != 0 {
+		t.Errorf("a bad attachment left %d posts behind", n)
+	}
+	var got model.Thread
+	db.First(&got, "id = ?", thread.ID)
+	if got.RepliesCount != 0 {
+		t.Errorf("replies_count = %d, want 0", got.RepliesCount)
+	}
+}
+
+func TestUpdateWithBadAttachment(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, &model.Thread{}, &model.Attachment{})
+	threads := NewThreadRepository(db)
+	author := uuid.New()
+
+	thread := &model.Thread{UserID: author, Title: "before", Slug: "t", Content: "c", Audience: "semua"}
+	if err := threads.Create(ctx, thread, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	thread.Title = "after"
+	if err := threads.Update(ctx, thread, []uint{42}); !errors.Is(err, ErrAttachmentUnavailable) {
+		t.Fatalf("Update: error %v, want %v", err, ErrAttachmentUnavailable)
+	}
+	var got model.Thread
+	db.First(&got, "id = ?", thread.ID)
+	if got.Title != "before" {
+		t.Errorf("title = %q, the edit was saved without its attachments", got.Title)
+	}
+}
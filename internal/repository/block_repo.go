@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BlockRepository interface {
+	Create(ctx context.Context, block *model.UserBlock) error
+	Delete(ctx context.Context, userID uuid.UUID, blockedID uuid.UUID) error
+	Count(ctx context.Context, userID uuid.UUID) (int64, error)
+	FindByUserID(ctx context.Context, userID uuid.UUID) ([]*model.UserBlock, error)
+	FindBlockedIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+type blockRepository struct {
+	db *gorm.DB
+}
+
+func NewBlockRepository(db *gorm.DB) BlockRepository {
+	return &blockRepository{db: db}
+}
+
+// Create keeps an existing block untouched, so blocking twice is a no-op.
+func (r *blockRepository) Create(ctx context.Context, block *model.UserBlock) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(block).Error
+}
+
+func (r *blockRepository) Delete(ctx context.Context, userID uuid.UUID, blockedID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Where("user_id = ? AND blocked_id = ?", userID, blockedID).
+		Delete(&model.UserBlock{}).Error
+}
+
+func (r *blockRepository) Count(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.UserBlock{}).
+		Where("user_id = ?", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// FindByUserID lists the user's blocks with the blocked users, newest first.
+func (r *blockRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]*model.UserBlock, error) {
+	var blocks []*model.UserBlock
+	err := r.db.WithContext(ctx).
+		Preload("Blocked").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&blocks).Error
+	return blocks, err
+}
+
+func (r *blockRepository) FindBlockedIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.WithContext(ctx).Model(&model.UserBlock{}).
+		Where("user_id = ?", userID).
+		Pluck("blocked_id", &ids).Error
+	return ids, err
+}
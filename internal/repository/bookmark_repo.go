@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BookmarkRepository interface {
+	Create(ctx context.Context, bookmark *model.Bookmark) error
+	FindByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*model.Bookmark, error)
+	FindExisting(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, postID *uuid.UUID) (*model.Bookmark, error)
+	FindByUserID(ctx context.Context, userID uuid.UUID, collectionID *uuid.UUID, offset, limit int) ([]*model.Bookmark, int64, error)
+	Update(ctx context.Context, bookmark *model.Bookmark) error
+	Delete(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+
+	CreateCollection(ctx context.Context, collection *model.BookmarkCollection) error
+	FindCollectionByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*model.BookmarkCollection, error)
+	FindCollectionByName(ctx context.Context, userID uuid.UUID, name string) (*model.BookmarkCollection, error)
+	FindCollectionsByUserID(ctx context.Context, userID uuid.UUID) ([]*model.BookmarkCollection, error)
+	CountByCollection(ctx context.Context, userID uuid.UUID) (map[uuid.UUID]int64, error)
+	UpdateCollection(ctx context.Context, collection *model.BookmarkCollection) error
+	DeleteCollection(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+}
+
+type bookmarkRepository struct {
+	db *gorm.DB
+}
+
+func NewBookmarkRepository(db *gorm.DB) BookmarkRepository {
+	return &bookmarkRepository{db: db}
+}
+
+// Create fails with gorm.ErrDuplicatedKey when the user already bookmarked
+// the thread or post, which the unique indexes on bookmarks enforce.
+func (r *bookmarkRepository) Create(ctx context.Context, bookmark *model.Bookmark) error {
+	err := r.db.WithContext(ctx).Create(bookmark).Error
+	if translator, ok := r.db.Dialector.(gorm.ErrorTranslator); ok && err != nil {
+		err = translator.Translate(err)
+	}
+	return err
+}
+
+// Every lookup is scoped by user so one user can never reach another user's bookmarks.
+func (r *bookmarkRepository) FindByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*model.Bookmark, error) {
+	var bookmark model.Bookmark
+	if err := r.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&bookmark).Error; err != nil {
+		return nil, err
+	}
+	return &bookmark, nil
+}
+
+func (r *bookmarkRepository) FindExisting(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, postID *uuid.UUID) (*model.Bookmark, error) {
+	var bookmark model.Bookmark
+	query := r.db.WithContext(ctx).Where("user_id = ? AND thread_id = ?", userID, threadID)
+	if postID != nil {
+		query = query.Where("post_id = ?", *postID)
+	} else {
+		query = query.Where("post_id IS NULL")
+	}
+	if err := query.First(&bookmark).Error; err != nil {
+		return nil, err
+	}
+	return &bookmark, nil
+}
+
+func (r *bookmarkRepository) FindByUserID(ctx context.Context, userID uuid.UUID, collectionID *uuid.UUID, offset, limit int) ([]*model.Bookmark, int64, error) {
+	var bookmarks []*model.Bookmark
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.Bookmark{}).Where("user_id = ?", userID)
+	if collectionID != nil {
+		query = query.Where("collection_id = ?", *collectionID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&bookmarks).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return bookmarks, total, nil
+}
+
+func (r *bookmarkRepository) Update(ctx context.Context, bookmark *model.Bookmark) error {
+	return r.db.WithContext(ctx).Save(bookmark).Error
+}
+
+func (r *bookmarkRepository) Delete(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&model.Bookmark{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *bookmarkRepository) CreateCollection(ctx context.Context, collection *model.BookmarkCollection) error {
+	return r.db.WithContext(ctx).Create(collection).Error
+}
+
+func (r *bookmarkRepository) FindCollectionByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*model.BookmarkCollection, error) {
+	var collection model.BookmarkCollection
+	if err := r.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&collection).Error; err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+func (r *bookmarkRepository) FindCollectionByName(ctx context.Context, userID uuid.UUID, name string) (*model.BookmarkCollection, error) {
+	var collection model.BookmarkCollection
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND name = ?", userID, name).
+		First(&collection).Error; err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+func (r *bookmarkRepository) FindCollectionsByUserID(ctx context.Context, userID uuid.UUID) ([]*model.BookmarkCollection, error) {
+	var collections []*model.BookmarkCollection
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("name ASC").
+		Find(&collections).Error
+	return collections, err
+}
+
+func (r *bookmarkRepository) CountByCollection(ctx context.Context, userID uuid.UUID) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		CollectionID uuid.UUID
+		Count        int64
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&model.Bookmark{}).
+		Select("collection_id, COUNT(*) AS count").
+		Where("user_id = ? AND collection_id IS NOT NULL", userID).
+		Group("collection_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CollectionID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *bookmarkRepository) UpdateCollection(ctx context.Context, collection *model.BookmarkCollection) error {
+	return r.db.WithContext(ctx).Save(collection).Error
+}
+
+// DeleteCollection removes the collection. Its bookmarks are kept and become
+// unsorted through the ON DELETE SET NULL constraint.
+func (r *bookmarkRepository) DeleteCollection(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&model.BookmarkCollection{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
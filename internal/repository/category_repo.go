@@ -14,6 +14,9 @@ type CategoryRepository interface {
 	FindByID(ctx context.Context, id uuid.UUID) (*model.Category, error)
 	FindAll(ctx context.Context, filter string) ([]*model.Category, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	FindBatch(ctx context.Context, after uuid.UUID, limit int) ([]*model.Category, error)
+	FindForSearch(ctx context.Context, ids []uuid.UUID) ([]*model.Category, error)
+	FindExistingIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
 }
 
 type categoryRepository struct {
@@ -25,7 +28,12 @@ func NewCategoryRepository(db *gorm.DB) CategoryRepository {
 }
 
 func (r *categoryRepository) Create(ctx context.Context, category *model.Category) error {
-	return r.db.WithContext(ctx).Create(category).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(category).Error; err != nil {
+			return err
+		}
+		return enqueueSearch(tx, model.SearchEntityCategory, category.ID)
+	})
 }
 
 func (r *categoryRepository) FindBySlug(ctx context.Context, slug string) (*model.Category, error) {
@@ -59,5 +67,43 @@ func (r *categoryRepository) FindAll(ctx context.Context, filter string) ([]*mod
 }
 
 func (r *categoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&model.Category{}, "id = ?", id).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&model.Category{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return enqueueSearch(tx, model.SearchEntityCategory, id)
+	})
+}
+
+// FindBatch walks every category in id order, limit rows after the given id.
+func (r *categoryRepository) FindBatch(ctx context.Context, after uuid.UUID, limit int) ([]*model.Category, error) {
+	var categories []*model.Category
+	err := r.db.WithContext(ctx).
+		Where("id > ?", after).
+		Order("id ASC").
+		Limit(limit).
+		Find(&categories).Error
+	return categories, err
+}
+
+// FindForSearch loads the given categories. Deleted ones are left out.
+func (r *categoryRepository) FindForSearch(ctx context.Context, ids []uuid.UUID) ([]*model.Category, error) {
+	var categories []*model.Category
+	if len(ids) == 0 {
+		return categories, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&categories).Error
+	return categories, err
+}
+
+// FindExistingIDs returns the ids that still have a category.
+func (r *categoryRepository) FindExistingIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	var existing []uuid.UUID
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	err := r.db.WithContext(ctx).Model(&model.Category{}).
+		Where("id IN ?", ids).
+		Pluck("id", &existing).Error
+	return existing, err
 }
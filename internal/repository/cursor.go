@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeysetCursor is the last row of a page in a listing ordered newest first
+// by (At, ID), or by (Score, At, ID) for score based sorts. At is the sort
+// timestamp of the row, which is not always created_at.
+type KeysetCursor struct {
+	Score int64
+	At    time.Time
+	ID    uuid.UUID
+}
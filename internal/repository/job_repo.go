@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"gorm.io/gorm"
+)
+
+type JobRepository interface {
+	Create(ctx context.Context, run *model.JobRun) error
+	Update(ctx context.Context, run *model.JobRun) error
+	FindAll(ctx context.Context, job string, status string, offset, limit int) ([]*model.JobRun, int64, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type jobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) JobRepository {
+	return &jobRepository{db: db}
+}
+
+func (r *jobRepository) Create(ctx context.Context, run *model.JobRun) error {
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+func (r *jobRepository) Update(ctx context.Context, run *model.JobRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
+
+// FindAll lists runs newest first. Empty job or status means any.
+func (r *jobRepository) FindAll(ctx context.Context, job string, status string, offset, limit int) ([]*model.JobRun, int64, error) {
+	var runs []*model.JobRun
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.JobRun{})
+	if job != "" {
+		query = query.Where("job = ?", job)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("started_at DESC").Offset(offset).Limit(limit).Find(&runs).Error
+	return runs, total, err
+}
+
+func (r *jobRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("started_at < ?", before).Delete(&model.JobRun{})
+	return res.RowsAffected, res.Error
+}
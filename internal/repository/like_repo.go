@@ -9,12 +9,20 @@ import (
 )
 
 type LikeRepository interface {
-	LikeThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error
+	ReactThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, reaction string) (bool, error)
 	UnlikeThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error
-	LikePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error
+	ReactPost(ctx context.Context, userID uuid.UUID, postID uuid.UUID, reaction string) (bool, error)
 	UnlikePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error
 	IsThreadLiked(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (bool, error)
 	IsPostLiked(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (bool, error)
+	CountThreadLikes(ctx context.Context, threadIDs []uuid.UUID) (map[uuid.UUID]int64, error)
+	CountPostLikes(ctx context.Context, postIDs []uuid.UUID) (map[uuid.UUID]int64, error)
+	FindLikedThreadIDs(ctx context.Context, userID uuid.UUID, threadIDs []uuid.UUID) (map[uuid.UUID]bool, error)
+	FindLikedPostIDs(ctx context.Context, userID uuid.UUID, postIDs []uuid.UUID) (map[uuid.UUID]bool, error)
+	FindThreadLikes(ctx context.Context, threadIDs []uuid.UUID) ([]model.ThreadLike, error)
+	FindPostLikes(ctx context.Context, postIDs []uuid.UUID) ([]model.PostLike, error)
+	FindLikedThreadIDsAfter(ctx context.Context, after uuid.UUID, limit int) ([]uuid.UUID, error)
+	FindLikedPostIDsAfter(ctx context.Context, after uuid.UUID, limit int) ([]uuid.UUID, error)
 }
 
 type likeRepository struct {
@@ -25,15 +33,33 @@ func NewLikeRepository(db *gorm.DB) LikeRepository {
 	return &likeRepository{db: db}
 }
 
-func (r *likeRepository) LikeThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error {
-	like := model.ThreadLike{
-		UserID:   userID,
-		ThreadID: threadID,
-	}
-	// Use Clause to ignore duplicate key error just in case
-	return r.db.WithContext(ctx).Clauses().Create(&like).Error
-	// Or standard Create, if duplicate it returns error, which is fine
-	// return r.db.WithContext(ctx).Create(&like).Error 
+// ReactThread stores the user's reaction on a thread, replacing any previous one.
+// It reports whether this is the user's first reaction on the thread.
+func (r *likeRepository) ReactThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, reaction string) (bool, error) {
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.ThreadLike{}).
+			Where("user_id = ? AND thread_id = ?", userID, threadID).
+			Update("reaction", reaction)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			return nil
+		}
+
+		like := model.ThreadLike{
+			UserID:   userID,
+			ThreadID: threadID,
+			Reaction: reaction,
+		}
+		if err := tx.Create(&like).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
 }
 
 func (r *likeRepository) UnlikeThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error {
@@ -42,12 +68,33 @@ func (r *likeRepository) UnlikeThread(ctx context.Context, userID uuid.UUID, thr
 		Delete(&model.ThreadLike{}).Error
 }
 
-func (r *likeRepository) LikePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error {
-	like := model.PostLike{
-		UserID: userID,
-		PostID: postID,
-	}
-	return r.db.WithContext(ctx).Create(&like).Error
+// ReactPost stores the user's reaction on a post, replacing any previous one.
+// It reports whether this is the user's first reaction on the post.
+func (r *likeRepository) ReactPost(ctx context.Context, userID uuid.UUID, postID uuid.UUID, reaction string) (bool, error) {
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.PostLike{}).
+			Where("user_id = ? AND post_id = ?", userID, postID).
+			Update("reaction", reaction)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			return nil
+		}
+
+		like := model.PostLike{
+			UserID:   userID,
+			PostID:   postID,
+			Reaction: reaction,
+		}
+		if err := tx.Create(&like).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
 }
 
 func (r *likeRepository) UnlikePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error {
@@ -79,3 +126,108 @@ func (r *likeRepository) IsPostLiked(ctx context.Context, userID uuid.UUID, post
 	}
 	return count > 0, nil
 }
+
+func (r *likeRepository) CountThreadLikes(ctx context.Context, threadIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	return r.countLikes(ctx, &model.ThreadLike{}, "thread_id", threadIDs)
+}
+
+func (r *likeRepository) CountPostLikes(ctx context.Context, postIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	return r.countLikes(ctx, &model.PostLike{}, "post_id", postIDs)
+}
+
+func (r *likeRepository) FindLikedThreadIDs(ctx context.Context, userID uuid.UUID, threadIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	return r.findLiked(ctx, &model.ThreadLike{}, "thread_id", userID, threadIDs)
+}
+
+func (r *likeRepository) FindLikedPostIDs(ctx context.Context, userID uuid.UUID, postIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	return r.findLiked(ctx, &model.PostLike{}, "post_id", userID, postIDs)
+}
+
+// countLikes groups the like rows of the given targets in a single query.
+// Targets without likes are absent from the result.
+func (r *likeRepository) countLikes(ctx context.Context, table interface{}, column string, ids []uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		TargetID uuid.UUID
+		Count    int64
+	}
+	err := r.db.WithContext(ctx).
+		Model(table).
+		Select(column+" AS target_id, COUNT(*) AS count").
+		Where(column+" IN ?", ids).
+		Group(column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.TargetID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *likeRepository) findLiked(ctx context.Context, table interface{}, column string, userID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	liked := make(map[uuid.UUID]bool, len(ids))
+	if len(ids) == 0 {
+		return liked, nil
+	}
+
+	var found []uuid.UUID
+	err := r.db.WithContext(ctx).
+		Model(table).
+		Where("user_id = ? AND "+column+" IN ?", userID, ids).
+		Pluck(column, &found).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range found {
+		liked[id] = true
+	}
+	return liked, nil
+}
+
+func (r *likeRepository) FindThreadLikes(ctx context.Context, threadIDs []uuid.UUID) ([]model.ThreadLike, error) {
+	var likes []model.ThreadLike
+	if len(threadIDs) == 0 {
+		return likes, nil
+	}
+	err := r.db.WithContext(ctx).Where("thread_id IN ?", threadIDs).Find(&likes).Error
+	return likes, err
+}
+
+func (r *likeRepository) FindPostLikes(ctx context.Context, postIDs []uuid.UUID) ([]model.PostLike, error) {
+	var likes []model.PostLike
+	if len(postIDs) == 0 {
+		return likes, nil
+	}
+	err := r.db.WithContext(ctx).Where("post_id IN ?", postIDs).Find(&likes).Error
+	return likes, err
+}
+
+// FindLikedThreadIDsAfter walks every thread with at least one like in id
+// order. Pass uuid.Nil to start from the beginning.
+func (r *likeRepository) FindLikedThreadIDsAfter(ctx context.Context, after uuid.UUID, limit int) ([]uuid.UUID, error) {
+	return r.findTargetsAfter(ctx, &model.ThreadLike{}, "thread_id", after, limit)
+}
+
+func (r *likeRepository) FindLikedPostIDsAfter(ctx context.Context, after uuid.UUID, limit int) ([]uuid.UUID, error) {
+	return r.findTargetsAfter(ctx, &model.PostLike{}, "post_id", after, limit)
+}
+
+func (r *likeRepository) findTargetsAfter(ctx context.Context, table interface{}, column string, after uuid.UUID, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.WithContext(ctx).
+		Model(table).
+		Distinct(column).
+		Where(column+" > ?", after).
+		Order(column).
+		Limit(limit).
+		Pluck(column, &ids).Error
+	return ids, err
+}
@@ -10,6 +10,8 @@ import (
 type MenfessRepository interface {
 	Create(ctx context.Context, menfess *model.Menfess) error
 	FindAll(ctx context.Context, offset, limit int) ([]*model.Menfess, int64, error)
+	FindPage(ctx context.Context, after *KeysetCursor, limit int) ([]*model.Menfess, error)
+	Count(ctx context.Context) (int64, error)
 }
 
 type menfessRepository struct {
@@ -42,3 +44,22 @@ func (r *menfessRepository) FindAll(ctx context.Context, offset, limit int) ([]*
 
 	return menfesses, total, nil
 }
+
+// FindPage returns up to limit menfess older than the cursor.
+func (r *menfessRepository) FindPage(ctx context.Context, after *KeysetCursor, limit int) ([]*model.Menfess, error) {
+	var menfesses []*model.Menfess
+
+	query := r.db.WithContext(ctx)
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.At, after.ID)
+	}
+
+	err := query.Order("created_at DESC").Order("id DESC").Limit(limit).Find(&menfesses).Error
+	return menfesses, err
+}
+
+func (r *menfessRepository) Count(ctx context.Context) (int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&model.Menfess{}).Count(&total).Error
+	return total, err
+}
@@ -1,17 +1,23 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"anoa.com/telkomalumiforum/internal/model"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type NotificationRepository interface {
 	Create(notification *model.Notification) error
-	GetByUserID(userID uuid.UUID, limit, offset int) ([]model.Notification, error)
+	Aggregate(notification *model.Notification, summarize func(count int) string) (*model.Notification, error)
+	GetByUserID(userID uuid.UUID, audiences []string, limit, offset int) ([]model.Notification, error)
+	GetByUserIDAfter(userID uuid.UUID, audiences []string, after *KeysetCursor, limit int) ([]model.Notification, error)
 	MarkAsRead(id uuid.UUID) error
 	MarkAllAsRead(userID uuid.UUID) error
-	CountUnread(userID uuid.UUID) (int64, error)
+	CountUnread(userID uuid.UUID, audiences []string) (int64, error)
 }
 
 type notificationRepository struct {
@@ -26,9 +32,107 @@ func (r *notificationRepository) Create(notification *model.Notification) error
 	return r.db.Create(notification).Error
 }
 
-func (r *notificationRepository) GetByUserID(userID uuid.UUID, limit, offset int) ([]model.Notification, error) {
+// aggregatedNotifications are the unread reaction notifications, of which a
+// recipient has at most one per entity and type.
+const aggregatedNotifications = "is_read = false AND type IN ('like_thread', 'like_post')"
+
+// NotificationAggregateIndex creates the index that keeps Aggregate from
+// inserting a second notification when two reactions arrive together.
+const NotificationAggregateIndex = "CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_aggregate ON notifications (user_id, entity_id, type) WHERE " + aggregatedNotifications
+
+// Aggregate inserts the notification, or folds it into the recipient's
+// unread notification of the same type for the same entity, whose row is
+// locked meanwhile. summarize builds the message for the number of distinct
+// actors. It returns the notification as stored.
+func (r *notificationRepository) Aggregate(notification *model.Notification, summarize func(count int) string) (*model.Notification, error) {
+	var stored *model.Notification
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for {
+			result := tx.Clauses(clause.OnConflict{
+				Columns:     []clause.Column{{Name: "user_id"}, {Name: "entity_id"}, {Name: "type"}},
+				TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: aggregatedNotifications}}},
+				DoNothing:   true,
+			}).Create(notification)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				stored = notification
+				_, err := addActor(tx, notification.ID, notification.ActorID)
+				return err
+			}
+
+			var existing model.Notification
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ? AND entity_id = ? AND type = ? AND is_read = ?", notification.UserID, notification.EntityID, notification.Type, false).
+				First(&existing).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Read since the insert gave way; insert again
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			// Notifications aggregated before actors were recorded know only
+			// their latest actor
+			if _, err := addActor(tx, existing.ID, existing.ActorID); err != nil {
+				return err
+			}
+			count, err := addActor(tx, existing.ID, notification.ActorID)
+			if err != nil {
+				return err
+			}
+
+			existing.ActorCount = int(count)
+			existing.ActorID = notification.ActorID
+			existing.Message = summarize(existing.ActorCount)
+			existing.CreatedAt = time.Now()
+			stored = &existing
+			return tx.Save(&existing).Error
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// addActor records the actor of an aggregated notification, once, and
+// returns how many distinct actors it has.
+func addActor(tx *gorm.DB, notificationID uuid.UUID, actorID uuid.UUID) (int64, error) {
+	actor := model.NotificationActor{NotificationID: notificationID, ActorID: actorID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&actor).Error; err != nil {
+		return 0, err
+	}
+	var count int64
+	err := tx.Model(&model.NotificationActor{}).Where("notification_id = ?", notificationID).Count(&count).Error
+	return count, err
+}
+
+// visibleNotifications keeps the notifications of userID about threads, or
+// posts in threads, the user may still see: those of the audiences listed
+// (all when nil) and the user's own.
+func visibleNotifications(userID uuid.UUID, audiences []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("notifications.user_id = ?", userID)
+		if audiences == nil {
+			return db
+		}
+		return db.Where(`EXISTS (
+			SELECT 1 FROM threads t
+			WHERE t.id = CASE notifications.entity_type
+				WHEN 'post' THEN (SELECT p.thread_id FROM posts p WHERE p.id = notifications.entity_id)
+				ELSE notifications.entity_id
+			END
+			AND (t.user_id = ? OR t.audience IN ?)
+		)`, userID, audiences)
+	}
+}
+
+func (r *notificationRepository) GetByUserID(userID uuid.UUID, audiences []string, limit, offset int) ([]model.Notification, error) {
 	var notifications []model.Notification
-	err := r.db.Where("user_id = ?", userID).
+	err := r.db.Scopes(visibleNotifications(userID, audiences)).
 		Order("created_at desc").
 		Limit(limit).
 		Offset(offset).
@@ -39,6 +143,24 @@ func (r *notificationRepository) GetByUserID(userID uuid.UUID, limit, offset int
 	return notifications, err
 }
 
+// GetByUserIDAfter returns up to limit notifications older than the cursor.
+func (r *notificationRepository) GetByUserIDAfter(userID uuid.UUID, audiences []string, after *KeysetCursor, limit int) ([]model.Notification, error) {
+	var notifications []model.Notification
+	query := r.db.Scopes(visibleNotifications(userID, audiences))
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.At, after.ID)
+	}
+	err := query.
+		Order("created_at desc").
+		Order("id desc").
+		Limit(limit).
+		Preload("Actor", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "username", "avatar_url")
+		}).
+		Find(&notifications).Error
+	return notifications, err
+}
+
 func (r *notificationRepository) MarkAsRead(id uuid.UUID) error {
 	return r.db.Model(&model.Notification{}).Where("id = ?", id).Update("is_read", true).Error
 }
@@ -47,8 +169,8 @@ func (r *notificationRepository) MarkAllAsRead(userID uuid.UUID) error {
 	return r.db.Model(&model.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Update("is_read", true).Error
 }
 
-func (r *notificationRepository) CountUnread(userID uuid.UUID) (int64, error) {
+func (r *notificationRepository) CountUnread(userID uuid.UUID, audiences []string) (int64, error) {
 	var count int64
-	err := r.db.Model(&model.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count).Error
+	err := r.db.Model(&model.Notification{}).Scopes(visibleNotifications(userID, audiences)).Where("is_read = ?", false).Count(&count).Error
 	return count, err
 }
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func newNotificationDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := newTestDB(t)
+	// Notification ids default to gen_random_uuid(), which SQLite does not know
+	for _, stmt := range []string{
+		`CREATE TABLE notifications (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			actor_id TEXT NOT NULL,
+			entity_id TEXT NOT NULL,
+			entity_slug TEXT,
+			entity_type TEXT NOT NULL,
+			type TEXT NOT NULL,
+			message TEXT,
+			actor_count INTEGER DEFAULT 1,
+			is_read NUMERIC DEFAULT false,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE notification_actors (
+			notification_id TEXT,
+			actor_id TEXT,
+			PRIMARY KEY (notification_id, actor_id)
+		)`,
+		NotificationAggregateIndex,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("create notifications: %v", err)
+		}
+	}
+	return db
+}
+
+func TestAggregateNotification(t *testing.T) {
+	db := newNotificationDB(t)
+	repo := NewNotificationRepository(db)
+	author, thread := uuid.New(), uuid.New()
+	summarize := func(count int) string { return fmt.Sprintf("%d people reacted", count) }
+
+	react := func(actor uuid.UUID) *model.Notification {
+		t.Helper()
+		stored, err := repo.Aggregate(&model.Notification{
+			ID:         uuid.New(),
+			UserID:     author,
+			ActorID:    actor,
+			EntityID:   thread,
+			EntityType: "thread",
+			Type:       "like_thread",
+			Message:    "Someone reacted",
+			ActorCount: 1,
+		}, summarize)
+		if err != nil {
+			t.Fatalf("Aggregate: %v", err)
+		}
+		return stored
+	}
+
+	alice, bob := uuid.New(), uuid.New()
+	first := react(alice)
+	if first.ActorCount != 1 || first.Message != "Someone reacted" {
+		t.Errorf("first notification %+v, want one actor", first)
+	}
+
+	// Another actor, then the same actor again, fold into it
+	second := react(bob)
+	third := react(bob)
+	for _, n := range []*model.Notification{second, third} {
+		if n.ID != first.ID || n.ActorCount != 2 || n.Message != "2 people reacted" || n.ActorID != bob {
+			t.Errorf("aggregated %+v, want the first notification with 2 actors", n)
+		}
+	}
+	if n := countRows(t, db, &model.Notification{}); n != 1 {
+		t.Fatalf("%d notifications, want 1", n)
+	}
+
+	// Once read, a new reaction starts a new notification
+	if err := repo.MarkAsRead(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	fresh := react(alice)
+	if fresh.ID == first.ID || fresh.ActorCount != 1 {
+		t.Errorf("after reading, %+v, want a new notification", fresh)
+	}
+	if n := countRows(t, db, &model.Notification{}); n != 2 {
+		t.Errorf("%d notifications, want 2", n)
+	}
+}
+
+func TestAggregateIndex(t *testing.T) {
+	db := newNotificationDB(t)
+	repo := NewNotificationRepository(db)
+	author, thread := uuid.New(), uuid.New()
+
+	notification := func(notifType string) *model.Notification {
+		return &model.Notification{ID: uuid.New(), UserID: author, ActorID: uuid.New(), EntityID: thread, EntityType: "thread", Type: notifType}
+	}
+
+	// A second unread reaction notification cannot be inserted beside the
+	// first, which is what stops concurrent reactions from racing
+	if err := repo.Create(notification("like_thread")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Create(notification("like_thread")); err == nil {
+		t.Error("second unread like_thread notification was inserted")
+	}
+
+	// Replies are not aggregated
+	for i := 0; i < 2; i++ {
+		if err := repo.Create(notification("reply_thread")); err != nil {
+			t.Errorf("reply notification %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PollRepository interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*model.Poll, error)
+	FindByThreadID(ctx context.Context, threadID uuid.UUID) (*model.Poll, error)
+	FindVotes(ctx context.Context, pollID uuid.UUID) ([]model.PollVote, error)
+	SaveVotes(ctx context.Context, pollID uuid.UUID, userID uuid.UUID, optionIDs []uuid.UUID) error
+	BackfillBallots(ctx context.Context) error
+	Close(ctx context.Context, id uuid.UUID, closedAt time.Time) error
+}
+
+type pollRepository struct {
+	db *gorm.DB
+}
+
+func NewPollRepository(db *gorm.DB) PollRepository {
+	return &pollRepository{db: db}
+}
+
+func (r *pollRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Poll, error) {
+	var poll model.Poll
+	if err := r.db.WithContext(ctx).
+		Preload("Options", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Where("id = ?", id).
+		First(&poll).Error; err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func (r *pollRepository) FindByThreadID(ctx context.Context, threadID uuid.UUID) (*model.Poll, error) {
+	var poll model.Poll
+	if err := r.db.WithContext(ctx).
+		Preload("Options", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Where("thread_id = ?", threadID).
+		First(&poll).Error; err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func (r *pollRepository) FindVotes(ctx context.Context, pollID uuid.UUID) ([]model.PollVote, error) {
+	var votes []model.PollVote
+	err := r.db.WithContext(ctx).
+		Select("poll_id", "user_id", "option_id").
+		Where("poll_id = ?", pollID).
+		Find(&votes).Error
+	return votes, err
+}
+
+// SaveVotes stores a ballot and its options together. A user has one ballot
+// per poll; when it already exists the task is a replay, or a second vote
+// that slipped past Redis, and what is stored is kept.
+func (r *pollRepository) SaveVotes(ctx context.Context, pollID uuid.UUID, userID uuid.UUID, optionIDs []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ballot := model.PollBallot{PollID: pollID, UserID: userID}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&ballot)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		votes := make([]model.PollVote, 0, len(optionIDs))
+		for _, optionID := range optionIDs {
+			votes = append(votes, model.PollVote{
+				PollID:   pollID,
+				UserID:   userID,
+				OptionID: optionID,
+			})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&votes).Error
+	})
+}
+
+// BackfillBallots gives every user who voted before ballots existed theirs.
+func (r *pollRepository) BackfillBallots(ctx context.Context) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO poll_ballots (poll_id, user_id, created_at)
+		SELECT poll_id, user_id, MIN(created_at) FROM poll_votes
+		GROUP BY poll_id, user_id
+		ON CONFLICT DO NOTHING`).Error
+}
+
+func (r *pollRepository) Close(ctx context.Context, id uuid.UUID, closedAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&model.Poll{}).
+		Where("id = ? AND closed_at IS NULL", id).
+		Update("closed_at", closedAt).Error
+}
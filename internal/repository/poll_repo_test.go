@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestSaveVotesOneBallot(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, &model.Poll{}, &model.PollOption{}, &model.PollVote{}, &model.PollBallot{})
+	polls := NewPollRepository(db)
+
+	poll := &model.Poll{
+		ThreadID:       uuid.New(),
+		Question:       "Reuni kapan?",
+		MultipleChoice: true,
+		Options:        []model.PollOption{{Text: "Juni", Position: 0}, {Text: "Juli", Position: 1}, {Text: "Agustus", Position: 2}},
+	}
+	if err := db.Create(poll).Error; err != nil {
+		t.Fatal(err)
+	}
+	june, july, august := poll.Options[0].ID, poll.Options[1].ID, poll.Options[2].ID
+	voter, other := uuid.New(), uuid.New()
+
+	optionsOf := func(user uuid.UUID) []uuid.UUID {
+		t.Helper()
+		votes, err := polls.FindVotes(ctx, poll.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []uuid.UUID
+		for _, v := range votes {
+			if v.UserID == user {
+				ids = append(ids, v.OptionID)
+			}
+		}
+		slices.SortFunc(ids, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+		return ids
+	}
+
+	if err := polls.SaveVotes(ctx, poll.ID, voter, []uuid.UUID{june, july}); err != nil {
+		t.Fatalf("SaveVotes: %v", err)
+	}
+	// A replay, and a second ballot with other options, keep the first
+	for _, options := range [][]uuid.UUID{{june, july}, {august}} {
+		if err := polls.SaveVotes(ctx, poll.ID, voter, options); err != nil {
+			t.Fatalf("SaveVotes again: %v", err)
+		}
+	}
+	if err := polls.SaveVotes(ctx, poll.ID, other, []uuid.UUID{august}); err != nil {
+		t.Fatalf("SaveVotes of another user: %v", err)
+	}
+
+	if got := optionsOf(voter); !slices.Equal(got, []uuid.UUID{june, july}) {
+		t.Errorf("voter's options %v, want the first ballot [%s %s]", got, june, july)
+	}
+	if got := optionsOf(other); !slices.Equal(got, []uuid.UUID{august}) {
+		t.Errorf("other voter's options %v, want [%s]", got, august)
+	}
+	if n := countRows(t, db, &model.PollBallot{}); n != 2 {
+		t.Errorf("%d ballots, want 2", n)
+	}
+}
+
+func TestBackfillBallots(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, &model.Poll{}, &model.PollOption{}, &model.PollVote{}, &model.PollBallot{})
+	polls := NewPollRepository(db)
+
+	pollID, voter := uuid.New(), uuid.New()
+	for _, option := range []uuid.UUID{uuid.New(), uuid.New()} {
+		if err := db.Create(&model.PollVote{PollID: pollID, UserID: voter, OptionID: option}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := polls.BackfillBallots(ctx); err != nil {
+			t.Fatalf("BackfillBallots: %v", err)
+		}
+	}
+	if n := countRows(t, db, &model.PollBallot{}); n != 1 {
+		t.Errorf("%d ballots, want 1 for the voter", n)
+	}
+	// The backfilled ballot stops a later vote
+	if err := polls.SaveVotes(ctx, pollID, voter, []uuid.UUID{uuid.New()}); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, db, &model.PollVote{}); n != 2 {
+		t.Errorf("%d votes, want the 2 already cast", n)
+	}
+}
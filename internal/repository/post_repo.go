@@ -2,19 +2,39 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"anoa.com/telkomalumiforum/internal/model"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrPostParentDeleted refuses to restore a reply while the post it answers
+// is still deleted.
+var ErrPostParentDeleted = errors.New("the post this reply answers is deleted")
+
 type PostRepository interface {
-	Create(ctx context.Context, post *model.Post) error
+	Create(ctx context.Context, post *model.Post, attachmentIDs []uint) error
 	FindByID(ctx context.Context, id uuid.UUID) (*model.Post, error)
+	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Post, error)
 	FindByThreadID(ctx context.Context, threadID uuid.UUID, offset, limit int) ([]*model.Post, int64, error)
-	FindAllByThreadID(ctx context.Context, threadID uuid.UUID) ([]*model.Post, error)
-	Update(ctx context.Context, post *model.Post) error
+	FindRootsByThreadID(ctx context.Context, threadID uuid.UUID, offset, limit int) ([]*model.Post, int64, error)
+	FindChildren(ctx context.Context, parentIDs []uuid.UUID, perParent int) ([]*model.Post, error)
+	FindChildrenAfter(ctx context.Context, parentID uuid.UUID, after *PostCursor, limit int) ([]*model.Post, error)
+	CountChildren(ctx context.Context, parentIDs []uuid.UUID) (map[uuid.UUID]int64, error)
+	FindFirstAfter(ctx context.Context, threadID uuid.UUID, excludeUserID uuid.UUID, after time.Time, exceptIDs []uuid.UUID) (*model.Post, error)
+	FindRoot(ctx context.Context, postID uuid.UUID) (*model.Post, error)
+	CountRootsBefore(ctx context.Context, root *model.Post) (int64, error)
+	CountBefore(ctx context.Context, post *model.Post) (int64, error)
+	FindBatch(ctx context.Context, after uuid.UUID, limit int) ([]*model.Post, error)
+	FindForSearch(ctx context.Context, ids []uuid.UUID) ([]*model.Post, error)
+	FindExistingIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
+	Update(ctx context.Context, post *model.Post, attachmentIDs []uint) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	Restore(ctx context.Context, id uuid.UUID) error
+	FindDeletedByID(ctx context.Context, id uuid.UUID) (*model.Post, error)
 }
 
 type postRepository struct {
@@ -25,27 +45,49 @@ func NewPostRepository(db *gorm.DB) PostRepository {
 	return &postRepository{db: db}
 }
 
-func (r *postRepository) Create(ctx context.Context, post *model.Post) error {
+// Create inserts the post, attaches the author's uploads to it and counts it
+// on the thread, all or nothing.
+func (r *postRepository) Create(ctx context.Context, post *model.Post, attachmentIDs []uint) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the thread first, in the same order as Delete
+		if err := lockThread(tx, post.ThreadID); err != nil {
+			return err
+		}
+
 		if err := tx.Create(post).Error; err != nil {
 			return err
 		}
-		// Increment thread replies_count
+		if err := attach(tx, attachmentIDs, postAttachable(attachmentIDs, post.ID, post.UserID), "post_id", post.ID); err != nil {
+			return err
+		}
+
 		if err := tx.Model(&model.Thread{}).Where("id = ?", post.ThreadID).
-			UpdateColumn("replies_count", gorm.Expr("replies_count + ?", 1)).Error; err != nil {
+			UpdateColumns(map[string]interface{}{
+				"replies_count":      gorm.Expr("replies_count + ?", 1),
+				"last_reply_at":      post.CreatedAt,
+				"last_reply_user_id": post.UserID,
+			}).Error; err != nil {
 			return err
 		}
-		return nil
+
+		return enqueueSearch(tx, model.SearchEntityPost, post.ID)
 	})
 }
 
+func lockThread(tx *gorm.DB, threadID uuid.UUID) error {
+	var thread model.Thread
+	return tx.Clauses(clause.Locking{Strength: "NO KEY UPDATE"}).
+		Select("id").
+		First(&thread, "id = ?", threadID).Error
+}
+
 func (r *postRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Post, error) {
 	var post model.Post
 	if err := r.db.WithContext(ctx).
 		Preload("User").
 		Preload("User.Profile").
 		Preload("Attachments").
-		Preload("Parent"). 
+		Preload("Parent").
 		Where("id = ?", id).
 		First(&post).Error; err != nil {
 		return nil, err
@@ -53,10 +95,36 @@ func (r *postRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.Pos
 	return &post, nil
 }
 
+// FindDeletedByID finds a post that was deleted, for restoring it.
+func (r *postRepository) FindDeletedByID(ctx context.Context, id uuid.UUID) (*model.Post, error) {
+	var post model.Post
+	if err := r.db.WithContext(ctx).Unscoped().
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&post).Error; err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (r *postRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Post, error) {
+	var posts []*model.Post
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	if err := r.db.WithContext(ctx).
+		Preload("User").
+		Where("id IN ?", ids).
+		Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
 func (r *postRepository) FindByThreadID(ctx context.Context, threadID uuid.UUID, offset, limit int) ([]*model.Post, int64, error) {
 	var posts []*model.Post
 	var total int64
-	
+
 	query := r.db.WithContext(ctx).
 		Preload("User").
 		Preload("User.Profile").
@@ -67,31 +135,29 @@ func (r *postRepository) FindByThreadID(ctx context.Context, threadID uuid.UUID,
 		return nil, 0, err
 	}
 
-	if err := query.Order("created_at ASC").Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
+	if err := query.Order("created_at ASC").Order("id ASC").Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return posts, total, nil
 }
 
-func (r *postRepository) FindAllByThreadID(ctx context.Context, threadID uuid.UUID) ([]*model.Post, error) {
-	var posts []*model.Post
-	
-	err := r.db.WithContext(ctx).
-		Preload("User").
-		Preload("User.Profile").
-		Preload("Attachments").
-		Where("thread_id = ?", threadID).
-		Order("created_at ASC").
-		Find(&posts).Error
-		
-	return posts, err
-}
-
-func (r *postRepository) Update(ctx context.Context, post *model.Post) error {
-	return r.db.WithContext(ctx).Save(post).Error
+// Update saves an edited post and attaches the uploads listed, together.
+func (r *postRepository) Update(ctx context.Context, post *model.Post, attachmentIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(post).Error; err != nil {
+			return err
+		}
+		if err := attach(tx, attachmentIDs, postAttachable(attachmentIDs, post.ID, post.UserID), "post_id", post.ID); err != nil {
+			return err
+		}
+		return enqueueSearch(tx, model.SearchEntityPost, post.ID)
+	})
 }
 
+// Delete soft-deletes a post together with its reply subtree and takes all
+// of them off the thread's counters. Replies deleted before keep their own
+// deletion time, so restoring this post leaves them deleted.
 func (r *postRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Get post to find ThreadID
@@ -100,15 +166,306 @@ func (r *postRepository) Delete(ctx context.Context, id uuid.UUID) error {
 			return err
 		}
 
-		if err := tx.Delete(&model.Post{}, id).Error; err != nil {
+		if err := lockThread(tx, post.ThreadID); err != nil {
 			return err
 		}
 
-		// Decrement thread replies_count
-		if err := tx.Model(&model.Thread{}).Where("id = ?", post.ThreadID).
-			UpdateColumn("replies_count", gorm.Expr("replies_count - ?", 1)).Error; err != nil {
+		var removed []uuid.UUID
+		if err := tx.Raw(`
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM posts WHERE id = ? AND deleted_at IS NULL
+				UNION ALL
+				SELECT p.id FROM posts p JOIN subtree s ON p.parent_id = s.id
+				WHERE p.deleted_at IS NULL
+			)
+			SELECT id FROM subtree
+		`, id).Scan(&removed).Error; err != nil {
+			return err
+		}
+
+		// One timestamp for the whole subtree is what Restore matches on
+		if err := tx.Model(&model.Post{}).Where("id IN ?", removed).
+			Update("deleted_at", time.Now().Truncate(time.Microsecond)).Error; err != nil {
+			return err
+		}
+
+		if err := enqueueSearch(tx, model.SearchEntityPost, removed...); err != nil {
+			return err
+		}
+		return updateReplyStats(tx, post.ThreadID, -len(removed))
+	})
+}
+
+// Restore brings a deleted post back with the replies deleted along with it
+// and counts them on the thread again. A reply whose parent is still deleted
+// cannot be restored on its own.
+func (r *postRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var post model.Post
+		if err := tx.Unscoped().Select("id", "thread_id", "parent_id", "deleted_at").
+			Where("deleted_at IS NOT NULL").
+			First(&post, id).Error; err != nil {
+			return err
+		}
+
+		if err := lockThread(tx, post.ThreadID); err != nil {
+			return err
+		}
+
+		if post.ParentID != nil {
+			var live int64
+			if err := tx.Model(&model.Post{}).Where("id = ?", *post.ParentID).Count(&live).Error; err != nil {
 				return err
+			}
+			if live == 0 {
+				return ErrPostParentDeleted
+			}
+		}
+
+		var restored []uuid.UUID
+		if err := tx.Raw(`
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM posts WHERE id = ?
+				UNION ALL
+				SELECT p.id FROM posts p JOIN subtree s ON p.parent_id = s.id
+				WHERE p.deleted_at = ?
+			)
+			SELECT id FROM subtree
+		`, id, post.DeletedAt.Time).Scan(&restored).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&model.Post{}).Where("id IN ?", restored).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
 		}
-		return nil
+
+		if err := enqueueSearch(tx, model.SearchEntityPost, restored...); err != nil {
+			return err
+		}
+		return updateReplyStats(tx, post.ThreadID, len(restored))
 	})
 }
+
+// updateReplyStats moves the thread's reply count by delta and takes its last
+// reply from the posts that are left, which deletes and restores may change.
+func updateReplyStats(tx *gorm.DB, threadID uuid.UUID, delta int) error {
+	return tx.Exec(`
+		UPDATE threads
+		SET replies_count = CASE WHEN replies_count + ? < 0 THEN 0 ELSE replies_count + ? END,
+			last_reply_at = (
+				SELECT created_at FROM posts WHERE thread_id = threads.id AND deleted_at IS NULL
+				ORDER BY created_at DESC, id DESC LIMIT 1
+			),
+			last_reply_user_id = (
+				SELECT user_id FROM posts WHERE thread_id = threads.id AND deleted_at IS NULL
+				ORDER BY created_at DESC, id DESC LIMIT 1
+			)
+		WHERE id = ?
+	`, delta, delta, threadID).Error
+}
+
+// PostCursor is a keyset position in chronological (created_at, id) order.
+type PostCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (r *postRepository) FindRootsByThreadID(ctx context.Context, threadID uuid.UUID, offset, limit int) ([]*model.Post, int64, error) {
+	var posts []*model.Post
+	var total int64
+
+	query := r.db.WithContext(ctx).
+		Preload("User").
+		Preload("User.Profile").
+		Preload("Attachments").
+		Where("thread_id = ? AND parent_id IS NULL", threadID)
+
+	if err := query.Model(&model.Post{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("created_at ASC").Order("id ASC").Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return posts, total, nil
+}
+
+// FindChildren returns up to perParent oldest direct replies of each parent,
+// ordered by parent and then chronologically.
+func (r *postRepository) FindChildren(ctx context.Context, parentIDs []uuid.UUID, perParent int) ([]*model.Post, error) {
+	var posts []*model.Post
+	if len(parentIDs) == 0 || perParent <= 0 {
+		return posts, nil
+	}
+
+	var ids []uuid.UUID
+	if err := r.db.WithContext(ctx).Raw(`
+		SELECT id FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY created_at ASC, id ASC) AS rn
+			FROM posts
+			WHERE parent_id IN ? AND deleted_at IS NULL
+		) ranked
+		WHERE rn <= ?
+	`, parentIDs, perParent).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Preload("User.Profile").
+		Preload("Attachments").
+		Where("id IN ?", ids).
+		Order("parent_id").Order("created_at ASC").Order("id ASC").
+		Find(&posts).Error
+	return posts, err
+}
+
+func (r *postRepository) FindChildrenAfter(ctx context.Context, parentID uuid.UUID, after *PostCursor, limit int) ([]*model.Post, error) {
+	var posts []*model.Post
+
+	query := r.db.WithContext(ctx).
+		Preload("User").
+		Preload("User.Profile").
+		Preload("Attachments").
+		Where("parent_id = ?", parentID)
+
+	if after != nil {
+		query = query.Where("(created_at, id) > (?, ?)", after.CreatedAt, after.ID)
+	}
+
+	err := query.Order("created_at ASC").Order("id ASC").Limit(limit).Find(&posts).Error
+	return posts, err
+}
+
+func (r *postRepository) CountChildren(ctx context.Context, parentIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(parentIDs))
+	if len(parentIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ParentID uuid.UUID
+		Count    int64
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&model.Post{}).
+		Select("parent_id, COUNT(*) AS count").
+		Where("parent_id IN ?", parentIDs).
+		Group("parent_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.ParentID] = row.Count
+	}
+	return counts, nil
+}
+
+// FindFirstAfter returns the oldest post in the thread written by someone
+// other than excludeUserID after the given time, skipping exceptIDs.
+func (r *postRepository) FindFirstAfter(ctx context.Context, threadID uuid.UUID, excludeUserID uuid.UUID, after time.Time, exceptIDs []uuid.UUID) (*model.Post, error) {
+	var post model.Post
+	query := r.db.WithContext(ctx).
+		Where("thread_id = ? AND user_id <> ? AND created_at > ?", threadID, excludeUserID, after)
+	if len(exceptIDs) > 0 {
+		query = query.Where("id NOT IN ?", exceptIDs)
+	}
+	if err := query.
+		Order("created_at ASC").Order("id ASC").
+		First(&post).Error; err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// FindRoot walks up the reply chain to the root post.
+func (r *postRepository) FindRoot(ctx context.Context, postID uuid.UUID) (*model.Post, error) {
+	var post model.Post
+	if err := r.db.WithContext(ctx).Raw(`
+		WITH RECURSIVE ancestors AS (
+			SELECT * FROM posts WHERE id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT p.* FROM posts p JOIN ancestors a ON p.id = a.parent_id
+			WHERE p.deleted_at IS NULL
+		)
+		SELECT * FROM ancestors WHERE parent_id IS NULL LIMIT 1
+	`, postID).Scan(&post).Error; err != nil {
+		return nil, err
+	}
+	if post.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &post, nil
+}
+
+func (r *postRepository) CountRootsBefore(ctx context.Context, root *model.Post) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Post{}).
+		Where("thread_id = ? AND parent_id IS NULL", root.ThreadID).
+		Where("(created_at, id) < (?, ?)", root.CreatedAt, root.ID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *postRepository) CountBefore(ctx context.Context, post *model.Post) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Post{}).
+		Where("thread_id = ?", post.ThreadID).
+		Where("(created_at, id) < (?, ?)", post.CreatedAt, post.ID).
+		Count(&count).Error
+	return count, err
+}
+
+// searchRows preloads what the search documents need.
+func (r *postRepository) searchRows(ctx context.Context) *gorm.DB {
+	// Soft-deleted posts stay in the index, marked hidden
+	return r.db.WithContext(ctx).Unscoped().
+		Preload("User").
+		Preload("Thread").
+		Preload("Thread.Category")
+}
+
+// FindBatch walks every post in id order, limit rows after the given id,
+// with what the search index needs.
+func (r *postRepository) FindBatch(ctx context.Context, after uuid.UUID, limit int) ([]*model.Post, error) {
+	var posts []*model.Post
+	err := r.searchRows(ctx).
+		Where("id > ?", after).
+		Order("id ASC").
+		Limit(limit).
+		Find(&posts).Error
+	return posts, err
+}
+
+// FindForSearch loads the given posts with what the search index needs,
+// soft-deleted ones included. Posts deleted for good are left out.
+func (r *postRepository) FindForSearch(ctx context.Context, ids []uuid.UUID) ([]*model.Post, error) {
+	var posts []*model.Post
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	err := r.searchRows(ctx).Where("id IN ?", ids).Find(&posts).Error
+	return posts, err
+}
+
+// FindExistingIDs returns the ids that still have a post, soft-deleted or
+// not.
+func (r *postRepository) FindExistingIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	var existing []uuid.UUID
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	err := r.db.WithContext(ctx).Unscoped().Model(&model.Post{}).
+		Where("id IN ?", ids).
+		Pluck("id", &existing).Error
+	return existing, err
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestDeleteAndRestorePost(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, &model.Thread{}, &model.Post{}, &model.Attachment{})
+	posts := NewPostRepository(db)
+
+	thread := &model.Thread{UserID: uuid.New(), Title: "t", Slug: "t", Content: "c", Audience: "semua"}
+	if err := db.Create(thread).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	author, other := uuid.New(), uuid.New()
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write := func(minute int, userID uuid.UUID, parent *model.Post) *model.Post {
+		t.Helper()
+		post := &model.Post{ThreadID: thread.ID, UserID: userID, Content: "c", CreatedAt: start.Add(time.Duration(minute) * time.Minute)}
+		if parent != nil {
+			post.ParentID = &parent.ID
+		}
+		if err := posts.Create(ctx, post, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return post
+	}
+	// e, then a with the replies b (with its own reply c) and d
+	e := write(0, author, nil)
+	a := write(1, author, nil)
+	b := write(2, author, a)
+	c := write(3, author, b)
+	d := write(4, other, a)
+
+	expect := func(step string, count int, last *model.Post) {
+		t.Helper()
+		var got model.Thread
+		if err := db.First(&got, "id = ?", thread.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got.RepliesCount != count {
+			t.Errorf("%s: replies_count %d, want %d", step, got.RepliesCount, count)
+		}
+		if got.LastReplyUserID == nil || *got.LastReplyUserID != last.UserID ||
+			got.LastReplyAt == nil || !got.LastReplyAt.Equal(last.CreatedAt) {
+			t.Errorf("%s: last reply %v by %v, want %v by %s", step, got.LastReplyAt, got.LastReplyUserID, last.CreatedAt, last.UserID)
+		}
+	}
+	expect("created", 5, d)
+
+	if err := posts.Delete(ctx, d.ID); err != nil {
+		t.Fatalf("Delete d: %v", err)
+	}
+	expect("d deleted", 4, c)
+	children, err := posts.FindChildren(ctx, []uuid.UUID{a.ID}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 1 || children[0].ID != b.ID {
+		t.Errorf("children of a %v, want only b", children)
+	}
+
+	// Deleting a takes b and c with it
+	if err := posts.Delete(ctx, a.ID); err != nil {
+		t.Fatalf("Delete a: %v", err)
+	}
+	expect("a deleted", 1, e)
+	for _, post := range []*model.Post{a, b, c} {
+		if _, err := posts.FindByID(ctx, post.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("deleted post still found: %v", err)
+		}
+	}
+
+	if err := posts.Restore(ctx, b.ID); !errors.Is(err, ErrPostParentDeleted) {
+		t.Errorf("Restore b under deleted a: error %v, want %v", err, ErrPostParentDeleted)
+	}
+
+	// a comes back with b and c, but d was deleted on its own before
+	if err := posts.Restore(ctx, a.ID); err != nil {
+		t.Fatalf("Restore a: %v", err)
+	}
+	expect("a restored", 4, c)
+	if _, err := posts.FindByID(ctx, d.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("d restored with a: %v", err)
+	}
+	if _, err := posts.FindRoot(ctx, c.ID); err != nil {
+		t.Errorf("FindRoot of restored c: %v", err)
+	}
+
+	if err := posts.Restore(ctx, d.ID); err != nil {
+		t.Fatalf("Restore d: %v", err)
+	}
+	expect("d restored", 5, d)
+
+	if err := posts.Restore(ctx, a.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Restore of a live post: error %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+
+	// Every change reached the search outbox: 5 creates, 4 deletes, 4 restores
+	if n := countRows(t, db, &model.SearchOutbox{}); n != 13 {
+		t.Errorf("%d outbox rows, want 13", n)
+	}
+}
+
+func TestFindForSearchDeleted(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, &model.Thread{}, &model.Post{}, &model.Attachment{}, &model.User{})
+	posts := NewPostRepository(db)
+
+	thread := &model.Thread{UserID: uuid.New(), Title: "t", Slug: "t", Content: "c", Audience: "semua"}
+	if err := db.Create(thread).Error; err != nil {
+		t.Fatal(err)
+	}
+	live := &model.Post{ThreadID: thread.ID, UserID: uuid.New(), Content: "c"}
+	deleted := &model.Post{ThreadID: thread.ID, UserID: uuid.New(), Content: "c"}
+	for _, post := range []*model.Post{live, deleted} {
+		if err := posts.Create(ctx, post, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := posts.Delete(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The index keeps soft-deleted posts, marked hidden, so it must see them
+	found, err := posts.FindForSearch(ctx, []uuid.UUID{live.ID, deleted.ID, uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden := map[uuid.UUID]bool{}
+	for _, post := range found {
+		hidden[post.ID] = post.DeletedAt.Valid
+	}
+	if len(found) != 2 || hidden[live.ID] || !hidden[deleted.ID] {
+		t.Errorf("found %v (id: deleted), want the live and the deleted post", hidden)
+	}
+
+	existing, err := posts.FindExistingIDs(ctx, []uuid.UUID{live.ID, deleted.ID, uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(existing) != 2 {
+		t.Errorf("existing %v, want the live and the deleted post", existing)
+	}
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReadRepository interface {
+	FindThreadReads(ctx context.Context, userID uuid.UUID) ([]*model.ThreadRead, error)
+	FindCategoryReads(ctx context.Context, userID uuid.UUID) ([]*model.CategoryRead, error)
+	UpsertThreadReads(ctx context.Context, reads []model.ThreadRead) error
+	UpsertCategoryReads(ctx context.Context, reads []model.CategoryRead) error
+	CountUnread(ctx context.Context, userID uuid.UUID, since map[uuid.UUID]time.Time) (map[uuid.UUID]int64, error)
+}
+
+type readRepository struct {
+	db *gorm.DB
+}
+
+func NewReadRepository(db *gorm.DB) ReadRepository {
+	return &readRepository{db: db}
+}
+
+func (r *readRepository) FindThreadReads(ctx context.Context, userID uuid.UUID) ([]*model.ThreadRead, error) {
+	var reads []*model.ThreadRead
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&reads).Error
+	return reads, err
+}
+
+func (r *readRepository) FindCategoryReads(ctx context.Context, userID uuid.UUID) ([]*model.CategoryRead, error) {
+	var reads []*model.CategoryRead
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&reads).Error
+	return reads, err
+}
+
+// Positions only move forward, so a stale write never rewinds a newer one.
+func (r *readRepository) UpsertThreadReads(ctx context.Context, reads []model.ThreadRead) error {
+	if len(reads) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "thread_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"last_read_at": gorm.Expr("GREATEST(thread_reads.last_read_at, EXCLUDED.last_read_at)")}),
+	}).Create(&reads).Error
+}
+
+func (r *readRepository) UpsertCategoryReads(ctx context.Context, reads []model.CategoryRead) error {
+	if len(reads) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "category_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"last_read_at": gorm.Expr("GREATEST(category_reads.last_read_at, EXCLUDED.last_read_at)")}),
+	}).Create(&reads).Error
+}
+
+// CountUnread counts, per thread, the posts by other users created after the
+// given time, in a single query.
+func (r *readRepository) CountUnread(ctx context.Context, userID uuid.UUID, since map[uuid.UUID]time.Time) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(since))
+	if len(since) == 0 {
+		return counts, nil
+	}
+
+	conds := make([]string, 0, len(since))
+	args := make([]interface{}, 0, len(since)*2)
+	for threadID, t := range since {
+		conds = append(conds, "(thread_id = ? AND created_at > ?)")
+		args = append(args, threadID, t)
+	}
+
+	var rows []struct {
+		ThreadID uuid.UUID
+		Count    int64
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&model.Post{}).
+		Select("thread_id, COUNT(*) AS count").
+		Where("user_id <> ?", userID).
+		Where("("+strings.Join(conds, " OR ")+")", args...).
+		Group("thread_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.ThreadID] = row.Count
+	}
+	return counts, nil
+}
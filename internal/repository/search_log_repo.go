@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchTotals sums the searches of a period.
+type SearchTotals struct {
+	Searches    int64
+	ZeroResults int64
+	Clicked     int64 // Searches with at least one click
+}
+
+// SearchQueryStats groups the searches of one query.
+type SearchQueryStats struct {
+	Query          string
+	Searches       int64
+	AvgResults     float64
+	Clicked        int64
+	LastSearchedAt time.Time
+}
+
+type SearchLogRepository interface {
+	Create(ctx context.Context, log *model.SearchLog) error
+	Exists(ctx context.Context, id uuid.UUID) (bool, error)
+	CreateClick(ctx context.Context, click *model.SearchClick) error
+	Totals(ctx context.Context, from, to time.Time) (*SearchTotals, error)
+	QueryStats(ctx context.Context, from, to time.Time, zeroResultsOnly bool, limit int) ([]SearchQueryStats, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type searchLogRepository struct {
+	db *gorm.DB
+}
+
+func NewSearchLogRepository(db *gorm.DB) SearchLogRepository {
+	return &searchLogRepository{db: db}
+}
+
+// clickedSearch is true for searches with at least one click.
+const clickedSearch = "EXISTS (SELECT 1 FROM search_clicks WHERE search_clicks.search_id = search_logs.id)"
+
+func (r *searchLogRepository) Create(ctx context.Context, log *model.SearchLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
+
+func (r *searchLogRepository) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.SearchLog{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+// CreateClick keeps the first click on a result, so opening it again does
+// not count twice.
+func (r *searchLogRepository) CreateClick(ctx context.Context, click *model.SearchClick) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(click).Error
+}
+
+func (r *searchLogRepository) Totals(ctx context.Context, from, to time.Time) (*SearchTotals, error) {
+	var totals SearchTotals
+	err := r.db.WithContext(ctx).
+		Model(&model.SearchLog{}).
+		Select("COUNT(*) AS searches, "+
+			"COUNT(*) FILTER (WHERE result_count = 0) AS zero_results, "+
+			"COUNT(*) FILTER (WHERE "+clickedSearch+") AS clicked").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Scan(&totals).Error
+	return &totals, err
+}
+
+// QueryStats returns the most searched queries of the period, optionally
+// only their searches without results.
+func (r *searchLogRepository) QueryStats(ctx context.Context, from, to time.Time, zeroResultsOnly bool, limit int) ([]SearchQueryStats, error) {
+	query := r.db.WithContext(ctx).
+		Model(&model.SearchLog{}).
+		Select("query, COUNT(*) AS searches, AVG(result_count) AS avg_results, "+
+			"COUNT(*) FILTER (WHERE "+clickedSearch+") AS clicked, "+
+			"MAX(created_at) AS last_searched_at").
+		Where("created_at >= ? AND created_at < ?", from, to)
+	if zeroResultsOnly {
+		query = query.Where("result_count = 0")
+	}
+
+	var stats []SearchQueryStats
+	err := query.
+		Group("query").
+		Order("searches DESC, query ASC").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
+
+func (r *searchLogRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("created_at < ?", before).Delete(&model.SearchLog{})
+	return result.RowsAffected, result.Error
+}
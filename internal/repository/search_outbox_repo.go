@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SearchOutboxRepository interface {
+	Enqueue(ctx context.Context, entity string, ids []uuid.UUID) error
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.SearchOutbox, error)
+	Delete(ctx context.Context, ids []uuid.UUID) error
+	Release(ctx context.Context, ids []uuid.UUID, lastError string, maxBackoff time.Duration) error
+}
+
+type searchOutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewSearchOutboxRepository(db *gorm.DB) SearchOutboxRepository {
+	return &searchOutboxRepository{db: db}
+}
+
+// enqueueSearch records in the caller's transaction that the entities
+// changed, so the change and its index update commit or roll back together.
+func enqueueSearch(tx *gorm.DB, entity string, ids ...uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows := make([]model.SearchOutbox, len(ids))
+	for i, id := range ids {
+		rows[i] = model.SearchOutbox{Entity: entity, EntityID: id}
+	}
+	return tx.CreateInBatches(&rows, 500).Error
+}
+
+// Enqueue records changes outside of a write, e.g. documents that depend on
+// a changed entity.
+func (r *searchOutboxRepository) Enqueue(ctx context.Context, entity string, ids []uuid.UUID) error {
+	return enqueueSearch(r.db.WithContext(ctx), entity, ids...)
+}
+
+// Claim takes up to limit due rows, oldest first, and hides them from other
+// workers for the lease. Rows of a worker that dies come back after it.
+func (r *searchOutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.SearchOutbox, error) {
+	var rows []*model.SearchOutbox
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE search_outboxes
+		SET available_at = NOW() + ? * INTERVAL '1 second', attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM search_outboxes
+			WHERE available_at <= NOW()
+			ORDER BY available_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`, lease.Seconds(), limit).Scan(&rows).Error
+	return rows, err
+}
+
+func (r *searchOutboxRepository) Delete(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&model.SearchOutbox{}).Error
+}
+
+// Release hands failed rows back with an exponential backoff on their
+// attempts: 1s, 2s, 4s, ... up to maxBackoff.
+func (r *searchOutboxRepository) Release(ctx context.Context, ids []uuid.UUID, lastError string, maxBackoff time.Duration) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Exec(`
+		UPDATE search_outboxes
+		SET last_error = ?,
+			available_at = NOW() + LEAST(POWER(2, LEAST(attempts - 1, 30)), ?) * INTERVAL '1 second'
+		WHERE id IN ?
+	`, lastError, maxBackoff.Seconds(), ids).Error
+}
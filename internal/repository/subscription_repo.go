@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SubscriptionRepository interface {
+	Find(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*model.ThreadSubscription, error)
+	Upsert(ctx context.Context, subscription *model.ThreadSubscription) error
+	CreateIfNotExists(ctx context.Context, subscription *model.ThreadSubscription) error
+	Delete(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error
+	FindByThreadID(ctx context.Context, threadID uuid.UUID) ([]*model.ThreadSubscription, error)
+	BackfillParticipants(ctx context.Context) error
+}
+
+type subscriptionRepository struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionRepository(db *gorm.DB) SubscriptionRepository {
+	return &subscriptionRepository{db: db}
+}
+
+func (r *subscriptionRepository) Find(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*model.ThreadSubscription, error) {
+	var subscription model.ThreadSubscription
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND thread_id = ?", userID, threadID).
+		First(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *subscriptionRepository) Upsert(ctx context.Context, subscription *model.ThreadSubscription) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "thread_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"mode", "updated_at"}),
+	}).Create(subscription).Error
+}
+
+// CreateIfNotExists keeps an existing subscription untouched, so auto-watching
+// never overrides a mode the user picked (e.g. muted).
+func (r *subscriptionRepository) CreateIfNotExists(ctx context.Context, subscription *model.ThreadSubscription) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(subscription).Error
+}
+
+func (r *subscriptionRepository) Delete(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Where("user_id = ? AND thread_id = ?", userID, threadID).
+		Delete(&model.ThreadSubscription{}).Error
+}
+
+func (r *subscriptionRepository) FindByThreadID(ctx context.Context, threadID uuid.UUID) ([]*model.ThreadSubscription, error) {
+	var subscriptions []*model.ThreadSubscription
+	err := r.db.WithContext(ctx).
+		Where("thread_id = ?", threadID).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// BackfillParticipants subscribes thread authors and repliers of existing
+// threads, matching what auto-watch would have done.
+func (r *subscriptionRepository) BackfillParticipants(ctx context.Context) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO thread_subscriptions (user_id, thread_id, mode, created_at, updated_at)
+		SELECT user_id, id, ?, NOW(), NOW() FROM threads
+		UNION
+		SELECT DISTINCT user_id, thread_id, ?, NOW(), NOW() FROM posts WHERE deleted_at IS NULL
+		ON CONFLICT DO NOTHING
+	`, model.SubscriptionModeAll, model.SubscriptionModeAll).Error
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens an in-memory SQLite database with the tables of models.
+// Statements specific to PostgreSQL are out of reach of these tests; they
+// cover what the repositories do in transactions.
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// One connection, or every new one would see an empty database
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	// The outbox defaults to now(), which SQLite does not know
+	if err := db.Exec(`CREATE TABLE search_outboxes (
+		id TEXT PRIMARY KEY,
+		entity TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		available_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		created_at DATETIME
+	)`).Error; err != nil {
+		t.Fatalf("create search_outboxes: %v", err)
+	}
+	return db
+}
+
+func countRows(t *testing.T, db *gorm.DB, m interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(m).Count(&n).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return n
+}
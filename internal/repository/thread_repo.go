@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"anoa.com/telkomalumiforum/internal/model"
 	"github.com/google/uuid"
@@ -9,13 +11,22 @@ import (
 )
 
 type ThreadRepository interface {
-	Create(ctx context.Context, thread *model.Thread) error
+	Create(ctx context.Context, thread *model.Thread, attachmentIDs []uint) error
 	FindBySlug(ctx context.Context, slug string) (*model.Thread, error)
 	FindByID(ctx context.Context, id uuid.UUID) (*model.Thread, error)
+	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Thread, error)
 	FindAll(ctx context.Context, categoryID *uuid.UUID, search string, audiences []string, sortBy string, offset, limit int) ([]*model.Thread, int64, error)
 	FindByUserID(ctx context.Context, userID uuid.UUID, audiences []string, offset, limit int) ([]*model.Thread, int64, error)
-	GetTrending(ctx context.Context, limit int) ([]*model.Thread, error)
-	Update(ctx context.Context, thread *model.Thread) error
+	FindPage(ctx context.Context, q ThreadListQuery, after *KeysetCursor, limit int) ([]*model.Thread, error)
+	Count(ctx context.Context, q ThreadListQuery) (int64, error)
+	FindTrendingCandidates(ctx context.Context, since time.Time) ([]TrendingCandidate, error)
+	FindByIDsOrdered(ctx context.Context, ids []uuid.UUID) ([]*model.Thread, error)
+	FindBatch(ctx context.Context, after uuid.UUID, limit int) ([]*model.Thread, error)
+	FindForSearch(ctx context.Context, ids []uuid.UUID) ([]*model.Thread, error)
+	FindExistingIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
+	Update(ctx context.Context, thread *model.Thread, attachmentIDs []uint) error
+	AddViews(ctx context.Context, deltas map[uuid.UUID]int64) (map[uuid.UUID]int, error)
+	RecountReplies(ctx context.Context) (int64, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
@@ -27,8 +38,18 @@ func NewThreadRepository(db *gorm.DB) ThreadRepository {
 	return &threadRepository{db: db}
 }
 
-func (r *threadRepository) Create(ctx context.Context, thread *model.Thread) error {
-	return r.db.WithContext(ctx).Create(thread).Error
+// Create inserts the thread and attaches the author's uploads to it, all or
+// nothing: with an attachment that cannot be attached no thread is left.
+func (r *threadRepository) Create(ctx context.Context, thread *model.Thread, attachmentIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(thread).Error; err != nil {
+			return err
+		}
+		if err := attach(tx, attachmentIDs, threadAttachable(attachmentIDs, thread.ID, thread.UserID), "thread_id", thread.ID); err != nil {
+			return err
+		}
+		return enqueueSearch(tx, model.SearchEntityThread, thread.ID)
+	})
 }
 
 func (r *threadRepository) FindBySlug(ctx context.Context, slug string) (*model.Thread, error) {
@@ -37,7 +58,9 @@ func (r *threadRepository) FindBySlug(ctx context.Context, slug string) (*model.
 		Preload("Category").
 		Preload("User").
 		Preload("User.Profile").
+		Preload("LastReplyUser").
 		Preload("Attachments").
+		Preload("Poll").
 		Where("slug = ?", slug).
 		First(&thread).Error; err != nil {
 		return nil, err
@@ -51,7 +74,9 @@ func (r *threadRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.T
 		Preload("Category").
 		Preload("User").
 		Preload("User.Profile").
+		Preload("LastReplyUser").
 		Preload("Attachments").
+		Preload("Poll").
 		Where("id = ?", id).
 		First(&thread).Error; err != nil {
 		return nil, err
@@ -59,14 +84,31 @@ func (r *threadRepository) FindByID(ctx context.Context, id uuid.UUID) (*model.T
 	return &thread, nil
 }
 
+func (r *threadRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Thread, error) {
+	var threads []*model.Thread
+	if len(ids) == 0 {
+		return threads, nil
+	}
+
+	if err := r.db.WithContext(ctx).
+		Preload("Category").
+		Preload("User").
+		Where("id IN ?", ids).
+		Find(&threads).Error; err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
 func (r *threadRepository) FindAll(ctx context.Context, categoryID *uuid.UUID, search string, audiences []string, sortBy string, offset, limit int) ([]*model.Thread, int64, error) {
 	var threads []*model.Thread
 	var total int64
-	
+
 	query := r.db.WithContext(ctx).
 		Preload("Category").
 		Preload("User").
 		Preload("User.Profile").
+		Preload("LastReplyUser").
 		Preload("Attachments")
 
 	if categoryID != nil {
@@ -85,10 +127,13 @@ func (r *threadRepository) FindAll(ctx context.Context, categoryID *uuid.UUID, s
 		return nil, 0, err
 	}
 
-	if sortBy == "popular" {
-		query = query.Order("views DESC").Order("created_at DESC")
-	} else {
-		query = query.Order("created_at DESC")
+	switch sortBy {
+	case "popular":
+		query = query.Order("views DESC").Order("created_at DESC").Order("id DESC")
+	case "activity":
+		query = query.Order("COALESCE(last_reply_at, created_at) DESC").Order("id DESC")
+	default:
+		query = query.Order("created_at DESC").Order("id DESC")
 	}
 
 	if err := query.Offset(offset).Limit(limit).Find(&threads).Error; err != nil {
@@ -106,6 +151,7 @@ func (r *threadRepository) FindByUserID(ctx context.Context, userID uuid.UUID, a
 		Preload("Category").
 		Preload("User").
 		Preload("User.Profile").
+		Preload("LastReplyUser").
 		Preload("Attachments").
 		Where("user_id = ?", userID)
 
@@ -117,17 +163,222 @@ func (r *threadRepository) FindByUserID(ctx context.Context, userID uuid.UUID, a
 		return nil, 0, err
 	}
 
-	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&threads).Error; err != nil {
+	if err := query.Order("created_at DESC").Order("id DESC").Offset(offset).Limit(limit).Find(&threads).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return threads, total, nil
 }
 
+// ThreadListQuery filters the keyset paginated thread listings.
+type ThreadListQuery struct {
+	CategoryID *uuid.UUID
+	UserID     *uuid.UUID
+	Search     string
+	Audiences  []string
+	SortBy     string // "newest", "popular", "activity"
+}
+
+func (r *threadRepository) listQuery(ctx context.Context, q ThreadListQuery) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&model.Thread{})
+
+	if q.CategoryID != nil {
+		query = query.Where("category_id = ?", q.CategoryID)
+	}
+	if q.UserID != nil {
+		query = query.Where("user_id = ?", q.UserID)
+	}
+	if q.Search != "" {
+		query = query.Where("(title ILIKE ? OR content ILIKE ?)", "%"+q.Search+"%", "%"+q.Search+"%")
+	}
+	if len(q.Audiences) > 0 {
+		query = query.Where("audience IN ?", q.Audiences)
+	}
+	return query
+}
+
+// FindPage returns up to limit threads after the cursor. The "popular" sort
+// keys on (views, created_at, id) and "activity" on the last reply time, so
+// the cursor must come from a page with the same sort.
+func (r *threadRepository) FindPage(ctx context.Context, q ThreadListQuery, after *KeysetCursor, limit int) ([]*model.Thread, error) {
+	var threads []*model.Thread
+
+	query := r.listQuery(ctx, q).
+		Preload("Category").
+		Preload("User").
+		Preload("User.Profile").
+		Preload("LastReplyUser").
+		Preload("Attachments")
+
+	switch q.SortBy {
+	case "popular":
+		if after != nil {
+			query = query.Where("(views, created_at, id) < (?, ?, ?)", after.Score, after.At, after.ID)
+		}
+		query = query.Order("views DESC").Order("created_at DESC").Order("id DESC")
+	case "activity":
+		if after != nil {
+			query = query.Where("(COALESCE(last_reply_at, created_at), id) < (?, ?)", after.At, after.ID)
+		}
+		query = query.Order("COALESCE(last_reply_at, created_at) DESC").Order("id DESC")
+	default:
+		if after != nil {
+			query = query.Where("(created_at, id) < (?, ?)", after.At, after.ID)
+		}
+		query = query.Order("created_at DESC").Order("id DESC")
+	}
+
+	err := query.Limit(limit).Find(&threads).Error
+	return threads, err
+}
+
+func (r *threadRepository) Count(ctx context.Context, q ThreadListQuery) (int64, error) {
+	var total int64
+	err := r.listQuery(ctx, q).Count(&total).Error
+	return total, err
+}
+
+// searchRows preloads what the search documents need.
+func (r *threadRepository) searchRows(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Preload("Category").
+		Preload("User")
+}
+
+// FindBatch walks every thread in id order, limit rows after the given id,
+// with what the search index needs.
+func (r *threadRepository) FindBatch(ctx context.Context, after uuid.UUID, limit int) ([]*model.Thread, error) {
+	var threads []*model.Thread
+	err := r.searchRows(ctx).
+		Where("id > ?", after).
+		Order("id ASC").
+		Limit(limit).
+		Find(&threads).Error
+	return threads, err
+}
+
+// FindForSearch loads the given threads with what the search index needs.
+// Deleted threads are left out.
+func (r *threadRepository) FindForSearch(ctx context.Context, ids []uuid.UUID) ([]*model.Thread, error) {
+	var threads []*model.Thread
+	if len(ids) == 0 {
+		return threads, nil
+	}
+	err := r.searchRows(ctx).Where("id IN ?", ids).Find(&threads).Error
+	return threads, err
+}
+
+// FindExistingIDs returns the ids that still have a thread.
+func (r *threadRepository) FindExistingIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	var existing []uuid.UUID
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	err := r.db.WithContext(ctx).Model(&model.Thread{}).
+		Where("id IN ?", ids).
+		Pluck("id", &existing).Error
+	return existing, err
+}
+
 func (r *threadRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&model.Thread{}, id).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&model.Thread{}, id).Error; err != nil {
+			return err
+		}
+		return enqueueSearch(tx, model.SearchEntityThread, id)
+	})
+}
+
+// Update saves an edited thread and attaches the uploads listed, together.
+// Counters are maintained by their own statements and are left alone so a
+// stale copy cannot overwrite them.
+func (r *threadRepository) Update(ctx context.Context, thread *model.Thread, attachmentIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Omit("views", "replies_count", "last_reply_at", "last_reply_user_id").
+			Save(thread).Error; err != nil {
+			return err
+		}
+		if err := attach(tx, attachmentIDs, threadAttachable(attachmentIDs, thread.ID, thread.UserID), "thread_id", thread.ID); err != nil {
+			return err
+		}
+		return enqueueSearch(tx, model.SearchEntityThread, thread.ID)
+	})
+}
+
+// AddViews adds deltas to the view counters in one statement, without
+// touching any other column, and returns the new totals of the threads that
+// still exist.
+func (r *threadRepository) AddViews(ctx context.Context, deltas map[uuid.UUID]int64) (map[uuid.UUID]int, error) {
+	totals := make(map[uuid.UUID]int, len(deltas))
+	if len(deltas) == 0 {
+		return totals, nil
+	}
+
+	values := make([]string, 0, len(deltas))
+	args := make([]interface{}, 0, len(deltas)*2)
+	for id, delta := range deltas {
+		values = append(values, "(?::uuid, ?::bigint)")
+		args = append(args, id, delta)
+	}
+
+	var rows []struct {
+		ID    uuid.UUID
+		Views int
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE threads AS t
+		SET views = t.views + v.delta
+		FROM (VALUES `+strings.Join(values, ", ")+`) AS v(id, delta)
+		WHERE t.id = v.id
+		RETURNING t.id, t.views
+	`, args...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		totals[row.ID] = row.Views
+	}
+	return totals, nil
 }
 
-func (r *threadRepository) Update(ctx context.Context, thread *model.Thread) error {
-	return r.db.WithContext(ctx).Save(thread).Error
+// RecountReplies recomputes replies_count and the last reply of every thread
+// from its posts and returns how many threads were out of date.
+func (r *threadRepository) RecountReplies(ctx context.Context) (int64, error) {
+	return recountReplies(r.db.WithContext(ctx), nil)
+}
+
+// recountReplies is RecountReplies for the given threads, or all of them
+// when threadIDs is nil. It runs on db so it can join a transaction.
+func recountReplies(db *gorm.DB, threadIDs []uuid.UUID) (int64, error) {
+	scope, args := "", []interface{}{}
+	if threadIDs != nil {
+		if len(threadIDs) == 0 {
+			return 0, nil
+		}
+		scope, args = "WHERE th.id IN ?", append(args, threadIDs)
+	}
+
+	result := db.Exec(`
+		UPDATE threads AS t
+		SET replies_count = s.replies,
+			last_reply_at = s.last_at,
+			last_reply_user_id = s.last_user
+		FROM (
+			SELECT th.id,
+				COUNT(p.id) AS replies,
+				MAX(p.created_at) AS last_at,
+				(ARRAY_AGG(p.user_id ORDER BY p.created_at DESC, p.id DESC))[1] AS last_user
+			FROM threads th
+			LEFT JOIN posts p ON p.thread_id = th.id AND p.deleted_at IS NULL
+			`+scope+`
+			GROUP BY th.id
+		) AS s
+		WHERE t.id = s.id
+			AND (t.replies_count IS DISTINCT FROM s.replies
+				OR t.last_reply_at IS DISTINCT FROM s.last_at
+				OR t.last_reply_user_id IS DISTINCT FROM s.last_user)
+	`, args...)
+	return result.RowsAffected, result.Error
 }
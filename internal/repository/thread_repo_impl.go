@@ -2,32 +2,54 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"anoa.com/telkomalumiforum/internal/model"
 	"github.com/google/uuid"
 )
 
-func (r *threadRepository) GetTrending(ctx context.Context, limit int) ([]*model.Thread, error) {
-	var ids []uuid.UUID
+// TrendingCandidate carries the signals trending scores are computed from.
+type TrendingCandidate struct {
+	ID         uuid.UUID
+	CategoryID *uuid.UUID
+	Audience   string
+	Views      int
+	Likes      int64
+	Replies    int
+	CreatedAt  time.Time
+}
+
+// FindTrendingCandidates returns every thread created since the given time
+// with its like count, aggregated once instead of per thread.
+func (r *threadRepository) FindTrendingCandidates(ctx context.Context, since time.Time) ([]TrendingCandidate, error) {
+	var candidates []TrendingCandidate
 
 	query := `
-		SELECT id
-		FROM threads
-		WHERE created_at >= NOW() - INTERVAL '7 days'
-		ORDER BY (
-			(COALESCE(views, 0) + 
-			((SELECT COUNT(*) FROM thread_likes WHERE thread_likes.thread_id = threads.id) * 5) + 
-			(COALESCE(replies_count, 0) * 30))
-			/ 
-			POWER((EXTRACT(EPOCH FROM (NOW() - created_at))/3600) + 2, 1.8)
-		) DESC
-		LIMIT ?
+		SELECT t.id, t.category_id, t.audience,
+			COALESCE(t.views, 0) AS views,
+			COALESCE(l.likes, 0) AS likes,
+			COALESCE(t.replies_count, 0) AS replies,
+			t.created_at
+		FROM threads t
+		LEFT JOIN (
+			SELECT tl.thread_id, COUNT(*) AS likes
+			FROM thread_likes tl
+			JOIN threads th ON th.id = tl.thread_id
+			WHERE th.created_at >= ?
+			GROUP BY tl.thread_id
+		) l ON l.thread_id = t.id
+		WHERE t.created_at >= ?
 	`
 
-	if err := r.db.WithContext(ctx).Raw(query, limit).Scan(&ids).Error; err != nil {
+	if err := r.db.WithContext(ctx).Raw(query, since, since).Scan(&candidates).Error; err != nil {
 		return nil, err
 	}
+	return candidates, nil
+}
 
+// FindByIDsOrdered loads threads for a list view, in the order of ids.
+// Threads that no longer exist are skipped.
+func (r *threadRepository) FindByIDsOrdered(ctx context.Context, ids []uuid.UUID) ([]*model.Thread, error) {
 	if len(ids) == 0 {
 		return []*model.Thread{}, nil
 	}
@@ -37,13 +59,13 @@ func (r *threadRepository) GetTrending(ctx context.Context, limit int) ([]*model
 		Preload("Category").
 		Preload("User").
 		Preload("User.Profile").
+		Preload("LastReplyUser").
 		Preload("Attachments").
 		Where("id IN ?", ids).
 		Find(&threads).Error; err != nil {
 		return nil, err
 	}
 
-	// Reorder threads to match the trending order
 	threadMap := make(map[uuid.UUID]*model.Thread)
 	for _, t := range threads {
 		threadMap[t.ID] = t
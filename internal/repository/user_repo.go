@@ -4,14 +4,20 @@ import (
 	"context"
 
 	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
+	FindBatch(ctx context.Context, after uuid.UUID, limit int) ([]*model.User, error)
+	FindForSearch(ctx context.Context, ids []uuid.UUID) ([]*model.User, error)
+	FindSearchableIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
 	Create(ctx context.Context, user *model.User, profile *model.Profile) error
 	FindByID(ctx context.Context, id string) (*model.User, error)
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
 	FindByUsername(ctx context.Context, username string) (*model.User, error)
+	FindByIDs(ctx context.Context, ids []string) ([]*model.User, error)
+	FindByUsernames(ctx context.Context, usernames []string) ([]*model.User, error)
 	FindRoleByName(ctx context.Context, name string) (*model.Role, error)
 	Update(ctx context.Context, user *model.User, profile *model.Profile) error
 	FindAll(ctx context.Context) ([]*model.User, error)
@@ -48,7 +54,7 @@ func (r *userRepository) Create(ctx context.Context, user *model.User, profile *
 			}
 		}
 
-		return nil
+		return enqueueSearch(tx, model.SearchEntityUser, user.ID)
 	})
 }
 
@@ -91,6 +97,38 @@ func (r *userRepository) FindByID(ctx context.Context, id string) (*model.User,
 	return &user, nil
 }
 
+func (r *userRepository) FindByIDs(ctx context.Context, ids []string) ([]*model.User, error) {
+	var users []*model.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	if err := r.db.WithContext(ctx).
+		Preload("Role").
+		Where("id IN ?", ids).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) FindByUsernames(ctx context.Context, usernames []string) ([]*model.User, error) {
+	var users []*model.User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	if err := r.db.WithContext(ctx).
+		Preload("Role").
+		Where("username IN ?", usernames).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (r *userRepository) FindRoleByName(ctx context.Context, name string) (*model.Role, error) {
 	var role model.Role
 	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&role).Error; err != nil {
@@ -112,7 +150,7 @@ func (r *userRepository) Update(ctx context.Context, user *model.User, profile *
 			}
 		}
 
-		return nil
+		return enqueueSearch(tx, model.SearchEntityUser, user.ID)
 	})
 }
 
@@ -128,6 +166,79 @@ func (r *userRepository) FindAll(ctx context.Context) ([]*model.User, error) {
 	return users, nil
 }
 
+// Delete removes the user for good. Their threads, posts and the replies
+// under those posts go with them through cascading foreign keys, so the
+// reply counts and last replies of the other threads they posted in are
+// recomputed in the same transaction. Unlike a deleted post, a deleted user
+// cannot be restored.
 func (r *userRepository) Delete(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Delete(&model.User{}, "id = ?", id).Error
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var threadIDs []uuid.UUID
+		if err := tx.Model(&model.Post{}).
+			Distinct("posts.thread_id").
+			Joins("JOIN threads ON threads.id = posts.thread_id").
+			Where("posts.user_id = ? AND threads.user_id <> ?", userID, userID).
+			Pluck("posts.thread_id", &threadIDs).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&model.User{}, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if len(threadIDs) > 0 {
+			if _, err := recountReplies(tx, threadIDs); err != nil {
+				return err
+			}
+		}
+		return enqueueSearch(tx, model.SearchEntityUser, userID)
+	})
+}
+
+// searchable limits a query to users who have not opted out of people
+// search, with what the search documents need.
+func (r *userRepository) searchable(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Preload("Role").
+		Preload("Profile").
+		Where("NOT EXISTS (SELECT 1 FROM profiles WHERE profiles.user_id = users.id AND profiles.hide_from_search)")
+}
+
+// FindBatch walks every searchable user in id order, limit rows after the
+// given id.
+func (r *userRepository) FindBatch(ctx context.Context, after uuid.UUID, limit int) ([]*model.User, error) {
+	var users []*model.User
+	err := r.searchable(ctx).
+		Where("users.id > ?", after).
+		Order("users.id ASC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// FindForSearch loads the given users that are searchable. Deleted and
+// hidden users are left out.
+func (r *userRepository) FindForSearch(ctx context.Context, ids []uuid.UUID) ([]*model.User, error) {
+	var users []*model.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.searchable(ctx).Where("users.id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+// FindSearchableIDs returns the ids that still belong to a searchable user.
+func (r *userRepository) FindSearchableIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	var existing []uuid.UUID
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	err := r.searchable(ctx).Model(&model.User{}).
+		Where("users.id IN ?", ids).
+		Pluck("users.id", &existing).Error
+	return existing, err
 }
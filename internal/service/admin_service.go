@@ -1,6 +1,7 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -23,12 +24,14 @@ type AdminService interface {
 type adminService struct {
 	repo         repository.UserRepository
 	imageStorage storage.ImageStorage
+	uploads      UploadValidator
 }
 
-func NewAdminService(repo repository.UserRepository, imageStorage storage.ImageStorage) AdminService {
+func NewAdminService(repo repository.UserRepository, imageStorage storage.ImageStorage, uploads UploadValidator) AdminService {
 	return &adminService{
 		repo:         repo,
 		imageStorage: imageStorage,
+		uploads:      uploads,
 	}
 }
 
@@ -60,7 +63,7 @@ func (s *adminService) CreateUser(ctx context.Context, input dto.CreateUserInput
 
 	var avatarURL *string
 	if avatar != nil && avatar.Reader != nil && s.imageStorage != nil {
-		url, err := s.imageStorage.UploadImage(ctx, avatar.Reader, "avatars", avatar.FileName)
+		url, err := s.uploadAvatar(ctx, avatar)
 		if err != nil {
 			return nil, err
 		}
@@ -174,7 +177,7 @@ func (s *adminService) UpdateUser(ctx context.Context, id string, input dto.Upda
 
 	// Upload new Avatar
 	if avatar != nil && avatar.Reader != nil && s.imageStorage != nil {
-		url, err := s.imageStorage.UploadImage(ctx, avatar.Reader, "avatars", avatar.FileName)
+		url, err := s.uploadAvatar(ctx, avatar)
 		if err != nil {
 			return nil, err
 		}
@@ -189,7 +192,7 @@ func (s *adminService) UpdateUser(ctx context.Context, id string, input dto.Upda
 	if input.FullName != "" {
 		user.Profile.FullName = input.FullName
 	}
-	
+
 	// Optional fields logic: if not nil, update
 	if input.IdentityNumber != nil {
 		user.Profile.IdentityNumber = normalizeOptional(input.IdentityNumber)
@@ -219,3 +222,12 @@ func (s *adminService) UpdateUser(ctx context.Context, id string, input dto.Upda
 		Profile: updatedUser.Profile,
 	}, nil
 }
+
+// uploadAvatar validates an avatar set by an admin, under the admin's limits.
+func (s *adminService) uploadAvatar(ctx context.Context, avatar *dto.AvatarFile) (string, error) {
+	upload, err := s.uploads.Validate(UploadAvatar, "admin", avatar.Reader, avatar.FileName)
+	if err != nil {
+		return "", err
+	}
+	return s.imageStorage.UploadImage(ctx, bytes.NewReader(upload.Data), "avatars", upload.FileName)
+}
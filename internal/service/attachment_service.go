@@ -1,8 +1,14 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"mime/multipart"
+	"net/http"
+	"path"
 	"time"
 
 	"anoa.com/telkomalumiforum/internal/dto"
@@ -10,33 +16,100 @@ import (
 	"anoa.com/telkomalumiforum/internal/repository"
 	"anoa.com/telkomalumiforum/pkg/storage"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrAttachmentUnavailable rejects attachment ids of a thread or post that
+	// are not the user's own uploads for that context, or already used
+	// elsewhere.
+	ErrAttachmentUnavailable = repository.ErrAttachmentUnavailable
 )
 
 type AttachmentService interface {
-	UploadAttachment(ctx context.Context, userID uuid.UUID, file *multipart.FileHeader) (*dto.UploadAttachmentResponse, error)
+	GetAttachment(ctx context.Context, userID uuid.UUID, attachmentID uint) (*dto.AttachmentResponse, error)
+	UploadAttachment(ctx context.Context, userID uuid.UUID, uploadContext string, file *multipart.FileHeader) (*dto.UploadAttachmentResponse, error)
 	CleanupOrphanAttachments(ctx context.Context) error
+	MigrateAttachments(ctx context.Context, sourcePrefix string) (int, error)
 }
 
 type attachmentService struct {
 	attachmentRepo repository.AttachmentRepository
+	userRepo       repository.UserRepository
 	fileStorage    storage.ImageStorage
+	uploads        UploadValidator
+	visibility     VisibilityPolicy
 }
 
-func NewAttachmentService(attachmentRepo repository.AttachmentRepository, fileStorage storage.ImageStorage) AttachmentService {
+func NewAttachmentService(attachmentRepo repository.AttachmentRepository, userRepo repository.UserRepository, fileStorage storage.ImageStorage, uploads UploadValidator, visibility VisibilityPolicy) AttachmentService {
 	return &attachmentService{
 		attachmentRepo: attachmentRepo,
+		userRepo:       userRepo,
 		fileStorage:    fileStorage,
+		uploads:        uploads,
+		visibility:     visibility,
 	}
 }
 
-func (s *attachmentService) UploadAttachment(ctx context.Context, userID uuid.UUID, file *multipart.FileHeader) (*dto.UploadAttachmentResponse, error) {
+// GetAttachment returns the attachment with a fresh URL, if the user may see
+// the thread or post it belongs to. Attachments not used yet are only
+// visible to their uploader.
+func (s *attachmentService) GetAttachment(ctx context.Context, userID uuid.UUID, attachmentID uint) (*dto.AttachmentResponse, error) {
+	attachment, err := s.attachmentRepo.FindByID(ctx, attachmentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case attachment.ThreadID != nil:
+		_, err = s.visibility.Thread(ctx, userID, *attachment.ThreadID)
+	case attachment.PostID != nil:
+		_, err = s.visibility.Post(ctx, userID, *attachment.PostID)
+	case attachment.UserID != userID:
+		err = ErrAttachmentNotFound
+	}
+	if errors.Is(err, ErrThreadNotFound) || errors.Is(err, ErrPostNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.AttachmentResponse{
+		ID:       attachment.ID,
+		FileURL:  storage.SignedURL(s.fileStorage, attachment.FileURL),
+		FileType: attachment.FileType,
+	}, nil
+}
+
+// UploadAttachment stores a file for a thread or a post (uploadContext), if
+// it passes the context's allowlist and the uploader's limits.
+func (s *attachmentService) UploadAttachment(ctx context.Context, userID uuid.UUID, uploadContext string, file *multipart.FileHeader) (*dto.UploadAttachmentResponse, error) {
+	if uploadContext != UploadThread && uploadContext != UploadPost {
+		return nil, ErrUploadContext
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, err
+	}
+
 	f, err := file.Open()
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	url, err := s.fileStorage.UploadImage(ctx, f, "attachments", file.Filename)
+	upload, err := s.uploads.Validate(uploadContext, user.Role.Name, f, file.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.fileStorage.UploadImage(ctx, bytes.NewReader(upload.Data), "attachments", upload.FileName)
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +117,8 @@ func (s *attachmentService) UploadAttachment(ctx context.Context, userID uuid.UU
 	attachment := &model.Attachment{
 		UserID:   userID,
 		FileURL:  url,
-		FileType: file.Header.Get("Content-Type"),
+		FileType: upload.ContentType,
+		Context:  uploadContext,
 		// ThreadID and PostID are nil by default
 	}
 
@@ -54,7 +128,7 @@ func (s *attachmentService) UploadAttachment(ctx context.Context, userID uuid.UU
 
 	return &dto.UploadAttachmentResponse{
 		ID:       attachment.ID,
-		FileURL:  attachment.FileURL,
+		FileURL:  storage.SignedURL(s.fileStorage, attachment.FileURL),
 		FileType: attachment.FileType,
 	}, nil
 }
@@ -69,9 +143,9 @@ func (s *attachmentService) CleanupOrphanAttachments(ctx context.Context) error
 	}
 
 	for _, orphan := range orphans {
-		// 1. Delete from Cloudinary
+		// 1. Delete from storage
 		if err := s.fileStorage.DeleteImage(ctx, orphan.FileURL); err != nil {
-			// e.g., print error but continue with other files? 
+			// e.g., print error but continue with other files?
 			// In a real app we'd use a logger.
 		}
 
@@ -82,3 +156,67 @@ func (s *attachmentService) CleanupOrphanAttachments(ctx context.Context) error
 	}
 	return nil
 }
+
+// MigrateAttachments copies the attachments whose URL starts with
+// sourcePrefix to the configured storage and points them at the copy. The
+// originals are kept, so the migration can be checked before deleting them.
+// Failed attachments are logged and keep their URL; running it again picks
+// up where it stopped.
+func (s *attachmentService) MigrateAttachments(ctx context.Context, sourcePrefix string) (int, error) {
+	client := &http.Client{Timeout: time.Minute}
+	migrated, failed := 0, 0
+	var afterID uint
+	for {
+		batch, err := s.attachmentRepo.FindByURLPrefix(ctx, sourcePrefix, afterID, 100)
+		if err != nil {
+			return migrated, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, att := range batch {
+			afterID = att.ID
+			if err := s.migrateAttachment(ctx, client, att); err != nil {
+				log.Printf("Failed to migrate attachment %d: %v", att.ID, err)
+				failed++
+				continue
+			}
+			migrated++
+		}
+		log.Printf("Migrated %d attachments so far", migrated)
+	}
+
+	if failed > 0 {
+		return migrated, fmt.Errorf("%d attachments could not be migrated", failed)
+	}
+	return migrated, nil
+}
+
+func (s *attachmentService) migrateAttachment(ctx context.Context, client *http.Client, att model.Attachment) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, att.FileURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download returned %s", resp.Status)
+	}
+
+	url, err := s.fileStorage.UploadImage(ctx, resp.Body, "attachments", path.Base(req.URL.Path))
+	if err != nil {
+		return err
+	}
+	if err := s.attachmentRepo.UpdateFileURL(ctx, att.ID, url); err != nil {
+		// Don't leave an unreferenced copy behind
+		if derr := s.fileStorage.DeleteImage(ctx, url); derr != nil {
+			log.Printf("Failed to delete copy of attachment %d: %v", att.ID, derr)
+		}
+		return err
+	}
+	return nil
+}
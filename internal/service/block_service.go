@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Blocks are carried in every search token, which bounds how many a user
+// may have.
+const maxBlocks = 200
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrCannotBlockSelf = errors.New("cannot block yourself")
+	ErrBlockLimit      = errors.New("block limit reached")
+)
+
+// BlockService manages the users a user has blocked. Blocked users are left
+// out of the user's search results, both in /api/search and in the search
+// token.
+type BlockService interface {
+	Block(ctx context.Context, userID uuid.UUID, username string) error
+	Unblock(ctx context.Context, userID uuid.UUID, username string) error
+	GetBlocked(ctx context.Context, userID uuid.UUID) ([]dto.BlockedUserResponse, error)
+}
+
+type blockService struct {
+	blockRepo repository.BlockRepository
+	userRepo  repository.UserRepository
+}
+
+func NewBlockService(blockRepo repository.BlockRepository, userRepo repository.UserRepository) BlockService {
+	return &blockService{
+		blockRepo: blockRepo,
+		userRepo:  userRepo,
+	}
+}
+
+func (s *blockService) findUser(ctx context.Context, username string) (*model.User, error) {
+	user, err := s.userRepo.FindByUsername(ctx, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *blockService) Block(ctx context.Context, userID uuid.UUID, username string) error {
+	blocked, err := s.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	if blocked.ID == userID {
+		return ErrCannotBlockSelf
+	}
+
+	count, err := s.blockRepo.Count(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if count >= maxBlocks {
+		return ErrBlockLimit
+	}
+
+	return s.blockRepo.Create(ctx, &model.UserBlock{UserID: userID, BlockedID: blocked.ID})
+}
+
+func (s *blockService) Unblock(ctx context.Context, userID uuid.UUID, username string) error {
+	blocked, err := s.findUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	return s.blockRepo.Delete(ctx, userID, blocked.ID)
+}
+
+func (s *blockService) GetBlocked(ctx context.Context, userID uuid.UUID) ([]dto.BlockedUserResponse, error) {
+	blocks, err := s.blockRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.BlockedUserResponse, len(blocks))
+	for i, block := range blocks {
+		response[i] = dto.BlockedUserResponse{
+			Username:  block.Blocked.Username,
+			AvatarURL: block.Blocked.AvatarURL,
+			BlockedAt: block.CreatedAt,
+		}
+	}
+	return response, nil
+}
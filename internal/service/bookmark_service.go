@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBookmarkNotFound       = errors.New("bookmark not found")
+	ErrBookmarkExists         = errors.New("already bookmarked")
+	ErrCollectionNotFound     = errors.New("collection not found")
+	ErrCollectionNameTaken    = errors.New("collection name already used")
+	ErrBookmarkTargetNotFound = errors.New("thread or post not found")
+)
+
+type BookmarkService interface {
+	CreateBookmark(ctx context.Context, userID uuid.UUID, req dto.CreateBookmarkRequest) (*dto.BookmarkResponse, error)
+	GetMyBookmarks(ctx context.Context, userID uuid.UUID, filter dto.BookmarkFilter) (*dto.PaginatedBookmarkResponse, error)
+	UpdateBookmark(ctx context.Context, userID uuid.UUID, bookmarkID uuid.UUID, req dto.UpdateBookmarkRequest) (*dto.BookmarkResponse, error)
+	DeleteBookmark(ctx context.Context, userID uuid.UUID, bookmarkID uuid.UUID) error
+	CreateCollection(ctx context.Context, userID uuid.UUID, req dto.BookmarkCollectionRequest) (*dto.BookmarkCollectionResponse, error)
+	GetMyCollections(ctx context.Context, userID uuid.UUID) ([]dto.BookmarkCollectionResponse, error)
+	UpdateCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, req dto.BookmarkCollectionRequest) (*dto.BookmarkCollectionResponse, error)
+	DeleteCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) error
+}
+
+type bookmarkService struct {
+	bookmarkRepo repository.BookmarkRepository
+	threadRepo   repository.ThreadRepository
+	postRepo     repository.PostRepository
+	userRepo     repository.UserRepository
+	visibility   VisibilityPolicy
+}
+
+func NewBookmarkService(bookmarkRepo repository.BookmarkRepository, threadRepo repository.ThreadRepository, postRepo repository.PostRepository, userRepo repository.UserRepository, visibility VisibilityPolicy) BookmarkService {
+	return &bookmarkService{
+		bookmarkRepo: bookmarkRepo,
+		threadRepo:   threadRepo,
+		postRepo:     postRepo,
+		userRepo:     userRepo,
+		visibility:   visibility,
+	}
+}
+
+func (s *bookmarkService) CreateBookmark(ctx context.Context, userID uuid.UUID, req dto.CreateBookmarkRequest) (*dto.BookmarkResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	threadID, _ := uuid.Parse(req.ThreadID)
+	thread, err := s.threadRepo.FindByID(ctx, threadID)
+	if err != nil || !s.visibility.CanViewAs(user, thread) {
+		return nil, ErrBookmarkTargetNotFound
+	}
+
+	bookmark := &model.Bookmark{
+		UserID:   userID,
+		ThreadID: threadID,
+		Note:     req.Note,
+	}
+
+	if req.PostID != "" {
+		postID, _ := uuid.Parse(req.PostID)
+		post, err := s.postRepo.FindByID(ctx, postID)
+		if err != nil || post.ThreadID != threadID {
+			return nil, ErrBookmarkTargetNotFound
+		}
+		bookmark.PostID = &postID
+	}
+
+	if req.CollectionID != "" {
+		collectionID, _ := uuid.Parse(req.CollectionID)
+		if _, err := s.bookmarkRepo.FindCollectionByID(ctx, userID, collectionID); err != nil {
+			return nil, ErrCollectionNotFound
+		}
+		bookmark.CollectionID = &collectionID
+	}
+
+	if _, err := s.bookmarkRepo.FindExisting(ctx, userID, threadID, bookmark.PostID); err == nil {
+		return nil, ErrBookmarkExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// The check above misses a concurrent request for the same target
+	if err := s.bookmarkRepo.Create(ctx, bookmark); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrBookmarkExists
+		}
+		return nil, err
+	}
+
+	responses, err := s.buildResponses(ctx, user, []*model.Bookmark{bookmark})
+	if err != nil {
+		return nil, err
+	}
+	return &responses[0], nil
+}
+
+func (s *bookmarkService) GetMyBookmarks(ctx context.Context, userID uuid.UUID, filter dto.BookmarkFilter) (*dto.PaginatedBookmarkResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	page, limit := filter.Page, filter.Limit
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	var collectionID *uuid.UUID
+	if filter.CollectionID != "" {
+		id, _ := uuid.Parse(filter.CollectionID)
+		if _, err := s.bookmarkRepo.FindCollectionByID(ctx, userID, id); err != nil {
+			return nil, ErrCollectionNotFound
+		}
+		collectionID = &id
+	}
+
+	offset := (page - 1) * limit
+	bookmarks, total, err := s.bookmarkRepo.FindByUserID(ctx, userID, collectionID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := s.buildResponses(ctx, user, bookmarks)
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(total) / limit
+	if int(total)%limit != 0 {
+		totalPages++
+	}
+
+	return &dto.PaginatedBookmarkResponse{
+		Data: responses,
+		Meta: dto.PaginationMeta{
+			CurrentPage: page,
+			TotalPages:  totalPages,
+			TotalItems:  total,
+			Limit:       limit,
+		},
+	}, nil
+}
+
+func (s *bookmarkService) UpdateBookmark(ctx context.Context, userID uuid.UUID, bookmarkID uuid.UUID, req dto.UpdateBookmarkRequest) (*dto.BookmarkResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	bookmark, err := s.bookmarkRepo.FindByID(ctx, userID, bookmarkID)
+	if err != nil {
+		return nil, ErrBookmarkNotFound
+	}
+
+	bookmark.CollectionID = nil
+	if req.CollectionID != "" {
+		collectionID, _ := uuid.Parse(req.CollectionID)
+		if _, err := s.bookmarkRepo.FindCollectionByID(ctx, userID, collectionID); err != nil {
+			return nil, ErrCollectionNotFound
+		}
+		bookmark.CollectionID = &collectionID
+	}
+	bookmark.Note = req.Note
+
+	if err := s.bookmarkRepo.Update(ctx, bookmark); err != nil {
+		return nil, err
+	}
+
+	responses, err := s.buildResponses(ctx, user, []*model.Bookmark{bookmark})
+	if err != nil {
+		return nil, err
+	}
+	return &responses[0], nil
+}
+
+func (s *bookmarkService) DeleteBookmark(ctx context.Context, userID uuid.UUID, bookmarkID uuid.UUID) error {
+	if err := s.bookmarkRepo.Delete(ctx, userID, bookmarkID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBookmarkNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// buildResponses resolves the bookmarked threads and posts in two queries and
+// reports a status instead of content when the target is gone or no longer
+// visible to the user.
+func (s *bookmarkService) buildResponses(ctx context.Context, user *model.User, bookmarks []*model.Bookmark) ([]dto.BookmarkResponse, error) {
+	var threadIDs, postIDs []uuid.UUID
+	for _, b := range bookmarks {
+		threadIDs = append(threadIDs, b.ThreadID)
+		if b.PostID != nil {
+			postIDs = append(postIDs, *b.PostID)
+		}
+	}
+
+	threads, err := s.threadRepo.FindByIDs(ctx, threadIDs)
+	if err != nil {
+		return nil, err
+	}
+	threadMap := make(map[uuid.UUID]*model.Thread, len(threads))
+	for _, t := range threads {
+		threadMap[t.ID] = t
+	}
+
+	posts, err := s.postRepo.FindByIDs(ctx, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	postMap := make(map[uuid.UUID]*model.Post, len(posts))
+	for _, p := range posts {
+		postMap[p.ID] = p
+	}
+
+	responses := make([]dto.BookmarkResponse, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		resp := dto.BookmarkResponse{
+			ID:           b.ID,
+			CollectionID: b.CollectionID,
+			ThreadID:     b.ThreadID,
+			PostID:       b.PostID,
+			Note:         b.Note,
+			CreatedAt:    b.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+
+		thread, ok := threadMap[b.ThreadID]
+		var post *model.Post
+		if ok && b.PostID != nil {
+			post, ok = postMap[*b.PostID]
+		}
+
+		switch {
+		case !ok:
+			resp.Status = dto.BookmarkStatusDeleted
+		case !s.visibility.CanViewAs(user, thread):
+			resp.Status = dto.BookmarkStatusRestricted
+		default:
+			resp.Status = dto.BookmarkStatusAvailable
+			resp.Thread = &dto.BookmarkThreadResponse{
+				Title:        thread.Title,
+				Slug:         thread.Slug,
+				CategoryName: thread.Category.Name,
+				Author:       bookmarkAuthor(thread.User),
+			}
+			if post != nil {
+				resp.Post = &dto.BookmarkPostResponse{
+					Content: post.Content,
+					Author:  bookmarkAuthor(post.User),
+				}
+			}
+		}
+
+		responses = append(responses, resp)
+	}
+
+	return responses, nil
+}
+
+func bookmarkAuthor(user model.User) dto.AuthorResponse {
+	author := dto.AuthorResponse{Username: "Unknown"}
+	if user.Username != "" {
+		author.Username = user.Username
+		author.AvatarURL = user.AvatarURL
+	}
+	return author
+}
+
+func (s *bookmarkService) CreateCollection(ctx context.Context, userID uuid.UUID, req dto.BookmarkCollectionRequest) (*dto.BookmarkCollectionResponse, error) {
+	if existing, err := s.bookmarkRepo.FindCollectionByName(ctx, userID, req.Name); err == nil && existing != nil {
+		return nil, ErrCollectionNameTaken
+	}
+
+	collection := &model.BookmarkCollection{
+		UserID:      userID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	if err := s.bookmarkRepo.CreateCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	resp := mapCollection(collection, 0)
+	return &resp, nil
+}
+
+func (s *bookmarkService) GetMyCollections(ctx context.Context, userID uuid.UUID) ([]dto.BookmarkCollectionResponse, error) {
+	collections, err := s.bookmarkRepo.FindCollectionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.bookmarkRepo.CountByCollection(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.BookmarkCollectionResponse, 0, len(collections))
+	for _, c := range collections {
+		responses = append(responses, mapCollection(c, counts[c.ID]))
+	}
+	return responses, nil
+}
+
+func (s *bookmarkService) UpdateCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, req dto.BookmarkCollectionRequest) (*dto.BookmarkCollectionResponse, error) {
+	collection, err := s.bookmarkRepo.FindCollectionByID(ctx, userID, collectionID)
+	if err != nil {
+		return nil, ErrCollectionNotFound
+	}
+
+	if existing, err := s.bookmarkRepo.FindCollectionByName(ctx, userID, req.Name); err == nil && existing.ID != collection.ID {
+		return nil, ErrCollectionNameTaken
+	}
+
+	collection.Name = req.Name
+	collection.Description = req.Description
+	if err := s.bookmarkRepo.UpdateCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	counts, err := s.bookmarkRepo.CountByCollection(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := mapCollection(collection, counts[collection.ID])
+	return &resp, nil
+}
+
+func (s *bookmarkService) DeleteCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) error {
+	if err := s.bookmarkRepo.DeleteCollection(ctx, userID, collectionID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCollectionNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func mapCollection(c *model.BookmarkCollection, count int64) dto.BookmarkCollectionResponse {
+	return dto.BookmarkCollectionResponse{
+		ID:             c.ID,
+		Name:           c.Name,
+		Description:    c.Description,
+		BookmarksCount: count,
+		CreatedAt:      c.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -12,6 +13,8 @@ import (
 	"anoa.com/telkomalumiforum/internal/repository"
 )
 
+var ErrCategoryNotFound = errors.New("category not found")
+
 type CategoryService interface {
 	CreateCategory(ctx context.Context, req dto.CreateCategoryRequest) error
 	GetAllCategories(ctx context.Context, filter dto.CategoryFilter) (*dto.PaginatedCategoryResponse, error)
@@ -70,7 +73,7 @@ func (s *categoryService) GetAllCategories(ctx context.Context, filter dto.Categ
 func (s *categoryService) DeleteCategory(ctx context.Context, id uuid.UUID) error {
 	_, err := s.repo.FindByID(ctx, id)
 	if err != nil {
-		return fmt.Errorf("category not found")
+		return ErrCategoryNotFound
 	}
 
 	return s.repo.Delete(ctx, id)
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Listings opt into keyset pagination with pagination=cursor, or by passing
+// a cursor. Cursors are opaque to clients: the listing they belong to, the
+// sort score, the sort time in unix nanoseconds and the row id, base64url
+// encoded. A cursor from one listing or sort is rejected by any other.
+
+func useCursor(filter dto.CursorFilter) bool {
+	return filter.Pagination == "cursor" || filter.Cursor != ""
+}
+
+func encodeCursor(kind string, c repository.KeysetCursor) string {
+	raw := fmt.Sprintf("%s|%d|%d|%s", kind, c.Score, c.At.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor returns nil for an empty cursor, which means the first page.
+func decodeCursor(kind string, cursor string) (*repository.KeysetCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 4 || parts[0] != kind {
+		return nil, ErrInvalidCursor
+	}
+	score, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(parts[3])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &repository.KeysetCursor{Score: score, At: time.Unix(0, nanos), ID: id}, nil
+}
+
+// nextCursor trims a page fetched with limit+1 rows and returns the cursor
+// of its last row, or nil when there is no next page.
+func nextCursor[T any](rows []T, limit int, kind string, key func(T) repository.KeysetCursor) ([]T, *string) {
+	if len(rows) <= limit {
+		return rows, nil
+	}
+	rows = rows[:limit]
+	cursor := encodeCursor(kind, key(rows[len(rows)-1]))
+	return rows, &cursor
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+)
+
+func rawCursor(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 14, 15, 9, 26, 535897932, time.UTC)
+	for _, c := range []repository.KeysetCursor{
+		{Score: 0, At: at, ID: uuid.New()},
+		{Score: 4200, At: at, ID: uuid.New()},
+		{Score: -7, At: time.Unix(0, 0), ID: uuid.Nil},
+	} {
+		got, err := decodeCursor("threads:popular", encodeCursor("threads:popular", c))
+		if err != nil {
+			t.Fatalf("decodeCursor(%+v): %v", c, err)
+		}
+		if got.Score != c.Score || !got.At.Equal(c.At) || got.ID != c.ID {
+			t.Errorf("round trip of %+v gave %+v", c, got)
+		}
+	}
+
+	if got, err := decodeCursor("threads:newest", ""); got != nil || err != nil {
+		t.Errorf("empty cursor: %+v, %v, want the first page", got, err)
+	}
+}
+
+func TestCursorTampered(t *testing.T) {
+	id := uuid.NewString()
+	valid := encodeCursor("threads:newest", repository.KeysetCursor{Score: 1, At: time.Now(), ID: uuid.MustParse(id)})
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"another listing", encodeCursor("threads:popular", repository.KeysetCursor{Score: 1, At: time.Now(), ID: uuid.New()})},
+		{"not base64", "not a cursor!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("threads:newest|1|2|" + id))},
+		{"truncated", valid[:len(valid)-4]},
+		{"missing part", rawCursor("threads:newest|1|" + id)},
+		{"extra part", rawCursor("threads:newest|1|2|" + id + "|3")},
+		{"score not a number", rawCursor("threads:newest|x|2|" + id)},
+		{"time not a number", rawCursor("threads:newest|1|yesterday|" + id)},
+		{"bad id", rawCursor("threads:newest|1|2|" + id[:30])},
+	}
+
+	for _, tt := range tests {
+		if got, err := decodeCursor("threads:newest", tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: %+v, %v, want %v", tt.name, got, err, ErrInvalidCursor)
+		}
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	at := time.Now()
+	rows := []int{1, 2, 3}
+	key := func(n int) repository.KeysetCursor {
+		return repository.KeysetCursor{Score: int64(n), At: at, ID: uuid.Nil}
+	}
+
+	page, next := nextCursor(rows, 3, "k", key)
+	if len(page) != 3 || next != nil {
+		t.Errorf("last page: %v, cursor %v, want all rows and no cursor", page, next)
+	}
+
+	page, next = nextCursor(rows, 2, "k", key)
+	if len(page) != 2 || next == nil {
+		t.Fatalf("full page: %v, cursor %v, want 2 rows and a cursor", page, next)
+	}
+	if c, err := decodeCursor("k", *next); err != nil || c.Score != 2 {
+		t.Errorf("next cursor %+v, %v, want the last row kept", c, err)
+	}
+}
+
+func TestPostCursor(t *testing.T) {
+	post := &model.Post{ID: uuid.New(), CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)}
+	got, err := decodePostCursor(encodePostCursor(post))
+	if err != nil {
+		t.Fatalf("decodePostCursor: %v", err)
+	}
+	if got.ID != post.ID || !got.CreatedAt.Equal(post.CreatedAt) {
+		t.Errorf("round trip gave %+v, want %s at %s", got, post.ID, post.CreatedAt)
+	}
+
+	for _, cursor := range []string{
+		"not a cursor!",
+		rawCursor("12345"),
+		rawCursor("soon_" + post.ID.String()),
+		rawCursor("12345_" + post.ID.String()[:30]),
+	} {
+		if _, err := decodePostCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodePostCursor(%q): error %v, want %v", cursor, err, ErrInvalidCursor)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/webp"
+)
+
+// Images are rebuilt before they are stored, so nothing but the picture
+// survives: EXIF (with GPS), XMP and comments are dropped, and so is anything
+// appended after the end of the image. JPEGs are decoded and encoded again,
+// with their EXIF orientation applied first as it is lost with the EXIF.
+// PNG, GIF and WebP are containers of chunks; those that carry pixels or
+// color are copied, the others left out, which keeps animations intact.
+
+// animationBudget is how many times the pixel limit all frames of an
+// animation may add up to.
+const animationBudget = 4
+
+// pngKeepChunks are the PNG chunks kept. Other critical chunks, whose type
+// starts with an upper-case letter, make the image invalid.
+var pngKeepChunks = map[string]bool{
+	"IHDR": true, "PLTE": true, "IDAT": true, "IEND": true,
+	"tRNS": true, "gAMA": true, "cHRM": true, "sRGB": true, "iCCP": true, "cICP": true,
+	"sBIT": true, "bKGD": true, "pHYs": true, "acTL": true, "fcTL": true, "fdAT": true,
+}
+
+var webpKeepChunks = map[string]bool{
+	"VP8 ": true, "VP8L": true, "VP8X": true, "ALPH": true, "ANIM": true, "ANMF": true, "ICCP": true,
+}
+
+func sanitizeImage(contentType string, data []byte, maxDimension, maxPixels int) ([]byte, error) {
+	var cfg image.Config
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		cfg, err = jpeg.DecodeConfig(bytes.NewReader(data))
+	case "image/png":
+		cfg, err = png.DecodeConfig(bytes.NewReader(data))
+	case "image/gif":
+		cfg, err = gif.DecodeConfig(bytes.NewReader(data))
+	case "image/webp":
+		cfg, err = webp.DecodeConfig(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUploadType, contentType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadInvalid, err)
+	}
+	if cfg.Width > maxDimension || cfg.Height > maxDimension || cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d, maximum is %d pixels per side and %d pixels in total",
+			ErrUploadDimensions, cfg.Width, cfg.Height, maxDimension, maxPixels)
+	}
+
+	switch contentType {
+	case "image/jpeg":
+		return sanitizeJPEG(data)
+	case "image/png":
+		return sanitizePNG(data, cfg, maxPixels)
+	case "image/gif":
+		return sanitizeGIF(data, maxPixels)
+	default:
+		return sanitizeWebP(data, cfg, maxPixels)
+	}
+}
+
+func sanitizeJPEG(data []byte) ([]byte, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadInvalid, err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, orient(img, jpegOrientation(data)), &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func sanitizePNG(data []byte, cfg image.Config, maxPixels int) ([]byte, error) {
+	out := append([]byte(nil), data[:8]...)
+	frames := 0
+	for i := 8; ; {
+		if i+12 > len(data) {
+			return nil, fmt.Errorf("%w: truncated PNG", ErrUploadInvalid)
+		}
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		typ := string(data[i+4 : i+8])
+		end := i + 12 + n
+		if n < 0 || end > len(data) {
+			return nil, fmt.Errorf("%w: truncated PNG", ErrUploadInvalid)
+		}
+
+		if typ == "fcTL" {
+			frames++
+			if frames*cfg.Width*cfg.Height > animationBudget*maxPixels {
+				return nil, fmt.Errorf("%w: animation has too many frames", ErrUploadDimensions)
+			}
+		}
+		if pngKeepChunks[typ] {
+			out = append(out, data[i:end]...)
+		} else if typ[0] >= 'A' && typ[0] <= 'Z' {
+			return nil, fmt.Errorf("%w: unknown critical PNG chunk %q", ErrUploadInvalid, typ)
+		}
+		if typ == "IEND" {
+			return out, checkTrailing(data[end:])
+		}
+		i = end
+	}
+}
+
+func sanitizeGIF(data []byte, maxPixels int) ([]byte, error) {
+	// Header, logical screen descriptor and global color table
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << (flags&7 + 1)
+	}
+	if i > len(data) {
+		return nil, fmt.Errorf("%w: truncated GIF", ErrUploadInvalid)
+	}
+	out := append([]byte(nil), data[:i]...)
+
+	pixels := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // Extension
+			if i+2 > len(data) {
+				return nil, fmt.Errorf("%w: truncated GIF", ErrUploadInvalid)
+			}
+			end, err := gifSubBlocks(data, i+2)
+			if err != nil {
+				return nil, err
+			}
+			// Graphic control (frame timing) and the looping extension
+			label := data[i+1]
+			if label == 0xF9 || label == 0xFF && bytes.HasPrefix(data[i+2:end], []byte("\x0bNETSCAPE2.0")) {
+				out = append(out, data[i:end]...)
+			}
+			i = end
+
+		case 0x2C: // Image descriptor
+			if i+11 > len(data) {
+				return nil, fmt.Errorf("%w: truncated GIF", ErrUploadInvalid)
+			}
+			pixels += int(binary.LittleEndian.Uint16(data[i+5:])) * int(binary.LittleEndian.Uint16(data[i+7:]))
+			if pixels > animationBudget*maxPixels {
+				return nil, fmt.Errorf("%w: animation has too many frames", ErrUploadDimensions)
+			}
+			j := i + 10
+			if flags := data[i+9]; flags&0x80 != 0 {
+				j += 3 << (flags&7 + 1)
+			}
+			// LZW minimum code size, then the image data
+			end, err := gifSubBlocks(data, j+1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, data[i:end]...)
+			i = end
+
+		case 0x3B: // Trailer
+			return append(out, 0x3B), checkTrailing(data[i+1:])
+
+		default:
+			return nil, fmt.Errorf("%w: malformed GIF", ErrUploadInvalid)
+		}
+	}
+	return nil, fmt.Errorf("%w: truncated GIF", ErrUploadInvalid)
+}
+
+// gifSubBlocks returns the end of the sub-blocks starting at i.
+func gifSubBlocks(data []byte, i int) (int, error) {
+	for i < len(data) {
+		n := int(data[i])
+		i++
+		if n == 0 {
+			return i, nil
+		}
+		i += n
+	}
+	return 0, fmt.Errorf("%w: truncated GIF", ErrUploadInvalid)
+}
+
+func sanitizeWebP(data []byte, cfg image.Config, maxPixels int) ([]byte, error) {
+	end := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	if end > len(data) || end < 12 {
+		return nil, fmt.Errorf("%w: truncated WebP", ErrUploadInvalid)
+	}
+
+	out := append([]byte(nil), data[:12]...)
+	frames := 0
+	vp8x := -1
+	for i := 12; i < end; {
+		if i+8 > end {
+			return nil, fmt.Errorf("%w: truncated WebP", ErrUploadInvalid)
+		}
+		fourCC := string(data[i : i+4])
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		next := i + 8 + n + n&1
+		if n < 0 || next > end {
+			return nil, fmt.Errorf("%w: truncated WebP", ErrUploadInvalid)
+		}
+
+		if fourCC == "ANMF" {
+			frames++
+			if frames*cfg.Width*cfg.Height > animationBudget*maxPixels {
+				return nil, fmt.Errorf("%w: animation has too many frames", ErrUploadDimensions)
+			}
+		}
+		if webpKeepChunks[fourCC] {
+			if fourCC == "VP8X" && n > 0 {
+				vp8x = len(out) + 8
+			}
+			out = append(out, data[i:next]...)
+		}
+		i = next
+	}
+
+	// The extended header announces the metadata chunks that were dropped
+	if vp8x >= 0 {
+		out[vp8x] &^= 0x04 | 0x08
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, checkTrailing(data[end:])
+}
+
+// checkTrailing rejects data after the end of an image, where archives and
+// scripts are appended to make polyglots. Zero padding is tolerated.
+func checkTrailing(rest []byte) error {
+	for _, b := range rest {
+		if b != 0 {
+			return fmt.Errorf("%w: data after the end of the image", ErrUploadPolyglot)
+		}
+	}
+	return nil
+}
+
+// jpegOrientation reads the EXIF orientation (1 to 8) of a JPEG, 1 when it
+// has none.
+func jpegOrientation(data []byte) int {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // Image data starts
+			return 1
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return 1
+		}
+		if segment := data[i+4 : i+2+n]; marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + n
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	for k := range int(order.Uint16(tiff[ifd:])) {
+		entry := ifd + 2 + k*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient turns the image upright for an EXIF orientation.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2: // Flip horizontally
+				dx, dy = w-1-x, y
+			case 3: // Rotate 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // Flip vertically
+				dx, dy = x, h-1-y
+			case 5: // Transpose
+				dx, dy = y, x
+			case 6: // Rotate 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // Transverse
+				dx, dy = h-1-y, w-1-x
+			default: // Rotate 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+const (
+	testMaxDimension = 1000
+	testMaxPixels    = 10_000
+)
+
+// A ZIP local file header, as appended to make a polyglot
+var zipHeader = []byte("PK\x03\x04\x14\x00\x00\x00")
+
+// A 1x1 lossless WebP
+var tinyWebP, _ = base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+
+func solid(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	return img
+}
+
+func encodePNG(img image.Image) []byte {
+	var b bytes.Buffer
+	png.Encode(&b, img)
+	return b.Bytes()
+}
+
+// appended copies data with more bytes after it.
+func appended(data []byte, more ...byte) []byte {
+	return append(append([]byte(nil), data...), more...)
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// withPNGChunks inserts chunks right after the IHDR chunk.
+func withPNGChunks(data []byte, chunks ...[]byte) []byte {
+	ihdrEnd := 8 + 12 + 13
+	out := append([]byte(nil), data[:ihdrEnd]...)
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return append(out, data[ihdrEnd:]...)
+}
+
+// withPNGSize rewrites the size in the IHDR chunk.
+func withPNGSize(data []byte, w, h uint32) []byte {
+	out := append([]byte(nil), data...)
+	binary.BigEndian.PutUint32(out[16:], w)
+	binary.BigEndian.PutUint32(out[20:], h)
+	binary.BigEndian.PutUint32(out[29:], crc32.ChecksumIEEE(out[12:29]))
+	return out
+}
+
+func encodeGIF(frames, w, h int, extra ...[]byte) []byte {
+	anim := &gif.GIF{LoopCount: 0}
+	palette := color.Palette{color.Black, color.White}
+	for range frames {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, w, h), palette))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var b bytes.Buffer
+	gif.EncodeAll(&b, anim)
+	data := b.Bytes()
+	if len(extra) == 0 {
+		return data
+	}
+	// Extensions go before the trailer
+	out := append([]byte(nil), data[:len(data)-1]...)
+	for _, e := range extra {
+		out = append(out, e...)
+	}
+	return append(out, 0x3B)
+}
+
+func webpChunk(fourCC string, data []byte) []byte {
+	chunk := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// extendedWebP builds a VP8X WebP of a w x h canvas with flags and chunks.
+func extendedWebP(flags byte, w, h int, chunks ...[]byte) []byte {
+	vp8x := []byte{flags, 0, 0, 0}
+	vp8x = append(vp8x, byte(w-1), byte((w-1)>>8), byte((w-1)>>16))
+	vp8x = append(vp8x, byte(h-1), byte((h-1)>>8), byte((h-1)>>16))
+	body := append([]byte("WEBP"), webpChunk("VP8X", vp8x)...)
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	out := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(out, body...)
+}
+
+// The VP8L chunk of tinyWebP
+var tinyVP8L = tinyWebP[12:]
+
+// exifSegment builds an EXIF APP1 segment with an orientation and a GPS
+// latitude.
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	// IFD0: orientation and the GPS IFD pointer
+	tiff = binary.BigEndian.AppendUint16(tiff, 2)
+	tiff = append(tiff, 0x01, 0x12, 0x00, 0x03, 0, 0, 0, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0)
+	tiff = append(tiff, 0x88, 0x25, 0x00, 0x04, 0, 0, 0, 1)
+	tiff = binary.BigEndian.AppendUint32(tiff, uint32(8+2+2*12+4))
+	tiff = append(tiff, 0, 0, 0, 0)
+	// GPS IFD: latitude reference "N"
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = append(tiff, 0x00, 0x01, 0x00, 0x02, 0, 0, 0, 2, 'N', 0, 0, 0)
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	white = color.RGBA{255, 255, 255, 255}
+)
+
+// quadrants is a 64x32 JPEG whose quadrants are red, green (top) and blue,
+// white (bottom), with the EXIF segment right after the start of image.
+func quadrants(exif []byte) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for y := range 32 {
+		for x := range 64 {
+			c := [2][2]color.RGBA{{red, green}, {blue, white}}[y/16][x/32]
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var b bytes.Buffer
+	jpeg.Encode(&b, img, &jpeg.Options{Quality: 95})
+	data := b.Bytes()
+	return append(append(append([]byte(nil), data[:2]...), exif...), data[2:]...)
+}
+
+// nearest names the test color closest to c.
+func nearest(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	names := map[string]color.RGBA{"red": red, "green": green, "blue": blue, "white": white}
+	best, bestDist := "", uint32(1<<32-1)
+	for name, want := range names {
+		wr, wg, wb, _ := want.RGBA()
+		d := diff(r, wr)/3 + diff(g, wg)/3 + diff(b, wb)/3
+		if d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func diff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestSanitizeJPEGOrientation(t *testing.T) {
+	// The colors of the displayed corners, top-left, top-right, bottom-left
+	// and bottom-right, for each EXIF orientation
+	want := map[uint16][4]string{
+		1: {"red", "green", "blue", "white"},
+		2: {"green", "red", "white", "blue"},
+		3: {"white", "blue", "green", "red"},
+		4: {"blue", "white", "red", "green"},
+		5: {"red", "blue", "green", "white"},
+		6: {"blue", "red", "white", "green"},
+		7: {"white", "green", "blue", "red"},
+		8: {"green", "white", "red", "blue"},
+	}
+
+	for orientation := uint16(1); orientation <= 8; orientation++ {
+		t.Run(fmt.Sprint(orientation), func(t *testing.T) {
+			out, err := sanitizeImage("image/jpeg", quadrants(exifSegment(orientation)), testMaxDimension, testMaxPixels)
+			if err != nil {
+				t.Fatalf("sanitizeImage: %v", err)
+			}
+			for _, marker := range []string{"Exif", "MM\x00\x2a"} {
+				if bytes.Contains(out, []byte(marker)) {
+					t.Errorf("output still contains %q", marker)
+				}
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("decode output: %v", err)
+			}
+			b := img.Bounds()
+			wantW, wantH := 64, 32
+			if orientation >= 5 {
+				wantW, wantH = 32, 64
+			}
+			if b.Dx() != wantW || b.Dy() != wantH {
+				t.Fatalf("size %dx%d, want %dx%d", b.Dx(), b.Dy(), wantW, wantH)
+			}
+			// Sample inside each corner quadrant, away from the edges
+			qx, qy := b.Dx()/4, b.Dy()/4
+			got := [4]string{
+				nearest(img.At(qx, qy)),
+				nearest(img.At(b.Dx()-qx, qy)),
+				nearest(img.At(qx, b.Dy()-qy)),
+				nearest(img.At(b.Dx()-qx, b.Dy()-qy)),
+			}
+			if got != want[orientation] {
+				t.Errorf("corners %v, want %v", got, want[orientation])
+			}
+		})
+	}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	plain := quadrants(nil)
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"no EXIF", plain, 1},
+		{"rotated", quadrants(exifSegment(6)), 6},
+		{"out of range", quadrants(exifSegment(9)), 1},
+		{"truncated segment", append([]byte{0xFF, 0xD8}, exifSegment(6)[:20]...), 1},
+		{"not a JPEG", []byte("GIF89a"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeImage(t *testing.T) {
+	smallPNG := encodePNG(solid(10, 10))
+	// As many pixels as allowed, so the budget is four frames
+	fullPNG := encodePNG(solid(100, 100))
+	metadataPNG := withPNGChunks(smallPNG,
+		pngChunk("tEXt", []byte("Comment\x00<script>alert(1)</script>")),
+		pngChunk("eXIf", []byte("MM\x00\x2agps")),
+		pngChunk("gAMA", []byte{0, 0, 0xB1, 0x8F}),
+	)
+	frames := func(n int) [][]byte {
+		var chunks [][]byte
+		chunks = append(chunks, pngChunk("acTL", make([]byte, 8)))
+		for range n {
+			chunks = append(chunks, pngChunk("fcTL", make([]byte, 26)))
+		}
+		return chunks
+	}
+	comment := append([]byte{0x21, 0xFE, 5}, []byte("hello\x00")...)
+	exifWebP := extendedWebP(0x08, 1, 1, tinyVP8L, webpChunk("EXIF", []byte("MM\x00\x2agps")))
+	anmf := func(n int) []byte {
+		chunks := [][]byte{webpChunk("ANIM", make([]byte, 6))}
+		for range n {
+			chunks = append(chunks, webpChunk("ANMF", make([]byte, 16)))
+		}
+		return extendedWebP(0x02, 10, 10, chunks...)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		want        error
+		dropped     []string // Must not be in the output
+		kept        []string // Must still be in the output
+	}{
+		{"png", "image/png", smallPNG, nil, nil, []string{"IDAT"}},
+		{"png metadata dropped", "image/png", metadataPNG, nil, []string{"tEXt", "<script>", "eXIf", "gps"}, []string{"gAMA"}},
+		{"png zero padding", "image/png", appended(smallPNG, 0, 0, 0), nil, nil, nil},
+		{"png with appended zip", "image/png", appended(smallPNG, zipHeader...), ErrUploadPolyglot, nil, nil},
+		{"png truncated chunk", "image/png", smallPNG[:len(smallPNG)-20], ErrUploadInvalid, nil, nil},
+		{"png chunk longer than the file", "image/png", withPNGChunks(smallPNG, []byte("\x7f\xff\xff\xfftEXt")), ErrUploadInvalid, nil, nil},
+		{"png unknown critical chunk", "image/png", withPNGChunks(smallPNG, pngChunk("HTML", []byte("<p>"))), ErrUploadInvalid, nil, nil},
+		{"png oversized header", "image/png", withPNGSize(smallPNG, 5000, 1), ErrUploadDimensions, nil, nil},
+		{"png too many pixels", "image/png", withPNGSize(smallPNG, 200, 200), ErrUploadDimensions, nil, nil},
+		{"apng within the frame budget", "image/png", withPNGChunks(fullPNG, frames(4)...), nil, nil, []string{"acTL", "fcTL"}},
+		{"apng over the frame budget", "image/png", withPNGChunks(fullPNG, frames(5)...), ErrUploadDimensions, nil, nil},
+
+		{"gif", "image/gif", encodeGIF(1, 10, 10), nil, nil, nil},
+		{"gif loop kept", "image/gif", encodeGIF(2, 10, 10), nil, nil, []string{"NETSCAPE2.0"}},
+		{"gif comment dropped", "image/gif", encodeGIF(1, 10, 10, comment), nil, []string{"hello"}, nil},
+		{"gif with appended zip", "image/gif", appended(encodeGIF(1, 10, 10), zipHeader...), ErrUploadPolyglot, nil, nil},
+		{"gif truncated", "image/gif", encodeGIF(2, 10, 10)[:60], ErrUploadInvalid, nil, nil},
+		{"gif within the frame budget", "image/gif", encodeGIF(4, 100, 100), nil, nil, nil},
+		{"gif over the frame budget", "image/gif", encodeGIF(5, 100, 100), ErrUploadDimensions, nil, nil},
+
+		{"webp", "image/webp", tinyWebP, nil, nil, []string{"VP8L"}},
+		{"webp exif dropped", "image/webp", exifWebP, nil, []string{"EXIF", "gps"}, []string{"VP8X", "VP8L"}},
+		{"webp with appended zip", "image/webp", appended(tinyWebP, zipHeader...), ErrUploadPolyglot, nil, nil},
+		{"webp truncated", "image/webp", tinyWebP[:len(tinyWebP)-4], ErrUploadInvalid, nil, nil},
+		{"webp within the frame budget", "image/webp", anmf(400), nil, nil, []string{"ANMF"}},
+		{"webp over the frame budget", "image/webp", anmf(401), ErrUploadDimensions, nil, nil},
+
+		{"jpeg truncated", "image/jpeg", quadrants(nil)[:200], ErrUploadInvalid, nil, nil},
+		{"not an image type", "image/svg+xml", []byte("<svg/>"), ErrUploadType, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := sanitizeImage(tt.contentType, tt.data, testMaxDimension, testMaxPixels)
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("sanitizeImage = %v, want %v", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sanitizeImage: %v", err)
+			}
+			for _, s := range tt.dropped {
+				if bytes.Contains(out, []byte(s)) {
+					t.Errorf("output still contains %q", s)
+				}
+			}
+			for _, s := range tt.kept {
+				if !bytes.Contains(out, []byte(s)) {
+					t.Errorf("output lost %q", s)
+				}
+			}
+			if !bytes.Contains([]byte(tt.name), []byte("budget")) {
+				if _, _, err := image.Decode(bytes.NewReader(out)); err != nil {
+					t.Errorf("output does not decode: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeWebPHeader(t *testing.T) {
+	out, err := sanitizeImage("image/webp", extendedWebP(0x08|0x04, 1, 1, tinyVP8L, webpChunk("XMP ", []byte("<x/>"))), testMaxDimension, testMaxPixels)
+	if err != nil {
+		t.Fatalf("sanitizeImage: %v", err)
+	}
+	// The header no longer announces EXIF or XMP, and the RIFF size matches
+	if flags := out[20]; flags&(0x04|0x08) != 0 {
+		t.Errorf("VP8X flags %#x still announce metadata", flags)
+	}
+	if size := binary.LittleEndian.Uint32(out[4:]); int(size) != len(out)-8 {
+		t.Errorf("RIFF size %d, want %d", size, len(out)-8)
+	}
+	if _, err := webp.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("output does not decode: %v", err)
+	}
+}
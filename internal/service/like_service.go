@@ -2,17 +2,63 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 	"time"
 
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
 	"anoa.com/telkomalumiforum/internal/model"
 	"anoa.com/telkomalumiforum/internal/repository"
+	"anoa.com/telkomalumiforum/pkg/queue"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrInvalidReaction = errors.New("invalid reaction")
+	ErrAlreadyLiked    = errors.New("already liked")
+	ErrAlreadyReacted  = errors.New("already reacted")
+)
+
+// reactScript moves ARGV[1] to the reaction whose set is KEYS[ARGV[2]]. KEYS[1]
+// is the likes set, which stands for the default reaction; the others are the
+// sets of the extra reactions. Returns 0 when the user already has that
+// reaction.
+var reactScript = redis.NewScript(`
+local current = 0
+if redis.call('SISMEMBER', KEYS[1], ARGV[1]) == 1 then
+	current = 1
+	for i = 2, #KEYS do
+		if redis.call('SISMEMBER', KEYS[i], ARGV[1]) == 1 then
+			current = i
+			break
+		end
+	end
+end
+local target = tonumber(ARGV[2])
+if current == target then
+	return 0
+end
+redis.call('SADD', KEYS[1], ARGV[1])
+if current > 1 then
+	redis.call('SREM', KEYS[current], ARGV[1])
+end
+if target > 1 then
+	redis.call('SADD', KEYS[target], ARGV[1])
+end
+return 1
+`)
+
+// LikeStats is what list endpoints show about a target's reactions.
+type LikeStats struct {
+	Count     int64
+	LikedByMe bool
+}
+
 type LikeService interface {
 	LikeThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error
 	UnlikeThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error
@@ -20,141 +66,416 @@ type LikeService interface {
 	UnlikePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error
 	GetThreadLikes(ctx context.Context, threadID uuid.UUID) (int64, error)
 	GetPostLikes(ctx context.Context, postID uuid.UUID) (int64, error)
+	GetThreadLikeStats(ctx context.Context, userID uuid.UUID, threadIDs []uuid.UUID) (map[uuid.UUID]LikeStats, error)
+	GetPostLikeStats(ctx context.Context, userID uuid.UUID, postIDs []uuid.UUID) (map[uuid.UUID]LikeStats, error)
 	CheckUserLikedThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (bool, error)
 	CheckUserLikedPost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (bool, error)
-	StartWorker(ctx context.Context)
+	ReactThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, reaction string) error
+	ReactPost(ctx context.Context, userID uuid.UUID, postID uuid.UUID, reaction string) error
+	GetThreadReactions(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*dto.ReactionSummary, error)
+	GetPostReactions(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (*dto.ReactionSummary, error)
+	GetThreadReactors(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, reaction string) ([]dto.AuthorResponse, error)
+	GetPostReactors(ctx context.Context, userID uuid.UUID, postID uuid.UUID, reaction string) ([]dto.AuthorResponse, error)
+	GetAvailableReactions() []dto.ReactionOption
+	Reconcile(ctx context.Context, repair bool) (*dto.LikeSyncReport, error)
+	Resync(ctx context.Context) (*dto.LikeSyncReport, error)
 }
 
 type likeService struct {
-	redisClient *redis.Client
+	redisClient         *redis.Client
 	likeRepo            repository.LikeRepository
 	threadRepo          repository.ThreadRepository
 	postRepo            repository.PostRepository
+	userRepo            repository.UserRepository
 	notificationService NotificationService
+	reactions           []dto.ReactionOption
+	jobs                *jobs.Manager
+	visibility          VisibilityPolicy
 }
 
-func NewLikeService(redisClient *redis.Client, likeRepo repository.LikeRepository, threadRepo repository.ThreadRepository, postRepo repository.PostRepository, notificationService NotificationService) LikeService {
-	return &likeService{
+func NewLikeService(redisClient *redis.Client, likeRepo repository.LikeRepository, threadRepo repository.ThreadRepository, postRepo repository.PostRepository, userRepo repository.UserRepository, notificationService NotificationService, visibility VisibilityPolicy, jobManager *jobs.Manager) LikeService {
+	s := &likeService{
 		redisClient:         redisClient,
 		likeRepo:            likeRepo,
 		threadRepo:          threadRepo,
 		postRepo:            postRepo,
+		userRepo:            userRepo,
 		notificationService: notificationService,
+		reactions:           loadReactions(),
+		jobs:                jobManager,
+		visibility:          visibility,
+	}
+
+	jobs.Register(jobManager, LikeJob, jobs.Options{
+		Queue:       LikeQueueKey,
+		Workers:     GetIntFromEnv("LIKE_WORKERS", 4),
+		MaxAttempts: GetIntFromEnv("LIKE_MAX_ATTEMPTS", 5),
+	}, s.processTask)
+
+	if redisClient != nil {
+		reconcileEvery := GetDurationFromEnv("LIKE_RECONCILE_INTERVAL", time.Hour)
+		jobManager.Schedule(LikeWarmUpJob, "@every 1m", 30*time.Minute, s.warmUpJob)
+		jobManager.Schedule("likes.reconcile", "@every "+reconcileEvery.String(), 30*time.Minute, s.reconcileJob)
 	}
+
+	return s
 }
 
 const (
-	LikeQueueKey = "like_queue"
+	LikeJob       = "likes.process"
+	LikeWarmUpJob = "likes.warmup"
+	LikeQueueKey  = "like_queue"
+
+	// DefaultReaction is the plain like (👍). Every reaction counts as a like
+	// for likes_count, so the legacy like endpoints map onto this reaction.
+	DefaultReaction = "like"
 )
 
+var defaultReactions = []dto.ReactionOption{
+	{Key: "like", Emoji: "👍"},
+	{Key: "love", Emoji: "❤️"},
+	{Key: "haha", Emoji: "😂"},
+	{Key: "wow", Emoji: "😮"},
+	{Key: "sad", Emoji: "😢"},
+	{Key: "pray", Emoji: "🙏"},
+}
+
+// loadReactions reads the reaction set from REACTIONS ("key=emoji,key=emoji").
+// The default 'like' reaction is always available and listed first.
+func loadReactions() []dto.ReactionOption {
+	raw := os.Getenv("REACTIONS")
+	if raw == "" {
+		return defaultReactions
+	}
+
+	reactions := []dto.ReactionOption{defaultReactions[0]}
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		if parts[0] == DefaultReaction {
+			reactions[0].Emoji = parts[1]
+			continue
+		}
+		reactions = append(reactions, dto.ReactionOption{Key: parts[0], Emoji: parts[1]})
+	}
+	return reactions
+}
+
 type LikeTask struct {
-	Type     string `json:"type"`      // "thread" or "post"
-	Action   string `json:"action"`    // "like" or "unlike"
+	Type     string `json:"type"`   // "thread" or "post"
+	Action   string `json:"action"` // "like" or "unlike"
 	UserID   string `json:"user_id"`
 	TargetID string `json:"target_id"`
+	Reaction string `json:"reaction,omitempty"` // Empty means DefaultReaction
+}
+
+// Redis layout:
+//
+//	<type>_likes:<id>               every user who reacted, whatever the reaction
+//	<type>_reactions:<id>:<reaction> users per non-default reaction
+//
+// Members of the likes set that are in no reaction set reacted with 👍, which
+// is how likes stored before reactions existed keep showing up as 👍.
+func likesKey(targetType string, targetID uuid.UUID) string {
+	return fmt.Sprintf("%s_likes:%s", targetType, targetID.String())
+}
+
+func reactionKey(targetType string, targetID uuid.UUID, reaction string) string {
+	return fmt.Sprintf("%s_reactions:%s:%s", targetType, targetID.String(), reaction)
+}
+
+func (s *likeService) GetAvailableReactions() []dto.ReactionOption {
+	return s.reactions
+}
+
+func (s *likeService) isValidReaction(reaction string) bool {
+	for _, r := range s.reactions {
+		if r.Key == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *likeService) emojiFor(reaction string) string {
+	for _, r := range s.reactions {
+		if r.Key == reaction {
+			return r.Emoji
+		}
+	}
+	return ""
+}
+
+// extraReactions returns every configured reaction except the default one.
+func (s *likeService) extraReactions() []dto.ReactionOption {
+	return s.reactions[1:]
 }
 
 func (s *likeService) LikeThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error {
-	key := fmt.Sprintf("thread_likes:%s", threadID.String())
-	
-	// 1. Check if user already liked in Redis
-	isMember, err := s.redisClient.SIsMember(ctx, key, userID.String()).Result()
-	if err != nil {
+	return s.react(ctx, "thread", userID, threadID, DefaultReaction)
+}
+
+func (s *likeService) UnlikeThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error {
+	return s.unreact(ctx, "thread", userID, threadID)
+}
+
+func (s *likeService) LikePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error {
+	return s.react(ctx, "post", userID, postID, DefaultReaction)
+}
+
+func (s *likeService) UnlikePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error {
+	return s.unreact(ctx, "post", userID, postID)
+}
+
+func (s *likeService) ReactThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, reaction string) error {
+	return s.react(ctx, "thread", userID, threadID, reaction)
+}
+
+func (s *likeService) ReactPost(ctx context.Context, userID uuid.UUID, postID uuid.UUID, reaction string) error {
+	return s.react(ctx, "post", userID, postID, reaction)
+}
+
+func (s *likeService) react(ctx context.Context, targetType string, userID uuid.UUID, targetID uuid.UUID, reaction string) error {
+	if !s.isValidReaction(reaction) {
+		return ErrInvalidReaction
+	}
+	if err := s.checkTarget(ctx, targetType, userID, targetID); err != nil {
 		return err
 	}
-	if isMember {
-		return fmt.Errorf("already liked")
+	if err := s.ensureLoaded(ctx, targetType, targetID); err != nil {
+		return err
 	}
 
-	// 2. Add to Redis
-	if err := s.redisClient.SAdd(ctx, key, userID.String()).Err(); err != nil {
+	// 1. Mark the target for the sync first, so a reconcile running meanwhile
+	// leaves it alone
+	pipe := s.redisClient.Pipeline()
+	s.touch(ctx, pipe, targetType, targetID)
+	if _, err := pipe.Exec(ctx); err != nil {
 		return err
 	}
 
+	// 2. Check the user's current reaction and move it, atomically so that
+	// concurrent requests cannot leave the user in two reaction sets
+	member := userID.String()
+	extras := s.extraReactions()
+	keys := make([]string, 0, len(extras)+1)
+	keys = append(keys, likesKey(targetType, targetID))
+	target := 1
+	for _, r := range extras {
+		keys = append(keys, reactionKey(targetType, targetID, r.Key))
+		if r.Key == reaction {
+			target = len(keys)
+		}
+	}
+	moved, err := reactScript.Run(ctx, s.redisClient, keys, member, target).Int()
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		if reaction == DefaultReaction {
+			return ErrAlreadyLiked
+		}
+		return ErrAlreadyReacted
+	}
+
 	// 3. Push to Worker Queue
 	task := LikeTask{
-		Type:     "thread",
+		Type:     targetType,
 		Action:   "like",
-		UserID:   userID.String(),
-		TargetID: threadID.String(),
+		UserID:   member,
+		TargetID: targetID.String(),
+		Reaction: reaction,
 	}
 	return s.pushTask(ctx, task)
 }
 
-func (s *likeService) UnlikeThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error {
-	key := fmt.Sprintf("thread_likes:%s", threadID.String())
-
-	// 1. Remove from Redis
-	// We don't necessarily need to check if exists, just remove. 
-	// But if strict:
-	// isMember, _ := s.redisClient.SIsMember(...)
-	
-	if err := s.redisClient.SRem(ctx, key, userID.String()).Err(); err != nil {
+func (s *likeService) unreact(ctx context.Context, targetType string, userID uuid.UUID, targetID uuid.UUID) error {
+	if err := s.checkTarget(ctx, targetType, userID, targetID); err != nil {
+		return err
+	}
+	if err := s.ensureLoaded(ctx, targetType, targetID); err != nil {
+		return err
+	}
+	member := userID.String()
+
+	// 1. Remove from Redis, whatever the reaction was
+	pipe := s.redisClient.TxPipeline()
+	pipe.SRem(ctx, likesKey(targetType, targetID), member)
+	for _, r := range s.extraReactions() {
+		pipe.SRem(ctx, reactionKey(targetType, targetID, r.Key), member)
+	}
+	s.touch(ctx, pipe, targetType, targetID)
+	if _, err := pipe.Exec(ctx); err != nil {
 		return err
 	}
 
 	// 2. Push to Worker Queue
 	task := LikeTask{
-		Type:     "thread",
+		Type:     targetType,
 		Action:   "unlike",
-		UserID:   userID.String(),
-		TargetID: threadID.String(),
+		UserID:   member,
+		TargetID: targetID.String(),
 	}
 	return s.pushTask(ctx, task)
 }
 
-func (s *likeService) LikePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error {
-	key := fmt.Sprintf("post_likes:%s", postID.String())
+// checkTarget fails with ErrThreadNotFound or ErrPostNotFound unless the
+// user can see the thread or post.
+func (s *likeService) checkTarget(ctx context.Context, targetType string, userID uuid.UUID, targetID uuid.UUID) error {
+	var err error
+	if targetType == "thread" {
+		_, err = s.visibility.Thread(ctx, userID, targetID)
+	} else {
+		_, err = s.visibility.Post(ctx, userID, targetID)
+	}
+	return err
+}
 
-	isMember, err := s.redisClient.SIsMember(ctx, key, userID.String()).Result()
-	if err != nil {
-		return err
+// currentReaction returns the user's reaction on the target, or "" if none.
+func (s *likeService) currentReaction(ctx context.Context, targetType string, userID uuid.UUID, targetID uuid.UUID) (string, error) {
+	member := userID.String()
+	extras := s.extraReactions()
+
+	pipe := s.redisClient.Pipeline()
+	likedCmd := pipe.SIsMember(ctx, likesKey(targetType, targetID), member)
+	cmds := make([]*redis.BoolCmd, len(extras))
+	for i, r := range extras {
+		cmds[i] = pipe.SIsMember(ctx, reactionKey(targetType, targetID, r.Key), member)
 	}
-	if isMember {
-		return fmt.Errorf("already liked")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
 	}
 
-	if err := s.redisClient.SAdd(ctx, key, userID.String()).Err(); err != nil {
-		return err
+	if !likedCmd.Val() {
+		return "", nil
 	}
-
-	task := LikeTask{
-		Type:     "post",
-		Action:   "like",
-		UserID:   userID.String(),
-		TargetID: postID.String(),
+	for i, r := range extras {
+		if cmds[i].Val() {
+			return r.Key, nil
+		}
 	}
-	return s.pushTask(ctx, task)
+	return DefaultReaction, nil
 }
 
-func (s *likeService) UnlikePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error {
-	key := fmt.Sprintf("post_likes:%s", postID.String())
-
-	if err := s.redisClient.SRem(ctx, key, userID.String()).Err(); err != nil {
-		return err
+func (s *likeService) GetThreadLikes(ctx context.Context, threadID uuid.UUID) (int64, error) {
+	if err := s.ensureLoaded(ctx, "thread", threadID); err != nil {
+		return 0, err
 	}
+	return s.redisClient.SCard(ctx, likesKey("thread", threadID)).Result()
+}
 
-	task := LikeTask{
-		Type:     "post",
-		Action:   "unlike",
-		UserID:   userID.String(),
-		TargetID: postID.String(),
+func (s *likeService) GetPostLikes(ctx context.Context, postID uuid.UUID) (int64, error) {
+	if err := s.ensureLoaded(ctx, "post", postID); err != nil {
+		return 0, err
 	}
-	return s.pushTask(ctx, task)
+	return s.redisClient.SCard(ctx, likesKey("post", postID)).Result()
 }
 
-func (s *likeService) GetThreadLikes(ctx context.Context, threadID uuid.UUID) (int64, error) {
-	key := fmt.Sprintf("thread_likes:%s", threadID.String())
-	return s.redisClient.SCard(ctx, key).Result()
+// GetThreadLikeStats loads counts and the user's own state for a page of
+// threads in one pipelined round trip. Pass uuid.Nil for anonymous callers.
+func (s *likeService) GetThreadLikeStats(ctx context.Context, userID uuid.UUID, threadIDs []uuid.UUID) (map[uuid.UUID]LikeStats, error) {
+	return s.likeStats(ctx, "thread", userID, threadIDs)
 }
 
-func (s *likeService) GetPostLikes(ctx context.Context, postID uuid.UUID) (int64, error) {
-	key := fmt.Sprintf("post_likes:%s", postID.String())
-	return s.redisClient.SCard(ctx, key).Result()
+// GetPostLikeStats is GetThreadLikeStats for posts.
+func (s *likeService) GetPostLikeStats(ctx context.Context, userID uuid.UUID, postIDs []uuid.UUID) (map[uuid.UUID]LikeStats, error) {
+	return s.likeStats(ctx, "post", userID, postIDs)
+}
+
+func (s *likeService) likeStats(ctx context.Context, targetType string, userID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]LikeStats, error) {
+	stats := make(map[uuid.UUID]LikeStats, len(ids))
+	if len(ids) == 0 {
+		return stats, nil
+	}
+
+	cold := ids
+	if s.redisClient != nil {
+		member := userID.String()
+		pipe := s.redisClient.Pipeline()
+		counts := make([]*redis.IntCmd, len(ids))
+		loaded := make([]*redis.IntCmd, len(ids))
+		liked := make([]*redis.BoolCmd, len(ids))
+		for i, id := range ids {
+			key := likesKey(targetType, id)
+			counts[i] = pipe.SCard(ctx, key)
+			loaded[i] = pipe.Exists(ctx, likesLoadedKey(targetType, id))
+			if userID != uuid.Nil {
+				liked[i] = pipe.SIsMember(ctx, key, member)
+			}
+		}
+
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Failed to load %s likes from redis, using database: %v", targetType, err)
+		} else {
+			// An empty set is indistinguishable from a key that was never
+			// loaded, so those targets go to the database unless they are
+			// marked as loaded without likes
+			cold = nil
+			for i, id := range ids {
+				if counts[i].Val() == 0 {
+					if loaded[i].Val() == 0 {
+						cold = append(cold, id)
+					} else {
+						stats[id] = LikeStats{}
+					}
+					continue
+				}
+				st := LikeStats{Count: counts[i].Val()}
+				if liked[i] != nil {
+					st.LikedByMe = liked[i].Val()
+				}
+				stats[id] = st
+			}
+		}
+	}
+
+	if len(cold) == 0 {
+		return stats, nil
+	}
+
+	var (
+		counts map[uuid.UUID]int64
+		liked  = map[uuid.UUID]bool{}
+		err    error
+	)
+	if targetType == "thread" {
+		counts, err = s.likeRepo.CountThreadLikes(ctx, cold)
+		if err == nil && userID != uuid.Nil {
+			liked, err = s.likeRepo.FindLikedThreadIDs(ctx, userID, cold)
+		}
+	} else {
+		counts, err = s.likeRepo.CountPostLikes(ctx, cold)
+		if err == nil && userID != uuid.Nil {
+			liked, err = s.likeRepo.FindLikedPostIDs(ctx, userID, cold)
+		}
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	for _, id := range cold {
+		stats[id] = LikeStats{Count: counts[id], LikedByMe: liked[id]}
+	}
+
+	// Load the sets back, or mark targets without likes as loaded, so the
+	// next page is served from Redis
+	if s.redisClient != nil {
+		go func() {
+			if err := s.rehydrate(context.Background(), targetType, cold); err != nil {
+				log.Printf("Failed to rehydrate %s likes: %v", targetType, err)
+			}
+		}()
+	}
+	return stats, nil
 }
 
 func (s *likeService) CheckUserLikedThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (bool, error) {
-	key := fmt.Sprintf("thread_likes:%s", threadID.String())
+	if err := s.checkTarget(ctx, "thread", userID, threadID); err != nil {
+		return false, err
+	}
+	key := likesKey("thread", threadID)
 	isMember, err := s.redisClient.SIsMember(ctx, key, userID.String()).Result()
 	if err == nil && isMember {
 		return true, nil
@@ -164,7 +485,10 @@ func (s *likeService) CheckUserLikedThread(ctx context.Context, userID uuid.UUID
 }
 
 func (s *likeService) CheckUserLikedPost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (bool, error) {
-	key := fmt.Sprintf("post_likes:%s", postID.String())
+	if err := s.checkTarget(ctx, "post", userID, postID); err != nil {
+		return false, err
+	}
+	key := likesKey("post", postID)
 	isMember, err := s.redisClient.SIsMember(ctx, key, userID.String()).Result()
 	if err == nil && isMember {
 		return true, nil
@@ -172,117 +496,240 @@ func (s *likeService) CheckUserLikedPost(ctx context.Context, userID uuid.UUID,
 	return s.likeRepo.IsPostLiked(ctx, userID, postID)
 }
 
-func (s *likeService) pushTask(ctx context.Context, task LikeTask) error {
-	bytes, err := json.Marshal(task)
+func (s *likeService) GetThreadReactions(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*dto.ReactionSummary, error) {
+	return s.reactionSummary(ctx, "thread", userID, threadID)
+}
+
+func (s *likeService) GetPostReactions(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (*dto.ReactionSummary, error) {
+	return s.reactionSummary(ctx, "post", userID, postID)
+}
+
+func (s *likeService) reactionSummary(ctx context.Context, targetType string, userID uuid.UUID, targetID uuid.UUID) (*dto.ReactionSummary, error) {
+	if err := s.checkTarget(ctx, targetType, userID, targetID); err != nil {
+		return nil, err
+	}
+	if err := s.ensureLoaded(ctx, targetType, targetID); err != nil {
+		return nil, err
+	}
+	extras := s.extraReactions()
+
+	pipe := s.redisClient.Pipeline()
+	totalCmd := pipe.SCard(ctx, likesKey(targetType, targetID))
+	cmds := make([]*redis.IntCmd, len(extras))
+	for i, r := range extras {
+		cmds[i] = pipe.SCard(ctx, reactionKey(targetType, targetID, r.Key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	total := totalCmd.Val()
+	likeCount := total
+	counts := make([]dto.ReactionCount, 0, len(s.reactions))
+	for i, r := range extras {
+		count := cmds[i].Val()
+		likeCount -= count
+		counts = append(counts, dto.ReactionCount{Reaction: r.Key, Emoji: r.Emoji, Count: count})
+	}
+	if likeCount < 0 {
+		likeCount = 0
+	}
+	counts = append([]dto.ReactionCount{{Reaction: DefaultReaction, Emoji: s.emojiFor(DefaultReaction), Count: likeCount}}, counts...)
+
+	summary := &dto.ReactionSummary{
+		Total:     total,
+		Reactions: counts,
+	}
+
+	current, err := s.currentReaction(ctx, targetType, userID, targetID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if current != "" {
+		summary.MyReaction = &current
 	}
-	return s.redisClient.RPush(ctx, LikeQueueKey, bytes).Err()
+
+	return summary, nil
 }
 
-func (s *likeService) StartWorker(ctx context.Context) {
-	log.Println("❤️ Like Worker Started...")
-	for {
-		// BLPOP blocks until item available
-		// 0 timeout means block indefinitely
-		res, err := s.redisClient.BLPop(ctx, 0, LikeQueueKey).Result()
-		if err != nil {
-			// If context cancelled or connection error
-			if ctx.Err() != nil {
-				return
-			}
-			log.Printf("Redis BLPOP error: %v, retrying in 1s...", err)
-			time.Sleep(1 * time.Second)
-			continue
-		}
+func (s *likeService) GetThreadReactors(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, reaction string) ([]dto.AuthorResponse, error) {
+	return s.reactors(ctx, "thread", userID, threadID, reaction)
+}
 
-		// res[0] is key, res[1] is value
-		if len(res) < 2 {
-			continue
-		}
-		
-		var task LikeTask
-		if err := json.Unmarshal([]byte(res[1]), &task); err != nil {
-			log.Printf("Invalid like task json: %v", err)
-			continue
+func (s *likeService) GetPostReactors(ctx context.Context, userID uuid.UUID, postID uuid.UUID, reaction string) ([]dto.AuthorResponse, error) {
+	return s.reactors(ctx, "post", userID, postID, reaction)
+}
+
+func (s *likeService) reactors(ctx context.Context, targetType string, userID uuid.UUID, targetID uuid.UUID, reaction string) ([]dto.AuthorResponse, error) {
+	if !s.isValidReaction(reaction) {
+		return nil, ErrInvalidReaction
+	}
+	if err := s.checkTarget(ctx, targetType, userID, targetID); err != nil {
+		return nil, err
+	}
+	if err := s.ensureLoaded(ctx, targetType, targetID); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	var err error
+	if reaction == DefaultReaction {
+		keys := []string{likesKey(targetType, targetID)}
+		for _, r := range s.extraReactions() {
+			keys = append(keys, reactionKey(targetType, targetID, r.Key))
 		}
+		ids, err = s.redisClient.SDiff(ctx, keys...).Result()
+	} else {
+		ids, err = s.redisClient.SMembers(ctx, reactionKey(targetType, targetID, reaction)).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		s.processTask(ctx, task)
+	users, err := s.userRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
 	}
+
+	authors := make([]dto.AuthorResponse, 0, len(users))
+	for _, u := range users {
+		authors = append(authors, dto.AuthorResponse{
+			Username:  u.Username,
+			AvatarURL: u.AvatarURL,
+		})
+	}
+	return authors, nil
+}
+
+func (s *likeService) pushTask(ctx context.Context, task LikeTask) error {
+	return s.jobs.Enqueue(ctx, LikeJob, task)
 }
 
-func (s *likeService) processTask(ctx context.Context, task LikeTask) {
+// processTask stores the user's reaction on the target. Workers handle tasks
+// in parallel, so a like and the unlike after it can run in either order;
+// instead of replaying its action the task stores the state Redis holds now,
+// which already reflects the latest one. The state is read again after the
+// write, and written again when a newer action changed it in between.
+func (s *likeService) processTask(ctx context.Context, task LikeTask) error {
 	userID, err := uuid.Parse(task.UserID)
 	if err != nil {
-		log.Printf("Invalid UserID in like task: %v", err)
-		return
+		return queue.Permanent(fmt.Errorf("invalid user id in like task: %w", err))
 	}
 	targetID, err := uuid.Parse(task.TargetID)
 	if err != nil {
-		log.Printf("Invalid TargetID in like task: %v", err)
-		return
+		return queue.Permanent(fmt.Errorf("invalid target id in like task: %w", err))
+	}
+	if task.Type != "thread" && task.Type != "post" {
+		return queue.Permanent(fmt.Errorf("unknown like task type %q", task.Type))
 	}
 
-	var opErr error
-	switch task.Type {
-		case "thread":
-			if task.Action == "like" {
-				opErr = s.likeRepo.LikeThread(ctx, userID, targetID)
-				if opErr == nil {
-					// Notify Thread Author
-					thread, err := s.threadRepo.FindByID(ctx, targetID)
-					if err == nil && thread.UserID != userID {
-						notif := &model.Notification{
-							UserID:     thread.UserID,
-							ActorID:    userID,
-							EntityID:   thread.ID,
-							EntitySlug: thread.Slug,
-							EntityType: "thread",
-							Type:       "like_thread",
-							Message:    "Someone liked your thread",
-						}
-						_ = s.notificationService.CreateNotification(ctx, notif)
-					}
-				}
-			} else {
-				opErr = s.likeRepo.UnlikeThread(ctx, userID, targetID)
-			}
-		case "post":
-			if task.Action == "like" {
-				opErr = s.likeRepo.LikePost(ctx, userID, targetID)
-				if opErr == nil {
-					// Notify Post Author
-					post, err := s.postRepo.FindByID(ctx, targetID)
-					if err == nil && post.UserID != userID {
-						// Need thread for slug
-						// post doesn't usually preload thread unless FindByID does.
-						// Safest is to fetch thread.
-						thread, errThread := s.threadRepo.FindByID(ctx, post.ThreadID)
-						var slug string
-						if errThread == nil {
-							slug = thread.Slug
-						}
-
-						notif := &model.Notification{
-							UserID:     post.UserID,
-							ActorID:    userID,
-							EntityID:   post.ID,
-							EntitySlug: slug,
-							EntityType: "post",
-							Type:       "like_post",
-							Message:    "Someone liked your post",
-						}
-						_ = s.notificationService.CreateNotification(ctx, notif)
-					}
-				}
-			} else {
-				opErr = s.likeRepo.UnlikePost(ctx, userID, targetID)
+	reaction, err := s.taskState(ctx, task, userID, targetID)
+	if err != nil {
+		return err
+	}
+	for range 3 {
+		created, err := s.storeReaction(ctx, task.Type, userID, targetID, reaction)
+		if err != nil {
+			// Reacting is an upsert and unliking is idempotent, so retrying is safe
+			return fmt.Errorf("failed to process like task: %w", err)
+		}
+
+		latest, err := s.taskState(ctx, task, userID, targetID)
+		if err != nil {
+			return err
+		}
+		if latest == reaction {
+			if created {
+				s.notifyReaction(ctx, task.Type, userID, targetID, reaction)
 			}
+			return nil
 		}
+		reaction = latest
+	}
+	return fmt.Errorf("reaction of %s on %s %s keeps changing", task.UserID, task.Type, task.TargetID)
+}
 
-	if opErr != nil {
-		// Log error, maybe retry? 
-		// For duplicates on 'like', we might ignore.
-		log.Printf("Failed to process like task %+v: %v", task, opErr)
+// taskState returns the reaction to store for the task, "" for none: the
+// user's current one in Redis. Only when Redis lost its data and was not
+// warmed up yet does the task's own action decide.
+func (s *likeService) taskState(ctx context.Context, task LikeTask, userID uuid.UUID, targetID uuid.UUID) (string, error) {
+	if s.redisClient != nil {
+		hydrated, err := s.redisClient.Exists(ctx, likesHydratedKey).Result()
+		if err != nil {
+			return "", err
+		}
+		if hydrated > 0 {
+			return s.currentReaction(ctx, task.Type, userID, targetID)
+		}
+	}
+
+	if task.Action != "like" {
+		return "", nil
+	}
+	if task.Reaction == "" {
+		return DefaultReaction, nil
+	}
+	return task.Reaction, nil
+}
+
+// storeReaction upserts the reaction, or deletes the user's reaction when it
+// is "". created reports a reaction where there was none.
+func (s *likeService) storeReaction(ctx context.Context, targetType string, userID uuid.UUID, targetID uuid.UUID, reaction string) (bool, error) {
+	switch {
+	case targetType == "thread" && reaction == "":
+		return false, s.likeRepo.UnlikeThread(ctx, userID, targetID)
+	case targetType == "thread":
+		return s.likeRepo.ReactThread(ctx, userID, targetID, reaction)
+	case reaction == "":
+		return false, s.likeRepo.UnlikePost(ctx, userID, targetID)
+	default:
+		return s.likeRepo.ReactPost(ctx, userID, targetID, reaction)
+	}
+}
+
+// notifyReaction tells the author of the thread or post about a new reaction.
+func (s *likeService) notifyReaction(ctx context.Context, targetType string, userID uuid.UUID, targetID uuid.UUID, reaction string) {
+	if targetType == "thread" {
+		// Notify Thread Author
+		thread, err := s.threadRepo.FindByID(ctx, targetID)
+		if err != nil || thread.UserID == userID {
+			return
+		}
+		notif := &model.Notification{
+			UserID:     thread.UserID,
+			ActorID:    userID,
+			EntityID:   thread.ID,
+			EntitySlug: thread.Slug,
+			EntityType: "thread",
+			Type:       "like_thread",
+			Message:    fmt.Sprintf("Someone reacted %s to your thread", s.emojiFor(reaction)),
+		}
+		_ = s.notificationService.AggregateNotification(ctx, notif, func(count int) string {
+			return fmt.Sprintf("%d people reacted to your thread", count)
+		})
+		return
+	}
+
+	// Notify Post Author
+	post, err := s.postRepo.FindByID(ctx, targetID)
+	if err != nil || post.UserID == userID {
+		return
+	}
+	// Need thread for slug. Skip authors who can no longer see the thread.
+	thread, err := s.visibility.Thread(ctx, post.UserID, post.ThreadID)
+	if err != nil {
+		return
+	}
+	notif := &model.Notification{
+		UserID:     post.UserID,
+		ActorID:    userID,
+		EntityID:   post.ID,
+		EntitySlug: thread.Slug,
+		EntityType: "post",
+		Type:       "like_post",
+		Message:    fmt.Sprintf("Someone reacted %s to your post", s.emojiFor(reaction)),
 	}
+	_ = s.notificationService.AggregateNotification(ctx, notif, func(count int) string {
+		return fmt.Sprintf("%d people reacted to your post", count)
+	})
 }
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// countingLikeRepo has no likes at all and counts the lookups that reach it.
+type countingLikeRepo struct {
+	repository.LikeRepository
+	mu      sync.Mutex
+	lookups int
+}
+
+func (r *countingLikeRepo) CountThreadLikes(ctx context.Context, threadIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	return map[uuid.UUID]int64{}, nil
+}
+
+func (r *countingLikeRepo) FindLikedThreadIDs(ctx context.Context, userID uuid.UUID, threadIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	return map[uuid.UUID]bool{}, nil
+}
+
+func (r *countingLikeRepo) FindThreadLikes(ctx context.Context, threadIDs []uuid.UUID) ([]model.ThreadLike, error) {
+	return nil, nil
+}
+
+func (r *countingLikeRepo) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups
+}
+
+func TestLikeStatsLoadedMarker(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := &countingLikeRepo{}
+	s := &likeService{redisClient: client, likeRepo: repo}
+	ctx := context.Background()
+
+	empty, liked := uuid.New(), uuid.New()
+	viewer := uuid.New()
+	client.SAdd(ctx, likesKey("thread", liked), viewer.String(), uuid.NewString())
+
+	stats, err := s.likeStats(ctx, "thread", viewer, []uuid.UUID{empty, liked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats[empty] != (LikeStats{}) || stats[liked] != (LikeStats{Count: 2, LikedByMe: true}) {
+		t.Fatalf("stats %+v", stats)
+	}
+	if repo.count() != 1 {
+		t.Fatalf("%d database lookups, want 1 for the empty thread", repo.count())
+	}
+
+	// The empty thread is marked as loaded in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Exists(ctx, likesLoadedKey("thread", empty)).Val() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("empty thread not marked as loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats, err = s.likeStats(ctx, "thread", viewer, []uuid.UUID{empty, liked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats[empty] != (LikeStats{}) || stats[liked].Count != 2 {
+		t.Errorf("stats %+v after marking", stats)
+	}
+	if repo.count() != 1 {
+		t.Errorf("%d database lookups, want none after the marker", repo.count()-1)
+	}
+}
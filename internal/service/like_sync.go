@@ -0,0 +1,459 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// The like tables in Postgres are the source of truth; the Redis sets are a
+// cache that can be rebuilt from them at any time. Targets written to in the
+// last LIKE_SYNC_GRACE may still have tasks in like_queue, so the database is
+// not trusted for them and they are left alone until the next pass.
+const (
+	likesHydratedKey = "likes:hydrated"
+	likesTouchedKey  = "likes:touched"
+	likesSyncLockKey = "likes:sync_lock"
+
+	likeSyncBatch    = 500
+	maxReportedDrift = 50
+)
+
+var ErrLikeSyncRunning = errors.New("like sync already running")
+
+// likeSyncReleaseScript deletes the sync lock only if this run still owns it.
+var likeSyncReleaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// likeRow is a stored reaction on either a thread or a post.
+type likeRow struct {
+	UserID   uuid.UUID
+	TargetID uuid.UUID
+	Reaction string
+}
+
+func touchedMember(targetType string, targetID uuid.UUID) string {
+	return targetType + ":" + targetID.String()
+}
+
+// likesLoadedKey marks a target found without likes in the database. Redis
+// drops empty sets, so without it every read of such a target would query
+// the database again.
+func likesLoadedKey(targetType string, targetID uuid.UUID) string {
+	return fmt.Sprintf("%s_likes_loaded:%s", targetType, targetID.String())
+}
+
+func (s *likeService) syncGrace() time.Duration {
+	return GetDurationFromEnv("LIKE_SYNC_GRACE", 2*time.Minute)
+}
+
+// touch records a write to the target so sync passes skip it for a while.
+func (s *likeService) touch(ctx context.Context, pipe redis.Pipeliner, targetType string, targetID uuid.UUID) {
+	pipe.ZAdd(ctx, likesTouchedKey, redis.Z{
+		Score:  float64(time.Now().Unix()),
+		Member: touchedMember(targetType, targetID),
+	})
+}
+
+func (s *likeService) recentlyTouched(ctx context.Context, targetType string, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	touched := make(map[uuid.UUID]bool)
+	if len(ids) == 0 {
+		return touched, nil
+	}
+
+	cutoff := float64(time.Now().Add(-s.syncGrace()).Unix())
+	pipe := s.redisClient.Pipeline()
+	cmds := make([]*redis.FloatCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.ZScore(ctx, likesTouchedKey, touchedMember(targetType, id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		if score, err := cmds[i].Result(); err == nil && score >= cutoff {
+			touched[id] = true
+		}
+	}
+	return touched, nil
+}
+
+func (s *likeService) loadRows(ctx context.Context, targetType string, ids []uuid.UUID) ([]likeRow, error) {
+	var rows []likeRow
+	if targetType == "thread" {
+		likes, err := s.likeRepo.FindThreadLikes(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range likes {
+			rows = append(rows, likeRow{UserID: l.UserID, TargetID: l.ThreadID, Reaction: l.Reaction})
+		}
+		return rows, nil
+	}
+
+	likes, err := s.likeRepo.FindPostLikes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range likes {
+		rows = append(rows, likeRow{UserID: l.UserID, TargetID: l.PostID, Reaction: l.Reaction})
+	}
+	return rows, nil
+}
+
+// normalizeReaction maps reactions that were removed from REACTIONS to 👍.
+func (s *likeService) normalizeReaction(reaction string) string {
+	if reaction == "" || !s.isValidReaction(reaction) {
+		return DefaultReaction
+	}
+	return reaction
+}
+
+// writeRows adds the rows to the Redis sets. With replace, the sets of every
+// given target are cleared first so they match the rows exactly.
+func (s *likeService) writeRows(ctx context.Context, targetType string, ids []uuid.UUID, rows []likeRow, replace bool) error {
+	pipe := s.redisClient.TxPipeline()
+	if replace {
+		for _, id := range ids {
+			keys := []string{likesKey(targetType, id)}
+			for _, r := range s.extraReactions() {
+				keys = append(keys, reactionKey(targetType, id, r.Key))
+			}
+			pipe.Del(ctx, keys...)
+		}
+	}
+	for _, row := range rows {
+		member := row.UserID.String()
+		pipe.SAdd(ctx, likesKey(targetType, row.TargetID), member)
+		if reaction := s.normalizeReaction(row.Reaction); reaction != DefaultReaction {
+			pipe.SAdd(ctx, reactionKey(targetType, row.TargetID, reaction), member)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ensureLoaded rehydrates a target whose likes key is missing, so that a
+// write never starts from an empty set after Redis lost its data. Targets
+// without likes are marked for LIKE_EMPTY_TTL (default 10m) and not looked
+// up again meanwhile.
+func (s *likeService) ensureLoaded(ctx context.Context, targetType string, targetID uuid.UUID) error {
+	n, err := s.redisClient.Exists(ctx, likesKey(targetType, targetID), likesLoadedKey(targetType, targetID)).Result()
+	if err != nil || n > 0 {
+		return err
+	}
+	return s.rehydrate(ctx, targetType, []uuid.UUID{targetID})
+}
+
+// rehydrate loads cold targets from the database into Redis. Writes are
+// additive so a reaction landing in between is never lost.
+func (s *likeService) rehydrate(ctx context.Context, targetType string, ids []uuid.UUID) error {
+	touched, err := s.recentlyTouched(ctx, targetType, ids)
+	if err != nil {
+		return err
+	}
+
+	cold := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !touched[id] {
+			cold = append(cold, id)
+		}
+	}
+	if len(cold) == 0 {
+		return nil
+	}
+
+	rows, err := s.loadRows(ctx, targetType, cold)
+	if err != nil {
+		return err
+	}
+
+	liked := make(map[uuid.UUID]bool, len(cold))
+	for _, row := range rows {
+		liked[row.TargetID] = true
+	}
+	emptyTTL := GetDurationFromEnv("LIKE_EMPTY_TTL", 10*time.Minute)
+	pipe := s.redisClient.Pipeline()
+	for _, id := range cold {
+		if !liked[id] {
+			pipe.Set(ctx, likesLoadedKey(targetType, id), 1, emptyTTL)
+		}
+	}
+	if pipe.Len() > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+	return s.writeRows(ctx, targetType, cold, rows, false)
+}
+
+// Reconcile compares every target that has likes in either store and reports
+// the ones whose Redis sets differ from the database. With repair, drifted
+// sets are rewritten from the database.
+func (s *likeService) Reconcile(ctx context.Context, repair bool) (*dto.LikeSyncReport, error) {
+	// A run outliving the lock must not release the next run's lock
+	token := uuid.NewString()
+	locked, err := s.redisClient.SetNX(ctx, likesSyncLockKey, token, 30*time.Minute).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrLikeSyncRunning
+	}
+	defer likeSyncReleaseScript.Run(context.Background(), s.redisClient, []string{likesSyncLockKey}, token)
+
+	start := time.Now()
+	report := &dto.LikeSyncReport{
+		Repair:    repair,
+		Drift:     []dto.LikeDrift{},
+		StartedAt: start.Format("2006-01-02 15:04:05"),
+	}
+
+	// Forget writes older than the grace period
+	cutoff := fmt.Sprintf("(%d", time.Now().Add(-s.syncGrace()).Unix())
+	if err := s.redisClient.ZRemRangeByScore(ctx, likesTouchedKey, "-inf", cutoff).Err(); err != nil {
+		return nil, err
+	}
+
+	for _, targetType := range []string{"thread", "post"} {
+		// 1. Every target with likes in the database
+		after := uuid.Nil
+		for {
+			ids, err := s.findLikedTargetsAfter(ctx, targetType, after)
+			if err != nil {
+				return nil, err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			if err := s.reconcileBatch(ctx, targetType, ids, repair, report); err != nil {
+				return nil, err
+			}
+			after = ids[len(ids)-1]
+		}
+
+		// 2. Sets left in Redis for targets without likes in the database
+		prefix := targetType + "_likes:"
+		var cursor uint64
+		for {
+			keys, next, err := s.redisClient.Scan(ctx, cursor, prefix+"*", likeSyncBatch).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			ids := make([]uuid.UUID, 0, len(keys))
+			for _, key := range keys {
+				if id, err := uuid.Parse(strings.TrimPrefix(key, prefix)); err == nil {
+					ids = append(ids, id)
+				}
+			}
+			counts, err := s.countLikedTargets(ctx, targetType, ids)
+			if err != nil {
+				return nil, err
+			}
+
+			var stale []uuid.UUID
+			for _, id := range ids {
+				if counts[id] == 0 {
+					stale = append(stale, id)
+				}
+			}
+			if err := s.reconcileBatch(ctx, targetType, stale, repair, report); err != nil {
+				return nil, err
+			}
+
+			cursor = next
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+	return report, nil
+}
+
+func (s *likeService) reconcileBatch(ctx context.Context, targetType string, ids []uuid.UUID, repair bool, report *dto.LikeSyncReport) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	touched, err := s.recentlyTouched(ctx, targetType, ids)
+	if err != nil {
+		return err
+	}
+	rows, err := s.loadRows(ctx, targetType, ids)
+	if err != nil {
+		return err
+	}
+
+	stored := make(map[uuid.UUID]map[string]string, len(ids))
+	for _, row := range rows {
+		if stored[row.TargetID] == nil {
+			stored[row.TargetID] = make(map[string]string)
+		}
+		stored[row.TargetID][row.UserID.String()] = s.normalizeReaction(row.Reaction)
+	}
+
+	extras := s.extraReactions()
+	pipe := s.redisClient.Pipeline()
+	likeCmds := make([]*redis.StringSliceCmd, len(ids))
+	reactionCmds := make([][]*redis.StringSliceCmd, len(ids))
+	for i, id := range ids {
+		likeCmds[i] = pipe.SMembers(ctx, likesKey(targetType, id))
+		reactionCmds[i] = make([]*redis.StringSliceCmd, len(extras))
+		for j, r := range extras {
+			reactionCmds[i][j] = pipe.SMembers(ctx, reactionKey(targetType, id, r.Key))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	var drifted []uuid.UUID
+	isDrifted := make(map[uuid.UUID]bool)
+	for i, id := range ids {
+		if touched[id] {
+			report.Skipped++
+			continue
+		}
+		report.TargetsChecked++
+
+		cached := make(map[string]string)
+		for _, member := range likeCmds[i].Val() {
+			cached[member] = DefaultReaction
+		}
+		for j, r := range extras {
+			for _, member := range reactionCmds[i][j].Val() {
+				cached[member] = r.Key
+			}
+		}
+
+		if sameReactions(cached, stored[id]) {
+			continue
+		}
+
+		drifted = append(drifted, id)
+		isDrifted[id] = true
+		report.Drifted++
+		if len(report.Drift) < maxReportedDrift {
+			report.Drift = append(report.Drift, dto.LikeDrift{
+				Type:       targetType,
+				TargetID:   id,
+				RedisCount: int64(len(likeCmds[i].Val())),
+				DBCount:    int64(len(stored[id])),
+			})
+		}
+	}
+
+	if !repair || len(drifted) == 0 {
+		return nil
+	}
+
+	var driftedRows []likeRow
+	for _, row := range rows {
+		if isDrifted[row.TargetID] {
+			driftedRows = append(driftedRows, row)
+		}
+	}
+	if err := s.writeRows(ctx, targetType, drifted, driftedRows, true); err != nil {
+		return err
+	}
+	report.Repaired += len(drifted)
+	return nil
+}
+
+func sameReactions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for user, reaction := range a {
+		if b[user] != reaction {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *likeService) findLikedTargetsAfter(ctx context.Context, targetType string, after uuid.UUID) ([]uuid.UUID, error) {
+	if targetType == "thread" {
+		return s.likeRepo.FindLikedThreadIDsAfter(ctx, after, likeSyncBatch)
+	}
+	return s.likeRepo.FindLikedPostIDsAfter(ctx, after, likeSyncBatch)
+}
+
+func (s *likeService) countLikedTargets(ctx context.Context, targetType string, ids []uuid.UUID) (map[uuid.UUID]int64, error) {
+	if targetType == "thread" {
+		return s.likeRepo.CountThreadLikes(ctx, ids)
+	}
+	return s.likeRepo.CountPostLikes(ctx, ids)
+}
+
+// Resync repairs every drifted target and marks Redis as hydrated.
+func (s *likeService) Resync(ctx context.Context) (*dto.LikeSyncReport, error) {
+	report, err := s.Reconcile(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisClient.Set(ctx, likesHydratedKey, time.Now().Unix(), 0).Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// WarmUp rebuilds the like sets when Redis comes up without them, e.g. after
+// a flush or a restart without persistence. The hydrated marker disappears
+// together with the data, which is how a cold Redis is detected.
+func (s *likeService) WarmUp(ctx context.Context) error {
+	n, err := s.redisClient.Exists(ctx, likesHydratedKey).Result()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	log.Println("❤️ Redis like sets are cold, rebuilding from database...")
+	report, err := s.Resync(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("❤️ Like warm-up done: %d targets, %d rebuilt in %s", report.TargetsChecked, report.Repaired, report.Duration)
+	return nil
+}
+
+// warmUpJob detects a flushed Redis within a minute.
+func (s *likeService) warmUpJob(ctx context.Context) error {
+	if err := s.WarmUp(ctx); err != nil && !errors.Is(err, ErrLikeSyncRunning) {
+		return err
+	}
+	return nil
+}
+
+// reconcileJob repairs drift left by lost queue tasks or manual edits.
+func (s *likeService) reconcileJob(ctx context.Context) error {
+	report, err := s.Reconcile(ctx, true)
+	if err != nil {
+		if errors.Is(err, ErrLikeSyncRunning) {
+			return nil
+		}
+		return err
+	}
+	if report.Drifted > 0 {
+		log.Printf("❤️ Like reconciliation repaired %d of %d targets", report.Repaired, report.TargetsChecked)
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"github.com/google/uuid"
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// Searchable indexes, in the order their results are merged.
+var searchIndexes = []string{"threads", "posts", "users", "categories"}
+
+// contentIndexes hold forum content. The audience and content filters only
+// apply to them; users and categories are public.
+var contentIndexes = map[string]bool{
+	"threads": true,
+	"posts":   true,
+}
+
+// Posts rank slightly below threads with the same relevance, people and
+// categories below both.
+var searchWeights = map[string]float64{
+	"threads":    1.0,
+	"posts":      0.9,
+	"users":      0.8,
+	"categories": 0.8,
+}
+
+var searchFacets = map[string][]string{
+	"threads": {"category.name", "audience"},
+	"posts":   {"category.name"},
+	"users":   {"role", "class_grade"},
+}
+
+// searchHighlights lists the attributes to highlight per index; the last
+// one is the long text that gets cropped.
+var searchHighlights = map[string][]string{
+	"threads":    {"title", "content"},
+	"posts":      {"thread_title", "content"},
+	"users":      {"username", "full_name", "bio"},
+	"categories": {"name", "description"},
+}
+
+// SearchQuery is a search already scoped to what the user may see. Roles
+// lists the allowed_roles values to match; nil means no restriction.
+type SearchQuery struct {
+	Query        string
+	Indexes      []string
+	Roles        []string
+	ExcludeUsers []uuid.UUID // Their threads, posts and profile are left out
+	CategoryID   string
+	Author       string
+	From         *time.Time
+	To           *time.Time
+	Strict       bool
+	Highlight    bool
+	Sort         []string // Only for single index searches
+	Offset       int
+	Limit        int
+}
+
+// searchRoles maps a user role to the allowed_roles values it may search.
+func searchRoles(role string) []string {
+	return audienceRoles(visibleAudiences(role))
+}
+
+// audienceRoles maps thread audiences to allowed_roles values; nil stays nil.
+func audienceRoles(audiences []string) []string {
+	if audiences == nil {
+		return nil
+	}
+	roles := make([]string, 0, len(audiences))
+	for _, audience := range audiences {
+		if audience == "semua" {
+			audience = "public"
+		}
+		roles = append(roles, audience)
+	}
+	return roles
+}
+
+func quoteFilterValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
+
+// filtersContent reports whether the query narrows content, which users and
+// categories cannot match.
+func (q SearchQuery) filtersContent() bool {
+	return q.CategoryID != "" || q.Author != "" || q.From != nil || q.To != nil
+}
+
+// rolesFilter matches documents open to one of the roles; nil roles match
+// everything.
+func rolesFilter(roles []string) string {
+	if roles == nil {
+		return ""
+	}
+	quoted := make([]string, len(roles))
+	for i, role := range roles {
+		quoted[i] = quoteFilterValue(role)
+	}
+	return "allowed_roles IN [" + strings.Join(quoted, ", ") + "]"
+}
+
+// visibleFilter leaves out content the visibility policy hides, i.e.
+// soft-deleted posts. Documents indexed before the attribute existed have no
+// "hidden" and match.
+const visibleFilter = "hidden != true"
+
+// contentFilter is the filter every search of threads and posts starts
+// with: what the roles may see, without hidden content and the excluded
+// users' documents.
+func contentFilter(roles []string, excludeUsers []uuid.UUID) []string {
+	conds := []string{visibleFilter}
+	if filter := rolesFilter(roles); filter != "" {
+		conds = append(conds, filter)
+	}
+	if filter := excludeUsersFilter("user_id", excludeUsers); filter != "" {
+		conds = append(conds, filter)
+	}
+	return conds
+}
+
+// excludeUsersFilter leaves out documents whose field is one of the users.
+func excludeUsersFilter(field string, ids []uuid.UUID) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = quoteFilterValue(id.String())
+	}
+	return field + " NOT IN [" + strings.Join(quoted, ", ") + "]"
+}
+
+func (q SearchQuery) filter(index string) string {
+	switch index {
+	case "users":
+		return excludeUsersFilter("id", q.ExcludeUsers)
+	case "categories":
+		return ""
+	}
+
+	conds := contentFilter(q.Roles, q.ExcludeUsers)
+	if q.CategoryID != "" {
+		conds = append(conds, "category_id = "+quoteFilterValue(q.CategoryID))
+	}
+	if q.Author != "" {
+		conds = append(conds, "user.username = "+quoteFilterValue(q.Author))
+	}
+	if q.From != nil {
+		conds = append(conds, fmt.Sprintf("created_at >= %d", q.From.Unix()))
+	}
+	if q.To != nil {
+		conds = append(conds, fmt.Sprintf("created_at < %d", q.To.Unix()))
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func (q SearchQuery) request(index string) *meilisearch.SearchRequest {
+	req := &meilisearch.SearchRequest{
+		IndexUID: index,
+		Query:    q.Query,
+	}
+	if filter := q.filter(index); filter != "" {
+		req.Filter = filter
+	}
+	if q.Strict {
+		req.MatchingStrategy = meilisearch.All
+	}
+	if q.Highlight {
+		attributes := searchHighlights[index]
+		req.AttributesToHighlight = attributes
+		req.AttributesToCrop = attributes[len(attributes)-1:]
+		req.CropLength = 40
+		req.HighlightPreTag = "<mark>"
+		req.HighlightPostTag = "</mark>"
+	}
+	return req
+}
+
+// Search runs one federated query over the requested indexes and merges the
+// hits by relevance. Facet counts are merged across indexes. Content filters
+// leave users and categories out.
+func (s *meiliSearchService) Search(ctx context.Context, q SearchQuery) (*dto.SearchResponse, error) {
+	indexes := q.Indexes
+	if len(indexes) == 0 {
+		indexes = searchIndexes
+	}
+
+	facets := make(map[string][]string, len(indexes))
+	queries := make([]*meilisearch.SearchRequest, 0, len(indexes))
+	for _, index := range indexes {
+		if !contentIndexes[index] && q.filtersContent() {
+			continue
+		}
+		req := q.request(index)
+		req.FederationOptions = &meilisearch.SearchFederationOptions{Weight: searchWeights[index]}
+		queries = append(queries, req)
+		if len(searchFacets[index]) > 0 {
+			facets[index] = searchFacets[index]
+		}
+	}
+	if len(queries) == 0 {
+		return &dto.SearchResponse{
+			Query:  q.Query,
+			Hits:   []dto.SearchHit{},
+			Limit:  q.Limit,
+			Facets: map[string]map[string]int{},
+		}, nil
+	}
+
+	resp, err := s.client.MultiSearchWithContext(ctx, &meilisearch.MultiSearchRequest{
+		Federation: &meilisearch.MultiSearchFederation{
+			Offset:        int64(q.Offset),
+			Limit:         int64(q.Limit),
+			FacetsByIndex: facets,
+			MergeFacets:   &meilisearch.MultiSearchFederationMergeFacets{},
+		},
+		Queries: queries,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.SearchResponse{
+		Query:            q.Query,
+		Hits:             make([]dto.SearchHit, 0, len(resp.Hits)),
+		EstimatedTotal:   resp.EstimatedTotalHits,
+		Limit:            q.Limit,
+		Facets:           make(map[string]map[string]int, len(resp.FacetDistribution)),
+		ProcessingTimeMs: resp.ProcessingTimeMs,
+	}
+	for _, hit := range resp.Hits {
+		searchHit, err := decodeSearchHit(hit)
+		if err != nil {
+			return nil, err
+		}
+		result.Hits = append(result.Hits, searchHit)
+	}
+	for facet, raw := range resp.FacetDistribution {
+		var counts map[string]int
+		if err := json.Unmarshal(raw, &counts); err != nil {
+			return nil, fmt.Errorf("failed to decode facet %s: %w", facet, err)
+		}
+		result.Facets[facet] = counts
+	}
+	return result, nil
+}
+
+// SearchThreadIDs returns the IDs of matching threads, most relevant first
+// unless q.Sort is set, with the estimated total.
+func (s *meiliSearchService) SearchThreadIDs(ctx context.Context, q SearchQuery) ([]uuid.UUID, int64, error) {
+	req := q.request("threads")
+	req.AttributesToRetrieve = []string{"id"}
+	req.Sort = q.Sort
+	req.Offset = int64(q.Offset)
+	req.Limit = int64(q.Limit)
+
+	resp, err := s.client.Index("threads").SearchWithContext(ctx, q.Query, req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(resp.Hits))
+	for _, hit := range resp.Hits {
+		var doc struct {
+			ID string `json:"id"`
+		}
+		if err := hit.DecodeInto(&doc); err != nil {
+			return nil, 0, err
+		}
+		id, err := uuid.Parse(doc.ID)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, resp.EstimatedTotalHits, nil
+}
+
+type searchHitDoc struct {
+	meiliThreadDoc
+	ThreadID    string              `json:"thread_id"`
+	ThreadSlug  string              `json:"thread_slug"`
+	ThreadTitle string              `json:"thread_title"`
+	Username    string              `json:"username"`
+	FullName    string              `json:"full_name"`
+	AvatarURL   string              `json:"avatar_url"`
+	Role        string              `json:"role"`
+	ClassGrade  string              `json:"class_grade"`
+	Bio         string              `json:"bio"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Formatted   *searchHitFormatted `json:"_formatted"`
+	Federation  struct {
+		IndexUID string `json:"indexUid"`
+	} `json:"_federation"`
+}
+
+type searchHitFormatted struct {
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	ThreadTitle string `json:"thread_title"`
+	Username    string `json:"username"`
+	FullName    string `json:"full_name"`
+	Bio         string `json:"bio"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// decodeSearchHit reads a document of any index into one struct, and the
+// federation metadata tells which index it came from.
+func decodeSearchHit(hit meilisearch.Hit) (dto.SearchHit, error) {
+	var doc searchHitDoc
+	if err := hit.DecodeInto(&doc); err != nil {
+		return dto.SearchHit{}, fmt.Errorf("failed to decode search hit: %w", err)
+	}
+
+	switch doc.Federation.IndexUID {
+	case "users":
+		return decodeUserHit(doc), nil
+	case "categories":
+		return decodeCategoryHit(doc), nil
+	}
+
+	result := dto.SearchHit{
+		ID:         doc.ID,
+		Title:      doc.Title,
+		Slug:       doc.Slug,
+		Content:    doc.Content,
+		CategoryID: doc.CategoryID,
+		Category:   doc.Category.Name,
+		Author: &dto.AuthorResponse{
+			Username: doc.User.Username,
+		},
+		CreatedAt: doc.CreatedAt,
+	}
+	if doc.User.AvatarURL != "" {
+		avatar := doc.User.AvatarURL
+		result.Author.AvatarURL = &avatar
+	}
+	if doc.Formatted != nil {
+		result.Title = doc.Formatted.Title
+		result.Content = doc.Formatted.Content
+		if doc.Formatted.ThreadTitle != "" {
+			doc.ThreadTitle = doc.Formatted.ThreadTitle
+		}
+	}
+
+	if doc.Federation.IndexUID == "posts" {
+		result.Type = "post"
+		result.Title = ""
+		result.ThreadID = doc.ThreadID
+		result.ThreadSlug = doc.ThreadSlug
+		result.ThreadTitle = doc.ThreadTitle
+	} else {
+		result.Type = "thread"
+		result.Audience = doc.Audience
+		result.Views = doc.Views
+	}
+	return result, nil
+}
+
+// decodeUserHit shows a person by username, with their bio as the content.
+func decodeUserHit(doc searchHitDoc) dto.SearchHit {
+	result := dto.SearchHit{
+		Type:       "user",
+		ID:         doc.ID,
+		Username:   doc.Username,
+		FullName:   doc.FullName,
+		Role:       doc.Role,
+		ClassGrade: doc.ClassGrade,
+		Content:    doc.Bio,
+		CreatedAt:  doc.CreatedAt,
+	}
+	if doc.AvatarURL != "" {
+		avatar := doc.AvatarURL
+		result.AvatarURL = &avatar
+	}
+	if doc.Formatted != nil {
+		result.Username = doc.Formatted.Username
+		result.FullName = doc.Formatted.FullName
+		result.Content = doc.Formatted.Bio
+	}
+	return result
+}
+
+// decodeCategoryHit shows a category with its name as the title.
+func decodeCategoryHit(doc searchHitDoc) dto.SearchHit {
+	result := dto.SearchHit{
+		Type:      "category",
+		ID:        doc.ID,
+		Title:     doc.Name,
+		Slug:      doc.Slug,
+		Content:   doc.Description,
+		CreatedAt: doc.CreatedAt,
+	}
+	if doc.Formatted != nil {
+		result.Title = doc.Formatted.Name
+		result.Content = doc.Formatted.Description
+	}
+	return result
+}
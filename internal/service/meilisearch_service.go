@@ -1,153 +1,191 @@
 package service
 
 import (
-	"fmt"
+	"context"
 	"html"
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
 	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
 	"github.com/meilisearch/meilisearch-go"
 	"github.com/microcosm-cc/bluemonday"
 )
 
 type MeiliSearchService interface {
-	IndexThread(thread *model.Thread) error
-	IndexPost(post *model.Post) error
-	DeleteThread(id string) error
-	DeletePost(id string) error
-	GenerateSearchToken(userRole string) (string, error)
+	Search(ctx context.Context, q SearchQuery) (*dto.SearchResponse, error)
+	SearchThreadIDs(ctx context.Context, q SearchQuery) ([]uuid.UUID, int64, error)
+	SimilarThreads(ctx context.Context, q SimilarQuery) ([]dto.SimilarThreadResponse, error)
+	SyncThreads(ctx context.Context, ids []uuid.UUID) error
+	SyncPosts(ctx context.Context, ids []uuid.UUID) error
+	SyncUsers(ctx context.Context, ids []uuid.UUID) error
+	SyncCategories(ctx context.Context, ids []uuid.UUID) error
+	FindDocumentIDs(ctx context.Context, index string, filter string) ([]uuid.UUID, error)
+	UpdateThreadViews(ctx context.Context, views map[string]int) error
+	GenerateSearchToken(ctx context.Context, scope SearchTokenScope) (string, time.Time, error)
+	RotateSigningKey(ctx context.Context, revoke bool) error
+	Reindex(ctx context.Context) error
+	StartReindex(ctx context.Context) error
+	CheckConsistency(ctx context.Context, repair bool) (*dto.SearchSyncReport, error)
 }
 
 type meiliSearchService struct {
-	client    meilisearch.ServiceManager
-	masterKey string
-	signingKeyUID string
-	signingKey    string
-	sanitizer     *bluemonday.Policy
+	client          meilisearch.ServiceManager
+	masterKey       string
+	keyMu           sync.Mutex
+	key             *signingKey // Cached newest signer key
+	keyLoadedAt     time.Time
+	tokenTTL        time.Duration
+	keyRotation     time.Duration
+	keyGrace        time.Duration
+	embedder        string // Empty without vectors
+	semanticRatio   float64
+	similarMinScore float64
+	sanitizer       *bluemonday.Policy
+	threadRepo      repository.ThreadRepository
+	postRepo        repository.PostRepository
+	userRepo        repository.UserRepository
+	categoryRepo    repository.CategoryRepository
+	jobManager      *jobs.Manager
 }
 
-func NewMeiliSearchService(client meilisearch.ServiceManager) MeiliSearchService {
+func NewMeiliSearchService(client meilisearch.ServiceManager, threadRepo repository.ThreadRepository, postRepo repository.PostRepository, userRepo repository.UserRepository, categoryRepo repository.CategoryRepository, jobManager *jobs.Manager) MeiliSearchService {
 	masterKey := os.Getenv("MEILI_MASTER_KEY")
 	if masterKey == "" {
 		log.Println("WARNING: MEILI_MASTER_KEY is not set.")
 	}
 
 	s := &meiliSearchService{
-		client:    client,
-		masterKey: masterKey,
-		sanitizer: bluemonday.StrictPolicy(),
+		client:          client,
+		masterKey:       masterKey,
+		sanitizer:       bluemonday.StrictPolicy(),
+		threadRepo:      threadRepo,
+		postRepo:        postRepo,
+		userRepo:        userRepo,
+		categoryRepo:    categoryRepo,
+		jobManager:      jobManager,
+		semanticRatio:   GetFloatFromEnv("SEARCH_SEMANTIC_RATIO", 0.5),
+		similarMinScore: GetFloatFromEnv("SEARCH_SIMILAR_MIN_SCORE", 0.5),
+	}
+	if threadEmbedder() != nil {
+		s.embedder = searchEmbedderName
 	}
 	s.initIndexes()
 	s.initSigningKey()
+
+	jobManager.Manual(SearchReindexJob, GetDurationFromEnv("SEARCH_REINDEX_TIMEOUT", 2*time.Hour), s.Reindex)
+	checkEvery := GetDurationFromEnv("SEARCH_CONSISTENCY_INTERVAL", 6*time.Hour)
+	jobManager.Schedule(SearchConsistencyJob, "@every "+checkEvery.String(), time.Hour, s.consistencyJob)
+	jobManager.Schedule(SearchKeyRotationJob, "@every "+s.keyRotation.String(), 5*time.Minute, s.rotateKeyJob)
 	return s
 }
 
-func (s *meiliSearchService) initSigningKey() {
-	// 1. List keys
-	resp, err := s.client.GetKeys(&meilisearch.KeysQuery{
-		Limit: 20,
-	})
-	if err != nil {
-		log.Printf("Failed to get meilisearch keys: %v", err)
-		return
-	}
-
-	// 2. Find existing key for signing
-	for _, key := range resp.Results {
-		if key.Name == "TenantTokenSigner" {
-			s.signingKeyUID = key.UID
-			s.signingKey = key.Key
-			log.Println("Found existing Meilisearch signing key")
-			return
+func (s *meiliSearchService) initIndexes() {
+	for _, index := range searchIndexes {
+		if _, err := s.client.Index(index).UpdateSettings(indexSettings(index)); err != nil {
+			log.Printf("Failed to update %s settings: %v", index, err)
 		}
 	}
 
-	// 3. Create new key if not found
-	// Expiry: nil (forever) or long time
-	expiry := time.Now().AddDate(100, 0, 0)
-    
-	key, err := s.client.CreateKey(&meilisearch.Key{
-		Description: "Key to sign tenant tokens",
-		Name:        "TenantTokenSigner",
-		Actions:     []string{"search"},
-		Indexes:     []string{"threads", "posts"},
-		ExpiresAt:   expiry, 
-	})
-	if err != nil {
-		log.Printf("Failed to create signing key: %v", err)
-		return
-	}
-
-	s.signingKeyUID = key.UID
-	s.signingKey = key.Key
-	log.Println("Created new Meilisearch signing key")
+	log.Println("Meilisearch indexes initialized")
 }
 
-func (s *meiliSearchService) initIndexes() {
-	// Threads Index
-	filterableAttrs := []string{"allowed_roles", "category_id"}
-	filterableInterface := make([]any, len(filterableAttrs))
-	for i, v := range filterableAttrs {
-		filterableInterface[i] = v
-	}
-	_, err := s.client.Index("threads").UpdateFilterableAttributes(&filterableInterface)
-	if err != nil {
-		log.Printf("Failed to update threads filterable attributes: %v", err)
-	}
-
-	sortableAttrs := []string{"created_at", "views"}
-	_, err = s.client.Index("threads").UpdateSortableAttributes(&sortableAttrs)
-	if err != nil {
-		log.Printf("Failed to update threads sortable attributes: %v", err)
+// indexSettings are applied on startup and to the fresh indexes of a
+// reindex, so a swap keeps them.
+func indexSettings(index string) *meilisearch.Settings {
+	// Typo tolerance, shared by all indexes. Slugs and usernames are matched
+	// exactly.
+	settings := &meilisearch.Settings{
+		TypoTolerance: &meilisearch.TypoTolerance{
+			Enabled: os.Getenv("MEILI_TYPO_TOLERANCE") != "false",
+			MinWordSizeForTypos: meilisearch.MinWordSizeForTypos{
+				OneTypo:  int64(GetIntFromEnv("MEILI_TYPO_MIN_WORD_ONE_TYPO", 5)),
+				TwoTypos: int64(GetIntFromEnv("MEILI_TYPO_MIN_WORD_TWO_TYPOS", 9)),
+			},
+			DisableOnAttributes: []string{"slug", "thread_slug", "user.username", "username"},
+		},
 	}
 
-	// Posts Index
-	postFilterable := []string{"allowed_roles", "thread_id"}
-	postFilterableInterface := make([]any, len(postFilterable))
-	for i, v := range postFilterable {
-		postFilterableInterface[i] = v
-	}
-	_, err = s.client.Index("posts").UpdateFilterableAttributes(&postFilterableInterface)
-	if err != nil {
-		log.Printf("Failed to update posts filterable attributes: %v", err)
-	}
-	
-	postSortable := []string{"created_at"}
-	_, err = s.client.Index("posts").UpdateSortableAttributes(&postSortable)
-	if err != nil {
-		log.Printf("Failed to update posts sortable attributes: %v", err)
+	switch index {
+	case "threads":
+		settings.FilterableAttributes = []string{"allowed_roles", "hidden", "category_id", "category.name", "audience", "user_id", "user.username", "user.avatar_url", "created_at"}
+		settings.SortableAttributes = []string{"created_at", "views"}
+		if embedder := threadEmbedder(); embedder != nil {
+			settings.Embedders = map[string]meilisearch.Embedder{searchEmbedderName: *embedder}
+		}
+	case "posts":
+		settings.FilterableAttributes = []string{"allowed_roles", "hidden", "thread_id", "category_id", "category.name", "user_id", "user.username", "user.avatar_url", "created_at", "updated_at"}
+		settings.SortableAttributes = []string{"created_at"}
+	case "users":
+		settings.SearchableAttributes = []string{"username", "full_name", "class_grade", "bio"}
+		settings.FilterableAttributes = []string{"id", "role", "class_grade"}
+	case "categories":
+		settings.SearchableAttributes = []string{"name", "description"}
 	}
-
-	log.Println("Meilisearch indexes initialized")
+	return settings
 }
 
 // Structs for Meilisearch Indexing
 type meiliThreadDoc struct {
-	ID           string           `json:"id"`
-	Title        string           `json:"title"`
-	Content      string           `json:"content"`
-	Slug         string           `json:"slug"`
-	Audience     string           `json:"audience"`
-	AllowedRoles []string         `json:"allowed_roles"`
-	Views        int              `json:"views"`
-	CreatedAt    int64            `json:"created_at"`
-	CategoryID   string           `json:"category_id"`
-	User         meiliUserSubset  `json:"user"`
+	ID           string              `json:"id"`
+	Title        string              `json:"title"`
+	Content      string              `json:"content"`
+	Slug         string              `json:"slug"`
+	Audience     string              `json:"audience"`
+	AllowedRoles []string            `json:"allowed_roles"`
+	Hidden       bool                `json:"hidden"` // Always false; threads have no soft delete
+	Views        int                 `json:"views"`
+	CreatedAt    int64               `json:"created_at"`
+	UpdatedAt    int64               `json:"updated_at"` // Unix milliseconds, compared by the consistency check
+	CategoryID   string              `json:"category_id"`
+	UserID       string              `json:"user_id"`
+	User         meiliUserSubset     `json:"user"`
 	Category     meiliCategorySubset `json:"category"`
 }
 
 type meiliPostDoc struct {
-	ID           string           `json:"id"`
-	Content      string           `json:"content"`
-	ThreadID     string           `json:"thread_id"`
-	ThreadSlug   string           `json:"thread_slug"`
-	ThreadTitle  string           `json:"thread_title"`
-	AllowedRoles []string         `json:"allowed_roles"`
-	CreatedAt    int64            `json:"created_at"`
-	User         meiliUserSubset  `json:"user"`
+	ID           string              `json:"id"`
+	Content      string              `json:"content"`
+	ThreadID     string              `json:"thread_id"`
+	ThreadSlug   string              `json:"thread_slug"`
+	ThreadTitle  string              `json:"thread_title"`
+	CategoryID   string              `json:"category_id"`
+	Category     meiliCategorySubset `json:"category"`
+	AllowedRoles []string            `json:"allowed_roles"`
+	Hidden       bool                `json:"hidden"` // Soft-deleted, until restored
+	CreatedAt    int64               `json:"created_at"`
+	UpdatedAt    int64               `json:"updated_at"` // Newest of the post and its thread, in unix milliseconds
+	UserID       string              `json:"user_id"`
+	User         meiliUserSubset     `json:"user"`
+}
+
+// meiliUserDoc only holds what the public profile shows, because tenant
+// tokens can filter documents but not hide their fields.
+type meiliUserDoc struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	FullName   string `json:"full_name,omitempty"` // Only when the user shows it
+	AvatarURL  string `json:"avatar_url"`
+	Role       string `json:"role"`
+	ClassGrade string `json:"class_grade,omitempty"`
+	Bio        string `json:"bio,omitempty"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+type meiliCategoryDoc struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"` // Categories cannot be edited, so this is created_at
 }
 
 type meiliUserSubset struct {
@@ -177,7 +215,7 @@ func (s *meiliSearchService) cleanContentForIndex(content string) string {
 	return cleanText
 }
 
-func (s *meiliSearchService) IndexThread(thread *model.Thread) error {
+func (s *meiliSearchService) threadDoc(thread *model.Thread) meiliThreadDoc {
 	allowedRoles := []string{}
 	if thread.Audience == "semua" {
 		allowedRoles = append(allowedRoles, "public")
@@ -194,40 +232,58 @@ func (s *meiliSearchService) IndexThread(thread *model.Thread) error {
 		AllowedRoles: allowedRoles,
 		Views:        thread.Views,
 		CreatedAt:    thread.CreatedAt.Unix(),
+		UpdatedAt:    thread.UpdatedAt.UnixMilli(),
 		CategoryID:   thread.CategoryID.String(), // Dereference if needed, but assuming model has *UUID handled or assuming it's UUID value. Double check model.
+		UserID:       thread.UserID.String(),
 		User: meiliUserSubset{
-			Username:   thread.User.Username,
-			AvatarURL:  getStringOrEmpty(thread.User.AvatarURL),
+			Username:  thread.User.Username,
+			AvatarURL: getStringOrEmpty(thread.User.AvatarURL),
 		},
 		Category: meiliCategorySubset{
 			Name: thread.Category.Name,
 		},
 	}
-	
+
 	// Double check CategoryID type in model.
-	// In model: CategoryID *uuid.UUID. 
+	// In model: CategoryID *uuid.UUID.
 	if thread.CategoryID != nil {
 		doc.CategoryID = thread.CategoryID.String()
 	}
+	return doc
+}
 
-	// Debug log
-	log.Printf("Indexing thread document: %+v", doc)
+// UpdateThreadViews only touches the views attribute of already indexed
+// threads, keyed by thread ID. UpdateDocuments would add a document holding
+// nothing but id and views for a thread that is not indexed, so those are
+// left out.
+func (s *meiliSearchService) UpdateThreadViews(ctx context.Context, views map[string]int) error {
+	if len(views) == 0 {
+		return nil
+	}
 
-	task, err := s.client.Index("threads").AddDocuments([]meiliThreadDoc{doc}, strPtr("id"))
+	index := s.client.Index("threads")
+	ids := make([]string, 0, len(views))
+	for id := range views {
+		ids = append(ids, id)
+	}
+	indexed, err := s.documentVersions(ctx, index, ids)
 	if err != nil {
 		return err
 	}
-	log.Printf("Indexed thread %s, task id: %d", thread.ID, task.TaskUID)
-	return nil
-}
-
-func (s *meiliSearchService) IndexPost(post *model.Post) error {
 
-	if post.Thread.ID.String() == "00000000-0000-0000-0000-000000000000" {
-		log.Println("Warning: IndexPost called with missing Thread relation. Audience might be wrong.")
-		return fmt.Errorf("post thread not loaded")
+	docs := make([]map[string]any, 0, len(indexed))
+	for id := range indexed {
+		docs = append(docs, map[string]any{"id": id, "views": views[id]})
 	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	_, err = index.UpdateDocumentsWithContext(ctx, docs, strPtr("id"))
+	return err
+}
 
+func (s *meiliSearchService) postDoc(post *model.Post) meiliPostDoc {
 	allowedRoles := []string{}
 	if post.Thread.Audience == "semua" {
 		allowedRoles = append(allowedRoles, "public")
@@ -235,96 +291,72 @@ func (s *meiliSearchService) IndexPost(post *model.Post) error {
 		allowedRoles = append(allowedRoles, post.Thread.Audience)
 	}
 
-	log.Println("Indexing post document: ", post)
+	updatedAt := post.UpdatedAt
+	if post.Thread.UpdatedAt.After(updatedAt) {
+		updatedAt = post.Thread.UpdatedAt
+	}
 
 	doc := meiliPostDoc{
-		ID:           post.ID.String(),
-		Content:      s.cleanContentForIndex(post.Content),
-		ThreadID:     post.ThreadID.String(),
-		ThreadSlug:   post.Thread.Slug,
-		ThreadTitle:  post.Thread.Title,
+		ID:          post.ID.String(),
+		Content:     s.cleanContentForIndex(post.Content),
+		ThreadID:    post.ThreadID.String(),
+		ThreadSlug:  post.Thread.Slug,
+		ThreadTitle: post.Thread.Title,
+		Category: meiliCategorySubset{
+			Name: post.Thread.Category.Name,
+		},
 		AllowedRoles: allowedRoles,
+		Hidden:       post.DeletedAt.Valid,
 		CreatedAt:    post.CreatedAt.Unix(),
+		UpdatedAt:    updatedAt.UnixMilli(),
+		UserID:       post.UserID.String(),
 		User: meiliUserSubset{
-			Username:   post.User.Username,
-			AvatarURL:  getStringOrEmpty(post.User.AvatarURL),
+			Username:  post.User.Username,
+			AvatarURL: getStringOrEmpty(post.User.AvatarURL),
 		},
 	}
 
-	log.Println("Indexed post document after sanitize: ", doc)
-
-	task, err := s.client.Index("posts").AddDocuments([]meiliPostDoc{doc}, strPtr("id"))
-	if err != nil {
-		return err
+	if post.Thread.CategoryID != nil {
+		doc.CategoryID = post.Thread.CategoryID.String()
 	}
-	log.Printf("Indexed post %s, task id: %d", post.ID, task.TaskUID)
-	return nil
+	return doc
 }
 
-func getStringOrEmpty(s *string) string {
-	if s == nil {
-		return ""
+func (s *meiliSearchService) userDoc(user *model.User) meiliUserDoc {
+	doc := meiliUserDoc{
+		ID:        user.ID.String(),
+		Username:  user.Username,
+		AvatarURL: getStringOrEmpty(user.AvatarURL),
+		Role:      user.Role.Name,
+		CreatedAt: user.CreatedAt.Unix(),
+		UpdatedAt: user.UpdatedAt.UnixMilli(),
 	}
-	return *s
-}
-
-func (s *meiliSearchService) DeleteThread(id string) error {
-	_, err := s.client.Index("threads").DeleteDocument(id)
-	return err
-}
-
-func (s *meiliSearchService) DeletePost(id string) error {
-	_, err := s.client.Index("posts").DeleteDocument(id)
-	return err
-}
-
-func (s *meiliSearchService) GenerateSearchToken(userRole string) (string, error) {
-	if s.signingKeyUID == "" || s.signingKey == "" {
-		return "", fmt.Errorf("signing key not initialized")
-	}
-
-	// Rules based on role
-	var filterRules string
-	
-	switch userRole {
-	case "admin":
-		filterRules = "" // No filter
-	case "guru":
-		filterRules = "allowed_roles IN ['guru', 'public']"
-	default:
-		// Siswa and others
-		filterRules = "allowed_roles IN ['siswa', 'public']"
-	}
-
-	searchRules := map[string]any{
-		"threads": map[string]any{},
-		"posts":   map[string]any{},
-	}
-
-	if filterRules != "" {
-		searchRules["threads"] = map[string]any{
-			"filter": filterRules,
+	if user.Profile != nil {
+		if user.Profile.ShowFullName {
+			doc.FullName = user.Profile.FullName
 		}
-		searchRules["posts"] = map[string]any{
-			"filter": filterRules,
-		}
-	} else {
-		// For admin (empty filterRules), we give full access.
-		searchRules["threads"] = map[string]any{"filter": nil}
-		searchRules["posts"] = map[string]any{"filter": nil}
+		doc.ClassGrade = getStringOrEmpty(user.Profile.ClassGrade)
+		doc.Bio = getStringOrEmpty(user.Profile.Bio)
 	}
+	return doc
+}
 
-	// Use the dedicated signing Key
-	token, err := s.client.GenerateTenantToken(s.signingKeyUID, searchRules, &meilisearch.TenantTokenOptions{
-		APIKey:    s.signingKey, 
-		ExpiresAt: time.Now().Add(24 * time.Hour), 
-	})
-
-	if err != nil {
-		return "", err
+func categoryDoc(category *model.Category) meiliCategoryDoc {
+	return meiliCategoryDoc{
+		ID:          category.ID.String(),
+		Name:        category.Name,
+		Slug:        category.Slug,
+		Description: category.Description,
+		CreatedAt:   category.CreatedAt.Unix(),
+		UpdatedAt:   category.CreatedAt.UnixMilli(),
 	}
+}
 
-	return token, nil
+func getStringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
 func strPtr(s string) *string {
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"html"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"github.com/google/uuid"
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// Similar threads are found by relevance to the thread's words. When an
+// embedder is configured through SEARCH_EMBEDDER_SOURCE, the threads index
+// also gets vectors: drafts are matched with hybrid search and existing
+// threads with Meilisearch's similar documents, which catch questions
+// asked in other words.
+const (
+	searchEmbedderName = "threads"
+
+	// Meilisearch only uses the first ten words of a query
+	maxSimilarQueryWords = 10
+)
+
+// SimilarQuery looks for threads resembling a draft or an existing thread.
+// Roles lists the allowed_roles values to match; nil means no restriction.
+type SimilarQuery struct {
+	Title    string
+	Content  string
+	ThreadID uuid.UUID // Set for an existing thread, which is left out of the results
+	Roles    []string
+	Limit    int
+}
+
+// threadEmbedder builds the threads embedder from the environment, or
+// returns nil when none is configured.
+func threadEmbedder() *meilisearch.Embedder {
+	source := os.Getenv("SEARCH_EMBEDDER_SOURCE") // openAi, huggingFace, ollama or rest
+	if source == "" {
+		return nil
+	}
+	return &meilisearch.Embedder{
+		Source:           meilisearch.EmbedderSource(source),
+		Model:            os.Getenv("SEARCH_EMBEDDER_MODEL"),
+		URL:              os.Getenv("SEARCH_EMBEDDER_URL"),
+		APIKey:           os.Getenv("SEARCH_EMBEDDER_API_KEY"),
+		DocumentTemplate: "{{doc.title}}\n{{doc.content|truncatewords: 100}}",
+	}
+}
+
+// similarQueryText picks the distinct words of the title, then the content,
+// skipping short ones, up to what Meilisearch reads of a query.
+func (s *meiliSearchService) similarQueryText(title, content string) string {
+	text := title + " " + html.UnescapeString(s.sanitizer.Sanitize(content))
+
+	var words []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Fields(text) {
+		word := strings.ToLower(strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}))
+		if len([]rune(word)) < 3 || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+		if len(words) == maxSimilarQueryWords {
+			break
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// SimilarThreads returns the threads most like the query that score at
+// least SEARCH_SIMILAR_MIN_SCORE, best first.
+func (s *meiliSearchService) SimilarThreads(ctx context.Context, q SimilarQuery) ([]dto.SimilarThreadResponse, error) {
+	filter := strings.Join(contentFilter(q.Roles, nil), " AND ")
+	limit := int64(q.Limit)
+	if q.ThreadID != uuid.Nil {
+		limit++ // The thread itself may come back
+	}
+	attributes := []string{"id", "title", "slug", "audience", "category", "created_at"}
+
+	var hits meilisearch.Hits
+	if s.embedder != "" && q.ThreadID != uuid.Nil {
+		var resp meilisearch.SimilarDocumentResult
+		if err := s.client.Index("threads").SearchSimilarDocumentsWithContext(ctx, &meilisearch.SimilarDocumentQuery{
+			Id:                    q.ThreadID.String(),
+			Embedder:              s.embedder,
+			AttributesToRetrieve:  attributes,
+			Limit:                 limit,
+			Filter:                filter,
+			ShowRankingScore:      true,
+			RankingScoreThreshold: s.similarMinScore,
+		}, &resp); err != nil {
+			return nil, err
+		}
+		hits = resp.Hits
+	} else {
+		query := s.similarQueryText(q.Title, q.Content)
+		if query == "" {
+			return []dto.SimilarThreadResponse{}, nil
+		}
+
+		// Frequency drops the most common words first, so a long question
+		// still matches threads that share its rarer words
+		req := &meilisearch.SearchRequest{
+			AttributesToRetrieve:  attributes,
+			MatchingStrategy:      meilisearch.Frequency,
+			Limit:                 limit,
+			ShowRankingScore:      true,
+			RankingScoreThreshold: s.similarMinScore,
+		}
+		if filter != "" {
+			req.Filter = filter
+		}
+		if s.embedder != "" {
+			req.Hybrid = &meilisearch.SearchRequestHybrid{
+				Embedder:      s.embedder,
+				SemanticRatio: s.semanticRatio,
+			}
+		}
+		resp, err := s.client.Index("threads").SearchWithContext(ctx, query, req)
+		if err != nil {
+			return nil, err
+		}
+		hits = resp.Hits
+	}
+
+	similar := make([]dto.SimilarThreadResponse, 0, len(hits))
+	for _, hit := range hits {
+		var doc struct {
+			ID        string              `json:"id"`
+			Title     string              `json:"title"`
+			Slug      string              `json:"slug"`
+			Audience  string              `json:"audience"`
+			Category  meiliCategorySubset `json:"category"`
+			CreatedAt int64               `json:"created_at"`
+			Score     float64             `json:"_rankingScore"`
+		}
+		if err := hit.DecodeInto(&doc); err != nil {
+			return nil, err
+		}
+		id, err := uuid.Parse(doc.ID)
+		if err != nil || id == q.ThreadID {
+			continue
+		}
+		similar = append(similar, dto.SimilarThreadResponse{
+			ID:           id,
+			Title:        doc.Title,
+			Slug:         doc.Slug,
+			CategoryName: doc.Category.Name,
+			Audience:     doc.Audience,
+			Score:        doc.Score,
+			CreatedAt:    time.Unix(doc.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+		})
+		if len(similar) == q.Limit {
+			break
+		}
+	}
+	return similar, nil
+}
@@ -0,0 +1,489 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"github.com/google/uuid"
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// The database is the source of truth; the Meilisearch indexes are copies kept current by the search outbox. A reindex rebuilds
+// an index from scratch into a fresh one and swaps it in, and the
+// consistency check compares the two sides by id and updated_at and repairs
+// missing, stale and extra documents, e.g. after a restore or a manual edit.
+const (
+	SearchReindexJob     = "search.reindex"
+	SearchConsistencyJob = "search.consistency"
+
+	searchSyncBatch = 500
+)
+
+// indexedDoc is a row rendered as its search document.
+type indexedDoc struct {
+	id        uuid.UUID
+	updatedAt int64
+	doc       any
+}
+
+// indexSource reads the rows behind one index.
+type indexSource struct {
+	index    string
+	next     func(ctx context.Context, after uuid.UUID) ([]indexedDoc, error) // Up to searchSyncBatch rows after the id, in id order
+	existing func(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
+}
+
+func (s *meiliSearchService) indexSources() []indexSource {
+	return []indexSource{
+		{index: "threads", next: s.threadDocsAfter, existing: s.threadRepo.FindExistingIDs},
+		{index: "posts", next: s.postDocsAfter, existing: s.postRepo.FindExistingIDs},
+		{index: "users", next: s.userDocsAfter, existing: s.userRepo.FindSearchableIDs},
+		{index: "categories", next: s.categoryDocsAfter, existing: s.categoryRepo.FindExistingIDs},
+	}
+}
+
+func (s *meiliSearchService) threadDocsAfter(ctx context.Context, after uuid.UUID) ([]indexedDoc, error) {
+	threads, err := s.threadRepo.FindBatch(ctx, after, searchSyncBatch)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]indexedDoc, len(threads))
+	for i, thread := range threads {
+		doc := s.threadDoc(thread)
+		docs[i] = indexedDoc{id: thread.ID, updatedAt: doc.UpdatedAt, doc: doc}
+	}
+	return docs, nil
+}
+
+func (s *meiliSearchService) postDocsAfter(ctx context.Context, after uuid.UUID) ([]indexedDoc, error) {
+	posts, err := s.postRepo.FindBatch(ctx, after, searchSyncBatch)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]indexedDoc, len(posts))
+	for i, post := range posts {
+		doc := s.postDoc(post)
+		docs[i] = indexedDoc{id: post.ID, updatedAt: doc.UpdatedAt, doc: doc}
+	}
+	return docs, nil
+}
+
+func (s *meiliSearchService) userDocsAfter(ctx context.Context, after uuid.UUID) ([]indexedDoc, error) {
+	users, err := s.userRepo.FindBatch(ctx, after, searchSyncBatch)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]indexedDoc, len(users))
+	for i, user := range users {
+		doc := s.userDoc(user)
+		docs[i] = indexedDoc{id: user.ID, updatedAt: doc.UpdatedAt, doc: doc}
+	}
+	return docs, nil
+}
+
+func (s *meiliSearchService) categoryDocsAfter(ctx context.Context, after uuid.UUID) ([]indexedDoc, error) {
+	categories, err := s.categoryRepo.FindBatch(ctx, after, searchSyncBatch)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]indexedDoc, len(categories))
+	for i, category := range categories {
+		doc := categoryDoc(category)
+		docs[i] = indexedDoc{id: category.ID, updatedAt: doc.UpdatedAt, doc: doc}
+	}
+	return docs, nil
+}
+
+// waitTask blocks until Meilisearch has processed the task and turns a
+// failed task into an error.
+func (s *meiliSearchService) waitTask(ctx context.Context, info *meilisearch.TaskInfo, err error) error {
+	if err != nil {
+		return err
+	}
+	task, err := s.client.WaitForTaskWithContext(ctx, info.TaskUID, 100*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	if task.Status != meilisearch.TaskStatusSucceeded {
+		return fmt.Errorf("meilisearch task %d %s: %s", info.TaskUID, task.Status, task.Error.Message)
+	}
+	return nil
+}
+
+// SyncThreads brings the documents of the given threads in line with the
+// database: threads that exist are re-added, deleted ones removed.
+func (s *meiliSearchService) SyncThreads(ctx context.Context, ids []uuid.UUID) error {
+	threads, err := s.threadRepo.FindForSearch(ctx, ids)
+	if err != nil {
+		return err
+	}
+	docs := make([]any, len(threads))
+	found := make(map[uuid.UUID]bool, len(threads))
+	for i, thread := range threads {
+		docs[i] = s.threadDoc(thread)
+		found[thread.ID] = true
+	}
+	return s.syncDocuments(ctx, "threads", ids, docs, found)
+}
+
+// SyncPosts is SyncThreads for posts.
+func (s *meiliSearchService) SyncPosts(ctx context.Context, ids []uuid.UUID) error {
+	posts, err := s.postRepo.FindForSearch(ctx, ids)
+	if err != nil {
+		return err
+	}
+	docs := make([]any, len(posts))
+	found := make(map[uuid.UUID]bool, len(posts))
+	for i, post := range posts {
+		docs[i] = s.postDoc(post)
+		found[post.ID] = true
+	}
+	return s.syncDocuments(ctx, "posts", ids, docs, found)
+}
+
+// SyncUsers is SyncThreads for users. Users who opted out of people search
+// are removed like deleted ones.
+func (s *meiliSearchService) SyncUsers(ctx context.Context, ids []uuid.UUID) error {
+	users, err := s.userRepo.FindForSearch(ctx, ids)
+	if err != nil {
+		return err
+	}
+	docs := make([]any, len(users))
+	found := make(map[uuid.UUID]bool, len(users))
+	for i, user := range users {
+		docs[i] = s.userDoc(user)
+		found[user.ID] = true
+	}
+	return s.syncDocuments(ctx, "users", ids, docs, found)
+}
+
+// SyncCategories is SyncThreads for categories.
+func (s *meiliSearchService) SyncCategories(ctx context.Context, ids []uuid.UUID) error {
+	categories, err := s.categoryRepo.FindForSearch(ctx, ids)
+	if err != nil {
+		return err
+	}
+	docs := make([]any, len(categories))
+	found := make(map[uuid.UUID]bool, len(categories))
+	for i, category := range categories {
+		docs[i] = categoryDoc(category)
+		found[category.ID] = true
+	}
+	return s.syncDocuments(ctx, "categories", ids, docs, found)
+}
+
+// syncDocuments writes docs and deletes the ids that were not found, and
+// waits for both so a failed task is reported.
+func (s *meiliSearchService) syncDocuments(ctx context.Context, index string, ids []uuid.UUID, docs []any, found map[uuid.UUID]bool) error {
+	if len(docs) > 0 {
+		info, err := s.client.Index(index).AddDocumentsWithContext(ctx, docs, strPtr("id"))
+		if err := s.waitTask(ctx, info, err); err != nil {
+			return err
+		}
+	}
+
+	var gone []string
+	for _, id := range ids {
+		if !found[id] {
+			gone = append(gone, id.String())
+		}
+	}
+	if len(gone) > 0 {
+		info, err := s.client.Index(index).DeleteDocumentsWithContext(ctx, gone)
+		if err := s.waitTask(ctx, info, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindDocumentIDs returns the ids of every document in the index matching
+// the filter.
+func (s *meiliSearchService) FindDocumentIDs(ctx context.Context, index string, filter string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for offset := int64(0); ; offset += searchSyncBatch {
+		var page meilisearch.DocumentsResult
+		if err := s.client.Index(index).GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
+			Offset: offset,
+			Limit:  searchSyncBatch,
+			Fields: []string{"id"},
+			Filter: filter,
+		}, &page); err != nil {
+			return nil, err
+		}
+
+		var docs []struct {
+			ID string `json:"id"`
+		}
+		if err := page.Results.DecodeInto(&docs); err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			if id, err := uuid.Parse(doc.ID); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		if len(docs) < searchSyncBatch {
+			return ids, nil
+		}
+	}
+}
+
+// StartReindex runs the reindex job in the background.
+func (s *meiliSearchService) StartReindex(ctx context.Context) error {
+	return s.jobManager.Trigger(ctx, SearchReindexJob)
+}
+
+// Reindex rebuilds every index. Writes that reach the live index while its
+// copy is filled are lost by the swap, so a repairing consistency check
+// follows right after.
+func (s *meiliSearchService) Reindex(ctx context.Context) error {
+	for _, src := range s.indexSources() {
+		start := time.Now()
+		n, err := s.rebuild(ctx, src)
+		if err != nil {
+			return fmt.Errorf("reindex %s: %w", src.index, err)
+		}
+		log.Printf("Reindexed %d %s in %s", n, src.index, time.Since(start).Round(time.Millisecond))
+	}
+
+	_, err := s.CheckConsistency(ctx, true)
+	return err
+}
+
+// rebuild fills <index>_reindex from the database and swaps it with the
+// live index, so searches never see a half-filled index.
+func (s *meiliSearchService) rebuild(ctx context.Context, src indexSource) (int, error) {
+	tmp := src.index + "_reindex"
+
+	// Swapping needs both indexes to exist
+	if _, err := s.client.GetIndexWithContext(ctx, src.index); err != nil {
+		info, err := s.client.CreateIndexWithContext(ctx, &meilisearch.IndexConfig{Uid: src.index, PrimaryKey: "id"})
+		if err := s.waitTask(ctx, info, err); err != nil {
+			return 0, err
+		}
+	}
+
+	// Leftover of an interrupted run
+	if _, err := s.client.GetIndexWithContext(ctx, tmp); err == nil {
+		info, err := s.client.DeleteIndexWithContext(ctx, tmp)
+		if err := s.waitTask(ctx, info, err); err != nil {
+			return 0, err
+		}
+	}
+
+	info, err := s.client.CreateIndexWithContext(ctx, &meilisearch.IndexConfig{Uid: tmp, PrimaryKey: "id"})
+	if err := s.waitTask(ctx, info, err); err != nil {
+		return 0, err
+	}
+	info, err = s.client.Index(tmp).UpdateSettingsWithContext(ctx, indexSettings(src.index))
+	if err := s.waitTask(ctx, info, err); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	after := uuid.Nil
+	for {
+		docs, err := src.next(ctx, after)
+		if err != nil {
+			return 0, err
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		batch := make([]any, len(docs))
+		for i, doc := range docs {
+			batch[i] = doc.doc
+		}
+		info, err := s.client.Index(tmp).AddDocumentsWithContext(ctx, batch, strPtr("id"))
+		if err := s.waitTask(ctx, info, err); err != nil {
+			return 0, err
+		}
+
+		total += len(docs)
+		after = docs[len(docs)-1].id
+	}
+
+	info, err = s.client.SwapIndexesWithContext(ctx, []*meilisearch.SwapIndexesParams{
+		{Indexes: []string{src.index, tmp}},
+	})
+	if err := s.waitTask(ctx, info, err); err != nil {
+		return 0, err
+	}
+
+	// tmp now holds the old documents
+	info, err = s.client.DeleteIndexWithContext(ctx, tmp)
+	if err := s.waitTask(ctx, info, err); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// CheckConsistency compares every index with the database. Without repair
+// it only reports; with repair, missing and stale documents are re-added
+// and extra ones deleted.
+func (s *meiliSearchService) CheckConsistency(ctx context.Context, repair bool) (*dto.SearchSyncReport, error) {
+	start := time.Now()
+	report := &dto.SearchSyncReport{
+		Repair:    repair,
+		Indexes:   []dto.SearchIndexReport{},
+		StartedAt: start.Format("2006-01-02 15:04:05"),
+	}
+
+	for _, src := range s.indexSources() {
+		indexReport, err := s.check(ctx, src, repair)
+		if err != nil {
+			return nil, fmt.Errorf("check %s: %w", src.index, err)
+		}
+		report.Indexes = append(report.Indexes, *indexReport)
+	}
+
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+	return report, nil
+}
+
+func (s *meiliSearchService) check(ctx context.Context, src indexSource, repair bool) (*dto.SearchIndexReport, error) {
+	report := &dto.SearchIndexReport{Index: src.index}
+	index := s.client.Index(src.index)
+
+	// 1. Every row needs a document of the same version
+	after := uuid.Nil
+	for {
+		docs, err := src.next(ctx, after)
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) == 0 {
+			break
+		}
+		after = docs[len(docs)-1].id
+		report.Checked += len(docs)
+
+		ids := make([]string, len(docs))
+		for i, doc := range docs {
+			ids[i] = doc.id.String()
+		}
+		versions, err := s.documentVersions(ctx, index, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		var outdated []any
+		for _, doc := range docs {
+			version, ok := versions[doc.id.String()]
+			switch {
+			case !ok:
+				report.Missing++
+			case version != doc.updatedAt:
+				report.Stale++
+			default:
+				continue
+			}
+			outdated = append(outdated, doc.doc)
+		}
+		if repair && len(outdated) > 0 {
+			if _, err := index.AddDocumentsWithContext(ctx, outdated, strPtr("id")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 2. Every document needs its row. Deletes wait until the walk is done
+	// so they do not shift the offsets.
+	var extra []string
+	for offset := int64(0); ; offset += searchSyncBatch {
+		var page meilisearch.DocumentsResult
+		if err := index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
+			Offset: offset,
+			Limit:  searchSyncBatch,
+			Fields: []string{"id"},
+		}, &page); err != nil {
+			return nil, err
+		}
+		if len(page.Results) == 0 {
+			break
+		}
+
+		var docs []struct {
+			ID string `json:"id"`
+		}
+		if err := page.Results.DecodeInto(&docs); err != nil {
+			return nil, err
+		}
+		ids := make([]uuid.UUID, 0, len(docs))
+		for _, doc := range docs {
+			id, err := uuid.Parse(doc.ID)
+			if err != nil {
+				extra = append(extra, doc.ID)
+				continue
+			}
+			ids = append(ids, id)
+		}
+
+		existing, err := src.existing(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		exists := make(map[uuid.UUID]bool, len(existing))
+		for _, id := range existing {
+			exists[id] = true
+		}
+		for _, id := range ids {
+			if !exists[id] {
+				extra = append(extra, id.String())
+			}
+		}
+	}
+	report.Extra = len(extra)
+
+	if repair && len(extra) > 0 {
+		if _, err := index.DeleteDocumentsWithContext(ctx, extra); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// documentVersions returns the updated_at of the given documents that are
+// in the index. Documents indexed before updated_at existed report 0.
+func (s *meiliSearchService) documentVersions(ctx context.Context, index meilisearch.IndexManager, ids []string) (map[string]int64, error) {
+	var page meilisearch.DocumentsResult
+	if err := index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
+		Ids:    ids,
+		Limit:  int64(len(ids)),
+		Fields: []string{"id", "updated_at"},
+	}, &page); err != nil {
+		return nil, err
+	}
+
+	var docs []struct {
+		ID        string `json:"id"`
+		UpdatedAt int64  `json:"updated_at"`
+	}
+	if err := page.Results.DecodeInto(&docs); err != nil {
+		return nil, err
+	}
+	versions := make(map[string]int64, len(docs))
+	for _, doc := range docs {
+		versions[doc.ID] = doc.UpdatedAt
+	}
+	return versions, nil
+}
+
+// consistencyJob repairs documents missed while Meilisearch was down.
+func (s *meiliSearchService) consistencyJob(ctx context.Context) error {
+	report, err := s.CheckConsistency(ctx, true)
+	if err != nil {
+		return err
+	}
+	for _, r := range report.Indexes {
+		if r.Missing+r.Stale+r.Extra > 0 {
+			log.Printf("Search consistency: %s had %d missing, %d stale and %d extra documents", r.Index, r.Missing, r.Stale, r.Extra)
+		}
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"time"
 
+	"anoa.com/telkomalumiforum/internal/dto"
 	"anoa.com/telkomalumiforum/internal/model"
 	"anoa.com/telkomalumiforum/internal/repository"
 	"github.com/google/uuid"
@@ -17,6 +18,7 @@ import (
 type MenfessService interface {
 	CreateMenfess(ctx context.Context, userID uuid.UUID, content string) error
 	GetMenfesses(ctx context.Context, offset, limit int) ([]*model.Menfess, int64, error)
+	GetMenfessPage(ctx context.Context, filter dto.CursorFilter, limit int) ([]*model.Menfess, *dto.CursorMeta, error)
 }
 
 type menfessService struct {
@@ -98,3 +100,30 @@ func (s *menfessService) CreateMenfess(ctx context.Context, userID uuid.UUID, co
 func (s *menfessService) GetMenfesses(ctx context.Context, offset, limit int) ([]*model.Menfess, int64, error) {
 	return s.repo.FindAll(ctx, offset, limit)
 }
+
+// GetMenfessPage is GetMenfesses with keyset pagination. The total is only
+// counted when asked for.
+func (s *menfessService) GetMenfessPage(ctx context.Context, filter dto.CursorFilter, limit int) ([]*model.Menfess, *dto.CursorMeta, error) {
+	after, err := decodeCursor("menfess", filter.Cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	menfesses, err := s.repo.FindPage(ctx, after, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	menfesses, next := nextCursor(menfesses, limit, "menfess", func(m *model.Menfess) repository.KeysetCursor {
+		return repository.KeysetCursor{At: m.CreatedAt, ID: m.ID}
+	})
+
+	meta := &dto.CursorMeta{NextCursor: next, Limit: limit}
+	if filter.IncludeTotal {
+		total, err := s.repo.Count(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		meta.TotalItems = &total
+	}
+	return menfesses, meta, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"anoa.com/telkomalumiforum/internal/dto"
 	"anoa.com/telkomalumiforum/internal/model"
 	"anoa.com/telkomalumiforum/internal/repository"
 	"github.com/google/uuid"
@@ -13,21 +14,25 @@ import (
 
 type NotificationService interface {
 	CreateNotification(ctx context.Context, notification *model.Notification) error
-	GetNotifications(userID uuid.UUID, limit, offset int) ([]model.Notification, error)
+	AggregateNotification(ctx context.Context, notification *model.Notification, summarize func(count int) string) error
+	GetNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]model.Notification, error)
+	GetNotificationPage(ctx context.Context, userID uuid.UUID, cursor string, limit int) ([]model.Notification, *dto.CursorMeta, error)
 	MarkAsRead(id uuid.UUID) error
 	MarkAllAsRead(userID uuid.UUID) error
-	UnreadCount(userID uuid.UUID) (int64, error)
+	UnreadCount(ctx context.Context, userID uuid.UUID) (int64, error)
 }
 
 type notificationService struct {
 	repo        repository.NotificationRepository
 	redisClient *redis.Client
+	visibility  VisibilityPolicy
 }
 
-func NewNotificationService(repo repository.NotificationRepository, redisClient *redis.Client) NotificationService {
+func NewNotificationService(repo repository.NotificationRepository, redisClient *redis.Client, visibility VisibilityPolicy) NotificationService {
 	return &notificationService{
 		repo:        repo,
 		redisClient: redisClient,
+		visibility:  visibility,
 	}
 }
 
@@ -38,20 +43,65 @@ func (s *notificationService) CreateNotification(ctx context.Context, notificati
 	}
 
 	// 2. Publish to Redis if Redis is available
-	if s.redisClient != nil {
-		channel := fmt.Sprintf("user_notifications:%s", notification.UserID.String())
-		
-		payload, err := json.Marshal(notification)
-		if err == nil {
-			s.redisClient.Publish(ctx, channel, payload)
-		}
+	s.publish(ctx, notification)
+
+	return nil
+}
+
+// AggregateNotification folds the notification into the recipient's unread
+// notification of the same type for the same entity, if any. summarize builds
+// the message for the number of distinct actors.
+func (s *notificationService) AggregateNotification(ctx context.Context, notification *model.Notification, summarize func(count int) string) error {
+	stored, err := s.repo.Aggregate(notification, summarize)
+	if err != nil {
+		return err
 	}
 
+	s.publish(ctx, stored)
 	return nil
 }
 
-func (s *notificationService) GetNotifications(userID uuid.UUID, limit, offset int) ([]model.Notification, error) {
-	return s.repo.GetByUserID(userID, limit, offset)
+func (s *notificationService) publish(ctx context.Context, notification *model.Notification) {
+	if s.redisClient == nil {
+		return
+	}
+
+	channel := fmt.Sprintf("user_notifications:%s", notification.UserID.String())
+	payload, err := json.Marshal(notification)
+	if err == nil {
+		s.redisClient.Publish(ctx, channel, payload)
+	}
+}
+
+// Notifications about threads the user can no longer see, e.g. after a
+// change of role, are left out of lists and counts.
+func (s *notificationService) GetNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]model.Notification, error) {
+	audiences, err := s.visibility.Audiences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByUserID(userID, audiences, limit, offset)
+}
+
+// GetNotificationPage is GetNotifications with keyset pagination.
+func (s *notificationService) GetNotificationPage(ctx context.Context, userID uuid.UUID, cursor string, limit int) ([]model.Notification, *dto.CursorMeta, error) {
+	after, err := decodeCursor("notifications", cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+	audiences, err := s.visibility.Audiences(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notifications, err := s.repo.GetByUserIDAfter(userID, audiences, after, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	notifications, next := nextCursor(notifications, limit, "notifications", func(n model.Notification) repository.KeysetCursor {
+		return repository.KeysetCursor{At: n.CreatedAt, ID: n.ID}
+	})
+	return notifications, &dto.CursorMeta{NextCursor: next, Limit: limit}, nil
 }
 
 func (s *notificationService) MarkAsRead(id uuid.UUID) error {
@@ -62,6 +112,10 @@ func (s *notificationService) MarkAllAsRead(userID uuid.UUID) error {
 	return s.repo.MarkAllAsRead(userID)
 }
 
-func (s *notificationService) UnreadCount(userID uuid.UUID) (int64, error) {
-	return s.repo.CountUnread(userID)
+func (s *notificationService) UnreadCount(ctx context.Context, userID uuid.UUID) (int64, error) {
+	audiences, err := s.visibility.Audiences(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return s.repo.CountUnread(userID, audiences)
 }
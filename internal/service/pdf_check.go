@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// PDFs are stored as uploaded, so those that can act on their own when
+// opened are rejected: script, actions run on open or on page events,
+// launched programs, embedded files and forms that submit or import data.
+// Names may be written with #xx escapes and dictionaries may sit in
+// compressed object streams, so both are undone before looking.
+
+// pdfActiveNames are the PDF names of active content.
+var pdfActiveNames = [][]byte{
+	[]byte("/JS"),
+	[]byte("/JavaScript"),
+	[]byte("/OpenAction"),
+	[]byte("/AA"),
+	[]byte("/Launch"),
+	[]byte("/EmbeddedFile"),
+	[]byte("/EmbeddedFiles"),
+	[]byte("/RichMedia"),
+	[]byte("/XFA"),
+	[]byte("/SubmitForm"),
+	[]byte("/ImportData"),
+}
+
+// pdfInflateBudget is how many times its size the streams of a PDF may
+// inflate to, all together.
+const pdfInflateBudget = 16
+
+func checkPDF(data []byte) error {
+	budget := int64(pdfInflateBudget * len(data))
+	var outside []byte
+	for rest := data; ; {
+		stream, start, end := nextPDFStream(rest)
+		if stream == nil {
+			outside = append(outside, rest...)
+			break
+		}
+		outside = append(outside, rest[:start]...)
+
+		// Streams that are not zlib, e.g. images, are opaque and left alone
+		if r, err := zlib.NewReader(bytes.NewReader(stream)); err == nil {
+			inflated, err := io.ReadAll(io.LimitReader(r, budget+1))
+			budget -= int64(len(inflated))
+			if budget < 0 {
+				return fmt.Errorf("%w: PDF streams expand too much", ErrUploadInvalid)
+			}
+			if err == nil {
+				if name := pdfActiveName(inflated); name != nil {
+					return fmt.Errorf("%w: PDF contains %s", ErrUploadActiveContent, name)
+				}
+			}
+		}
+		rest = rest[end:]
+	}
+
+	if name := pdfActiveName(outside); name != nil {
+		return fmt.Errorf("%w: PDF contains %s", ErrUploadActiveContent, name)
+	}
+	// Stream data is binary; markup hidden in a PDF is outside of it
+	return checkPolyglot(outside)
+}
+
+// nextPDFStream finds the data of the first stream in data and where the
+// stream, keywords included, starts and ends.
+func nextPDFStream(data []byte) (stream []byte, start, end int) {
+	for i := 0; ; {
+		j := bytes.Index(data[i:], []byte("stream"))
+		if j < 0 {
+			return nil, 0, 0
+		}
+		start = i + j
+		i = start + len("stream")
+		// Not "endstream", and followed by the end of the line
+		if start > 0 && data[start-1] == 'd' {
+			continue
+		}
+		switch {
+		case bytes.HasPrefix(data[i:], []byte("\r\n")):
+			i += 2
+		case bytes.HasPrefix(data[i:], []byte("\n")):
+			i++
+		default:
+			continue
+		}
+		k := bytes.Index(data[i:], []byte("endstream"))
+		if k < 0 {
+			return data[i:], start, len(data)
+		}
+		return data[i : i+k], start, i + k + len("endstream")
+	}
+}
+
+// pdfActiveName returns the first active content name in data, if any.
+func pdfActiveName(data []byte) []byte {
+	data = unescapePDFNames(data)
+	for _, name := range pdfActiveNames {
+		for i := 0; ; {
+			j := bytes.Index(data[i:], name)
+			if j < 0 {
+				break
+			}
+			i += j + len(name)
+			// The whole name, not the start of a longer one
+			if i == len(data) || isPDFDelimiter(data[i]) {
+				return name
+			}
+		}
+	}
+	return nil
+}
+
+// unescapePDFNames decodes the #xx escapes of names, e.g. /J#53 for /JS.
+func unescapePDFNames(data []byte) []byte {
+	if bytes.IndexByte(data, '#') < 0 {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == '#' && i+2 < len(data) {
+			if hi, ok := fromHex(data[i+1]); ok {
+				if lo, ok := fromHex(data[i+2]); ok {
+					out = append(out, hi<<4|lo)
+					i += 2
+					continue
+				}
+			}
+		}
+		out = append(out, data[i])
+	}
+	return out
+}
+
+func fromHex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// isPDFDelimiter reports whether c ends a name: white space or a delimiter.
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ', '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
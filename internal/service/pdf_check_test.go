@@ -0,0 +1,62 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// pdfWith builds a minimal PDF around body, with streams as its stream data.
+func pdfWith(body string, streams ...[]byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R " + body + " >>\nendobj\n")
+	for _, data := range streams {
+		b.WriteString("3 0 obj\n<< /Length 10 /Filter /FlateDecode >>\nstream\n")
+		b.Write(data)
+		b.WriteString("\nendstream\nendobj\n")
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func deflate(s string) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write([]byte(s))
+	w.Close()
+	return b.Bytes()
+}
+
+func TestCheckPDF(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"plain", pdfWith(""), nil},
+		{"script", pdfWith("/Names << /JavaScript 5 0 R >>"), ErrUploadActiveContent},
+		{"short script", pdfWith("/S /JS"), ErrUploadActiveContent},
+		{"escaped name", pdfWith("/S /J#53"), ErrUploadActiveContent},
+		{"open action", pdfWith("/OpenAction 4 0 R"), ErrUploadActiveContent},
+		{"page action at the end of a dictionary", pdfWith("/AA<<>>"), ErrUploadActiveContent},
+		{"launch", pdfWith("/S /Launch"), ErrUploadActiveContent},
+		{"embedded file", pdfWith("/EmbeddedFiles 6 0 R"), ErrUploadActiveContent},
+		{"longer names are fine", pdfWith("/JSONData 1 /AAPL 2 /Launcher 3"), nil},
+		{"action in a compressed object stream", pdfWith("", deflate("<< /OpenAction 4 0 R >>")), ErrUploadActiveContent},
+		{"compressed content", pdfWith("", deflate("BT /F1 12 Tf (Hello) Tj ET")), nil},
+		{"markup in an opaque stream", pdfWith("", []byte("\xff\xd8<svg<script\xff\xd9")), nil},
+		{"markup outside of streams", pdfWith("/Title (<script>alert(1)</script>)"), ErrUploadPolyglot},
+		{"stream bomb", pdfWith("", deflate(strings.Repeat("0", 1<<20))), ErrUploadInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPDF(tt.data)
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("checkPDF = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
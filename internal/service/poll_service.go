@@ -0,0 +1,406 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"anoa.com/telkomalumiforum/pkg/queue"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPollNotFound      = errors.New("poll not found")
+	ErrPollClosed        = errors.New("poll is closed")
+	ErrPollAlreadyVoted  = errors.New("already voted")
+	ErrPollInvalidOption = errors.New("invalid poll option")
+	ErrPollForbidden     = errors.New("unauthorized: only the thread author or an admin can close this poll")
+)
+
+type PollService interface {
+	GetPollByThreadID(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*dto.PollResponse, error)
+	Vote(ctx context.Context, userID uuid.UUID, pollID uuid.UUID, req dto.VotePollRequest) (*dto.PollResponse, error)
+	ClosePoll(ctx context.Context, userID uuid.UUID, pollID uuid.UUID) (*dto.PollResponse, error)
+}
+
+type pollService struct {
+	redisClient *redis.Client
+	pollRepo    repository.PollRepository
+	userRepo    repository.UserRepository
+	visibility  VisibilityPolicy
+	jobs        *jobs.Manager
+}
+
+func NewPollService(redisClient *redis.Client, pollRepo repository.PollRepository, userRepo repository.UserRepository, visibility VisibilityPolicy, jobManager *jobs.Manager) PollService {
+	s := &pollService{
+		redisClient: redisClient,
+		pollRepo:    pollRepo,
+		userRepo:    userRepo,
+		visibility:  visibility,
+		jobs:        jobManager,
+	}
+
+	jobs.Register(jobManager, PollVoteJob, jobs.Options{Queue: PollQueueKey}, s.processTask)
+	return s
+}
+
+const (
+	PollVoteJob  = "polls.vote"
+	PollQueueKey = "poll_queue"
+)
+
+type PollVoteTask struct {
+	PollID    string   `json:"poll_id"`
+	UserID    string   `json:"user_id"`
+	OptionIDs []string `json:"option_ids"`
+}
+
+// NewPollModel validates a poll request and converts it to a model ready to be
+// created together with its thread.
+func NewPollModel(req *dto.CreatePollRequest) (*model.Poll, error) {
+	if req.Deadline != nil && !req.Deadline.After(time.Now()) {
+		return nil, fmt.Errorf("poll deadline must be in the future")
+	}
+
+	poll := &model.Poll{
+		Question:              req.Question,
+		MultipleChoice:        req.MultipleChoice,
+		Anonymous:             req.Anonymous,
+		ShowResultsBeforeVote: req.ShowResultsBeforeVote,
+		Deadline:              req.Deadline,
+	}
+	for i, text := range req.Options {
+		poll.Options = append(poll.Options, model.PollOption{
+			Text:     text,
+			Position: i,
+		})
+	}
+	return poll, nil
+}
+
+func pollVotersKey(pollID uuid.UUID) string {
+	return fmt.Sprintf("poll_voters:%s", pollID.String())
+}
+
+func pollVotesKey(pollID uuid.UUID, optionID uuid.UUID) string {
+	return fmt.Sprintf("poll_votes:%s:%s", pollID.String(), optionID.String())
+}
+
+func (s *pollService) GetPollByThreadID(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*dto.PollResponse, error) {
+	if _, err := s.visibility.Thread(ctx, userID, threadID); err != nil {
+		return nil, err
+	}
+
+	poll, err := s.pollRepo.FindByThreadID(ctx, threadID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPollNotFound
+		}
+		return nil, err
+	}
+	return s.buildResponse(ctx, userID, poll)
+}
+
+func (s *pollService) Vote(ctx context.Context, userID uuid.UUID, pollID uuid.UUID, req dto.VotePollRequest) (*dto.PollResponse, error) {
+	if s.redisClient == nil {
+		return nil, errors.New("redis is required for polls")
+	}
+
+	poll, _, err := s.findPoll(ctx, userID, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	if poll.IsClosed(time.Now()) {
+		return nil, ErrPollClosed
+	}
+
+	if !poll.MultipleChoice && len(req.OptionIDs) != 1 {
+		return nil, fmt.Errorf("%w: this poll accepts exactly one option", ErrPollInvalidOption)
+	}
+
+	validOptions := make(map[uuid.UUID]bool)
+	for _, opt := range poll.Options {
+		validOptions[opt.ID] = true
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	var optionIDs []uuid.UUID
+	for _, idStr := range req.OptionIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil || !validOptions[id] {
+			return nil, ErrPollInvalidOption
+		}
+		if !seen[id] {
+			seen[id] = true
+			optionIDs = append(optionIDs, id)
+		}
+	}
+
+	if err := s.ensureLoaded(ctx, poll.ID); err != nil {
+		return nil, err
+	}
+
+	// 1. Claim the ballot. SADD is atomic, so only one request per user wins.
+	added, err := s.redisClient.SAdd(ctx, pollVotersKey(poll.ID), userID.String()).Result()
+	if err != nil {
+		return nil, err
+	}
+	if added == 0 {
+		return nil, ErrPollAlreadyVoted
+	}
+
+	// 2. Record the chosen options
+	pipe := s.redisClient.TxPipeline()
+	for _, optionID := range optionIDs {
+		pipe.SAdd(ctx, pollVotesKey(poll.ID, optionID), userID.String())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.releaseBallot(ctx, poll.ID, userID, optionIDs)
+		return nil, err
+	}
+
+	// 3. Persist asynchronously
+	task := PollVoteTask{
+		PollID:    poll.ID.String(),
+		UserID:    userID.String(),
+		OptionIDs: make([]string, 0, len(optionIDs)),
+	}
+	for _, optionID := range optionIDs {
+		task.OptionIDs = append(task.OptionIDs, optionID.String())
+	}
+	if err := s.pushTask(ctx, task); err != nil {
+		// Without the task the vote would never be stored; let the user retry
+		s.releaseBallot(ctx, poll.ID, userID, optionIDs)
+		return nil, err
+	}
+
+	return s.buildResponse(ctx, userID, poll)
+}
+
+// releaseBallot undoes the Redis writes of a vote that could not go through.
+func (s *pollService) releaseBallot(ctx context.Context, pollID uuid.UUID, userID uuid.UUID, optionIDs []uuid.UUID) {
+	ctx = context.WithoutCancel(ctx)
+	pipe := s.redisClient.TxPipeline()
+	for _, optionID := range optionIDs {
+		pipe.SRem(ctx, pollVotesKey(pollID, optionID), userID.String())
+	}
+	pipe.SRem(ctx, pollVotersKey(pollID), userID.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to release poll ballot of %s on %s: %v", userID, pollID, err)
+	}
+}
+
+// ensureLoaded rehydrates the poll's sets from the database when Redis lost
+// them, so that a user who already voted cannot vote again and the counts
+// stay right. Writes are additive, so a vote landing in between is kept.
+// Polls nobody voted on have no sets and are looked up every time, which is
+// a single indexed query.
+func (s *pollService) ensureLoaded(ctx context.Context, pollID uuid.UUID) error {
+	n, err := s.redisClient.Exists(ctx, pollVotersKey(pollID)).Result()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	votes, err := s.pollRepo.FindVotes(ctx, pollID)
+	if err != nil || len(votes) == 0 {
+		return err
+	}
+
+	pipe := s.redisClient.TxPipeline()
+	for _, vote := range votes {
+		pipe.SAdd(ctx, pollVotersKey(pollID), vote.UserID.String())
+		pipe.SAdd(ctx, pollVotesKey(pollID, vote.OptionID), vote.UserID.String())
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *pollService) ClosePoll(ctx context.Context, userID uuid.UUID, pollID uuid.UUID) (*dto.PollResponse, error) {
+	poll, thread, err := s.findPoll(ctx, userID, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	if thread.UserID != userID {
+		user, err := s.userRepo.FindByID(ctx, userID.String())
+		if err != nil {
+			return nil, fmt.Errorf("user not found")
+		}
+		if user.Role.Name != "admin" {
+			return nil, ErrPollForbidden
+		}
+	}
+
+	if poll.ClosedAt == nil {
+		now := time.Now()
+		if err := s.pollRepo.Close(ctx, poll.ID, now); err != nil {
+			return nil, err
+		}
+		poll.ClosedAt = &now
+	}
+
+	return s.buildResponse(ctx, userID, poll)
+}
+
+// findPoll loads a poll together with its thread. Polls on threads the user
+// cannot see are reported as missing.
+func (s *pollService) findPoll(ctx context.Context, userID uuid.UUID, pollID uuid.UUID) (*model.Poll, *model.Thread, error) {
+	poll, err := s.pollRepo.FindByID(ctx, pollID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrPollNotFound
+		}
+		return nil, nil, err
+	}
+
+	thread, err := s.visibility.Thread(ctx, userID, poll.ThreadID)
+	if err != nil {
+		if errors.Is(err, ErrThreadNotFound) {
+			return nil, nil, ErrPollNotFound
+		}
+		return nil, nil, err
+	}
+	return poll, thread, nil
+}
+
+func (s *pollService) buildResponse(ctx context.Context, userID uuid.UUID, poll *model.Poll) (*dto.PollResponse, error) {
+	resp := &dto.PollResponse{
+		ID:                    poll.ID,
+		ThreadID:              poll.ThreadID,
+		Question:              poll.Question,
+		MultipleChoice:        poll.MultipleChoice,
+		Anonymous:             poll.Anonymous,
+		ShowResultsBeforeVote: poll.ShowResultsBeforeVote,
+		IsClosed:              poll.IsClosed(time.Now()),
+	}
+	if poll.Deadline != nil {
+		deadline := poll.Deadline.Format("2006-01-02 15:04:05")
+		resp.Deadline = &deadline
+	}
+
+	if s.redisClient == nil {
+		for _, opt := range poll.Options {
+			resp.Options = append(resp.Options, dto.PollOptionResponse{ID: opt.ID, Text: opt.Text})
+		}
+		return resp, nil
+	}
+
+	if err := s.ensureLoaded(ctx, poll.ID); err != nil {
+		return nil, err
+	}
+
+	// Fetch everything in one round trip
+	pipe := s.redisClient.Pipeline()
+	totalCmd := pipe.SCard(ctx, pollVotersKey(poll.ID))
+	countCmds := make([]*redis.IntCmd, len(poll.Options))
+	mineCmds := make([]*redis.BoolCmd, len(poll.Options))
+	var memberCmds []*redis.StringSliceCmd
+	for i, opt := range poll.Options {
+		key := pollVotesKey(poll.ID, opt.ID)
+		countCmds[i] = pipe.SCard(ctx, key)
+		mineCmds[i] = pipe.SIsMember(ctx, key, userID.String())
+		if !poll.Anonymous {
+			memberCmds = append(memberCmds, pipe.SMembers(ctx, key))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, opt := range poll.Options {
+		if mineCmds[i].Val() {
+			resp.HasVoted = true
+			resp.MyVotes = append(resp.MyVotes, opt.ID)
+		}
+	}
+
+	resp.ResultsVisible = resp.IsClosed || resp.HasVoted || poll.ShowResultsBeforeVote
+
+	var voterNames map[string]dto.AuthorResponse
+	if resp.ResultsVisible && !poll.Anonymous {
+		var ids []string
+		for _, cmd := range memberCmds {
+			ids = append(ids, cmd.Val()...)
+		}
+		voterNames = s.lookupAuthors(ctx, ids)
+	}
+
+	for i, opt := range poll.Options {
+		optResp := dto.PollOptionResponse{ID: opt.ID, Text: opt.Text}
+		if resp.ResultsVisible {
+			count := countCmds[i].Val()
+			optResp.VotesCount = &count
+			if !poll.Anonymous {
+				for _, voterID := range memberCmds[i].Val() {
+					if author, ok := voterNames[voterID]; ok {
+						optResp.Voters = append(optResp.Voters, author)
+					}
+				}
+			}
+		}
+		resp.Options = append(resp.Options, optResp)
+	}
+
+	if resp.ResultsVisible {
+		total := totalCmd.Val()
+		resp.TotalVoters = &total
+	}
+
+	return resp, nil
+}
+
+func (s *pollService) lookupAuthors(ctx context.Context, ids []string) map[string]dto.AuthorResponse {
+	authors := make(map[string]dto.AuthorResponse)
+	if len(ids) == 0 {
+		return authors
+	}
+
+	users, err := s.userRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("Failed to load poll voters: %v", err)
+		return authors
+	}
+
+	for _, u := range users {
+		authors[u.ID.String()] = dto.AuthorResponse{
+			Username:  u.Username,
+			AvatarURL: u.AvatarURL,
+		}
+	}
+	return authors
+}
+
+func (s *pollService) pushTask(ctx context.Context, task PollVoteTask) error {
+	return s.jobs.Enqueue(ctx, PollVoteJob, task)
+}
+
+func (s *pollService) processTask(ctx context.Context, task PollVoteTask) error {
+	pollID, err := uuid.Parse(task.PollID)
+	if err != nil {
+		return queue.Permanent(fmt.Errorf("invalid poll id in poll task: %w", err))
+	}
+	userID, err := uuid.Parse(task.UserID)
+	if err != nil {
+		return queue.Permanent(fmt.Errorf("invalid user id in poll task: %w", err))
+	}
+
+	optionIDs := make([]uuid.UUID, 0, len(task.OptionIDs))
+	for _, idStr := range task.OptionIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return queue.Permanent(fmt.Errorf("invalid option id in poll task: %w", err))
+		}
+		optionIDs = append(optionIDs, id)
+	}
+
+	return s.pollRepo.SaveVotes(ctx, pollID, userID, optionIDs)
+}
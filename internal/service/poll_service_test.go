@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// memoryPollRepo keeps polls and votes in memory, one ballot per user like
+// the database.
+type memoryPollRepo struct {
+	repository.PollRepository
+	mu    sync.Mutex
+	polls map[uuid.UUID]*model.Poll
+	votes []model.PollVote
+}
+
+func (r *memoryPollRepo) FindByID(ctx context.Context, id uuid.UUID) (*model.Poll, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if poll, ok := r.polls[id]; ok {
+		copied := *poll
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryPollRepo) FindVotes(ctx context.Context, pollID uuid.UUID) ([]model.PollVote, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var votes []model.PollVote
+	for _, v := range r.votes {
+		if v.PollID == pollID {
+			votes = append(votes, v)
+		}
+	}
+	return votes, nil
+}
+
+func (r *memoryPollRepo) SaveVotes(ctx context.Context, pollID uuid.UUID, userID uuid.UUID, optionIDs []uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.votes {
+		if v.PollID == pollID && v.UserID == userID {
+			return nil
+		}
+	}
+	for _, id := range optionIDs {
+		r.votes = append(r.votes, model.PollVote{PollID: pollID, UserID: userID, OptionID: id})
+	}
+	return nil
+}
+
+func (r *memoryPollRepo) stored(userID uuid.UUID) []uuid.UUID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []uuid.UUID
+	for _, v := range r.votes {
+		if v.UserID == userID {
+			ids = append(ids, v.OptionID)
+		}
+	}
+	return ids
+}
+
+type nopJobRepo struct {
+	repository.JobRepository
+}
+
+func (nopJobRepo) Create(ctx context.Context, run *model.JobRun) error { return nil }
+
+type pollFixture struct {
+	service *pollService
+	repo    *memoryPollRepo
+	redis   *miniredis.Miniredis
+	voter   uuid.UUID
+	jobs    *jobs.Manager
+}
+
+// newPollFixture sets up a poll service whose votes are persisted through a
+// job manager without Redis, i.e. in a goroutine per vote.
+func newPollFixture(t *testing.T, polls ...*model.Poll) *pollFixture {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	voter := &model.User{ID: uuid.New(), Role: model.Role{Name: "siswa"}}
+	threads := map[uuid.UUID]*model.Thread{}
+	repo := &memoryPollRepo{polls: map[uuid.UUID]*model.Poll{}}
+	for _, poll := range polls {
+		threads[poll.ThreadID] = &model.Thread{ID: poll.ThreadID, UserID: uuid.New(), Audience: "semua"}
+		repo.polls[poll.ID] = poll
+	}
+	visibility := NewVisibilityPolicy(
+		&fakeUserRepo{users: map[string]*model.User{voter.ID.String(): voter}},
+		&fakeThreadRepo{threads: threads},
+		&fakePostRepo{},
+	)
+
+	manager := jobs.NewManager(nil, nopJobRepo{})
+	s := NewPollService(client, repo, nil, visibility, manager).(*pollService)
+	return &pollFixture{service: s, repo: repo, redis: server, voter: voter.ID, jobs: manager}
+}
+
+// newTestPoll returns an anonymous poll with the given number of options.
+func newTestPoll(multiple bool, options int) *model.Poll {
+	poll := &model.Poll{ID: uuid.New(), ThreadID: uuid.New(), Question: "Reuni kapan?", MultipleChoice: multiple, Anonymous: true}
+	for i := 0; i < options; i++ {
+		poll.Options = append(poll.Options, model.PollOption{ID: uuid.New(), PollID: poll.ID, Position: i})
+	}
+	return poll
+}
+
+func (f *pollFixture) vote(poll *model.Poll, options ...uuid.UUID) (*dto.PollResponse, error) {
+	req := dto.VotePollRequest{}
+	for _, id := range options {
+		req.OptionIDs = append(req.OptionIDs, id.String())
+	}
+	return f.service.Vote(context.Background(), f.voter, poll.ID, req)
+}
+
+func TestPollVote(t *testing.T) {
+	poll := newTestPoll(false, 2)
+	yes, no := poll.Options[0].ID, poll.Options[1].ID
+	f := newPollFixture(t, poll)
+
+	resp, err := f.vote(poll, yes)
+	if err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+	if !resp.HasVoted || !slices.Equal(resp.MyVotes, []uuid.UUID{yes}) || *resp.TotalVoters != 1 || *resp.Options[0].VotesCount != 1 {
+		t.Errorf("response %+v, want one vote for the first option", resp)
+	}
+
+	f.jobs.Wait()
+	if got := f.repo.stored(f.voter); !slices.Equal(got, []uuid.UUID{yes}) {
+		t.Errorf("stored %v, want [%s]", got, yes)
+	}
+
+	// The ballot is cast; changing it is refused, also after Redis lost the
+	// sets and they come back from the database
+	for _, lost := range []bool{false, true} {
+		if lost {
+			f.redis.FlushAll()
+		}
+		if _, err := f.vote(poll, no); !errors.Is(err, ErrPollAlreadyVoted) {
+			t.Errorf("changing the vote (Redis lost = %v): error %v, want %v", lost, err, ErrPollAlreadyVoted)
+		}
+	}
+	f.jobs.Wait()
+	if got := f.repo.stored(f.voter); !slices.Equal(got, []uuid.UUID{yes}) {
+		t.Errorf("stored %v after changing the vote, want still [%s]", got, yes)
+	}
+	resp, err = f.service.buildResponse(context.Background(), f.voter, poll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(resp.MyVotes, []uuid.UUID{yes}) || *resp.Options[1].VotesCount != 0 {
+		t.Errorf("response %+v after changing the vote, want the first vote", resp)
+	}
+}
+
+func TestPollVoteClosed(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	closed := newTestPoll(false, 2)
+	closed.ClosedAt = &past
+	expired := newTestPoll(false, 2)
+	expired.Deadline = &past
+	f := newPollFixture(t, closed, expired)
+
+	for name, poll := range map[string]*model.Poll{"closed": closed, "past deadline": expired} {
+		if _, err := f.vote(poll, poll.Options[0].ID); !errors.Is(err, ErrPollClosed) {
+			t.Errorf("%s: error %v, want %v", name, err, ErrPollClosed)
+		}
+	}
+	f.jobs.Wait()
+	if got := f.repo.stored(f.voter); len(got) != 0 {
+		t.Errorf("stored %v, want no votes", got)
+	}
+}
+
+func TestPollVoteOptions(t *testing.T) {
+	single := newTestPoll(false, 3)
+	multiple := newTestPoll(true, 3)
+	other := newTestPoll(true, 1)
+
+	tests := []struct {
+		name    string
+		poll    *model.Poll
+		options []uuid.UUID
+		want    []uuid.UUID
+		wantErr error
+	}{
+		{"single choice, one option", single, []uuid.UUID{single.Options[1].ID}, []uuid.UUID{single.Options[1].ID}, nil},
+		{"single choice, two options", single, []uuid.UUID{single.Options[0].ID, single.Options[1].ID}, nil, ErrPollInvalidOption},
+		{"single choice, the same option twice", single, []uuid.UUID{single.Options[0].ID, single.Options[0].ID}, nil, ErrPollInvalidOption},
+		{"multiple choice, every option", multiple, []uuid.UUID{multiple.Options[0].ID, multiple.Options[1].ID, multiple.Options[2].ID}, []uuid.UUID{multiple.Options[0].ID, multiple.Options[1].ID, multiple.Options[2].ID}, nil},
+		{"multiple choice, duplicates", multiple, []uuid.UUID{multiple.Options[2].ID, multiple.Options[2].ID, multiple.Options[0].ID}, []uuid.UUID{multiple.Options[2].ID, multiple.Options[0].ID}, nil},
+		{"option of another poll", multiple, []uuid.UUID{multiple.Options[0].ID, other.Options[0].ID}, nil, ErrPollInvalidOption},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPollFixture(t, single, multiple, other)
+			_, err := f.vote(tt.poll, tt.options...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Vote: error %v, want %v", err, tt.wantErr)
+			}
+
+			f.jobs.Wait()
+			got := f.repo.stored(f.voter)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("stored %v, want %v", got, tt.want)
+			}
+			// A refused vote leaves the ballot free
+			if tt.wantErr != nil {
+				if n := f.redis.Exists(pollVotersKey(tt.poll.ID)); n {
+					t.Error("refused vote claimed the ballot")
+				}
+			}
+		})
+	}
+}
@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"anoa.com/telkomalumiforum/internal/dto"
@@ -11,39 +15,50 @@ import (
 	"anoa.com/telkomalumiforum/pkg/storage"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 type PostService interface {
 	CreatePost(ctx context.Context, userID uuid.UUID, req dto.CreatePostRequest) (*dto.PostResponse, error)
-	GetPostsByThreadID(ctx context.Context, threadID uuid.UUID, filter dto.PostFilter) (*dto.PaginatedPostResponse, error)
-	GetPostByID(ctx context.Context, postID uuid.UUID) (*dto.PostResponse, error)
+	GetPostsByThreadID(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, filter dto.PostFilter) (*dto.PaginatedPostResponse, error)
+	GetReplies(ctx context.Context, userID uuid.UUID, postID uuid.UUID, filter dto.RepliesFilter) (*dto.PaginatedRepliesResponse, error)
+	GetPostByID(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (*dto.PostResponse, error)
 	UpdatePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID, req dto.UpdatePostRequest) (*dto.PostResponse, error)
 	DeletePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error
+	RestorePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (*dto.PostResponse, error)
 }
 
+// ErrPostParentDeleted refuses to restore a reply whose parent is deleted;
+// the parent has to be restored first.
+var ErrPostParentDeleted = repository.ErrPostParentDeleted
+
 type postService struct {
-	postRepo       repository.PostRepository
-	threadRepo     repository.ThreadRepository
-	userRepo       repository.UserRepository
-	attachmentRepo repository.AttachmentRepository
-	likeService    LikeService
-	fileStorage    storage.ImageStorage
-	redisClient    *redis.Client
+	postRepo            repository.PostRepository
+	threadRepo          repository.ThreadRepository
+	userRepo            repository.UserRepository
+	attachmentRepo      repository.AttachmentRepository
+	likeService         LikeService
+	fileStorage         storage.ImageStorage
+	redisClient         *redis.Client
 	notificationService NotificationService
-	meili          MeiliSearchService
+	subscriptionService SubscriptionService
+	readService         ReadService
+	visibility          VisibilityPolicy
 }
 
-func NewPostService(postRepo repository.PostRepository, threadRepo repository.ThreadRepository, userRepo repository.UserRepository, attachmentRepo repository.AttachmentRepository, likeService LikeService, fileStorage storage.ImageStorage, redisClient *redis.Client, notificationService NotificationService, meili MeiliSearchService) PostService {
+func NewPostService(postRepo repository.PostRepository, threadRepo repository.ThreadRepository, userRepo repository.UserRepository, attachmentRepo repository.AttachmentRepository, likeService LikeService, fileStorage storage.ImageStorage, redisClient *redis.Client, notificationService NotificationService, subscriptionService SubscriptionService, readService ReadService, visibility VisibilityPolicy) PostService {
 	return &postService{
-		postRepo:       postRepo,
-		threadRepo:     threadRepo,
-		userRepo:       userRepo,
-		attachmentRepo: attachmentRepo,
-		likeService:    likeService,
-		fileStorage:    fileStorage,
-		redisClient:    redisClient,
+		postRepo:            postRepo,
+		threadRepo:          threadRepo,
+		userRepo:            userRepo,
+		attachmentRepo:      attachmentRepo,
+		likeService:         likeService,
+		fileStorage:         fileStorage,
+		redisClient:         redisClient,
 		notificationService: notificationService,
-		meili:          meili,
+		subscriptionService: subscriptionService,
+		readService:         readService,
+		visibility:          visibility,
 	}
 }
 
@@ -92,10 +107,10 @@ func (s *postService) CreatePost(ctx context.Context, userID uuid.UUID, req dto.
 		return nil, fmt.Errorf("invalid thread id")
 	}
 
-	// Verify Thread Exists
-	thread, err := s.threadRepo.FindByID(ctx, threadID)
-	if err != nil || thread == nil {
-		return nil, fmt.Errorf("thread not found")
+	// Verify Thread Exists and the user can see it
+	thread, err := s.visibility.Thread(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
 	}
 
 	var parentID *uuid.UUID
@@ -104,9 +119,9 @@ func (s *postService) CreatePost(ctx context.Context, userID uuid.UUID, req dto.
 		if err != nil {
 			return nil, fmt.Errorf("invalid parent id")
 		}
-		// Verify Parent Exists
+		// Verify Parent Exists in the same thread
 		parent, err := s.postRepo.FindByID(ctx, pid)
-		if err != nil || parent == nil {
+		if err != nil || parent == nil || parent.ThreadID != threadID {
 			return nil, fmt.Errorf("parent post not found")
 		}
 		parentID = &pid
@@ -119,15 +134,13 @@ func (s *postService) CreatePost(ctx context.Context, userID uuid.UUID, req dto.
 		Content:  req.Content,
 	}
 
-	if err := s.postRepo.Create(ctx, post); err != nil {
+	// The attachments are linked in the same transaction, so a bad id leaves
+	// no post behind for a retry to duplicate
+	if err := s.postRepo.Create(ctx, post, req.AttachmentIDs); err != nil {
 		return nil, err
 	}
 
 	if len(req.AttachmentIDs) > 0 {
-		if err := s.attachmentRepo.UpdatePostID(ctx, req.AttachmentIDs, post.ID, userID); err != nil {
-			return nil, err
-		}
-
 		// Reload post to get attachments
 		reloaded, err := s.postRepo.FindByID(ctx, post.ID)
 		if err == nil {
@@ -142,45 +155,11 @@ func (s *postService) CreatePost(ctx context.Context, userID uuid.UUID, req dto.
 	// Everything succeeded, don't roll back the rate limits.
 	creationFailed = false
 
-	go func() {
-		// Avoid notifying the user themselves
-		var targetUserID uuid.UUID
-		var notifType string
-		var message string
-
-		if parentID != nil {
-			// Reply to a post
-			// Re-fetch parent to safely check UserID
-			p, err := s.postRepo.FindByID(context.Background(), *parentID)
-			if err == nil && p.UserID != userID {
-				targetUserID = p.UserID
-				notifType = "reply_post"
-				message = fmt.Sprintf("Someone replied to your post in '%s'", thread.Title)
-			}
-		} else {
-			// Reply to thread
-			if thread.UserID != userID {
-				targetUserID = thread.UserID
-				notifType = "reply_thread"
-				message = fmt.Sprintf("Someone commented on your thread '%s'", thread.Title)
-			}
-		}
-
-		if targetUserID != uuid.Nil {
-			notification := &model.Notification{
-				UserID:     targetUserID,
-				ActorID:    userID,
-				EntityID:   post.ID, // The new reply
-				EntitySlug: thread.Slug,
-				EntityType: "post",
-				Type:       notifType,
-				Message:    message,
-			}
-			_ = s.notificationService.CreateNotification(context.Background(), notification)
-		}
-	}()
+	// Replying watches the thread; notifying the author, parent author,
+	// mentioned users and watchers happens in the background worker.
+	s.subscriptionService.AutoWatch(ctx, userID, threadID)
+	s.subscriptionService.NotifyReply(ctx, post.ID)
 
-	// Index to Meilisearch
 	post.Thread = *thread
 	// Ensure User is populated if not already
 	if post.User.Username == "" {
@@ -189,98 +168,328 @@ func (s *postService) CreatePost(ctx context.Context, userID uuid.UUID, req dto.
 			post.User = *u
 		}
 	}
-	
-	if s.meili != nil {
-		if err := s.meili.IndexPost(post); err != nil {
-			fmt.Printf("Failed to index post: %v\n", err)
-		}
-	}
 
-	return s.mapToResponse(post), nil
+	return s.mapToResponse(ctx, userID, post), nil
 }
 
-func (s *postService) GetPostsByThreadID(ctx context.Context, threadID uuid.UUID, filter dto.PostFilter) (*dto.PaginatedPostResponse, error) {
+func (s *postService) GetPostsByThreadID(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, filter dto.PostFilter) (*dto.PaginatedPostResponse, error) {
 	if filter.Page == 0 {
 		filter.Page = 1
 	}
 	if filter.Limit == 0 {
 		filter.Limit = 10
 	}
+	flat := filter.Mode == "flat"
 
-	// Fetch ALL posts for the thread to build the tree
-	allPosts, err := s.postRepo.FindAllByThreadID(ctx, threadID)
+	thread, err := s.visibility.Thread(ctx, userID, threadID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 1. Convert all to DTOs and store in map
-	postMap := make(map[uuid.UUID]*dto.PostResponse)
-	for _, p := range allPosts {
-		postMap[p.ID] = s.mapToResponse(p)
+	// Locate the first unread post and the page that holds it
+	firstUnread := s.firstUnread(ctx, userID, thread, flat, filter.Limit)
+	if filter.Jump == "first_unread" && firstUnread != nil {
+		filter.Page = firstUnread.Page
 	}
 
-	// 2. Build Tree
-	var roots []*dto.PostResponse
-	for _, p := range allPosts {
-		node := postMap[p.ID]
-		if p.ParentID == nil {
-			roots = append(roots, node)
-		} else {
-			if parent, exists := postMap[*p.ParentID]; exists {
-				parent.Replies = append(parent.Replies, node)
-			}
-			// If parent doesn't exist (shouldn't happen with valid FKs), we ignore or treat as root.
-			// Currently ignoring to avoid clutter.
-		}
-	}
-
-	// 3. Paginate Roots
-	totalRoots := int64(len(roots))
-	startIndex := (filter.Page - 1) * filter.Limit
-	endIndex := startIndex + filter.Limit
+	offset := (filter.Page - 1) * filter.Limit
 
-	if startIndex < 0 {
-		startIndex = 0
-	}
-
-	var paginatedRoots []dto.PostResponse
-	if startIndex < int(totalRoots) {
-		if endIndex > int(totalRoots) {
-			endIndex = int(totalRoots)
+	var data []dto.PostResponse
+	var loaded []*model.Post
+	var total int64
+	if flat {
+		// Chronological list of every post; replies keep their parent_id
+		posts, count, err := s.postRepo.FindByThreadID(ctx, threadID, offset, filter.Limit)
+		if err != nil {
+			return nil, err
 		}
-		for _, r := range roots[startIndex:endIndex] {
-			paginatedRoots = append(paginatedRoots, *r)
+		data, loaded, err = s.buildTree(ctx, userID, posts, 1, false)
+		if err != nil {
+			return nil, err
 		}
+		total = count
 	} else {
-		paginatedRoots = []dto.PostResponse{}
+		// Paginate root posts in the database, then load their replies level by level
+		roots, count, err := s.postRepo.FindRootsByThreadID(ctx, threadID, offset, filter.Limit)
+		if err != nil {
+			return nil, err
+		}
+		data, loaded, err = s.buildTree(ctx, userID, roots, s.treeDepth(filter.Depth), true)
+		if err != nil {
+			return nil, err
+		}
+		total = count
 	}
 
-	totalPages := int(totalRoots) / filter.Limit
-	if int(totalRoots)%filter.Limit != 0 {
+	totalPages := int(total) / filter.Limit
+	if int(total)%filter.Limit != 0 {
 		totalPages++
 	}
 
+	s.markPageRead(ctx, userID, thread, loaded)
+
 	return &dto.PaginatedPostResponse{
-		Data: paginatedRoots,
+		Data: data,
 		Meta: dto.PaginationMeta{
 			CurrentPage: filter.Page,
 			TotalPages:  totalPages,
-			TotalItems:  totalRoots,
+			TotalItems:  total,
 			Limit:       filter.Limit,
 		},
+		FirstUnread: firstUnread,
 	}, nil
 }
 
-func (s *postService) GetPostByID(ctx context.Context, postID uuid.UUID) (*dto.PostResponse, error) {
-	post, err := s.postRepo.FindByID(ctx, postID)
+// GetReplies loads the next direct replies of a post after the cursor from a
+// "more_replies" link, each with its own replies down to the requested depth.
+func (s *postService) GetReplies(ctx context.Context, userID uuid.UUID, postID uuid.UUID, filter dto.RepliesFilter) (*dto.PaginatedRepliesResponse, error) {
+	if filter.Limit == 0 {
+		filter.Limit = 10
+	}
+
+	if _, err := s.visibility.Post(ctx, userID, postID); err != nil {
+		return nil, err
+	}
+
+	var after *repository.PostCursor
+	if filter.Cursor != "" {
+		cursor, err := decodePostCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = cursor
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	children, err := s.postRepo.FindChildrenAfter(ctx, postID, after, filter.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var nextCursor *string
+	if len(children) > filter.Limit {
+		children = children[:filter.Limit]
+		last := children[len(children)-1]
+		cursor := encodePostCursor(last)
+		nextCursor = &cursor
+	}
+
+	data, _, err := s.buildTree(ctx, userID, children, s.treeDepth(filter.Depth), true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.PaginatedRepliesResponse{
+		Data:       data,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// treeDepth clamps the requested depth to POST_TREE_MAX_DEPTH (default 3).
+func (s *postService) treeDepth(requested int) int {
+	maxDepth := GetIntFromEnv("POST_TREE_MAX_DEPTH", 3)
+	if requested < 1 || requested > maxDepth {
+		return maxDepth
+	}
+	return requested
+}
+
+// buildTree maps top posts to responses and, when nested, loads depth-1
+// further levels of replies below them. Each branch holds at most
+// POST_REPLIES_PER_BRANCH replies per level; the rest is reachable through the
+// "more_replies" cursor. It issues two queries per level plus one pipelined
+// like lookup for every loaded post, and returns all loaded posts.
+func (s *postService) buildTree(ctx context.Context, userID uuid.UUID, top []*model.Post, depth int, nested bool) ([]dto.PostResponse, []*model.Post, error) {
+	perBranch := GetIntFromEnv("POST_REPLIES_PER_BRANCH", 5)
+
+	loaded := append([]*model.Post{}, top...)
+	replyCounts := make(map[uuid.UUID]int64)
+	children := make(map[uuid.UUID][]*model.Post)
+
+	level := top
+	for d := 1; len(level) > 0; d++ {
+		ids := make([]uuid.UUID, len(level))
+		for i, p := range level {
+			ids[i] = p.ID
+		}
+
+		counts, err := s.postRepo.CountChildren(ctx, ids)
+		if err != nil {
+			return nil, nil, err
+		}
+		for id, c := range counts {
+			replyCounts[id] = c
+		}
+
+		if !nested || d >= depth {
+			break
+		}
+
+		next, err := s.postRepo.FindChildren(ctx, ids, perBranch)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range next {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+		loaded = append(loaded, next...)
+		level = next
+	}
+
+	ids := make([]uuid.UUID, len(loaded))
+	for i, p := range loaded {
+		ids[i] = p.ID
+	}
+	likes, err := s.likeService.GetPostLikeStats(ctx, userID, ids)
+	if err != nil {
+		// Counts are cosmetic; keep serving the page
+		fmt.Printf("Failed to load post likes: %v\n", err)
+	}
+
+	var build func(p *model.Post) *dto.PostResponse
+	build = func(p *model.Post) *dto.PostResponse {
+		resp := s.toResponse(p, likes[p.ID])
+		resp.RepliesCount = replyCounts[p.ID]
+
+		kids := children[p.ID]
+		for _, c := range kids {
+			resp.Replies = append(resp.Replies, build(c))
+		}
+
+		if nested && resp.RepliesCount > int64(len(kids)) {
+			more := &dto.MoreRepliesCursor{Remaining: resp.RepliesCount - int64(len(kids))}
+			if len(kids) > 0 {
+				more.Cursor = encodePostCursor(kids[len(kids)-1])
+			}
+			resp.MoreReplies = more
+		}
+		return resp
+	}
+
+	data := make([]dto.PostResponse, 0, len(top))
+	for _, p := range top {
+		data = append(data, *build(p))
+	}
+	return data, loaded, nil
+}
+
+func encodePostCursor(p *model.Post) string {
+	raw := fmt.Sprintf("%d_%s", p.CreatedAt.UnixNano(), p.ID.String())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePostCursor(cursor string) (*repository.PostCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "_", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &repository.PostCursor{CreatedAt: time.Unix(0, nanos), ID: id}, nil
+}
+
+// firstUnread returns the oldest post by someone else after the user's read
+// position, with the page that contains it: the page of its root post in tree
+// mode, or its own page in flat mode.
+func (s *postService) firstUnread(ctx context.Context, userID uuid.UUID, thread *model.Thread, flat bool, limit int) *dto.FirstUnreadCursor {
+	lastRead, _, err := s.readService.LastReadAt(ctx, userID, thread)
+	if err != nil {
+		fmt.Printf("Failed to get read position: %v\n", err)
+		return nil
+	}
+	if lastRead.IsZero() {
+		return nil
+	}
+
+	post, err := s.postRepo.FindFirstAfter(ctx, thread.ID, userID, lastRead, nil)
+	if err != nil {
+		return nil
+	}
+
+	var before int64
+	if flat {
+		before, err = s.postRepo.CountBefore(ctx, post)
+	} else {
+		var root *model.Post
+		if root, err = s.postRepo.FindRoot(ctx, post.ID); err == nil {
+			before, err = s.postRepo.CountRootsBefore(ctx, root)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Failed to locate first unread post: %v\n", err)
+		return nil
+	}
+
+	return &dto.FirstUnreadCursor{PostID: post.ID, Page: int(before)/limit + 1}
+}
+
+// markPageRead moves the read position over the posts shown on the page, but
+// not past the oldest unread post the page left out: in tree mode a late
+// reply to an earlier root is newer than roots on the next pages, and
+// replies deeper than the page shows are not loaded at all.
+func (s *postService) markPageRead(ctx context.Context, userID uuid.UUID, thread *model.Thread, loaded []*model.Post) {
+	var newest time.Time
+	shown := make([]uuid.UUID, 0, len(loaded))
+	for _, p := range loaded {
+		shown = append(shown, p.ID)
+		if p.CreatedAt.After(newest) {
+			newest = p.CreatedAt
+		}
+	}
+
+	if !newest.IsZero() {
+		lastRead, _, err := s.readService.LastReadAt(ctx, userID, thread)
+		if err != nil {
+			fmt.Printf("Failed to get read position: %v\n", err)
+			return
+		}
+		unseen, err := s.postRepo.FindFirstAfter(ctx, thread.ID, userID, lastRead, shown)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			fmt.Printf("Failed to locate unseen posts: %v\n", err)
+			return
+		}
+		if err == nil && !unseen.CreatedAt.After(newest) {
+			// Read positions have millisecond precision
+			newest = unseen.CreatedAt.Truncate(time.Millisecond).Add(-time.Millisecond)
+			if !newest.After(lastRead) {
+				newest = time.Time{}
+			}
+		}
+	}
+
+	var err error
+	if newest.IsZero() {
+		err = s.readService.MarkThreadSeen(ctx, userID, thread.ID)
+	} else {
+		err = s.readService.MarkThreadRead(ctx, userID, thread.ID, newest)
+	}
+	if err != nil {
+		fmt.Printf("Failed to update read position: %v\n", err)
+	}
+}
+
+func (s *postService) GetPostByID(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (*dto.PostResponse, error) {
+	post, err := s.visibility.Post(ctx, userID, postID)
 	if err != nil {
 		return nil, err
 	}
-	return s.mapToResponse(post), nil
+	return s.mapToResponse(ctx, userID, post), nil
 }
 
 func (s *postService) UpdatePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID, req dto.UpdatePostRequest) (*dto.PostResponse, error) {
-	post, err := s.postRepo.FindByID(ctx, postID)
+	post, err := s.visibility.Post(ctx, userID, postID)
 	if err != nil {
 		return nil, err
 	}
@@ -302,7 +511,10 @@ func (s *postService) UpdatePost(ctx context.Context, userID uuid.UUID, postID u
 		desiredAttachments[id] = true
 	}
 
-	// Delete removed attachments
+	// Attach the new list with the edit, then delete the removed attachments
+	if err := s.postRepo.Update(ctx, post, req.AttachmentIDs); err != nil {
+		return nil, err
+	}
 	for id, att := range currentAttachments {
 		if !desiredAttachments[id] {
 			_ = s.fileStorage.DeleteImage(ctx, att.FileURL)
@@ -310,40 +522,17 @@ func (s *postService) UpdatePost(ctx context.Context, userID uuid.UUID, postID u
 		}
 	}
 
-	// Add new attachments
-	if len(req.AttachmentIDs) > 0 {
-		if err := s.attachmentRepo.UpdatePostID(ctx, req.AttachmentIDs, post.ID, userID); err != nil {
-			return nil, err
-		}
-	}
-
-	if err := s.postRepo.Update(ctx, post); err != nil {
-		return nil, err
-	}
-
 	// Reload to get updated attachments for response
 	updatedPost, err := s.postRepo.FindByID(ctx, post.ID)
 	if err == nil {
 		post = updatedPost
 	}
 
-	// Index to Meilisearch
-	if s.meili != nil {
-		// Ensure Thread is loaded for IndexPost (Audience check)
-		if post.Thread.ID == uuid.Nil {
-			t, err := s.threadRepo.FindByID(ctx, post.ThreadID)
-			if err == nil {
-				post.Thread = *t
-			}
-		}
-		_ = s.meili.IndexPost(post)
-	}
-
-	return s.mapToResponse(post), nil
+	return s.mapToResponse(ctx, userID, post), nil
 }
 
 func (s *postService) DeletePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) error {
-	post, err := s.postRepo.FindByID(ctx, postID)
+	post, err := s.visibility.Post(ctx, userID, postID)
 	if err != nil {
 		return err
 	}
@@ -357,33 +546,66 @@ func (s *postService) DeletePost(ctx context.Context, userID uuid.UUID, postID u
 		return fmt.Errorf("unauthorized: you can only delete your own post unless you are an admin")
 	}
 
-	// Delete Attachments
-	for _, att := range post.Attachments {
-		_ = s.fileStorage.DeleteImage(ctx, att.FileURL)
-		_ = s.attachmentRepo.Delete(ctx, att.ID)
+	// Attachments stay with the deleted post for a restore
+	return s.postRepo.Delete(ctx, postID)
+}
+
+// RestorePost undoes DeletePost for the author or an admin, bringing back the
+// replies deleted with the post.
+func (s *postService) RestorePost(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (*dto.PostResponse, error) {
+	post, err := s.postRepo.FindDeletedByID(ctx, postID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPostNotFound
+		}
+		return nil, err
+	}
+	if _, err := s.visibility.Thread(ctx, userID, post.ThreadID); err != nil {
+		if errors.Is(err, ErrThreadNotFound) {
+			return nil, ErrPostNotFound
+		}
+		return nil, err
 	}
 
-	// Since we set CASCADE on ParentID in database (scheme.sql), child posts *should* be deleted automatically?
-	// Scheme: parent_id UUID REFERENCES posts(id) ON DELETE CASCADE
-	// Yes, deletions cascade.
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
 
-	if err := s.postRepo.Delete(ctx, postID); err != nil {
-		return err
+	if post.UserID != userID && user.Role.Name != "admin" {
+		return nil, fmt.Errorf("unauthorized: you can only restore your own post unless you are an admin")
 	}
 
-	if s.meili != nil {
-		_ = s.meili.DeletePost(postID.String())
+	if err := s.postRepo.Restore(ctx, postID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPostNotFound
+		}
+		return nil, err
+	}
+
+	restored, err := s.postRepo.FindByID(ctx, postID)
+	if err != nil {
+		return nil, err
 	}
-	
-	return nil
+	return s.mapToResponse(ctx, userID, restored), nil
 }
 
-func (s *postService) mapToResponse(post *model.Post) *dto.PostResponse {
+// mapToResponse maps a single post with the same enrichment as a page of posts.
+func (s *postService) mapToResponse(ctx context.Context, userID uuid.UUID, post *model.Post) *dto.PostResponse {
+	data, _, err := s.buildTree(ctx, userID, []*model.Post{post}, 1, false)
+	if err != nil {
+		fmt.Printf("Failed to enrich post: %v\n", err)
+		return s.toResponse(post, LikeStats{})
+	}
+	return &data[0]
+}
+
+func (s *postService) toResponse(post *model.Post, likes LikeStats) *dto.PostResponse {
 	var attachments []dto.AttachmentResponse
 	for _, att := range post.Attachments {
 		attachments = append(attachments, dto.AttachmentResponse{
 			ID:       att.ID,
-			FileURL:  att.FileURL,
+			FileURL:  storage.SignedURL(s.fileStorage, att.FileURL),
 			FileType: att.FileType,
 		})
 	}
@@ -396,8 +618,6 @@ func (s *postService) mapToResponse(post *model.Post) *dto.PostResponse {
 		authorResponse.AvatarURL = post.User.AvatarURL
 	}
 
-	likesCount, _ := s.likeService.GetPostLikes(context.Background(), post.ID)
-
 	return &dto.PostResponse{
 		ID:          post.ID,
 		ThreadID:    post.ThreadID,
@@ -405,7 +625,8 @@ func (s *postService) mapToResponse(post *model.Post) *dto.PostResponse {
 		Content:     post.Content,
 		Author:      authorResponse,
 		Attachments: attachments,
-		LikesCount:  likesCount,
+		LikesCount:  likes.Count,
+		LikedByMe:   likes.LikedByMe,
 		CreatedAt:   post.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt:   post.UpdatedAt.Format("2006-01-02 15:04:05"),
 	}
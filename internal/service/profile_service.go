@@ -1,6 +1,7 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -22,12 +23,14 @@ type ProfileService interface {
 type profileService struct {
 	repo         repository.UserRepository
 	imageStorage storage.ImageStorage
+	uploads      UploadValidator
 }
 
-func NewProfileService(repo repository.UserRepository, imageStorage storage.ImageStorage) ProfileService {
+func NewProfileService(repo repository.UserRepository, imageStorage storage.ImageStorage, uploads UploadValidator) ProfileService {
 	return &profileService{
 		repo:         repo,
 		imageStorage: imageStorage,
+		uploads:      uploads,
 	}
 }
 
@@ -64,7 +67,11 @@ func (s *profileService) UpdateProfile(ctx context.Context, userID string, input
 	}
 
 	if avatar != nil && avatar.Reader != nil && s.imageStorage != nil {
-		url, err := s.imageStorage.UploadImage(ctx, avatar.Reader, "avatars", avatar.FileName)
+		upload, err := s.uploads.Validate(UploadAvatar, user.Role.Name, avatar.Reader, avatar.FileName)
+		if err != nil {
+			return nil, err
+		}
+		url, err := s.imageStorage.UploadImage(ctx, bytes.NewReader(upload.Data), "avatars", upload.FileName)
 		if err != nil {
 			return nil, err
 		}
@@ -77,6 +84,12 @@ func (s *profileService) UpdateProfile(ctx context.Context, userID string, input
 		if input.Bio != nil {
 			profile.Bio = normalizeOptional(input.Bio)
 		}
+		if input.ShowFullName != nil {
+			profile.ShowFullName = *input.ShowFullName
+		}
+		if input.HideFromSearch != nil {
+			profile.HideFromSearch = *input.HideFromSearch
+		}
 	}
 
 	if err := s.repo.Update(ctx, user, profile); err != nil {
@@ -112,6 +125,9 @@ func (s *profileService) GetProfileByUsername(ctx context.Context, username stri
 	if user.Profile != nil {
 		response.ClassGrade = user.Profile.ClassGrade
 		response.Bio = user.Profile.Bio
+		if user.Profile.ShowFullName {
+			response.FullName = &user.Profile.FullName
+		}
 	}
 
 	return response, nil
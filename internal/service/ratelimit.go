@@ -82,3 +82,29 @@ func GetDurationFromEnv(key string, defaultDuration time.Duration) time.Duration
 
 	return defaultDuration
 }
+
+func GetIntFromEnv(key string, defaultValue int) int {
+	valStr := os.Getenv(key)
+	if valStr == "" {
+		return defaultValue
+	}
+
+	valInt, err := strconv.Atoi(valStr)
+	if err != nil || valInt < 1 {
+		return defaultValue
+	}
+	return valInt
+}
+
+func GetFloatFromEnv(key string, defaultValue float64) float64 {
+	valStr := os.Getenv(key)
+	if valStr == "" {
+		return defaultValue
+	}
+
+	val, err := strconv.ParseFloat(valStr, 64)
+	if err != nil || val < 0 {
+		return defaultValue
+	}
+	return val
+}
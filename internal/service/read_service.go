@@ -0,0 +1,407 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+type ReadService interface {
+	// LastReadAt returns the user's effective read position in the thread and
+	// whether the user has opened the thread at all.
+	LastReadAt(ctx context.Context, userID uuid.UUID, thread *model.Thread) (time.Time, bool, error)
+	MarkThreadRead(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, at time.Time) error
+	MarkThreadSeen(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error
+	MarkCategoryRead(ctx context.Context, userID uuid.UUID, categoryID uuid.UUID) error
+	AnnotateThreads(ctx context.Context, userID uuid.UUID, threads []*model.Thread, responses []dto.ThreadResponse)
+	Flush(ctx context.Context) error
+}
+
+type readService struct {
+	redisClient  *redis.Client
+	readRepo     repository.ReadRepository
+	categoryRepo repository.CategoryRepository
+	visibility   VisibilityPolicy
+}
+
+func NewReadService(redisClient *redis.Client, readRepo repository.ReadRepository, categoryRepo repository.CategoryRepository, visibility VisibilityPolicy, jobManager *jobs.Manager) ReadService {
+	s := &readService{
+		redisClient:  redisClient,
+		readRepo:     readRepo,
+		categoryRepo: categoryRepo,
+		visibility:   visibility,
+	}
+
+	if redisClient != nil {
+		jobManager.Schedule("reads.sync", "@every 1m", 5*time.Minute, s.Flush)
+	}
+	return s
+}
+
+// Redis layout, per user:
+//
+//	read:threads:<user>     hash thread_id   -> last read (unix ms)
+//	read:categories:<user>  hash category_id -> last read (unix ms), "all" for every category
+//	pending:reads           set of "t:<user>:<thread>" / "c:<user>:<category>" to persist
+//
+// The categories hash always holds "all" once loaded, so its existence tells
+// whether the user's positions are cached.
+const (
+	readAllField      = "all"
+	pendingReadsKey   = "pending:reads"
+	readCacheTTL      = 30 * 24 * time.Hour
+	readSyncBatchSize = 500
+)
+
+// setMaxScript only moves a position forward.
+var setMaxScript = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], ARGV[1])
+if (not cur) or tonumber(cur) < tonumber(ARGV[2]) then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+func threadReadsKey(userID uuid.UUID) string {
+	return fmt.Sprintf("read:threads:%s", userID.String())
+}
+
+func categoryReadsKey(userID uuid.UUID) string {
+	return fmt.Sprintf("read:categories:%s", userID.String())
+}
+
+// ensureLoaded fills the cache from Postgres. A user without any stored
+// position starts tracking now, so existing threads don't all show up as new.
+func (s *readService) ensureLoaded(ctx context.Context, userID uuid.UUID) error {
+	exists, err := s.redisClient.Exists(ctx, categoryReadsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 1 {
+		return nil
+	}
+
+	categoryReads, err := s.readRepo.FindCategoryReads(ctx, userID)
+	if err != nil {
+		return err
+	}
+	threadReads, err := s.readRepo.FindThreadReads(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	categoryFields := map[string]interface{}{}
+	for _, r := range categoryReads {
+		field := r.CategoryID.String()
+		if r.CategoryID == uuid.Nil {
+			field = readAllField
+		}
+		categoryFields[field] = r.LastReadAt.UnixMilli()
+	}
+
+	pipe := s.redisClient.TxPipeline()
+	if _, ok := categoryFields[readAllField]; !ok {
+		// HSETNX so a concurrent load doesn't move the starting point
+		pipe.HSetNX(ctx, categoryReadsKey(userID), readAllField, time.Now().UnixMilli())
+		pipe.SAdd(ctx, pendingReadsKey, fmt.Sprintf("c:%s:%s", userID, readAllField))
+	}
+	if len(categoryFields) > 0 {
+		pipe.HSet(ctx, categoryReadsKey(userID), categoryFields)
+	}
+	if len(threadReads) > 0 {
+		threadFields := make(map[string]interface{}, len(threadReads))
+		for _, r := range threadReads {
+			threadFields[r.ThreadID.String()] = r.LastReadAt.UnixMilli()
+		}
+		pipe.HSet(ctx, threadReadsKey(userID), threadFields)
+	}
+	pipe.Expire(ctx, categoryReadsKey(userID), readCacheTTL)
+	pipe.Expire(ctx, threadReadsKey(userID), readCacheTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *readService) LastReadAt(ctx context.Context, userID uuid.UUID, thread *model.Thread) (time.Time, bool, error) {
+	if s.redisClient == nil {
+		return time.Time{}, false, nil
+	}
+	if err := s.ensureLoaded(ctx, userID); err != nil {
+		return time.Time{}, false, err
+	}
+
+	positions, err := s.positions(ctx, userID, []*model.Thread{thread})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	p := positions[thread.ID]
+	return p.lastReadAt, p.opened, nil
+}
+
+type readPosition struct {
+	lastReadAt time.Time
+	opened     bool
+}
+
+// positions resolves the effective read position of each thread with two
+// HMGETs: the latest of the thread, its category and the "all" watermark.
+func (s *readService) positions(ctx context.Context, userID uuid.UUID, threads []*model.Thread) (map[uuid.UUID]readPosition, error) {
+	threadFields := make([]string, len(threads))
+	categoryFields := []string{readAllField}
+	for i, t := range threads {
+		threadFields[i] = t.ID.String()
+		if t.CategoryID != nil {
+			categoryFields = append(categoryFields, t.CategoryID.String())
+		}
+	}
+
+	pipe := s.redisClient.Pipeline()
+	threadCmd := pipe.HMGet(ctx, threadReadsKey(userID), threadFields...)
+	categoryCmd := pipe.HMGet(ctx, categoryReadsKey(userID), categoryFields...)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	categoryReads := make(map[string]int64, len(categoryFields))
+	for i, v := range categoryCmd.Val() {
+		if ms, ok := parseMillis(v); ok {
+			categoryReads[categoryFields[i]] = ms
+		}
+	}
+
+	positions := make(map[uuid.UUID]readPosition, len(threads))
+	for i, t := range threads {
+		latest := categoryReads[readAllField]
+		if t.CategoryID != nil && categoryReads[t.CategoryID.String()] > latest {
+			latest = categoryReads[t.CategoryID.String()]
+		}
+
+		threadMs, opened := parseMillis(threadCmd.Val()[i])
+		if opened && threadMs > latest {
+			latest = threadMs
+		}
+
+		positions[t.ID] = readPosition{
+			lastReadAt: time.UnixMilli(latest),
+			opened:     opened,
+		}
+	}
+	return positions, nil
+}
+
+func parseMillis(v interface{}) (int64, bool) {
+	str, ok := v.(string)
+	if !ok {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return ms, true
+}
+
+func (s *readService) MarkThreadRead(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, at time.Time) error {
+	if _, err := s.visibility.Thread(ctx, userID, threadID); err != nil {
+		return err
+	}
+	if s.redisClient == nil {
+		return nil
+	}
+	return s.mark(ctx, userID, threadReadsKey(userID), threadID.String(), "t", at.UnixMilli())
+}
+
+// MarkThreadSeen records that the user opened the thread without moving the
+// read position, which clears the "new" marker but keeps unread replies.
+func (s *readService) MarkThreadSeen(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error {
+	if s.redisClient == nil {
+		return nil
+	}
+	return s.mark(ctx, userID, threadReadsKey(userID), threadID.String(), "t", 0)
+}
+
+// MarkCategoryRead marks every thread of the category as read up to now.
+// Categories are visible to everyone, so only a missing one is refused.
+func (s *readService) MarkCategoryRead(ctx context.Context, userID uuid.UUID, categoryID uuid.UUID) error {
+	if _, err := s.categoryRepo.FindByID(ctx, categoryID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
+		}
+		return err
+	}
+	if s.redisClient == nil {
+		return nil
+	}
+	return s.mark(ctx, userID, categoryReadsKey(userID), categoryID.String(), "c", time.Now().UnixMilli())
+}
+
+func (s *readService) mark(ctx context.Context, userID uuid.UUID, key string, field string, kind string, ms int64) error {
+	if err := s.ensureLoaded(ctx, userID); err != nil {
+		return err
+	}
+
+	changed, err := setMaxScript.Run(ctx, s.redisClient, []string{key}, field, ms).Int()
+	if err != nil {
+		return err
+	}
+
+	pipe := s.redisClient.Pipeline()
+	if changed == 1 {
+		pipe.SAdd(ctx, pendingReadsKey, fmt.Sprintf("%s:%s:%s", kind, userID, field))
+	}
+	pipe.Expire(ctx, categoryReadsKey(userID), readCacheTTL)
+	pipe.Expire(ctx, threadReadsKey(userID), readCacheTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// AnnotateThreads fills unread_replies and is_new on a page of thread
+// responses. Failures are logged and leave the defaults in place.
+func (s *readService) AnnotateThreads(ctx context.Context, userID uuid.UUID, threads []*model.Thread, responses []dto.ThreadResponse) {
+	if s.redisClient == nil || len(threads) == 0 {
+		return
+	}
+
+	if err := s.ensureLoaded(ctx, userID); err != nil {
+		log.Printf("Failed to load read positions for %s: %v", userID, err)
+		return
+	}
+
+	positions, err := s.positions(ctx, userID, threads)
+	if err != nil {
+		log.Printf("Failed to load read positions for %s: %v", userID, err)
+		return
+	}
+
+	since := make(map[uuid.UUID]time.Time, len(threads))
+	for _, t := range threads {
+		since[t.ID] = positions[t.ID].lastReadAt
+	}
+	counts, err := s.readRepo.CountUnread(ctx, userID, since)
+	if err != nil {
+		log.Printf("Failed to count unread replies for %s: %v", userID, err)
+		return
+	}
+
+	for i, t := range threads {
+		p := positions[t.ID]
+		responses[i].UnreadReplies = counts[t.ID]
+		responses[i].IsNew = !p.opened && t.UserID != userID && t.CreatedAt.After(p.lastReadAt)
+	}
+}
+
+// Flush persists every pending read position in batches. SPOP hands each
+// pending entry to exactly one sync; a later change re-adds it.
+func (s *readService) Flush(ctx context.Context) error {
+	if s.redisClient == nil {
+		return nil
+	}
+
+	synced := 0
+	for {
+		members, err := s.redisClient.SPopN(ctx, pendingReadsKey, readSyncBatchSize).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get pending reads: %w", err)
+		}
+		if len(members) == 0 {
+			break
+		}
+
+		type entry struct {
+			kind   string
+			userID uuid.UUID
+			field  string
+			cmd    *redis.StringCmd
+		}
+
+		pipe := s.redisClient.Pipeline()
+		entries := make([]entry, 0, len(members))
+		for _, m := range members {
+			parts := strings.SplitN(m, ":", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			userID, err := uuid.Parse(parts[1])
+			if err != nil {
+				continue
+			}
+			e := entry{kind: parts[0], userID: userID, field: parts[2]}
+			if e.kind == "t" {
+				e.cmd = pipe.HGet(ctx, threadReadsKey(userID), e.field)
+			} else {
+				e.cmd = pipe.HGet(ctx, categoryReadsKey(userID), e.field)
+			}
+			entries = append(entries, e)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			s.redisClient.SAdd(ctx, pendingReadsKey, toInterfaces(members)...)
+			return fmt.Errorf("failed to read pending reads: %w", err)
+		}
+
+		var threadReads []model.ThreadRead
+		var categoryReads []model.CategoryRead
+		for _, e := range entries {
+			ms, err := e.cmd.Int64()
+			if err != nil {
+				continue
+			}
+			at := time.UnixMilli(ms)
+
+			if e.kind == "t" {
+				threadID, err := uuid.Parse(e.field)
+				if err != nil {
+					continue
+				}
+				threadReads = append(threadReads, model.ThreadRead{UserID: e.userID, ThreadID: threadID, LastReadAt: at})
+				continue
+			}
+
+			categoryID := uuid.Nil
+			if e.field != readAllField {
+				if categoryID, err = uuid.Parse(e.field); err != nil {
+					continue
+				}
+			}
+			categoryReads = append(categoryReads, model.CategoryRead{UserID: e.userID, CategoryID: categoryID, LastReadAt: at})
+		}
+
+		if err := s.readRepo.UpsertThreadReads(ctx, threadReads); err != nil {
+			// Usually a thread deleted in the meantime; retry one by one so
+			// the rest of the batch still lands.
+			for _, r := range threadReads {
+				if err := s.readRepo.UpsertThreadReads(ctx, []model.ThreadRead{r}); err != nil {
+					log.Printf("Failed to persist read of thread %s for %s: %v", r.ThreadID, r.UserID, err)
+				}
+			}
+		}
+		if err := s.readRepo.UpsertCategoryReads(ctx, categoryReads); err != nil {
+			log.Printf("Failed to persist category reads: %v", err)
+		}
+		synced += len(entries)
+	}
+
+	if synced > 0 {
+		log.Printf("Synced %d read positions", synced)
+	}
+	return nil
+}
+
+func toInterfaces(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// threadPosts is one thread's posts in memory, serving the lookups of read
+// positions for both the post and the read repository fakes.
+type threadPosts struct {
+	repository.PostRepository
+	posts []*model.Post
+}
+
+// before reports whether a sorts before b chronologically.
+func before(a, b *model.Post) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return slices.Compare(a.ID[:], b.ID[:]) < 0
+}
+
+func (r *threadPosts) FindFirstAfter(ctx context.Context, threadID uuid.UUID, excludeUserID uuid.UUID, after time.Time, exceptIDs []uuid.UUID) (*model.Post, error) {
+	var first *model.Post
+	for _, p := range r.posts {
+		if p.ThreadID != threadID || p.UserID == excludeUserID || !p.CreatedAt.After(after) || slices.Contains(exceptIDs, p.ID) {
+			continue
+		}
+		if first == nil || before(p, first) {
+			first = p
+		}
+	}
+	if first == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return first, nil
+}
+
+func (r *threadPosts) FindRoot(ctx context.Context, postID uuid.UUID) (*model.Post, error) {
+	for id := postID; ; {
+		i := slices.IndexFunc(r.posts, func(p *model.Post) bool { return p.ID == id })
+		if i < 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		if r.posts[i].ParentID == nil {
+			return r.posts[i], nil
+		}
+		id = *r.posts[i].ParentID
+	}
+}
+
+func (r *threadPosts) CountRootsBefore(ctx context.Context, root *model.Post) (int64, error) {
+	var n int64
+	for _, p := range r.posts {
+		if p.ParentID == nil && before(p, root) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *threadPosts) CountBefore(ctx context.Context, post *model.Post) (int64, error) {
+	var n int64
+	for _, p := range r.posts {
+		if before(p, post) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// memoryReadRepo has no stored positions besides a starting point for every
+// category, and counts unread posts in threadPosts.
+type memoryReadRepo struct {
+	repository.ReadRepository
+	since time.Time
+	posts *threadPosts
+}
+
+func (r *memoryReadRepo) FindCategoryReads(ctx context.Context, userID uuid.UUID) ([]*model.CategoryRead, error) {
+	return []*model.CategoryRead{{UserID: userID, CategoryID: uuid.Nil, LastReadAt: r.since}}, nil
+}
+
+func (r *memoryReadRepo) FindThreadReads(ctx context.Context, userID uuid.UUID) ([]*model.ThreadRead, error) {
+	return nil, nil
+}
+
+func (r *memoryReadRepo) CountUnread(ctx context.Context, userID uuid.UUID, since map[uuid.UUID]time.Time) (map[uuid.UUID]int64, error) {
+	counts := map[uuid.UUID]int64{}
+	for _, p := range r.posts.posts {
+		if t, ok := since[p.ThreadID]; ok && p.UserID != userID && p.CreatedAt.After(t) {
+			counts[p.ThreadID]++
+		}
+	}
+	return counts, nil
+}
+
+type fakeCategoryRepo struct {
+	repository.CategoryRepository
+	categories map[uuid.UUID]*model.Category
+}
+
+func (r *fakeCategoryRepo) FindByID(ctx context.Context, id uuid.UUID) (*model.Category, error) {
+	if category, ok := r.categories[id]; ok {
+		return category, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type readFixture struct {
+	reads    *readService
+	posts    *postService
+	thread   *model.Thread
+	category *model.Category
+	repo     *threadPosts
+	reader   uuid.UUID
+	start    time.Time
+}
+
+// newReadFixture sets up a reader who has read everything before start, and
+// a thread in a category written by someone else.
+func newReadFixture(t *testing.T) *readFixture {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	reader := &model.User{ID: uuid.New(), Role: model.Role{Name: "siswa"}}
+	category := &model.Category{ID: uuid.New(), Name: "Umum"}
+	thread := &model.Thread{ID: uuid.New(), UserID: uuid.New(), CategoryID: &category.ID, Audience: "semua", CreatedAt: start}
+
+	repo := &threadPosts{}
+	visibility := NewVisibilityPolicy(
+		&fakeUserRepo{users: map[string]*model.User{reader.ID.String(): reader}},
+		&fakeThreadRepo{threads: map[uuid.UUID]*model.Thread{thread.ID: thread}},
+		repo,
+	)
+	reads := &readService{
+		redisClient:  client,
+		readRepo:     &memoryReadRepo{since: start, posts: repo},
+		categoryRepo: &fakeCategoryRepo{categories: map[uuid.UUID]*model.Category{category.ID: category}},
+		visibility:   visibility,
+	}
+	posts := &postService{postRepo: repo, readService: reads, visibility: visibility}
+	return &readFixture{reads: reads, posts: posts, thread: thread, category: category, repo: repo, reader: reader.ID, start: start}
+}
+
+// write adds a post by someone else the given minutes after start.
+func (f *readFixture) write(minutes int, parent *model.Post) *model.Post {
+	post := &model.Post{ID: uuid.New(), ThreadID: f.thread.ID, UserID: f.thread.UserID, CreatedAt: f.start.Add(time.Duration(minutes) * time.Minute)}
+	if parent != nil {
+		post.ParentID = &parent.ID
+	}
+	f.repo.posts = append(f.repo.posts, post)
+	return post
+}
+
+func (f *readFixture) unread(t *testing.T) int64 {
+	t.Helper()
+	responses := make([]dto.ThreadResponse, 1)
+	f.reads.AnnotateThreads(context.Background(), f.reader, []*model.Thread{f.thread}, responses)
+	return responses[0].UnreadReplies
+}
+
+func TestFirstUnread(t *testing.T) {
+	ctx := context.Background()
+	f := newReadFixture(t)
+	// Four roots, two per page, and a late reply to the first one
+	r0, r1 := f.write(1, nil), f.write(2, nil)
+	r2, r3 := f.write(3, nil), f.write(4, nil)
+	late := f.write(5, r0)
+
+	if got := f.posts.firstUnread(ctx, f.reader, f.thread, false, 2); got == nil || got.PostID != r0.ID || got.Page != 1 {
+		t.Errorf("before reading: first unread %+v, want %s on page 1", got, r0.ID)
+	}
+	if n := f.unread(t); n != 5 {
+		t.Errorf("before reading: %d unread, want 5", n)
+	}
+
+	// Reading page 1 with the late reply shown stops before r2, the oldest
+	// post the page left out, so r2 and r3 are not lost behind the reply
+	f.posts.markPageRead(ctx, f.reader, f.thread, []*model.Post{r0, r1, late})
+	for _, tt := range []struct {
+		flat bool
+		want *model.Post
+		page int
+	}{
+		{false, r2, 2},
+		{true, r2, 2},
+	} {
+		got := f.posts.firstUnread(ctx, f.reader, f.thread, tt.flat, 2)
+		if got == nil || got.PostID != tt.want.ID || got.Page != tt.page {
+			t.Errorf("after page 1 (flat = %v): first unread %+v, want %s on page %d", tt.flat, got, tt.want.ID, tt.page)
+		}
+	}
+	// The late reply is newer than the position, so it still counts
+	if n := f.unread(t); n != 3 {
+		t.Errorf("after page 1: %d unread, want 3", n)
+	}
+
+	// Page 2 leaves nothing older out; in flat mode the late reply is on
+	// page 3 and its own first unread post
+	f.posts.markPageRead(ctx, f.reader, f.thread, []*model.Post{r2, r3})
+	if got := f.posts.firstUnread(ctx, f.reader, f.thread, true, 2); got == nil || got.PostID != late.ID || got.Page != 3 {
+		t.Errorf("after page 2: first unread %+v, want %s on page 3", got, late.ID)
+	}
+	// In tree mode the late reply jumps back to the page of its root
+	if got := f.posts.firstUnread(ctx, f.reader, f.thread, false, 2); got == nil || got.PostID != late.ID || got.Page != 1 {
+		t.Errorf("after page 2: first unread %+v in tree mode, want %s on page 1", got, late.ID)
+	}
+	if n := f.unread(t); n != 1 {
+		t.Errorf("after page 2: %d unread, want 1", n)
+	}
+
+	// Showing it reads the thread to the end
+	f.posts.markPageRead(ctx, f.reader, f.thread, []*model.Post{late})
+	if got := f.posts.firstUnread(ctx, f.reader, f.thread, true, 2); got != nil {
+		t.Errorf("all read: first unread %+v, want none", got)
+	}
+	if n := f.unread(t); n != 0 {
+		t.Errorf("all read: %d unread, want 0", n)
+	}
+}
+
+func TestMarkCategoryRead(t *testing.T) {
+	ctx := context.Background()
+	f := newReadFixture(t)
+	f.write(1, nil)
+	f.write(2, nil)
+
+	if err := f.reads.MarkCategoryRead(ctx, f.reader, uuid.New()); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("unknown category: error %v, want %v", err, ErrCategoryNotFound)
+	}
+	if n := f.unread(t); n != 2 {
+		t.Errorf("after an unknown category: %d unread, want 2", n)
+	}
+
+	if err := f.reads.MarkCategoryRead(ctx, f.reader, f.category.ID); err != nil {
+		t.Fatalf("MarkCategoryRead: %v", err)
+	}
+	if n := f.unread(t); n != 0 {
+		t.Errorf("after marking the category: %d unread, want 0", n)
+	}
+	responses := make([]dto.ThreadResponse, 1)
+	f.reads.AnnotateThreads(ctx, f.reader, []*model.Thread{f.thread}, responses)
+	if responses[0].IsNew {
+		t.Error("thread of a read category is still new")
+	}
+}
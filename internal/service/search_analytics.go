@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Searches are logged without the user, and queries are normalized so the
+// same search groups together: lowercased, whitespace collapsed, and email
+// addresses and long numbers (phone or identity numbers) masked.
+const (
+	SearchAnalyticsPruneJob = "search.analytics_prune"
+
+	maxLoggedQueryLength = 200
+)
+
+var ErrSearchNotFound = errors.New("search not found")
+
+var (
+	queryEmailPattern  = regexp.MustCompile(`[^\s@]+@[^\s@]+`)
+	queryNumberPattern = regexp.MustCompile(`\d{6,}`)
+)
+
+func normalizeSearchQuery(query string) string {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	query = queryEmailPattern.ReplaceAllString(query, "<email>")
+	query = queryNumberPattern.ReplaceAllString(query, "<number>")
+	if runes := []rune(query); len(runes) > maxLoggedQueryLength {
+		query = string(runes[:maxLoggedQueryLength])
+	}
+	return query
+}
+
+// searchLogFilters encodes the filters of a search, leaving out paging and
+// display options.
+func searchLogFilters(filter dto.SearchFilter) string {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"types":       filter.Types,
+		"category_id": filter.CategoryID,
+		"author":      filter.Author,
+		"from":        filter.From,
+		"to":          filter.To,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	if filter.Strict {
+		values.Set("strict", "true")
+	}
+	return values.Encode()
+}
+
+// logSearch records the first page of a search with a query and returns
+// its id, or "" when it was not logged. A failed insert never fails the
+// search.
+func (s *searchService) logSearch(ctx context.Context, filter dto.SearchFilter, total int64) string {
+	query := normalizeSearchQuery(filter.Query)
+	if query == "" || filter.Page != 1 {
+		return ""
+	}
+
+	entry := &model.SearchLog{
+		Query:       query,
+		Filters:     searchLogFilters(filter),
+		ResultCount: total,
+	}
+	if err := s.logRepo.Create(ctx, entry); err != nil {
+		log.Printf("Failed to log search: %v", err)
+		return ""
+	}
+	return entry.ID.String()
+}
+
+func (s *searchService) RecordClick(ctx context.Context, input dto.SearchClickInput) error {
+	searchID, _ := uuid.Parse(input.SearchID)
+	resultID, _ := uuid.Parse(input.ID)
+
+	exists, err := s.logRepo.Exists(ctx, searchID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrSearchNotFound
+	}
+
+	return s.logRepo.CreateClick(ctx, &model.SearchClick{
+		SearchID:   searchID,
+		ResultID:   resultID,
+		ResultType: input.Type,
+		Position:   input.Position,
+	})
+}
+
+// ratio is part/whole rounded to three decimals, 0 for an empty whole.
+func ratio(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*1000) / 1000
+}
+
+func queryReports(stats []repository.SearchQueryStats) []dto.SearchQueryReport {
+	reports := make([]dto.SearchQueryReport, len(stats))
+	for i, stat := range stats {
+		reports[i] = dto.SearchQueryReport{
+			Query:            stat.Query,
+			Searches:         stat.Searches,
+			AvgResults:       math.Round(stat.AvgResults*10) / 10,
+			ClickThroughRate: ratio(stat.Clicked, stat.Searches),
+			LastSearchedAt:   stat.LastSearchedAt,
+		}
+	}
+	return reports
+}
+
+// Analytics reports the searches of the last days: the most searched
+// queries with their click through rate, and the queries that found
+// nothing, which point at missing threads or categories.
+func (s *searchService) Analytics(ctx context.Context, filter dto.SearchAnalyticsFilter) (*dto.SearchAnalyticsReport, error) {
+	if filter.Days == 0 {
+		filter.Days = 30
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+	to := time.Now()
+	from := to.AddDate(0, 0, -filter.Days)
+
+	totals, err := s.logRepo.Totals(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	top, err := s.logRepo.QueryStats(ctx, from, to, false, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	zero, err := s.logRepo.QueryStats(ctx, from, to, true, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.SearchAnalyticsReport{
+		From:              from,
+		To:                to,
+		Searches:          totals.Searches,
+		ZeroResults:       totals.ZeroResults,
+		ZeroResultRate:    ratio(totals.ZeroResults, totals.Searches),
+		ClickThroughRate:  ratio(totals.Clicked, totals.Searches),
+		TopQueries:        queryReports(top),
+		ZeroResultQueries: queryReports(zero),
+	}, nil
+}
+
+// pruneLogs deletes searches older than SEARCH_ANALYTICS_RETENTION, with
+// their clicks.
+func (s *searchService) pruneLogs(ctx context.Context) error {
+	deleted, err := s.logRepo.DeleteBefore(ctx, time.Now().Add(-s.logRetention))
+	if err == nil && deleted > 0 {
+		log.Printf("Pruned %d search logs", deleted)
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Search documents copy fields of what they reference: the author's username
+// and avatar, the category name and, in posts, the thread's title, slug,
+// audience and category. After an entity is delivered, the documents whose
+// copy no longer matches it are looked up in the index and queued in the
+// outbox, so they are rebuilt from the database like any other change. When
+// the entity is gone every document referencing it is queued, and the rows
+// removed by cascading deletes drop out of the index on rebuild.
+
+// searchDependents maps each index to the outbox entity of its documents.
+var searchDependents = map[string]string{
+	"threads": model.SearchEntityThread,
+	"posts":   model.SearchEntityPost,
+}
+
+// cascade queues the documents that depend on the given entities.
+func (s *searchIndexer) cascade(ctx context.Context, entity string, ids []uuid.UUID) error {
+	for _, id := range ids {
+		filters, err := s.dependentFilters(ctx, entity, id)
+		if err != nil {
+			return err
+		}
+		for index, filter := range filters {
+			dependents, err := s.meili.FindDocumentIDs(ctx, index, filter)
+			if err != nil {
+				return fmt.Errorf("find %s depending on %s %s: %w", index, entity, id, err)
+			}
+			if err := s.outboxRepo.Enqueue(ctx, searchDependents[index], dependents); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dependentFilters returns, per index, the filter matching documents with
+// an outdated copy of the entity.
+func (s *searchIndexer) dependentFilters(ctx context.Context, entity string, id uuid.UUID) (map[string]string, error) {
+	ref := quoteFilterValue(id.String())
+
+	switch entity {
+	case model.SearchEntityThread:
+		// Post versions include their thread's, so older ones are stale
+		threads, err := s.threadRepo.FindForSearch(ctx, []uuid.UUID{id})
+		if err != nil {
+			return nil, err
+		}
+		if len(threads) == 0 {
+			return map[string]string{"posts": "thread_id = " + ref}, nil
+		}
+		return map[string]string{
+			"posts": fmt.Sprintf("thread_id = %s AND updated_at < %d", ref, threads[0].UpdatedAt.UnixMilli()),
+		}, nil
+
+	case model.SearchEntityUser:
+		filter := "user_id = " + ref
+		user, err := s.userRepo.FindByID(ctx, id.String())
+		if err == nil {
+			filter += fmt.Sprintf(" AND (user.username != %s OR user.avatar_url != %s)",
+				quoteFilterValue(user.Username), quoteFilterValue(getStringOrEmpty(user.AvatarURL)))
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return map[string]string{"threads": filter, "posts": filter}, nil
+
+	case model.SearchEntityCategory:
+		filter := "category_id = " + ref
+		category, err := s.categoryRepo.FindByID(ctx, id)
+		if err == nil {
+			filter += " AND category.name != " + quoteFilterValue(category.Name)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return map[string]string{"threads": filter, "posts": filter}, nil
+	}
+	return nil, nil
+}
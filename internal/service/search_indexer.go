@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+)
+
+// SearchIndexer delivers the search outbox to Meilisearch. A row only says
+// which entity changed; its documents are rebuilt from the database at
+// delivery time, so rows for the same entity merge, can be retried any
+// number of times and may be delivered in any order. Documents that copy
+// fields of a delivered entity are queued after it, see search_cascade.go.
+type SearchIndexer interface {
+	// Run delivers the outbox until ctx is cancelled.
+	Run(ctx context.Context)
+}
+
+type searchIndexer struct {
+	outboxRepo   repository.SearchOutboxRepository
+	threadRepo   repository.ThreadRepository
+	userRepo     repository.UserRepository
+	categoryRepo repository.CategoryRepository
+	meili        MeiliSearchService
+	batch        int
+	interval     time.Duration
+	lease        time.Duration
+	maxBackoff   time.Duration
+}
+
+func NewSearchIndexer(outboxRepo repository.SearchOutboxRepository, threadRepo repository.ThreadRepository, userRepo repository.UserRepository, categoryRepo repository.CategoryRepository, meili MeiliSearchService) SearchIndexer {
+	return &searchIndexer{
+		outboxRepo:   outboxRepo,
+		threadRepo:   threadRepo,
+		userRepo:     userRepo,
+		categoryRepo: categoryRepo,
+		meili:        meili,
+		batch:        GetIntFromEnv("SEARCH_OUTBOX_BATCH", 200),
+		interval:     GetDurationFromEnv("SEARCH_OUTBOX_INTERVAL", time.Second),
+		lease:        GetDurationFromEnv("SEARCH_OUTBOX_LEASE", time.Minute),
+		maxBackoff:   GetDurationFromEnv("SEARCH_OUTBOX_MAX_BACKOFF", 5*time.Minute),
+	}
+}
+
+func (s *searchIndexer) Run(ctx context.Context) {
+	log.Println("🔎 Search indexer started")
+	for {
+		n, err := s.deliver(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Search outbox: %v", err)
+		}
+
+		// A full batch means there is probably more waiting
+		if n < s.batch || err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.interval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// deliver claims one batch, syncs it per entity and settles the rows: the
+// delivered ones are deleted, the others released for a retry.
+func (s *searchIndexer) deliver(ctx context.Context) (int, error) {
+	rows, err := s.outboxRepo.Claim(ctx, s.batch, s.lease)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+
+	rowIDs := make(map[string][]uuid.UUID)
+	entityIDs := make(map[string][]uuid.UUID)
+	seen := make(map[string]map[uuid.UUID]bool)
+	for _, row := range rows {
+		rowIDs[row.Entity] = append(rowIDs[row.Entity], row.ID)
+		if seen[row.Entity] == nil {
+			seen[row.Entity] = make(map[uuid.UUID]bool)
+		}
+		if !seen[row.Entity][row.EntityID] {
+			seen[row.Entity][row.EntityID] = true
+			entityIDs[row.Entity] = append(entityIDs[row.Entity], row.EntityID)
+		}
+	}
+
+	// Settle on a fresh context so a shutdown mid-batch still releases the
+	// rows instead of leaving them to the lease
+	var firstErr error
+	var delivered []uuid.UUID
+	for entity, ids := range entityIDs {
+		err := s.sync(ctx, entity, ids)
+		if err == nil {
+			err = s.cascade(ctx, entity, ids)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if rerr := s.outboxRepo.Release(context.Background(), rowIDs[entity], err.Error(), s.maxBackoff); rerr != nil {
+				log.Printf("Search outbox: failed to release %s rows: %v", entity, rerr)
+			}
+			continue
+		}
+		delivered = append(delivered, rowIDs[entity]...)
+	}
+	if err := s.outboxRepo.Delete(context.Background(), delivered); err != nil {
+		return len(rows), err
+	}
+	return len(rows), firstErr
+}
+
+func (s *searchIndexer) sync(ctx context.Context, entity string, ids []uuid.UUID) error {
+	switch entity {
+	case model.SearchEntityThread:
+		return s.meili.SyncThreads(ctx, ids)
+	case model.SearchEntityPost:
+		return s.meili.SyncPosts(ctx, ids)
+	case model.SearchEntityUser:
+		return s.meili.SyncUsers(ctx, ids)
+	case model.SearchEntityCategory:
+		return s.meili.SyncCategories(ctx, ids)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidSearchType = errors.New("invalid search type")
+
+// searchTypes maps the public type names to their index.
+var searchTypes = map[string]string{
+	"threads":    "threads",
+	"posts":      "posts",
+	"users":      "users",
+	"categories": "categories",
+}
+
+// SearchService answers searches on behalf of a user through the backend's
+// master key, so audience filtering happens here instead of in a tenant
+// token, and issues the tenant tokens for searching from the frontend.
+type SearchService interface {
+	Search(ctx context.Context, userID uuid.UUID, filter dto.SearchFilter) (*dto.SearchResponse, error)
+	RecordClick(ctx context.Context, input dto.SearchClickInput) error
+	Analytics(ctx context.Context, filter dto.SearchAnalyticsFilter) (*dto.SearchAnalyticsReport, error)
+	Token(ctx context.Context, userID uuid.UUID) (*dto.SearchTokenResponse, error)
+	RevokeTokens(ctx context.Context) error
+	Reindex(ctx context.Context) error
+	CheckConsistency(ctx context.Context) (*dto.SearchSyncReport, error)
+}
+
+type searchService struct {
+	meili        MeiliSearchService
+	userRepo     repository.UserRepository
+	blockRepo    repository.BlockRepository
+	logRepo      repository.SearchLogRepository
+	logRetention time.Duration
+}
+
+func NewSearchService(meili MeiliSearchService, userRepo repository.UserRepository, blockRepo repository.BlockRepository, logRepo repository.SearchLogRepository, jobManager *jobs.Manager) SearchService {
+	s := &searchService{
+		meili:        meili,
+		userRepo:     userRepo,
+		blockRepo:    blockRepo,
+		logRepo:      logRepo,
+		logRetention: GetDurationFromEnv("SEARCH_ANALYTICS_RETENTION", 90*24*time.Hour),
+	}
+
+	jobManager.Schedule(SearchAnalyticsPruneJob, "@daily", time.Hour, s.pruneLogs)
+	return s
+}
+
+func (s *searchService) Search(ctx context.Context, userID uuid.UUID, filter dto.SearchFilter) (*dto.SearchResponse, error) {
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	blocked, err := s.blockRepo.FindBlockedIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := SearchQuery{
+		Query:        strings.TrimSpace(filter.Query),
+		Roles:        searchRoles(user.Role.Name),
+		ExcludeUsers: blocked,
+		CategoryID:   filter.CategoryID,
+		Author:       filter.Author,
+		Strict:       filter.Strict,
+		Highlight:    filter.Highlight == nil || *filter.Highlight,
+		Offset:       (filter.Page - 1) * filter.Limit,
+		Limit:        filter.Limit,
+	}
+
+	if filter.Types != "" {
+		for _, t := range strings.Split(filter.Types, ",") {
+			index, ok := searchTypes[strings.TrimSpace(t)]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidSearchType, t)
+			}
+			q.Indexes = append(q.Indexes, index)
+		}
+	}
+
+	// Dates are whole days in server time; "to" includes its day
+	if filter.From != "" {
+		from, err := time.ParseInLocation("2006-01-02", filter.From, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		q.From = &from
+	}
+	if filter.To != "" {
+		to, err := time.ParseInLocation("2006-01-02", filter.To, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		to = to.AddDate(0, 0, 1)
+		q.To = &to
+	}
+
+	result, err := s.meili.Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	result.Page = filter.Page
+	result.SearchID = s.logSearch(ctx, filter, result.EstimatedTotal)
+	return result, nil
+}
+
+// Token issues a search token with the user's current role and blocks. The
+// user is read again on every refresh, so a deleted user gets no new token.
+func (s *searchService) Token(ctx context.Context, userID uuid.UUID) (*dto.SearchTokenResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	blocked, err := s.blockRepo.FindBlockedIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := s.meili.GenerateSearchToken(ctx, SearchTokenScope{
+		Roles:        searchRoles(user.Role.Name),
+		ExcludeUsers: blocked,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.SearchTokenResponse{
+		SearchToken: token,
+		ExpiresAt:   expiresAt.Unix(),
+	}, nil
+}
+
+// RevokeTokens invalidates every search token issued so far.
+func (s *searchService) RevokeTokens(ctx context.Context) error {
+	return s.meili.RotateSigningKey(ctx, true)
+}
+
+// Reindex starts a full rebuild of the indexes in the background.
+func (s *searchService) Reindex(ctx context.Context) error {
+	return s.meili.StartReindex(ctx)
+}
+
+// CheckConsistency reports how far the indexes are from the database
+// without changing them.
+func (s *searchService) CheckConsistency(ctx context.Context) (*dto.SearchSyncReport, error) {
+	return s.meili.CheckConsistency(ctx, false)
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// Search tokens are tenant tokens the frontend uses to query Meilisearch
+// directly. They are signed with a search-only API key named
+// TenantTokenSigner and carry the user's filters, so they are short-lived
+// and refreshed through /api/search/token. The signing key is replaced every
+// SEARCH_KEY_ROTATION_INTERVAL; the previous one stays valid for
+// SEARCH_KEY_GRACE so tokens signed just before a rotation keep working,
+// and is deleted by the next rotation. The keys live in Meilisearch, so
+// every replica signs with the newest one within searchKeyRefresh.
+const (
+	SearchKeyRotationJob = "search.key_rotation"
+
+	searchKeyName    = "TenantTokenSigner"
+	searchKeyRefresh = time.Minute
+)
+
+// SearchTokenScope is what a token may see. Roles lists the allowed_roles
+// values to match, nil meaning no restriction; the threads, posts and
+// profile of ExcludeUsers are left out.
+type SearchTokenScope struct {
+	Roles        []string
+	ExcludeUsers []uuid.UUID
+}
+
+type signingKey struct {
+	uid       string
+	key       string
+	expiresAt time.Time // Zero for keys that never expire
+}
+
+// usableUntil reports whether the key is still valid at t.
+func (k *signingKey) usableUntil(t time.Time) bool {
+	return k.expiresAt.IsZero() || k.expiresAt.After(t)
+}
+
+func (s *meiliSearchService) initSigningKey() {
+	s.tokenTTL = GetDurationFromEnv("SEARCH_TOKEN_TTL", 15*time.Minute)
+	s.keyRotation = GetDurationFromEnv("SEARCH_KEY_ROTATION_INTERVAL", 24*time.Hour)
+	s.keyGrace = GetDurationFromEnv("SEARCH_KEY_GRACE", time.Hour)
+	// Replicas may sign with the old key until they refresh, and those
+	// tokens must expire before it does
+	if minGrace := s.tokenTTL + searchKeyRefresh; s.keyGrace < minGrace {
+		s.keyGrace = minGrace
+	}
+
+	if _, err := s.currentSigningKey(context.Background()); err != nil {
+		log.Printf("Failed to load Meilisearch signing key: %v", err)
+	}
+}
+
+func (s *meiliSearchService) rotateKeyJob(ctx context.Context) error {
+	return s.RotateSigningKey(ctx, false)
+}
+
+// signerKeys lists the TenantTokenSigner keys, newest first.
+func (s *meiliSearchService) signerKeys(ctx context.Context) ([]meilisearch.Key, error) {
+	var keys []meilisearch.Key
+	for offset := int64(0); ; offset += 100 {
+		resp, err := s.client.GetKeysWithContext(ctx, &meilisearch.KeysQuery{Offset: offset, Limit: 100})
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range resp.Results {
+			if key.Name == searchKeyName {
+				keys = append(keys, key)
+			}
+		}
+		if int64(len(resp.Results)) < 100 {
+			break
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// createSigningKey adds a key that expires one grace period after the
+// rotation that replaces it.
+func (s *meiliSearchService) createSigningKey(ctx context.Context) (*signingKey, error) {
+	key, err := s.client.CreateKeyWithContext(ctx, &meilisearch.Key{
+		Name:        searchKeyName,
+		Description: "Key to sign tenant tokens",
+		Actions:     []string{"search"},
+		Indexes:     searchIndexes,
+		ExpiresAt:   time.Now().Add(s.keyRotation + s.keyGrace),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signing key: %w", err)
+	}
+	log.Println("Created new Meilisearch signing key")
+	return &signingKey{uid: key.UID, key: key.Key, expiresAt: key.ExpiresAt}, nil
+}
+
+// currentSigningKey returns the newest key that covers every index and
+// outlives a token signed now, creating one if there is none, e.g. on the
+// first start or when rotation has stopped.
+func (s *meiliSearchService) currentSigningKey(ctx context.Context) (*signingKey, error) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	now := time.Now()
+	if s.key != nil && now.Sub(s.keyLoadedAt) < searchKeyRefresh && s.key.usableUntil(now.Add(s.tokenTTL)) {
+		return s.key, nil
+	}
+
+	keys, err := s.signerKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var current *signingKey
+	for _, key := range keys {
+		candidate := &signingKey{uid: key.UID, key: key.Key, expiresAt: key.ExpiresAt}
+		if coversIndexes(key.Indexes) && candidate.usableUntil(now.Add(s.tokenTTL)) {
+			current = candidate
+			break
+		}
+	}
+	if current == nil {
+		if current, err = s.createSigningKey(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	s.key, s.keyLoadedAt = current, now
+	return current, nil
+}
+
+// RotateSigningKey signs new tokens with a fresh key. The key it replaces
+// stays valid for the grace period and older ones are deleted. With revoke,
+// every other key is deleted at once, which invalidates every outstanding
+// token; replicas pick up the new key within searchKeyRefresh.
+func (s *meiliSearchService) RotateSigningKey(ctx context.Context, revoke bool) error {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	current, err := s.createSigningKey(ctx)
+	if err != nil {
+		return err
+	}
+	s.key, s.keyLoadedAt = current, time.Now()
+
+	keys, err := s.signerKeys(ctx)
+	if err != nil {
+		return err
+	}
+	// Each key was replaced when the next newer one was created
+	now := time.Now()
+	var replacedAt time.Time
+	for _, key := range keys {
+		if key.UID == current.uid {
+			replacedAt = key.CreatedAt
+			continue
+		}
+		if replacedAt.IsZero() {
+			// Newer than ours, from a concurrent rotation
+			continue
+		}
+		expired := !key.ExpiresAt.IsZero() && !key.ExpiresAt.After(now)
+		if revoke || expired || now.Sub(replacedAt) >= s.keyGrace {
+			if _, err := s.client.DeleteKeyWithContext(ctx, key.UID); err != nil {
+				return fmt.Errorf("failed to delete signing key: %w", err)
+			}
+			log.Printf("Deleted Meilisearch signing key %s", key.UID)
+		}
+		replacedAt = key.CreatedAt
+	}
+	return nil
+}
+
+// coversIndexes reports whether a key with these indexes can search all of
+// searchIndexes. Keys cannot gain indexes, so one made before an index was
+// added is never used again.
+func coversIndexes(indexes []string) bool {
+	has := make(map[string]bool, len(indexes))
+	for _, index := range indexes {
+		has[index] = true
+	}
+	if has["*"] {
+		return true
+	}
+	for _, index := range searchIndexes {
+		if !has[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// searchRule restricts an index to the filter; an empty filter allows all.
+func searchRule(filter string) map[string]any {
+	if filter == "" {
+		return map[string]any{}
+	}
+	return map[string]any{"filter": filter}
+}
+
+// searchRules limit a token to what the scope may see. Users and categories
+// hold public data only and are open to every role.
+func searchRules(scope SearchTokenScope) map[string]any {
+	content := strings.Join(contentFilter(scope.Roles, scope.ExcludeUsers), " AND ")
+	return map[string]any{
+		"threads":    searchRule(content),
+		"posts":      searchRule(content),
+		"users":      searchRule(excludeUsersFilter("id", scope.ExcludeUsers)),
+		"categories": searchRule(""),
+	}
+}
+
+// GenerateSearchToken signs a token limited to the scope. It expires after
+// SEARCH_TOKEN_TTL, or with its signing key if that is sooner.
+func (s *meiliSearchService) GenerateSearchToken(ctx context.Context, scope SearchTokenScope) (string, time.Time, error) {
+	key, err := s.currentSigningKey(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(s.tokenTTL)
+	if !key.expiresAt.IsZero() && key.expiresAt.Before(expiresAt) {
+		expiresAt = key.expiresAt
+	}
+
+	token, err := s.client.GenerateTenantToken(key.uid, searchRules(scope), &meilisearch.TenantTokenOptions{
+		APIKey:    key.key,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
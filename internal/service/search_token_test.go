@@ -0,0 +1,83 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+	"github.com/microcosm-cc/bluemonday"
+	"gorm.io/gorm"
+)
+
+func ruleFilter(rules map[string]any, index string) string {
+	rule, _ := rules[index].(map[string]any)
+	filter, _ := rule["filter"].(string)
+	return filter
+}
+
+func TestSearchRules(t *testing.T) {
+	blocked := uuid.MustParse("0190a5f0-0000-7000-8000-000000000001")
+
+	tests := []struct {
+		name    string
+		scope   SearchTokenScope
+		content string
+		users   string
+	}{
+		{
+			name:    "admin",
+			scope:   SearchTokenScope{Roles: searchRoles("admin")},
+			content: `hidden != true`,
+		},
+		{
+			name:    "siswa",
+			scope:   SearchTokenScope{Roles: searchRoles("siswa")},
+			content: `hidden != true AND allowed_roles IN ["siswa", "public"]`,
+		},
+		{
+			name:    "guru with a blocked user",
+			scope:   SearchTokenScope{Roles: searchRoles("guru"), ExcludeUsers: []uuid.UUID{blocked}},
+			content: `hidden != true AND allowed_roles IN ["guru", "public"] AND user_id NOT IN ["0190a5f0-0000-7000-8000-000000000001"]`,
+			users:   `id NOT IN ["0190a5f0-0000-7000-8000-000000000001"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := searchRules(tt.scope)
+			for _, index := range []string{"threads", "posts"} {
+				if got := ruleFilter(rules, index); got != tt.content {
+					t.Errorf("%s filter %q, want %q", index, got, tt.content)
+				}
+			}
+			if got := ruleFilter(rules, "users"); got != tt.users {
+				t.Errorf("users filter %q, want %q", got, tt.users)
+			}
+			if _, ok := rules["categories"]; !ok || ruleFilter(rules, "categories") != "" {
+				t.Errorf("categories rule %v, want open", rules["categories"])
+			}
+
+			// The backend's own searches apply the same filter
+			q := SearchQuery{Roles: tt.scope.Roles, ExcludeUsers: tt.scope.ExcludeUsers}
+			for _, index := range []string{"threads", "posts"} {
+				if got := q.filter(index); got != tt.content {
+					t.Errorf("search %s filter %q, want %q", index, got, tt.content)
+				}
+			}
+		})
+	}
+}
+
+func TestPostDocHidden(t *testing.T) {
+	s := &meiliSearchService{sanitizer: bluemonday.StrictPolicy()}
+	post := &model.Post{ID: uuid.New(), Content: "<p>halo</p>", Thread: model.Thread{Audience: "semua"}}
+
+	if doc := s.postDoc(post); doc.Hidden {
+		t.Error("live post indexed as hidden")
+	}
+	post.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	if doc := s.postDoc(post); !doc.Hidden {
+		t.Error("soft-deleted post indexed as visible")
+	}
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"anoa.com/telkomalumiforum/pkg/queue"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidSubscriptionMode = errors.New("invalid subscription mode")
+
+type SubscriptionService interface {
+	GetSubscription(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*dto.SubscriptionResponse, error)
+	Watch(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, mode string) (*dto.SubscriptionResponse, error)
+	Unwatch(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error
+	AutoWatch(ctx context.Context, userID uuid.UUID, threadID uuid.UUID)
+	NotifyReply(ctx context.Context, postID uuid.UUID)
+}
+
+type subscriptionService struct {
+	redisClient         *redis.Client
+	subscriptionRepo    repository.SubscriptionRepository
+	threadRepo          repository.ThreadRepository
+	postRepo            repository.PostRepository
+	userRepo            repository.UserRepository
+	notificationService NotificationService
+	visibility          VisibilityPolicy
+	jobs                *jobs.Manager
+}
+
+func NewSubscriptionService(redisClient *redis.Client, subscriptionRepo repository.SubscriptionRepository, threadRepo repository.ThreadRepository, postRepo repository.PostRepository, userRepo repository.UserRepository, notificationService NotificationService, visibility VisibilityPolicy, jobManager *jobs.Manager) SubscriptionService {
+	s := &subscriptionService{
+		redisClient:         redisClient,
+		subscriptionRepo:    subscriptionRepo,
+		threadRepo:          threadRepo,
+		postRepo:            postRepo,
+		userRepo:            userRepo,
+		notificationService: notificationService,
+		visibility:          visibility,
+		jobs:                jobManager,
+	}
+
+	jobs.Register(jobManager, ReplyNotificationJob, jobs.Options{Queue: ReplyNotificationQueueKey, Workers: 2}, s.processTask)
+	return s
+}
+
+const (
+	ReplyNotificationJob      = "notifications.reply"
+	ReplyNotificationQueueKey = "reply_notification_queue"
+)
+
+type ReplyNotificationTask struct {
+	PostID string `json:"post_id"`
+}
+
+var mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_.]+)`)
+
+func isValidSubscriptionMode(mode string) bool {
+	switch mode {
+	case model.SubscriptionModeAll, model.SubscriptionModeDirect, model.SubscriptionModeMuted:
+		return true
+	}
+	return false
+}
+
+func (s *subscriptionService) GetSubscription(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*dto.SubscriptionResponse, error) {
+	if _, err := s.visibility.Thread(ctx, userID, threadID); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.subscriptionRepo.Find(ctx, userID, threadID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &dto.SubscriptionResponse{ThreadID: threadID, Watching: false}, nil
+		}
+		return nil, err
+	}
+
+	return &dto.SubscriptionResponse{ThreadID: threadID, Watching: true, Mode: sub.Mode}, nil
+}
+
+func (s *subscriptionService) Watch(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, mode string) (*dto.SubscriptionResponse, error) {
+	if mode == "" {
+		mode = model.SubscriptionModeAll
+	}
+	if !isValidSubscriptionMode(mode) {
+		return nil, ErrInvalidSubscriptionMode
+	}
+
+	if _, err := s.visibility.Thread(ctx, userID, threadID); err != nil {
+		return nil, err
+	}
+
+	sub := &model.ThreadSubscription{
+		UserID:   userID,
+		ThreadID: threadID,
+		Mode:     mode,
+	}
+	if err := s.subscriptionRepo.Upsert(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return &dto.SubscriptionResponse{ThreadID: threadID, Watching: true, Mode: mode}, nil
+}
+
+func (s *subscriptionService) Unwatch(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error {
+	return s.subscriptionRepo.Delete(ctx, userID, threadID)
+}
+
+// AutoWatch subscribes a thread author or replier with mode 'all' unless they
+// already have a subscription.
+func (s *subscriptionService) AutoWatch(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) {
+	sub := &model.ThreadSubscription{
+		UserID:   userID,
+		ThreadID: threadID,
+		Mode:     model.SubscriptionModeAll,
+	}
+	if err := s.subscriptionRepo.CreateIfNotExists(ctx, sub); err != nil {
+		log.Printf("Failed to auto-watch thread %s for user %s: %v", threadID, userID, err)
+	}
+}
+
+// NotifyReply queues the notification fan-out for a new post.
+func (s *subscriptionService) NotifyReply(ctx context.Context, postID uuid.UUID) {
+	task := ReplyNotificationTask{PostID: postID.String()}
+	if err := s.jobs.Enqueue(ctx, ReplyNotificationJob, task); err != nil {
+		log.Printf("Failed to queue reply notification for post %s: %v", postID, err)
+	}
+}
+
+func (s *subscriptionService) processTask(ctx context.Context, task ReplyNotificationTask) error {
+	postID, err := uuid.Parse(task.PostID)
+	if err != nil {
+		return queue.Permanent(fmt.Errorf("invalid post id in reply notification task: %w", err))
+	}
+	return s.fanOut(ctx, postID)
+}
+
+type replyRecipient struct {
+	notifType string
+	message   string
+}
+
+// fanOut notifies everyone interested in a new post:
+//   - the parent post author (reply_post) and mentioned users (mention),
+//     unless they muted the thread
+//   - the thread author for top-level replies (reply_thread), unless muted
+//   - watchers in mode 'all' (reply_thread)
+//
+// Each user gets at most one notification, direct ones taking precedence.
+// Lookups that fail before anything is sent are returned so the job retries.
+func (s *subscriptionService) fanOut(ctx context.Context, postID uuid.UUID) error {
+	post, err := s.postRepo.FindByID(ctx, postID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted before we got to it
+			return nil
+		}
+		return fmt.Errorf("failed to load post %s: %w", postID, err)
+	}
+
+	thread, err := s.threadRepo.FindByID(ctx, post.ThreadID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to load thread %s: %w", post.ThreadID, err)
+	}
+
+	subs, err := s.subscriptionRepo.FindByThreadID(ctx, thread.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load watchers of %s: %w", thread.ID, err)
+	}
+	modes := make(map[uuid.UUID]string, len(subs))
+	for _, sub := range subs {
+		modes[sub.UserID] = sub.Mode
+	}
+
+	recipients := make(map[uuid.UUID]replyRecipient)
+	addDirect := func(userID uuid.UUID, r replyRecipient) {
+		if userID == post.UserID || modes[userID] == model.SubscriptionModeMuted {
+			return
+		}
+		if _, ok := recipients[userID]; !ok {
+			recipients[userID] = r
+		}
+	}
+
+	if post.Parent != nil {
+		addDirect(post.Parent.UserID, replyRecipient{
+			notifType: "reply_post",
+			message:   fmt.Sprintf("Someone replied to your post in '%s'", thread.Title),
+		})
+	} else {
+		addDirect(thread.UserID, replyRecipient{
+			notifType: "reply_thread",
+			message:   fmt.Sprintf("Someone commented on your thread '%s'", thread.Title),
+		})
+	}
+
+	for _, mentioned := range s.mentionedUsers(ctx, post.Content) {
+		addDirect(mentioned, replyRecipient{
+			notifType: "mention",
+			message:   fmt.Sprintf("Someone mentioned you in '%s'", thread.Title),
+		})
+	}
+
+	for userID, mode := range modes {
+		if mode == model.SubscriptionModeAll {
+			addDirect(userID, replyRecipient{
+				notifType: "reply_thread",
+				message:   fmt.Sprintf("New reply in a thread you are watching: '%s'", thread.Title),
+			})
+		}
+	}
+
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	// Drop recipients who can no longer see the thread
+	ids := make([]string, 0, len(recipients))
+	for userID := range recipients {
+		ids = append(ids, userID.String())
+	}
+	users, err := s.userRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to load recipients: %w", err)
+	}
+
+	for _, user := range users {
+		if !s.visibility.CanViewAs(user, thread) {
+			continue
+		}
+		r := recipients[user.ID]
+		notification := &model.Notification{
+			UserID:     user.ID,
+			ActorID:    post.UserID,
+			EntityID:   post.ID,
+			EntitySlug: thread.Slug,
+			EntityType: "post",
+			Type:       r.notifType,
+			Message:    r.message,
+		}
+		// Not retried: the others were already notified
+		if err := s.notificationService.CreateNotification(ctx, notification); err != nil {
+			log.Printf("Reply notification: failed to notify %s: %v", user.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *subscriptionService) mentionedUsers(ctx context.Context, content string) []uuid.UUID {
+	matches := mentionPattern.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var usernames []string
+	for _, m := range matches {
+		name := strings.TrimRight(m[1], ".")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			usernames = append(usernames, name)
+		}
+	}
+
+	users, err := s.userRepo.FindByUsernames(ctx, usernames)
+	if err != nil {
+		log.Printf("Reply notification: failed to resolve mentions: %v", err)
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
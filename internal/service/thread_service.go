@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
 	"time"
@@ -18,40 +19,47 @@ import (
 type ThreadService interface {
 	CreateThread(ctx context.Context, userID uuid.UUID, req dto.CreateThreadRequest) error
 	GetAllThreads(ctx context.Context, userID uuid.UUID, filter dto.ThreadFilter) (*dto.PaginatedThreadResponse, error)
-	GetMyThreads(ctx context.Context, userID uuid.UUID, page, limit int) (*dto.PaginatedThreadResponse, error)
-	GetThreadBySlug(ctx context.Context, slug string) (*dto.ThreadResponse, error)
+	GetMyThreads(ctx context.Context, userID uuid.UUID, page, limit int, cursor dto.CursorFilter) (*dto.PaginatedThreadResponse, error)
+	GetThreadBySlug(ctx context.Context, userID uuid.UUID, slug string) (*dto.ThreadResponse, error)
 	DeleteThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error
 	UpdateThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, req dto.UpdateThreadRequest) error
 	IncrementView(ctx context.Context, threadID uuid.UUID, userID uuid.UUID) error
-	GetThreadsByUsername(ctx context.Context, currentUserID uuid.UUID, username string, page, limit int) (*dto.PaginatedThreadResponse, error)
-	GetTrendingThreads(ctx context.Context, limit int) ([]dto.ThreadResponse, error)
+	GetThreadsByUsername(ctx context.Context, currentUserID uuid.UUID, username string, page, limit int, cursor dto.CursorFilter) (*dto.PaginatedThreadResponse, error)
+	GetTrendingThreads(ctx context.Context, userID uuid.UUID, filter dto.TrendingFilter) ([]dto.ThreadResponse, error)
+	GetSimilarThreads(ctx context.Context, userID uuid.UUID, req dto.SimilarThreadsRequest) ([]dto.SimilarThreadResponse, error)
 }
 
 type threadService struct {
-	threadRepo     repository.ThreadRepository
-	categoryRepo   repository.CategoryRepository
-	userRepo       repository.UserRepository
-	attachmentRepo repository.AttachmentRepository
-	likeService    LikeService
-	fileStorage    storage.ImageStorage
-	redisClient    *redis.Client
-	viewService    ViewService
-	meili          MeiliSearchService
+	threadRepo          repository.ThreadRepository
+	categoryRepo        repository.CategoryRepository
+	userRepo            repository.UserRepository
+	attachmentRepo      repository.AttachmentRepository
+	likeService         LikeService
+	fileStorage         storage.ImageStorage
+	redisClient         *redis.Client
+	viewService         ViewService
+	meili               MeiliSearchService
+	subscriptionService SubscriptionService
+	readService         ReadService
+	trendingService     TrendingService
+	visibility          VisibilityPolicy
 }
 
-func NewThreadService(threadRepo repository.ThreadRepository, categoryRepo repository.CategoryRepository, userRepo repository.UserRepository, attachmentRepo repository.AttachmentRepository, likeService LikeService, fileStorage storage.ImageStorage, redisClient *redis.Client, meili MeiliSearchService) ThreadService {
-	viewService := NewViewService(redisClient, threadRepo)
-
+func NewThreadService(threadRepo repository.ThreadRepository, categoryRepo repository.CategoryRepository, userRepo repository.UserRepository, attachmentRepo repository.AttachmentRepository, likeService LikeService, fileStorage storage.ImageStorage, redisClient *redis.Client, meili MeiliSearchService, subscriptionService SubscriptionService, readService ReadService, viewService ViewService, trendingService TrendingService, visibility VisibilityPolicy) ThreadService {
 	return &threadService{
-		threadRepo:     threadRepo,
-		categoryRepo:   categoryRepo,
-		userRepo:       userRepo,
-		attachmentRepo: attachmentRepo,
-		likeService:    likeService,
-		fileStorage:    fileStorage,
-		redisClient:    redisClient,
-		viewService:    viewService,
-		meili:          meili,
+		threadRepo:          threadRepo,
+		categoryRepo:        categoryRepo,
+		userRepo:            userRepo,
+		attachmentRepo:      attachmentRepo,
+		likeService:         likeService,
+		fileStorage:         fileStorage,
+		redisClient:         redisClient,
+		viewService:         viewService,
+		meili:               meili,
+		subscriptionService: subscriptionService,
+		readService:         readService,
+		trendingService:     trendingService,
+		visibility:          visibility,
 	}
 }
 
@@ -102,15 +110,8 @@ func (s *threadService) CreateThread(ctx context.Context, userID uuid.UUID, req
 	}
 
 	// Validate Audience based on Role
-	switch user.Role.Name {
-	case "siswa":
-		if req.Audience == "guru" {
-			return fmt.Errorf("siswa cannot create thread for guru")
-		}
-	case "guru":
-		if req.Audience == "siswa" {
-			return fmt.Errorf("guru cannot create thread for siswa")
-		}
+	if !canPostToAudience(user.Role.Name, req.Audience) {
+		return fmt.Errorf("%s cannot create thread for %s", user.Role.Name, req.Audience)
 	}
 
 	categoryID, err := uuid.Parse(req.CategoryID)
@@ -133,7 +134,6 @@ func (s *threadService) CreateThread(ctx context.Context, userID uuid.UUID, req
 	// Trim hyphens
 	slug = strings.Trim(slug, "-")
 
-
 	// Basic slug uniqueness check
 	existing, _ := s.threadRepo.FindBySlug(ctx, slug)
 	if existing != nil {
@@ -150,29 +150,25 @@ func (s *threadService) CreateThread(ctx context.Context, userID uuid.UUID, req
 		Audience:   req.Audience,
 	}
 
-	if err := s.threadRepo.Create(ctx, thread); err != nil {
-		return err
-	}
-
-	if len(req.AttachmentIDs) > 0 {
-		if err := s.attachmentRepo.UpdateThreadID(ctx, req.AttachmentIDs, thread.ID, userID); err != nil {
+	// The poll is created in the same insert as the thread
+	if req.Poll != nil {
+		poll, err := NewPollModel(req.Poll)
+		if err != nil {
 			return err
 		}
+		thread.Poll = poll
+	}
+
+	// The attachments are linked in the same transaction, so a bad id leaves
+	// no thread behind for a retry to duplicate
+	if err := s.threadRepo.Create(ctx, thread, req.AttachmentIDs); err != nil {
+		return err
 	}
 
 	// Everything succeeded, don't roll back the rate limits.
 	creationFailed = false
 
-	// Index to Meilisearch
-	thread.User = *user
-	thread.Category = *category
-	if s.meili != nil {
-		if err := s.meili.IndexThread(thread); err != nil {
-			// Log error but don't fail the request?
-			// Or fail? Best to log.
-			fmt.Printf("Failed to index thread: %v\n", err)
-		}
-	}
+	s.subscriptionService.AutoWatch(ctx, userID, thread.ID)
 
 	return nil
 }
@@ -186,19 +182,8 @@ func (s *threadService) GetAllThreads(ctx context.Context, userID uuid.UUID, fil
 
 	// 2. Determine Allowed Audiences
 	var effectiveAudiences []string
-	var allowed []string
-
-	switch user.Role.Name {
-	case "siswa":
-		allowed = []string{"siswa", "semua"}
-	case "guru":
-		allowed = []string{"guru", "semua"}
-	default:
-		// Admin sees everything, leave allowed empty to indicate "all" or specific logic
-		// But Wait, if filter is set, we use filter. If not set, we return all.
-		// Existing logic: if len(audiences) > 0 -> WHERE audience IN...
-		// So for admin, if we keep effectiveAudiences empty, it means no WHERE audience constraint -> ALL.
-	}
+	// Empty for admins: no WHERE audience constraint, so all threads
+	allowed := visibleAudiences(user.Role.Name)
 
 	if len(allowed) > 0 {
 		if filter.Audience != "" {
@@ -211,6 +196,12 @@ func (s *threadService) GetAllThreads(ctx context.Context, userID uuid.UUID, fil
 				}
 			}
 			if !isAllowed {
+				if useCursor(filter.CursorFilter) {
+					return &dto.PaginatedThreadResponse{
+						Data:   []dto.ThreadResponse{},
+						Cursor: &dto.CursorMeta{Limit: filter.Limit},
+					}, nil
+				}
 				return &dto.PaginatedThreadResponse{
 					Data: []dto.ThreadResponse{},
 					Meta: dto.PaginationMeta{
@@ -241,48 +232,33 @@ func (s *threadService) GetAllThreads(ctx context.Context, userID uuid.UUID, fil
 		categoryID = &id
 	}
 
+	if useCursor(filter.CursorFilter) {
+		q := repository.ThreadListQuery{
+			CategoryID: categoryID,
+			Search:     filter.Search,
+			Audiences:  effectiveAudiences,
+			SortBy:     filter.SortBy,
+		}
+		return s.threadPage(ctx, userID, q, filter.CursorFilter, filter.Limit)
+	}
+
+	// Searches go to Meilisearch; ILIKE is only the fallback for when it is
+	// unreachable and for the activity sort, which the index cannot order by
+	if filter.Search != "" && s.meili != nil && filter.SortBy != "activity" {
+		resp, err := s.searchThreads(ctx, userID, filter, effectiveAudiences, categoryID)
+		if err == nil {
+			return resp, nil
+		}
+		log.Printf("Thread search failed, falling back to ILIKE: %v", err)
+	}
+
 	offset := (filter.Page - 1) * filter.Limit
 	threads, total, err := s.threadRepo.FindAll(ctx, categoryID, filter.Search, effectiveAudiences, filter.SortBy, offset, filter.Limit)
 	if err != nil {
 		return nil, err
 	}
 
-	var threadResponses []dto.ThreadResponse
-	for _, thread := range threads {
-		var attachments []dto.AttachmentResponse
-		for _, att := range thread.Attachments {
-			attachments = append(attachments, dto.AttachmentResponse{
-				ID:       att.ID,
-				FileURL:  att.FileURL,
-				FileType: att.FileType,
-			})
-		}
-
-		authorResponse := dto.AuthorResponse{
-			Username: "Unknown",
-		}
-		if thread.User.Username != "" {
-			authorResponse.Username = thread.User.Username
-			authorResponse.AvatarURL = thread.User.AvatarURL
-		}
-
-		likesCount, _ := s.likeService.GetThreadLikes(ctx, thread.ID)
-
-		resp := dto.ThreadResponse{
-			ID:           thread.ID,
-			CategoryName: thread.Category.Name,
-			Title:        thread.Title,
-			Slug:         thread.Slug,
-			Content:      thread.Content,
-			Audience:     thread.Audience,
-			Views:        thread.Views,
-			Author:       authorResponse,
-			Attachments:  attachments,
-			LikesCount:   likesCount,
-			CreatedAt:    thread.CreatedAt.Format("2006-01-02 15:04:05"),
-		}
-		threadResponses = append(threadResponses, resp)
-	}
+	threadResponses := s.listResponses(ctx, userID, threads)
 
 	totalPages := int(total) / filter.Limit
 	if int(total)%filter.Limit != 0 {
@@ -300,7 +276,7 @@ func (s *threadService) GetAllThreads(ctx context.Context, userID uuid.UUID, fil
 	}, nil
 }
 
-func (s *threadService) GetMyThreads(ctx context.Context, userID uuid.UUID, page, limit int) (*dto.PaginatedThreadResponse, error) {
+func (s *threadService) GetMyThreads(ctx context.Context, userID uuid.UUID, page, limit int, cursor dto.CursorFilter) (*dto.PaginatedThreadResponse, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -311,48 +287,17 @@ func (s *threadService) GetMyThreads(ctx context.Context, userID uuid.UUID, page
 		limit = 50
 	}
 
+	if useCursor(cursor) {
+		return s.threadPage(ctx, userID, repository.ThreadListQuery{UserID: &userID}, cursor, limit)
+	}
+
 	offset := (page - 1) * limit
 	threads, total, err := s.threadRepo.FindByUserID(ctx, userID, nil, offset, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	var threadResponses []dto.ThreadResponse
-	for _, thread := range threads {
-		var attachments []dto.AttachmentResponse
-		for _, att := range thread.Attachments {
-			attachments = append(attachments, dto.AttachmentResponse{
-				ID:       att.ID,
-				FileURL:  att.FileURL,
-				FileType: att.FileType,
-			})
-		}
-
-		authorResponse := dto.AuthorResponse{
-			Username: "Unknown",
-		}
-		if thread.User.Username != "" {
-			authorResponse.Username = thread.User.Username
-			authorResponse.AvatarURL = thread.User.AvatarURL
-		}
-
-		likesCount, _ := s.likeService.GetThreadLikes(ctx, thread.ID)
-
-		resp := dto.ThreadResponse{
-			ID:           thread.ID,
-			CategoryName: thread.Category.Name,
-			Title:        thread.Title,
-			Slug:         thread.Slug,
-			Content:      thread.Content,
-			Audience:     thread.Audience,
-			Views:        thread.Views,
-			Author:       authorResponse,
-			Attachments:  attachments,
-			LikesCount:   likesCount,
-			CreatedAt:    thread.CreatedAt.Format("2006-01-02 15:04:05"),
-		}
-		threadResponses = append(threadResponses, resp)
-	}
+	threadResponses := s.listResponses(ctx, userID, threads)
 
 	totalPages := int(total) / limit
 	if int(total)%limit != 0 {
@@ -370,7 +315,7 @@ func (s *threadService) GetMyThreads(ctx context.Context, userID uuid.UUID, page
 	}, nil
 }
 
-func (s *threadService) GetThreadsByUsername(ctx context.Context, currentUserID uuid.UUID, username string, page, limit int) (*dto.PaginatedThreadResponse, error) {
+func (s *threadService) GetThreadsByUsername(ctx context.Context, currentUserID uuid.UUID, username string, page, limit int, cursor dto.CursorFilter) (*dto.PaginatedThreadResponse, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -391,16 +336,11 @@ func (s *threadService) GetThreadsByUsername(ctx context.Context, currentUserID
 		return nil, fmt.Errorf("user not found")
 	}
 
-	var allowedAudiences []string
-	switch currentUser.Role.Name {
-	case "siswa":
-		allowedAudiences = []string{"semua", "siswa"}
-	case "guru":
-		allowedAudiences = []string{"semua", "guru"}
-	case "admin":
-		allowedAudiences = nil // See all
-	default:
-		allowedAudiences = []string{"semua"}
+	allowedAudiences := visibleAudiences(currentUser.Role.Name)
+
+	if useCursor(cursor) {
+		q := repository.ThreadListQuery{UserID: &user.ID, Audiences: allowedAudiences}
+		return s.threadPage(ctx, currentUserID, q, cursor, limit)
 	}
 
 	offset := (page - 1) * limit
@@ -409,13 +349,89 @@ func (s *threadService) GetThreadsByUsername(ctx context.Context, currentUserID
 		return nil, err
 	}
 
+	threadResponses := s.listResponses(ctx, currentUserID, threads)
+
+	totalPages := int(total) / limit
+	if int(total)%limit != 0 {
+		totalPages++
+	}
+
+	return &dto.PaginatedThreadResponse{
+		Data: threadResponses,
+		Meta: dto.PaginationMeta{
+			CurrentPage: page,
+			TotalPages:  totalPages,
+			TotalItems:  total,
+			Limit:       limit,
+		},
+	}, nil
+}
+
+// searchThreads serves a page of GetAllThreads from the threads index.
+// Without an explicit sort the results are ordered by relevance.
+func (s *threadService) searchThreads(ctx context.Context, userID uuid.UUID, filter dto.ThreadFilter, audiences []string, categoryID *uuid.UUID) (*dto.PaginatedThreadResponse, error) {
+	q := SearchQuery{
+		Query:  filter.Search,
+		Roles:  audienceRoles(audiences),
+		Offset: (filter.Page - 1) * filter.Limit,
+		Limit:  filter.Limit,
+	}
+	if categoryID != nil {
+		q.CategoryID = categoryID.String()
+	}
+	switch filter.SortBy {
+	case "popular":
+		q.Sort = []string{"views:desc", "created_at:desc"}
+	case "newest":
+		q.Sort = []string{"created_at:desc"}
+	}
+
+	ids, total, err := s.meili.SearchThreadIDs(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	threads, err := s.threadRepo.FindByIDsOrdered(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	// The index may lag behind an audience change or a deleted thread; the
+	// policy has the final word on each hit
+	threads, err = s.visibility.FilterThreads(ctx, userID, threads)
+	if err != nil {
+		return nil, err
+	}
+
+	data := s.listResponses(ctx, userID, threads)
+	if data == nil {
+		data = []dto.ThreadResponse{}
+	}
+
+	totalPages := int(total) / filter.Limit
+	if int(total)%filter.Limit != 0 {
+		totalPages++
+	}
+
+	return &dto.PaginatedThreadResponse{
+		Data: data,
+		Meta: dto.PaginationMeta{
+			CurrentPage: filter.Page,
+			TotalPages:  totalPages,
+			TotalItems:  total,
+			Limit:       filter.Limit,
+		},
+	}, nil
+}
+
+// listResponses builds the list entries for threads, with reactions, last
+// reply and read state filled in.
+func (s *threadService) listResponses(ctx context.Context, userID uuid.UUID, threads []*model.Thread) []dto.ThreadResponse {
 	var threadResponses []dto.ThreadResponse
 	for _, thread := range threads {
 		var attachments []dto.AttachmentResponse
 		for _, att := range thread.Attachments {
 			attachments = append(attachments, dto.AttachmentResponse{
 				ID:       att.ID,
-				FileURL:  att.FileURL,
+				FileURL:  storage.SignedURL(s.fileStorage, att.FileURL),
 				FileType: att.FileType,
 			})
 		}
@@ -428,8 +444,6 @@ func (s *threadService) GetThreadsByUsername(ctx context.Context, currentUserID
 			authorResponse.AvatarURL = thread.User.AvatarURL
 		}
 
-		likesCount, _ := s.likeService.GetThreadLikes(ctx, thread.ID)
-
 		resp := dto.ThreadResponse{
 			ID:           thread.ID,
 			CategoryName: thread.Category.Name,
@@ -440,30 +454,64 @@ func (s *threadService) GetThreadsByUsername(ctx context.Context, currentUserID
 			Views:        thread.Views,
 			Author:       authorResponse,
 			Attachments:  attachments,
-			LikesCount:   likesCount,
+			RepliesCount: thread.RepliesCount,
 			CreatedAt:    thread.CreatedAt.Format("2006-01-02 15:04:05"),
 		}
 		threadResponses = append(threadResponses, resp)
 	}
 
-	totalPages := int(total) / limit
-	if int(total)%limit != 0 {
-		totalPages++
+	s.enrichThreads(ctx, userID, threads, threadResponses)
+	s.readService.AnnotateThreads(ctx, userID, threads, threadResponses)
+	return threadResponses
+}
+
+// threadPage serves a thread listing with keyset pagination. The total is
+// only counted when asked for.
+func (s *threadService) threadPage(ctx context.Context, userID uuid.UUID, q repository.ThreadListQuery, filter dto.CursorFilter, limit int) (*dto.PaginatedThreadResponse, error) {
+	if q.SortBy != "popular" && q.SortBy != "activity" {
+		q.SortBy = "newest"
+	}
+	kind := "threads:" + q.SortBy
+	after, err := decodeCursor(kind, filter.Cursor)
+	if err != nil {
+		return nil, err
 	}
 
-	return &dto.PaginatedThreadResponse{
-		Data: threadResponses,
-		Meta: dto.PaginationMeta{
-			CurrentPage: page,
-			TotalPages:  totalPages,
-			TotalItems:  total,
-			Limit:       limit,
-		},
-	}, nil
+	// Fetch one extra row to know whether there is a next page
+	threads, err := s.threadRepo.FindPage(ctx, q, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	threads, next := nextCursor(threads, limit, kind, func(t *model.Thread) repository.KeysetCursor {
+		switch q.SortBy {
+		case "popular":
+			return repository.KeysetCursor{Score: int64(t.Views), At: t.CreatedAt, ID: t.ID}
+		case "activity":
+			if t.LastReplyAt != nil {
+				return repository.KeysetCursor{At: *t.LastReplyAt, ID: t.ID}
+			}
+		}
+		return repository.KeysetCursor{At: t.CreatedAt, ID: t.ID}
+	})
+
+	meta := &dto.CursorMeta{NextCursor: next, Limit: limit}
+	if filter.IncludeTotal {
+		total, err := s.threadRepo.Count(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		meta.TotalItems = &total
+	}
+
+	data := s.listResponses(ctx, userID, threads)
+	if data == nil {
+		data = []dto.ThreadResponse{}
+	}
+	return &dto.PaginatedThreadResponse{Data: data, Cursor: meta}, nil
 }
 
-func (s *threadService) GetThreadBySlug(ctx context.Context, slug string) (*dto.ThreadResponse, error) {
-	thread, err := s.threadRepo.FindBySlug(ctx, slug)
+func (s *threadService) GetThreadBySlug(ctx context.Context, userID uuid.UUID, slug string) (*dto.ThreadResponse, error) {
+	thread, err := s.visibility.ThreadBySlug(ctx, userID, slug)
 	if err != nil {
 		return nil, err
 	}
@@ -472,7 +520,7 @@ func (s *threadService) GetThreadBySlug(ctx context.Context, slug string) (*dto.
 	for _, att := range thread.Attachments {
 		attachments = append(attachments, dto.AttachmentResponse{
 			ID:       att.ID,
-			FileURL:  att.FileURL,
+			FileURL:  storage.SignedURL(s.fileStorage, att.FileURL),
 			FileType: att.FileType,
 		})
 	}
@@ -485,9 +533,12 @@ func (s *threadService) GetThreadBySlug(ctx context.Context, slug string) (*dto.
 		authorResponse.AvatarURL = thread.User.AvatarURL
 	}
 
-	likesCount, _ := s.likeService.GetThreadLikes(ctx, thread.ID)
+	var pollID *uuid.UUID
+	if thread.Poll != nil {
+		pollID = &thread.Poll.ID
+	}
 
-	return &dto.ThreadResponse{
+	resp := dto.ThreadResponse{
 		ID:           thread.ID,
 		CategoryName: thread.Category.Name,
 		Title:        thread.Title,
@@ -497,18 +548,106 @@ func (s *threadService) GetThreadBySlug(ctx context.Context, slug string) (*dto.
 		Views:        thread.Views,
 		Author:       authorResponse,
 		Attachments:  attachments,
-		LikesCount:   likesCount,
+		RepliesCount: thread.RepliesCount,
+		PollID:       pollID,
 		CreatedAt:    thread.CreatedAt.Format("2006-01-02 15:04:05"),
-	}, nil
+	}
+
+	responses := []dto.ThreadResponse{resp}
+	s.enrichThreads(ctx, userID, []*model.Thread{thread}, responses)
+	responses[0].Related = s.relatedThreads(ctx, userID, thread)
+	return &responses[0], nil
+}
+
+// GetSimilarThreads finds threads the user can see that resemble a draft,
+// so they can check whether their question was already asked.
+func (s *threadService) GetSimilarThreads(ctx context.Context, userID uuid.UUID, req dto.SimilarThreadsRequest) ([]dto.SimilarThreadResponse, error) {
+	if req.Limit == 0 {
+		req.Limit = 5
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return s.meili.SimilarThreads(ctx, SimilarQuery{
+		Title:   req.Title,
+		Content: req.Content,
+		Roles:   searchRoles(user.Role.Name),
+		Limit:   req.Limit,
+	})
+}
+
+// relatedThreads lists threads like the given one for the thread page. The
+// page still loads when search is unavailable or slow, just without them:
+// the search gets SEARCH_RELATED_TIMEOUT at most.
+func (s *threadService) relatedThreads(ctx context.Context, userID uuid.UUID, thread *model.Thread) []dto.SimilarThreadResponse {
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, GetDurationFromEnv("SEARCH_RELATED_TIMEOUT", 500*time.Millisecond))
+	defer cancel()
+	related, err := s.meili.SimilarThreads(ctx, SimilarQuery{
+		Title:    thread.Title,
+		Content:  thread.Content,
+		ThreadID: thread.ID,
+		Roles:    searchRoles(user.Role.Name),
+		Limit:    GetIntFromEnv("SEARCH_RELATED_LIMIT", 5),
+	})
+	if err != nil {
+		log.Printf("Failed to load related threads: %v", err)
+		return nil
+	}
+	return related
+}
+
+// enrichThreads fills like counts, the caller's like state and the last reply
+// for a page of threads in one round trip. Failures leave the counts at zero.
+func (s *threadService) enrichThreads(ctx context.Context, userID uuid.UUID, threads []*model.Thread, responses []dto.ThreadResponse) {
+	if len(threads) == 0 {
+		return
+	}
+
+	ids := make([]uuid.UUID, len(threads))
+	for i, t := range threads {
+		ids[i] = t.ID
+	}
+
+	stats, err := s.likeService.GetThreadLikeStats(ctx, userID, ids)
+	if err != nil {
+		fmt.Printf("Failed to load thread likes: %v\n", err)
+	}
+	for i := range responses {
+		st := stats[responses[i].ID]
+		responses[i].LikesCount = st.Count
+		responses[i].LikedByMe = st.LikedByMe
+
+		if t := threads[i]; t.LastReplyAt != nil {
+			at := t.LastReplyAt.Format("2006-01-02 15:04:05")
+			responses[i].LastReplyAt = &at
+			if t.LastReplyUser != nil {
+				responses[i].LastReplyBy = &dto.AuthorResponse{
+					Username:  t.LastReplyUser.Username,
+					AvatarURL: t.LastReplyUser.AvatarURL,
+				}
+			}
+		}
+	}
 }
 
 func (s *threadService) IncrementView(ctx context.Context, threadID uuid.UUID, userID uuid.UUID) error {
+	if err := s.readService.MarkThreadSeen(ctx, userID, threadID); err != nil {
+		fmt.Printf("Failed to mark thread seen: %v\n", err)
+	}
 	return s.viewService.IncrementView(ctx, threadID, userID)
 }
 
 func (s *threadService) DeleteThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) error {
 	// 1. Get Thread
-	thread, err := s.threadRepo.FindByID(ctx, threadID)
+	thread, err := s.visibility.Thread(ctx, userID, threadID)
 	if err != nil {
 		return err
 	}
@@ -539,19 +678,11 @@ func (s *threadService) DeleteThread(ctx context.Context, userID uuid.UUID, thre
 	}
 
 	// 5. Delete Thread
-	if err := s.threadRepo.Delete(ctx, threadID); err != nil {
-		return err
-	}
-
-	if s.meili != nil {
-		_ = s.meili.DeleteThread(threadID.String())
-	}
-	
-	return nil
+	return s.threadRepo.Delete(ctx, threadID)
 }
 
 func (s *threadService) UpdateThread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID, req dto.UpdateThreadRequest) error {
-	thread, err := s.threadRepo.FindByID(ctx, threadID)
+	thread, err := s.visibility.Thread(ctx, userID, threadID)
 	if err != nil {
 		return err
 	}
@@ -575,15 +706,8 @@ func (s *threadService) UpdateThread(ctx context.Context, userID uuid.UUID, thre
 		return fmt.Errorf("user not found")
 	}
 
-	switch user.Role.Name {
-	case "siswa":
-		if req.Audience == "guru" {
-			return fmt.Errorf("siswa cannot set audience to guru")
-		}
-	case "guru":
-		if req.Audience == "siswa" {
-			return fmt.Errorf("guru cannot set audience to siswa")
-		}
+	if !canPostToAudience(user.Role.Name, req.Audience) {
+		return fmt.Errorf("%s cannot set audience to %s", user.Role.Name, req.Audience)
 	}
 	thread.Audience = req.Audience
 
@@ -599,32 +723,15 @@ func (s *threadService) UpdateThread(ctx context.Context, userID uuid.UUID, thre
 		desiredAttachments[id] = true
 	}
 
-	// Delete removed attachments
+	// Attach the new list with the edit, then delete the removed attachments
+	if err := s.threadRepo.Update(ctx, thread, req.AttachmentIDs); err != nil {
+		return err
+	}
 	for id, att := range currentAttachments {
 		if !desiredAttachments[id] {
 			_ = s.fileStorage.DeleteImage(ctx, att.FileURL)
 			_ = s.attachmentRepo.Delete(ctx, id)
 		}
 	}
-
-	// Add new attachments (orphan or just ensure link)
-	if len(req.AttachmentIDs) > 0 {
-		if err := s.attachmentRepo.UpdateThreadID(ctx, req.AttachmentIDs, thread.ID, userID); err != nil {
-			return err
-		}
-	}
-
-	if err := s.threadRepo.Update(ctx, thread); err != nil {
-		return err
-	}
-
-	if s.meili != nil {
-		// Reload thread to get fresh associations for indexing
-		reloadedThread, err := s.threadRepo.FindByID(ctx, threadID)
-		if err == nil {
-			_ = s.meili.IndexThread(reloadedThread)
-		}
-	}
-
 	return nil
 }
@@ -2,27 +2,67 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"slices"
 
 	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/pkg/storage"
+	"github.com/google/uuid"
 )
 
-func (s *threadService) GetTrendingThreads(ctx context.Context, limit int) ([]dto.ThreadResponse, error) {
-	if limit <= 0 {
-		limit = 10
+func (s *threadService) GetTrendingThreads(ctx context.Context, userID uuid.UUID, filter dto.TrendingFilter) ([]dto.ThreadResponse, error) {
+	allowed, err := s.visibility.Audiences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	// Only lists of audiences the viewer can see are merged
+	var audiences []string
+	for _, audience := range trendingAudiences {
+		if filter.Audience != "" && filter.Audience != audience {
+			continue
+		}
+		if allowed == nil || slices.Contains(allowed, audience) {
+			audiences = append(audiences, audience)
+		}
+	}
+
+	query := TrendingQuery{
+		Variant:   filter.Variant,
+		Audiences: audiences,
+		Limit:     filter.Limit,
+	}
+	if filter.CategoryID != "" {
+		id, err := uuid.Parse(filter.CategoryID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid category id")
+		}
+		query.CategoryID = &id
 	}
-	
-	threads, err := s.threadRepo.GetTrending(ctx, limit)
+
+	ids, err := s.trendingService.Rank(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	var threadResponses []dto.ThreadResponse
+	threads, err := s.threadRepo.FindByIDsOrdered(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	// The lists are per audience; the policy has the final word on each
+	// thread, e.g. when its audience changed after it was ranked
+	threads, err = s.visibility.FilterThreads(ctx, userID, threads)
+	if err != nil {
+		return nil, err
+	}
+
+	threadResponses := []dto.ThreadResponse{}
 	for _, thread := range threads {
 		var attachments []dto.AttachmentResponse
 		for _, att := range thread.Attachments {
 			attachments = append(attachments, dto.AttachmentResponse{
 				ID:       att.ID,
-				FileURL:  att.FileURL,
+				FileURL:  storage.SignedURL(s.fileStorage, att.FileURL),
 				FileType: att.FileType,
 			})
 		}
@@ -35,13 +75,6 @@ func (s *threadService) GetTrendingThreads(ctx context.Context, limit int) ([]dt
 			authorResponse.AvatarURL = thread.User.AvatarURL
 		}
 
-		// Note: We might want to optimize this by fetching likes count in bulk or joining in the repo query.
-		// However, for trending threads (usually small limit like 10), calling GetThreadLikes N times is acceptable for now.
-		// A better approach would be to include likes count in the GetTrending repo query result, 
-		// but that requires changing the Thread model or returning a different struct.
-		// Since we want standard ThreadResponse, let's keep it simple.
-		likesCount, _ := s.likeService.GetThreadLikes(ctx, thread.ID)
-
 		resp := dto.ThreadResponse{
 			ID:           thread.ID,
 			CategoryName: thread.Category.Name,
@@ -52,11 +85,12 @@ func (s *threadService) GetTrendingThreads(ctx context.Context, limit int) ([]dt
 			Views:        thread.Views,
 			Author:       authorResponse,
 			Attachments:  attachments,
-			LikesCount:   likesCount,
+			RepliesCount: thread.RepliesCount,
 			CreatedAt:    thread.CreatedAt.Format("2006-01-02 15:04:05"),
 		}
 		threadResponses = append(threadResponses, resp)
 	}
 
+	s.enrichThreads(ctx, userID, threads, threadResponses)
 	return threadResponses, nil
 }
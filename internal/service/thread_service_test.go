@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"anoa.com/telkomalumiforum/internal/dto"
+	"anoa.com/telkomalumiforum/internal/model"
+	"github.com/google/uuid"
+)
+
+func (r *fakeThreadRepo) FindByIDsOrdered(ctx context.Context, ids []uuid.UUID) ([]*model.Thread, error) {
+	var threads []*model.Thread
+	for _, id := range ids {
+		if thread, ok := r.threads[id]; ok {
+			copied := *thread
+			threads = append(threads, &copied)
+		}
+	}
+	return threads, nil
+}
+
+// staleIndex answers every search with the same hits, whatever the roles
+// asked for, like an index that missed an audience change.
+type staleIndex struct {
+	MeiliSearchService
+	hits []uuid.UUID
+}
+
+func (m *staleIndex) SearchThreadIDs(ctx context.Context, q SearchQuery) ([]uuid.UUID, int64, error) {
+	return m.hits, int64(len(m.hits)), nil
+}
+
+type noLikes struct {
+	LikeService
+}
+
+func (noLikes) GetThreadLikeStats(ctx context.Context, userID uuid.UUID, threadIDs []uuid.UUID) (map[uuid.UUID]LikeStats, error) {
+	return map[uuid.UUID]LikeStats{}, nil
+}
+
+func TestSearchThreadsVisibility(t *testing.T) {
+	siswa := &model.User{ID: uuid.New(), Role: model.Role{Name: "siswa"}}
+	public := &model.Thread{ID: uuid.New(), UserID: uuid.New(), Title: "public", Audience: "semua"}
+	forGuru := &model.Thread{ID: uuid.New(), UserID: uuid.New(), Title: "guru", Audience: "guru"}
+	own := &model.Thread{ID: uuid.New(), UserID: siswa.ID, Title: "own", Audience: "guru"}
+
+	threads := &fakeThreadRepo{threads: map[uuid.UUID]*model.Thread{public.ID: public, forGuru.ID: forGuru, own.ID: own}}
+	s := &threadService{
+		threadRepo:  threads,
+		likeService: noLikes{},
+		readService: &readService{},
+		meili:       &staleIndex{hits: []uuid.UUID{forGuru.ID, public.ID, own.ID}},
+		visibility: NewVisibilityPolicy(
+			&fakeUserRepo{users: map[string]*model.User{siswa.ID.String(): siswa}},
+			threads,
+			&fakePostRepo{},
+		),
+	}
+
+	resp, err := s.searchThreads(context.Background(), siswa.ID, dto.ThreadFilter{Search: "reuni", Page: 1, Limit: 10}, []string{"siswa", "semua"}, nil)
+	if err != nil {
+		t.Fatalf("searchThreads: %v", err)
+	}
+	var got []string
+	for _, thread := range resp.Data {
+		got = append(got, thread.Title)
+	}
+	if len(got) != 2 || got[0] != "public" || got[1] != "own" {
+		t.Errorf("hits %v, want [public own] in index order", got)
+	}
+}
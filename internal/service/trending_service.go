@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	TrendingHot    = "hot"    // Engagement decayed by age
+	TrendingRising = "rising" // Engagement per hour of young threads
+
+	TrendingJob = "threads.trending"
+
+	trendingKeyPrefix   = "trending:"
+	trendingIndexKey    = "trending:keys"
+	trendingRefreshedAt = "trending:refreshed_at"
+)
+
+var trendingAudiences = []string{"semua", "guru", "siswa"}
+
+// TrendingQuery selects one trending list. Audiences are the ones the viewer
+// may see; a nil CategoryID means all categories.
+type TrendingQuery struct {
+	Variant    string
+	Audiences  []string
+	CategoryID *uuid.UUID
+	Limit      int
+}
+
+type TrendingService interface {
+	Rank(ctx context.Context, query TrendingQuery) ([]uuid.UUID, error)
+	Refresh(ctx context.Context) error
+}
+
+// trendingConfig holds the tunable scoring parameters, read from the
+// environment once at startup.
+type trendingConfig struct {
+	Window        time.Duration // TRENDING_WINDOW, threads older than this never trend
+	RisingWindow  time.Duration // TRENDING_RISING_WINDOW
+	WeightViews   float64       // TRENDING_WEIGHT_VIEWS
+	WeightLikes   float64       // TRENDING_WEIGHT_LIKES
+	WeightReplies float64       // TRENDING_WEIGHT_REPLIES
+	Gravity       float64       // TRENDING_GRAVITY
+	Size          int           // TRENDING_SIZE, threads kept per list
+}
+
+type trendingService struct {
+	redisClient *redis.Client
+	threadRepo  repository.ThreadRepository
+	config      trendingConfig
+}
+
+func NewTrendingService(redisClient *redis.Client, threadRepo repository.ThreadRepository, jobManager *jobs.Manager) TrendingService {
+	s := &trendingService{
+		redisClient: redisClient,
+		threadRepo:  threadRepo,
+		config: trendingConfig{
+			Window:        GetDurationFromEnv("TRENDING_WINDOW", 7*24*time.Hour),
+			RisingWindow:  GetDurationFromEnv("TRENDING_RISING_WINDOW", 24*time.Hour),
+			WeightViews:   GetFloatFromEnv("TRENDING_WEIGHT_VIEWS", 1),
+			WeightLikes:   GetFloatFromEnv("TRENDING_WEIGHT_LIKES", 5),
+			WeightReplies: GetFloatFromEnv("TRENDING_WEIGHT_REPLIES", 30),
+			Gravity:       GetFloatFromEnv("TRENDING_GRAVITY", 1.8),
+			Size:          GetIntFromEnv("TRENDING_SIZE", 100),
+		},
+	}
+
+	if redisClient != nil {
+		interval := GetDurationFromEnv("TRENDING_REFRESH_INTERVAL", 5*time.Minute)
+		jobManager.Schedule(TrendingJob, "@every "+interval.String(), 5*time.Minute, s.Refresh)
+	}
+	return s
+}
+
+func (c trendingConfig) engagement(t repository.TrendingCandidate) float64 {
+	return float64(t.Views)*c.WeightViews +
+		float64(t.Likes)*c.WeightLikes +
+		float64(t.Replies)*c.WeightReplies
+}
+
+// scores returns the score of t in every variant it qualifies for.
+func (c trendingConfig) scores(t repository.TrendingCandidate, now time.Time) map[string]float64 {
+	age := now.Sub(t.CreatedAt).Hours()
+	if age < 0 {
+		age = 0
+	}
+
+	engagement := c.engagement(t)
+	if engagement <= 0 {
+		return nil
+	}
+
+	scores := map[string]float64{
+		TrendingHot: engagement / math.Pow(age+2, c.Gravity),
+	}
+	if now.Sub(t.CreatedAt) <= c.RisingWindow {
+		scores[TrendingRising] = engagement / (age + 1)
+	}
+	return scores
+}
+
+func trendingKey(variant, audience string, categoryID *uuid.UUID) string {
+	key := trendingKeyPrefix + variant + ":" + audience
+	if categoryID != nil {
+		key += ":" + categoryID.String()
+	}
+	return key
+}
+
+// lists scores all candidates into every list they belong to: per variant
+// and audience, with and without their category.
+func (s *trendingService) lists(ctx context.Context) (map[string][]redis.Z, error) {
+	now := time.Now()
+	candidates, err := s.threadRepo.FindTrendingCandidates(ctx, now.Add(-s.config.Window))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trending candidates: %w", err)
+	}
+
+	lists := make(map[string][]redis.Z)
+	for _, t := range candidates {
+		for variant, score := range s.config.scores(t, now) {
+			member := redis.Z{Score: score, Member: t.ID.String()}
+			key := trendingKey(variant, t.Audience, nil)
+			lists[key] = append(lists[key], member)
+			if t.CategoryID != nil {
+				key = trendingKey(variant, t.Audience, t.CategoryID)
+				lists[key] = append(lists[key], member)
+			}
+		}
+	}
+
+	for key, members := range lists {
+		sort.Slice(members, func(i, j int) bool { return members[i].Score > members[j].Score })
+		if len(members) > s.config.Size {
+			lists[key] = members[:s.config.Size]
+		}
+	}
+	return lists, nil
+}
+
+// Refresh recomputes every trending list and swaps them in at once. Lists
+// that no longer have any thread are removed.
+func (s *trendingService) Refresh(ctx context.Context) error {
+	lists, err := s.lists(ctx)
+	if err != nil {
+		return err
+	}
+
+	previous, err := s.redisClient.SMembers(ctx, trendingIndexKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to load trending index: %w", err)
+	}
+
+	pipe := s.redisClient.TxPipeline()
+	for _, key := range previous {
+		if _, ok := lists[key]; !ok {
+			pipe.Del(ctx, key)
+		}
+	}
+	pipe.Del(ctx, trendingIndexKey)
+	for key, members := range lists {
+		pipe.Del(ctx, key)
+		pipe.ZAdd(ctx, key, members...)
+		pipe.SAdd(ctx, trendingIndexKey, key)
+	}
+	pipe.Set(ctx, trendingRefreshedAt, time.Now().Unix(), 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store trending lists: %w", err)
+	}
+
+	log.Printf("Refreshed %d trending lists", len(lists))
+	return nil
+}
+
+// Rank returns the top threads of the query, best first. Before the first
+// refresh, or without Redis, the lists are computed from the database.
+func (s *trendingService) Rank(ctx context.Context, query TrendingQuery) ([]uuid.UUID, error) {
+	if query.Variant == "" {
+		query.Variant = TrendingHot
+	}
+	if query.Limit <= 0 {
+		query.Limit = 10
+	}
+	if query.Limit > s.config.Size {
+		query.Limit = s.config.Size
+	}
+	if len(query.Audiences) == 0 {
+		return []uuid.UUID{}, nil
+	}
+
+	if s.redisClient != nil {
+		ready, err := s.redisClient.Exists(ctx, trendingRefreshedAt).Result()
+		if err == nil && ready > 0 {
+			return s.rankFromRedis(ctx, query)
+		}
+		if err != nil {
+			log.Printf("Failed to check trending lists, computing from database: %v", err)
+		}
+	}
+
+	lists, err := s.lists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []redis.Z
+	for _, audience := range query.Audiences {
+		members = append(members, lists[trendingKey(query.Variant, audience, query.CategoryID)]...)
+	}
+	return topMembers(members, query.Limit), nil
+}
+
+func (s *trendingService) rankFromRedis(ctx context.Context, query TrendingQuery) ([]uuid.UUID, error) {
+	pipe := s.redisClient.Pipeline()
+	cmds := make([]*redis.ZSliceCmd, len(query.Audiences))
+	for i, audience := range query.Audiences {
+		cmds[i] = pipe.ZRevRangeWithScores(ctx, trendingKey(query.Variant, audience, query.CategoryID), 0, int64(query.Limit)-1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read trending lists: %w", err)
+	}
+
+	var members []redis.Z
+	for _, cmd := range cmds {
+		members = append(members, cmd.Val()...)
+	}
+	return topMembers(members, query.Limit), nil
+}
+
+// topMembers merges lists of different audiences by score.
+func topMembers(members []redis.Z, limit int) []uuid.UUID {
+	sort.Slice(members, func(i, j int) bool { return members[i].Score > members[j].Score })
+
+	ids := make([]uuid.UUID, 0, limit)
+	for _, m := range members {
+		if len(ids) == limit {
+			break
+		}
+		str, _ := m.Member.(string)
+		if id, err := uuid.Parse(str); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
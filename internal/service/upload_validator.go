@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Upload contexts, each with its own allowlist of content types
+const (
+	UploadAvatar = "avatar"
+	UploadThread = "thread"
+	UploadPost   = "post"
+)
+
+var (
+	ErrUploadTooLarge   = errors.New("file is too large")
+	ErrUploadType       = errors.New("file type is not allowed")
+	ErrUploadInvalid    = errors.New("file is invalid")
+	ErrUploadDimensions = errors.New("image dimensions are too large")
+	ErrUploadPolyglot   = errors.New("file contains embedded content of another type")
+	ErrUploadContext    = errors.New("invalid upload context")
+	// ErrUploadActiveContent rejects documents that run script or other
+	// actions when opened
+	ErrUploadActiveContent = errors.New("file contains active content")
+)
+
+// uploadExtensions is the extension files of each supported content type are
+// stored with, whatever name the client gave them.
+var uploadExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// polyglotMarkers betray a second format hidden in a file: markup a browser
+// could render or a script interpreter could run.
+var polyglotMarkers = [][]byte{
+	[]byte("<script"),
+	[]byte("<html"),
+	[]byte("<!doctype"),
+	[]byte("<svg"),
+	[]byte("<iframe"),
+	[]byte("<?php"),
+}
+
+// ValidatedUpload is a file that passed validation, ready to be stored.
+type ValidatedUpload struct {
+	Data        []byte
+	ContentType string
+	FileName    string
+}
+
+// UploadValidator checks uploads against the policy of their context and
+// the uploader's role. The content type is sniffed from the content, never
+// taken from the client. Images are rebuilt without metadata such as EXIF
+// and GPS, see image_sanitize.go; PDFs with active content are rejected, see
+// pdf_check.go.
+type UploadValidator interface {
+	Validate(uploadContext, role string, r io.Reader, fileName string) (*ValidatedUpload, error)
+}
+
+type uploadValidator struct {
+	allowed      map[string]map[string]bool
+	maxSize      map[string]int64
+	defaultSize  int64
+	avatarSize   int64
+	maxDimension int
+	maxPixels    int
+}
+
+func NewUploadValidator() UploadValidator {
+	const mb = 1 << 20
+	return &uploadValidator{
+		allowed: map[string]map[string]bool{
+			UploadAvatar: uploadTypesFromEnv("UPLOAD_TYPES_AVATAR", "image/jpeg,image/png,image/webp"),
+			UploadThread: uploadTypesFromEnv("UPLOAD_TYPES_THREAD", "image/jpeg,image/png,image/gif,image/webp"),
+			UploadPost:   uploadTypesFromEnv("UPLOAD_TYPES_POST", "image/jpeg,image/png,image/gif,image/webp"),
+		},
+		maxSize: map[string]int64{
+			"admin": int64(GetIntFromEnv("UPLOAD_MAX_MB_ADMIN", 20)) * mb,
+			"guru":  int64(GetIntFromEnv("UPLOAD_MAX_MB_GURU", 10)) * mb,
+			"siswa": int64(GetIntFromEnv("UPLOAD_MAX_MB_SISWA", 5)) * mb,
+		},
+		defaultSize:  int64(GetIntFromEnv("UPLOAD_MAX_MB_SISWA", 5)) * mb,
+		avatarSize:   int64(GetIntFromEnv("UPLOAD_MAX_MB_AVATAR", 2)) * mb,
+		maxDimension: GetIntFromEnv("UPLOAD_MAX_DIMENSION", 8000),
+		maxPixels:    GetIntFromEnv("UPLOAD_MAX_PIXELS", 25_000_000),
+	}
+}
+
+// uploadTypesFromEnv reads a comma separated list of content types. Types
+// the validator cannot check are ignored.
+func uploadTypesFromEnv(key, fallback string) map[string]bool {
+	list := os.Getenv(key)
+	if list == "" {
+		list = fallback
+	}
+	types := make(map[string]bool)
+	for _, t := range strings.Split(list, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if _, ok := uploadExtensions[t]; ok {
+			types[t] = true
+		}
+	}
+	return types
+}
+
+func (v *uploadValidator) Validate(uploadContext, role string, r io.Reader, fileName string) (*ValidatedUpload, error) {
+	allowed, ok := v.allowed[uploadContext]
+	if !ok {
+		return nil, ErrUploadContext
+	}
+
+	limit, ok := v.maxSize[role]
+	if !ok {
+		limit = v.defaultSize
+	}
+	if uploadContext == UploadAvatar && v.avatarSize < limit {
+		limit = v.avatarSize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: maximum is %d MB", ErrUploadTooLarge, limit>>20)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrUploadInvalid)
+	}
+
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if !allowed[contentType] {
+		return nil, fmt.Errorf("%w: %s is not accepted here", ErrUploadType, contentType)
+	}
+
+	// Images are rebuilt from the parts that carry pixels, so nothing hidden
+	// in them survives; their compressed data is not searched for markup,
+	// which random bytes match often enough in large files.
+	if strings.HasPrefix(contentType, "image/") {
+		data, err = sanitizeImage(contentType, data, v.maxDimension, v.maxPixels)
+	} else {
+		err = checkPDF(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	if strings.Trim(name, `./\`) == "" {
+		name = "file"
+	}
+	return &ValidatedUpload{
+		Data:        data,
+		ContentType: contentType,
+		FileName:    name + uploadExtensions[contentType],
+	}, nil
+}
+
+// checkPolyglot rejects files that also carry markup or a ZIP archive, the
+// usual way of smuggling a second format past a type check. It is for data
+// that is not compressed, where the markers cannot turn up by chance.
+func checkPolyglot(data []byte) error {
+	// ASCII only; bytes.ToLower would rewrite binary data as UTF-8
+	lower := make([]byte, len(data))
+	for i, c := range data {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	for _, marker := range polyglotMarkers {
+		if bytes.Contains(lower, marker) {
+			return fmt.Errorf("%w: found %q", ErrUploadPolyglot, marker)
+		}
+	}
+
+	// A ZIP local file header: signature, then a plausible version
+	for i := 0; ; {
+		j := bytes.Index(data[i:], []byte("PK\x03\x04"))
+		if j < 0 {
+			return nil
+		}
+		i += j + 4
+		if i+2 <= len(data) && data[i] <= 63 && data[i+1] == 0 {
+			return fmt.Errorf("%w: found a ZIP archive", ErrUploadPolyglot)
+		}
+	}
+}
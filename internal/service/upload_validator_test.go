@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestUploadValidatorLimits(t *testing.T) {
+	t.Setenv("UPLOAD_MAX_MB_ADMIN", "3")
+	t.Setenv("UPLOAD_MAX_MB_GURU", "2")
+	t.Setenv("UPLOAD_MAX_MB_SISWA", "1")
+	t.Setenv("UPLOAD_MAX_MB_AVATAR", "1")
+	v := NewUploadValidator()
+
+	const mb = 1 << 20
+	tests := []struct {
+		name    string
+		context string
+		role    string
+		size    int
+		tooBig  bool
+	}{
+		{"siswa at the limit", UploadThread, "siswa", mb, false},
+		{"siswa over the limit", UploadThread, "siswa", mb + 1, true},
+		{"guru", UploadThread, "guru", 2 * mb, false},
+		{"guru over the limit", UploadThread, "guru", 2*mb + 1, true},
+		{"admin", UploadPost, "admin", 3 * mb, false},
+		{"admin over the limit", UploadPost, "admin", 3*mb + 1, true},
+		{"unknown role gets the siswa limit", UploadThread, "tamu", mb + 1, true},
+		{"avatar caps the role limit", UploadAvatar, "admin", mb + 1, true},
+		{"avatar at its limit", UploadAvatar, "admin", mb, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Zeros pass the size check and then fail as an unknown type
+			_, err := v.Validate(tt.context, tt.role, bytes.NewReader(make([]byte, tt.size)), "a.png")
+			if got := errors.Is(err, ErrUploadTooLarge); got != tt.tooBig {
+				t.Errorf("Validate = %v, too large %v, want %v", err, got, tt.tooBig)
+			}
+		})
+	}
+}
+
+func TestUploadValidator(t *testing.T) {
+	v := NewUploadValidator()
+	png := encodePNG(solid(10, 10))
+
+	tests := []struct {
+		name     string
+		context  string
+		data     []byte
+		fileName string
+		want     error
+		wantName string
+		wantType string
+	}{
+		{"png", UploadThread, png, "photo.png", nil, "photo.png", "image/png"},
+		{"extension from the content", UploadPost, png, "photo.exe", nil, "photo.png", "image/png"},
+		{"path in the name", UploadPost, png, "../../etc/passwd", nil, "passwd.png", "image/png"},
+		{"empty name", UploadPost, png, "", nil, "file.png", "image/png"},
+		{"gif not allowed for avatars", UploadAvatar, encodeGIF(1, 10, 10), "a.gif", ErrUploadType, "", ""},
+		{"pdf not allowed by default", UploadThread, pdfWith(""), "a.pdf", ErrUploadType, "", ""},
+		{"html", UploadThread, []byte("<html><body>hi</body></html>"), "a.png", ErrUploadType, "", ""},
+		{"empty file", UploadThread, nil, "a.png", ErrUploadInvalid, "", ""},
+		{"unknown context", "banner", png, "a.png", ErrUploadContext, "", ""},
+		{"png with appended zip", UploadThread, appended(png, zipHeader...), "a.png", ErrUploadPolyglot, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Validate(tt.context, "siswa", bytes.NewReader(tt.data), tt.fileName)
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("Validate = %v, want %v", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if got.FileName != tt.wantName || got.ContentType != tt.wantType {
+				t.Errorf("Validate = %s %s, want %s %s", got.FileName, got.ContentType, tt.wantName, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestUploadValidatorPDF(t *testing.T) {
+	t.Setenv("UPLOAD_TYPES_THREAD", "image/png,application/pdf")
+	v := NewUploadValidator()
+
+	if _, err := v.Validate(UploadThread, "siswa", bytes.NewReader(pdfWith("")), "doc.pdf"); err != nil {
+		t.Errorf("plain PDF: %v", err)
+	}
+	_, err := v.Validate(UploadThread, "siswa", bytes.NewReader(pdfWith("/OpenAction 4 0 R")), "doc.pdf")
+	if !errors.Is(err, ErrUploadActiveContent) {
+		t.Errorf("PDF with an open action: %v, want %v", err, ErrUploadActiveContent)
+	}
+}
@@ -28,10 +28,10 @@ type authService struct {
 	secret       string
 	tokenTTL     time.Duration
 	defaultRole  string
-	meili        MeiliSearchService
+	search       SearchService
 }
 
-func NewAuthService(repo repository.UserRepository, imageStorage storage.ImageStorage, meili MeiliSearchService) AuthService {
+func NewAuthService(repo repository.UserRepository, imageStorage storage.ImageStorage, search SearchService) AuthService {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		secret = "change-me"
@@ -55,7 +55,7 @@ func NewAuthService(repo repository.UserRepository, imageStorage storage.ImageSt
 		secret:       secret,
 		tokenTTL:     ttl,
 		defaultRole:  defaultRole,
-		meili:        meili,
+		search:       search,
 	}
 }
 
@@ -72,40 +72,38 @@ func (s *authService) Login(ctx context.Context, input dto.LoginInput) (*dto.Aut
 		return nil, errors.New("invalid credentials")
 	}
 
-	return s.buildAuthResponse(user)
+	return s.buildAuthResponse(ctx, user)
 }
 
-func (s *authService) buildAuthResponse(user *model.User) (*dto.AuthResponse, error) {
+func (s *authService) buildAuthResponse(ctx context.Context, user *model.User) (*dto.AuthResponse, error) {
 	token, expiresAt, err := s.generateToken(user)
 	if err != nil {
 		return nil, err
 	}
 
-	var searchToken string
-	if s.meili != nil {
-		roleName := "siswa" // Default fallback
-		if user.RoleID != nil {
-			roleName = user.Role.Name
-		}
-		st, err := s.meili.GenerateSearchToken(roleName)
+	// Login still works when Meilisearch is down; the frontend can refresh
+	// the search token later
+	var searchToken dto.SearchTokenResponse
+	if s.search != nil {
+		st, err := s.search.Token(ctx, user.ID)
 		if err != nil {
-			log.Printf("Failed to generate search token for user %s (role %s): %v", user.Username, roleName, err)
-			searchToken = ""
+			log.Printf("Failed to generate search token for user %s: %v", user.Username, err)
 		} else {
-			searchToken = st
+			searchToken = *st
 		}
 	}
 
 	user.PasswordHash = ""
 
 	return &dto.AuthResponse{
-		AccessToken: token,
-		TokenType:   "Bearer",
-		ExpiresIn:   expiresAt,
-		User:        user,
-		Role:        &user.Role,
-		Profile:     user.Profile,
-		SearchToken: searchToken,
+		AccessToken:          token,
+		TokenType:            "Bearer",
+		ExpiresIn:            expiresAt,
+		User:                 user,
+		Role:                 &user.Role,
+		Profile:              user.Profile,
+		SearchToken:          searchToken.SearchToken,
+		SearchTokenExpiresAt: searchToken.ExpiresAt,
 	}, nil
 }
 
@@ -1,149 +1,173 @@
 package service
 
 import (
-    "context"
-    "fmt"
-    "time"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
 
-    "anoa.com/telkomalumiforum/internal/repository"
-    "github.com/google/uuid"
-    "github.com/redis/go-redis/v9"
+	"anoa.com/telkomalumiforum/internal/jobs"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
 type ViewService interface {
-    IncrementView(ctx context.Context, threadID uuid.UUID, userID uuid.UUID) error
-    StartViewSyncWorker(ctx context.Context)
+	IncrementView(ctx context.Context, threadID uuid.UUID, userID uuid.UUID) error
 }
 
+const (
+	viewPendingKey = "pending:thread_views"
+	viewSyncingKey = "syncing:thread_views"
+	viewKeyPrefix  = "thread:views:"
+	viewSyncBatch  = 500
+)
+
+// viewDrainScript moves up to ARGV[1] pending counters into the syncing hash
+// in one step, so views counted while a sync runs land in fresh keys and are
+// never lost. Each flush takes the whole hash; a flush that fails puts the
+// counts back for the next run.
+var viewDrainScript = redis.NewScript(`
+local ids = redis.call('SPOP', KEYS[1], ARGV[1])
+for _, id in ipairs(ids) do
+    local key = ARGV[2] .. id
+    local n = redis.call('GET', key)
+    if n then
+        redis.call('DEL', key)
+        redis.call('HINCRBY', KEYS[2], id, n)
+    end
+end
+return #ids
+`)
+
 type viewService struct {
-    redisClient *redis.Client
-    threadRepo  repository.ThreadRepository
+	redisClient *redis.Client
+	threadRepo  repository.ThreadRepository
+	meili       MeiliSearchService
 }
 
-func NewViewService(redisClient *redis.Client, threadRepo repository.ThreadRepository) ViewService {
-    return &viewService{
-        redisClient: redisClient,
-        threadRepo:  threadRepo,
-    }
+func NewViewService(redisClient *redis.Client, threadRepo repository.ThreadRepository, meili MeiliSearchService, jobManager *jobs.Manager) ViewService {
+	s := &viewService{
+		redisClient: redisClient,
+		threadRepo:  threadRepo,
+		meili:       meili,
+	}
+
+	if redisClient != nil {
+		jobManager.Schedule("views.sync", "@every 1m", 5*time.Minute, s.syncViewsToDB)
+	}
+	return s
 }
 
 func (s *viewService) IncrementView(ctx context.Context, threadID uuid.UUID, userID uuid.UUID) error {
-    // 1. Check if user viewed in last hour
-    userViewKey := fmt.Sprintf("thread:user_view:%s:%s", threadID, userID)
-    
-    exists, err := s.redisClient.Exists(ctx, userViewKey).Result()
-    if err != nil {
-        return fmt.Errorf("failed to check user view: %w", err)
-    }
-    
-    // If user already viewed within last hour, don't increment
-    if exists == 1 {
-        return nil
-    }
-    
-    // 2. Increment view count in Redis
-    viewKey := fmt.Sprintf("thread:views:%s", threadID)
-    _, err = s.redisClient.Incr(ctx, viewKey).Result()
-    if err != nil {
-        return fmt.Errorf("failed to increment view: %w", err)
-    }
-    
-    // 3. Add to pending sync set
-    pendingKey := "pending:thread_views"
-    _, err = s.redisClient.SAdd(ctx, pendingKey, threadID.String()).Result()
-    if err != nil {
-        return fmt.Errorf("failed to add to pending: %w", err)
-    }
-    
-    // 4. Mark user as viewed (expires in 1 hour)
-    _, err = s.redisClient.SetEx(ctx, userViewKey, "viewed", time.Hour).Result()
-    if err != nil {
-        return fmt.Errorf("failed to set user view: %w", err)
-    }
-    
-    return nil
+	// 1. Mark user as viewed (expires in 1 hour); a view within the last hour doesn't count
+	userViewKey := fmt.Sprintf("thread:user_view:%s:%s", threadID, userID)
+
+	first, err := s.redisClient.SetNX(ctx, userViewKey, "viewed", time.Hour).Result()
+	if err != nil {
+		return fmt.Errorf("failed to set user view: %w", err)
+	}
+	if !first {
+		return nil
+	}
+
+	// 2. Increment view count and add to pending sync set together
+	pipe := s.redisClient.TxPipeline()
+	pipe.Incr(ctx, viewKeyPrefix+threadID.String())
+	pipe.SAdd(ctx, viewPendingKey, threadID.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to increment view: %w", err)
+	}
+
+	return nil
+}
+
+func (s *viewService) syncViewsToDB(ctx context.Context) error {
+	synced := 0
+	for {
+		drained, err := viewDrainScript.Run(ctx, s.redisClient, []string{viewPendingKey, viewSyncingKey}, viewSyncBatch, viewKeyPrefix).Int()
+		if err != nil {
+			return fmt.Errorf("failed to drain thread views: %w", err)
+		}
+
+		// Includes counts left behind by a previous failed run
+		n, err := s.flushSyncing(ctx)
+		if err != nil {
+			return err
+		}
+		synced += n
+
+		if drained < viewSyncBatch {
+			break
+		}
+	}
+
+	if synced > 0 {
+		log.Printf("Synced views for %d threads", synced)
+	}
+	return nil
 }
 
-func (s *viewService) syncViewsToDB(ctx context.Context) {
-    pendingKey := "pending:thread_views"
-    
-    // Get all thread IDs that need sync
-    threadIDs, err := s.redisClient.SMembers(ctx, pendingKey).Result()
-    if err != nil {
-        fmt.Printf("Error getting pending thread views: %v\n", err)
-        return
-    }
-    
-    if len(threadIDs) == 0 {
-        return
-    }
-    
-    for _, threadIDStr := range threadIDs {
-        threadID, err := uuid.Parse(threadIDStr)
-        if err != nil {
-            fmt.Printf("Invalid thread ID: %s: %v\n", threadIDStr, err)
-            continue
-        }
-        
-        // Get current view count from Redis
-        viewKey := fmt.Sprintf("thread:views:%s", threadID)
-        viewCountStr, err := s.redisClient.Get(ctx, viewKey).Result()
-        if err != nil && err != redis.Nil {
-            fmt.Printf("Error getting view count for thread %s: %v\n", threadID, err)
-            continue
-        }
-        
-        if viewCountStr == "" {
-            // No views in Redis, skip
-            continue
-        }
-        
-        var viewCount int
-        fmt.Sscanf(viewCountStr, "%d", &viewCount)
-        
-        if viewCount > 0 {
-            // Update database
-            thread, err := s.threadRepo.FindByID(ctx, threadID)
-            if err != nil {
-                fmt.Printf("Thread not found: %s: %v\n", threadID, err)
-                continue
-            }
-            
-            // Add Redis view count to DB view count
-            thread.Views += viewCount
-            
-            if err := s.threadRepo.Update(ctx, thread); err != nil {
-                fmt.Printf("Failed to update thread views in DB: %v\n", err)
-                continue
-            }
-            
-            // Reset Redis counter
-            _, err = s.redisClient.Del(ctx, viewKey).Result()
-            if err != nil {
-                fmt.Printf("Failed to reset Redis counter: %v\n", err)
-            }
-        }
-    }
-    
-    // Clear pending set
-    _, err = s.redisClient.Del(ctx, pendingKey).Result()
-    if err != nil {
-        fmt.Printf("Failed to clear pending set: %v\n", err)
-    }
-    
-    fmt.Printf("Synced views for %d threads\n", len(threadIDs))
+// flushSyncing takes the syncing hash, reading and deleting it in one step,
+// and writes it to the database in one statement. The counts are put back
+// only when that statement fails, so a batch is never applied twice; a crash
+// in between drops it instead, which is preferred for view counts.
+func (s *viewService) flushSyncing(ctx context.Context) (int, error) {
+	pipe := s.redisClient.TxPipeline()
+	take := pipe.HGetAll(ctx, viewSyncingKey)
+	pipe.Del(ctx, viewSyncingKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to take syncing thread views: %w", err)
+	}
+	counts := take.Val()
+	if len(counts) == 0 {
+		return 0, nil
+	}
+
+	deltas := make(map[uuid.UUID]int64, len(counts))
+	for idStr, countStr := range counts {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Printf("Invalid thread ID in view sync: %s", idStr)
+			continue
+		}
+		count, err := strconv.ParseInt(countStr, 10, 64)
+		if err != nil || count <= 0 {
+			continue
+		}
+		deltas[id] = count
+	}
+
+	totals, err := s.threadRepo.AddViews(ctx, deltas)
+	if err != nil {
+		if rerr := s.restoreSyncing(deltas); rerr != nil {
+			log.Printf("Failed to put back %d thread view counts: %v", len(deltas), rerr)
+		}
+		return 0, fmt.Errorf("failed to update thread views: %w", err)
+	}
+
+	if s.meili != nil && len(totals) > 0 {
+		views := make(map[string]int, len(totals))
+		for id, total := range totals {
+			views[id.String()] = total
+		}
+		if err := s.meili.UpdateThreadViews(ctx, views); err != nil {
+			log.Printf("Failed to update thread views in search index: %v", err)
+		}
+	}
+
+	return len(totals), nil
 }
 
-func (s *viewService) StartViewSyncWorker(ctx context.Context) {
-    ticker := time.NewTicker(1 * time.Minute)
-    defer ticker.Stop()
-    
-    for {
-        select {
-        case <-ticker.C:
-            s.syncViewsToDB(ctx)
-        case <-ctx.Done():
-            return
-        }
-    }
+// restoreSyncing adds counts that did not reach the database back to the
+// syncing hash, on top of anything drained since.
+func (s *viewService) restoreSyncing(deltas map[uuid.UUID]int64) error {
+	ctx := context.Background()
+	pipe := s.redisClient.TxPipeline()
+	for id, delta := range deltas {
+		pipe.HIncrBy(ctx, viewSyncingKey, id.String(), delta)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
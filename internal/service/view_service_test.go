@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/meilisearch/meilisearch-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// viewThreadRepo keeps view counters in memory; with fail set AddViews
+// fails without applying anything.
+type viewThreadRepo struct {
+	repository.ThreadRepository
+	views map[uuid.UUID]int
+	fail  bool
+}
+
+func (r *viewThreadRepo) AddViews(ctx context.Context, deltas map[uuid.UUID]int64) (map[uuid.UUID]int, error) {
+	if r.fail {
+		return nil, errors.New("database is down")
+	}
+	totals := make(map[uuid.UUID]int, len(deltas))
+	for id, delta := range deltas {
+		if _, ok := r.views[id]; !ok {
+			continue
+		}
+		r.views[id] += int(delta)
+		totals[id] = r.views[id]
+	}
+	return totals, nil
+}
+
+func newTestViewService(t *testing.T, repo *viewThreadRepo) (*viewService, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &viewService{redisClient: client, threadRepo: repo}, client
+}
+
+func TestSyncViews(t *testing.T) {
+	thread := uuid.New()
+	repo := &viewThreadRepo{views: map[uuid.UUID]int{thread: 10}}
+	s, client := newTestViewService(t, repo)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := s.IncrementView(ctx, thread, uuid.New()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The same user within the hour does not count
+	viewer := uuid.New()
+	for i := 0; i < 2; i++ {
+		if err := s.IncrementView(ctx, thread, viewer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.syncViewsToDB(ctx); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if repo.views[thread] != 14 {
+		t.Fatalf("views %d after sync, want 14", repo.views[thread])
+	}
+
+	// Nothing is left to apply a second time
+	if err := s.syncViewsToDB(ctx); err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	if repo.views[thread] != 14 {
+		t.Errorf("views %d after a second sync, want still 14", repo.views[thread])
+	}
+	if n, _ := client.Exists(ctx, viewSyncingKey, viewPendingKey, viewKeyPrefix+thread.String()).Result(); n != 0 {
+		t.Errorf("%d sync keys left behind", n)
+	}
+}
+
+func TestSyncViewsFailure(t *testing.T) {
+	thread := uuid.New()
+	repo := &viewThreadRepo{views: map[uuid.UUID]int{thread: 0}, fail: true}
+	s, client := newTestViewService(t, repo)
+	ctx := context.Background()
+
+	if err := s.IncrementView(ctx, thread, uuid.New()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.syncViewsToDB(ctx); err == nil {
+		t.Fatal("sync succeeded with the database down")
+	}
+	if n, _ := client.HGet(ctx, viewSyncingKey, thread.String()).Int(); n != 1 {
+		t.Fatalf("syncing hash holds %d views after the failure, want the 1 put back", n)
+	}
+
+	// Views counted meanwhile are added on top of the ones put back
+	if err := s.IncrementView(ctx, thread, uuid.New()); err != nil {
+		t.Fatal(err)
+	}
+	repo.fail = false
+	if err := s.syncViewsToDB(ctx); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if repo.views[thread] != 2 {
+		t.Errorf("views %d, want 2", repo.views[thread])
+	}
+	if err := s.syncViewsToDB(ctx); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if repo.views[thread] != 2 {
+		t.Errorf("views %d after a second sync, want still 2", repo.views[thread])
+	}
+}
+
+func TestUpdateThreadViews(t *testing.T) {
+	indexed, unindexed := uuid.NewString(), uuid.NewString()
+
+	var mu sync.Mutex
+	var updated []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/indexes/threads/documents/fetch":
+			var query struct {
+				Ids []string `json:"ids"`
+			}
+			json.NewDecoder(r.Body).Decode(&query)
+			results := []map[string]any{}
+			for _, id := range query.Ids {
+				if id == indexed {
+					results = append(results, map[string]any{"id": id, "updated_at": 1})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"results": results, "limit": len(query.Ids), "total": len(results)})
+		case r.Method == http.MethodPut && r.URL.Path == "/indexes/threads/documents":
+			mu.Lock()
+			json.NewDecoder(r.Body).Decode(&updated)
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]any{"taskUid": 1, "indexUid": "threads", "status": "enqueued"})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	s := &meiliSearchService{client: meilisearch.New(server.URL)}
+	if err := s.UpdateThreadViews(context.Background(), map[string]int{indexed: 5, unindexed: 7}); err != nil {
+		t.Fatalf("UpdateThreadViews: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(updated) != 1 || updated[0]["id"] != indexed || updated[0]["views"] != float64(5) {
+		t.Errorf("updated %v, want only the indexed thread", updated)
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Threads and posts a user may not see are reported exactly like missing
+// ones, so their existence does not leak.
+var (
+	ErrThreadNotFound = errors.New("thread not found")
+	ErrPostNotFound   = errors.New("post not found")
+)
+
+// canViewAudience is the audience rule every thread read goes through:
+// admins see everything, siswa and guru see their own audience and
+// "semua", any other role only "semua".
+func canViewAudience(role string, audience string) bool {
+	switch role {
+	case "admin":
+		return true
+	case "siswa":
+		return audience == "siswa" || audience == "semua"
+	case "guru":
+		return audience == "guru" || audience == "semua"
+	default:
+		return audience == "semua"
+	}
+}
+
+// visibleAudiences lists the audiences role may see for list queries; nil
+// means all of them.
+func visibleAudiences(role string) []string {
+	switch role {
+	case "admin":
+		return nil
+	case "siswa":
+		return []string{"siswa", "semua"}
+	case "guru":
+		return []string{"guru", "semua"}
+	default:
+		return []string{"semua"}
+	}
+}
+
+// canPostToAudience reports whether role may create a thread for audience:
+// only for audiences it can see itself.
+func canPostToAudience(role string, audience string) bool {
+	switch role {
+	case "admin":
+		return true
+	case "siswa":
+		return audience != "guru"
+	case "guru":
+		return audience != "siswa"
+	default:
+		return audience == "semua"
+	}
+}
+
+// VisibilityPolicy loads threads and posts on behalf of a user. Every single
+// thread or post read and write goes through it; anything the user cannot
+// see comes back as ErrThreadNotFound or ErrPostNotFound. Whatever shows or
+// notifies about threads (bookmarks, trending, notifications, attachments)
+// asks it too, so authors see their own threads everywhere.
+type VisibilityPolicy interface {
+	Thread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*model.Thread, error)
+	ThreadBySlug(ctx context.Context, userID uuid.UUID, slug string) (*model.Thread, error)
+	Post(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (*model.Post, error)
+	CanView(ctx context.Context, userID uuid.UUID, thread *model.Thread) bool
+	// CanViewAs is CanView for an already loaded user, for checks over many
+	// users or threads.
+	CanViewAs(user *model.User, thread *model.Thread) bool
+	// FilterThreads keeps, in order, the threads the user may see.
+	FilterThreads(ctx context.Context, userID uuid.UUID, threads []*model.Thread) ([]*model.Thread, error)
+	// Audiences lists the audiences the user may see for queries that filter
+	// in the database; nil means all of them.
+	Audiences(ctx context.Context, userID uuid.UUID) ([]string, error)
+}
+
+type visibilityPolicy struct {
+	userRepo   repository.UserRepository
+	threadRepo repository.ThreadRepository
+	postRepo   repository.PostRepository
+}
+
+func NewVisibilityPolicy(userRepo repository.UserRepository, threadRepo repository.ThreadRepository, postRepo repository.PostRepository) VisibilityPolicy {
+	return &visibilityPolicy{
+		userRepo:   userRepo,
+		threadRepo: threadRepo,
+		postRepo:   postRepo,
+	}
+}
+
+// CanView reports whether the user may see the thread. Authors always see
+// their own threads.
+func (p *visibilityPolicy) CanView(ctx context.Context, userID uuid.UUID, thread *model.Thread) bool {
+	if thread.UserID == userID {
+		return true
+	}
+	user, err := p.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return false
+	}
+	return p.CanViewAs(user, thread)
+}
+
+func (p *visibilityPolicy) CanViewAs(user *model.User, thread *model.Thread) bool {
+	return thread.UserID == user.ID || canViewAudience(user.Role.Name, thread.Audience)
+}
+
+func (p *visibilityPolicy) FilterThreads(ctx context.Context, userID uuid.UUID, threads []*model.Thread) ([]*model.Thread, error) {
+	user, err := p.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]*model.Thread, 0, len(threads))
+	for _, thread := range threads {
+		if p.CanViewAs(user, thread) {
+			visible = append(visible, thread)
+		}
+	}
+	return visible, nil
+}
+
+func (p *visibilityPolicy) Audiences(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	user, err := p.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	return visibleAudiences(user.Role.Name), nil
+}
+
+func (p *visibilityPolicy) checkThread(ctx context.Context, userID uuid.UUID, thread *model.Thread, err error) (*model.Thread, error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrThreadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !p.CanView(ctx, userID, thread) {
+		return nil, ErrThreadNotFound
+	}
+	return thread, nil
+}
+
+func (p *visibilityPolicy) Thread(ctx context.Context, userID uuid.UUID, threadID uuid.UUID) (*model.Thread, error) {
+	thread, err := p.threadRepo.FindByID(ctx, threadID)
+	return p.checkThread(ctx, userID, thread, err)
+}
+
+func (p *visibilityPolicy) ThreadBySlug(ctx context.Context, userID uuid.UUID, slug string) (*model.Thread, error) {
+	thread, err := p.threadRepo.FindBySlug(ctx, slug)
+	return p.checkThread(ctx, userID, thread, err)
+}
+
+// Post returns the post with its Thread filled in.
+func (p *visibilityPolicy) Post(ctx context.Context, userID uuid.UUID, postID uuid.UUID) (*model.Post, error) {
+	post, err := p.postRepo.FindByID(ctx, postID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	thread, err := p.Thread(ctx, userID, post.ThreadID)
+	if errors.Is(err, ErrThreadNotFound) {
+		return nil, ErrPostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	post.Thread = *thread
+	return post, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"anoa.com/telkomalumiforum/internal/model"
+	"anoa.com/telkomalumiforum/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The fakes embed the repository interfaces and implement only the lookups
+// the policy uses.
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[string]*model.User
+}
+
+func (r *fakeUserRepo) FindByID(ctx context.Context, id string) (*model.User, error) {
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type fakeThreadRepo struct {
+	repository.ThreadRepository
+	threads map[uuid.UUID]*model.Thread
+}
+
+func (r *fakeThreadRepo) FindByID(ctx context.Context, id uuid.UUID) (*model.Thread, error) {
+	if thread, ok := r.threads[id]; ok {
+		copied := *thread
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeThreadRepo) FindBySlug(ctx context.Context, slug string) (*model.Thread, error) {
+	for _, thread := range r.threads {
+		if thread.Slug == slug {
+			copied := *thread
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type fakePostRepo struct {
+	repository.PostRepository
+	posts map[uuid.UUID]*model.Post
+}
+
+func (r *fakePostRepo) FindByID(ctx context.Context, id uuid.UUID) (*model.Post, error) {
+	if post, ok := r.posts[id]; ok {
+		copied := *post
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+var testAudiences = []string{"semua", "guru", "siswa"}
+
+// Which audiences each role may see, and post to, when not the author
+var (
+	wantView = map[string]map[string]bool{
+		"admin": {"semua": true, "guru": true, "siswa": true},
+		"guru":  {"semua": true, "guru": true, "siswa": false},
+		"siswa": {"semua": true, "guru": false, "siswa": true},
+		"other": {"semua": true, "guru": false, "siswa": false},
+	}
+	wantPost = map[string]map[string]bool{
+		"admin": {"semua": true, "guru": true, "siswa": true},
+		"guru":  {"semua": true, "guru": true, "siswa": false},
+		"siswa": {"semua": true, "guru": false, "siswa": true},
+		"other": {"semua": true, "guru": false, "siswa": false},
+	}
+)
+
+func TestVisibility(t *testing.T) {
+	ctx := context.Background()
+
+	for _, role := range []string{"admin", "guru", "siswa", "other"} {
+		for _, audience := range testAudiences {
+			for _, author := range []bool{true, false} {
+				t.Run(fmt.Sprintf("%s/%s/author=%v", role, audience, author), func(t *testing.T) {
+					viewer := &model.User{ID: uuid.New(), Role: model.Role{Name: role}}
+					authorID := uuid.New()
+					if author {
+						authorID = viewer.ID
+					}
+					thread := &model.Thread{ID: uuid.New(), UserID: authorID, Audience: audience, Slug: "thread-" + audience}
+					post := &model.Post{ID: uuid.New(), ThreadID: thread.ID, UserID: uuid.New()}
+
+					policy := NewVisibilityPolicy(
+						&fakeUserRepo{users: map[string]*model.User{viewer.ID.String(): viewer}},
+						&fakeThreadRepo{threads: map[uuid.UUID]*model.Thread{thread.ID: thread}},
+						&fakePostRepo{posts: map[uuid.UUID]*model.Post{post.ID: post}},
+					)
+
+					view := wantView[role][audience]
+					visible := view || author
+
+					if got := canViewAudience(role, audience); got != view {
+						t.Errorf("canViewAudience = %v, want %v", got, view)
+					}
+					listed := visibleAudiences(role)
+					if got := listed == nil || slices.Contains(listed, audience); got != view {
+						t.Errorf("visibleAudiences = %v, contains %s: %v, want %v", listed, audience, got, view)
+					}
+					if got := canPostToAudience(role, audience); got != wantPost[role][audience] {
+						t.Errorf("canPostToAudience = %v, want %v", got, wantPost[role][audience])
+					}
+
+					if got := policy.CanView(ctx, viewer.ID, thread); got != visible {
+						t.Errorf("CanView = %v, want %v", got, visible)
+					}
+					if got := policy.CanViewAs(viewer, thread); got != visible {
+						t.Errorf("CanViewAs = %v, want %v", got, visible)
+					}
+					filtered, err := policy.FilterThreads(ctx, viewer.ID, []*model.Thread{thread})
+					if err != nil {
+						t.Fatalf("FilterThreads: %v", err)
+					}
+					if got := len(filtered) == 1; got != visible {
+						t.Errorf("FilterThreads kept %d threads, want visible %v", len(filtered), visible)
+					}
+
+					gotThread, err := policy.Thread(ctx, viewer.ID, thread.ID)
+					checkFound(t, "Thread", err, ErrThreadNotFound, visible)
+					if visible && gotThread.ID != thread.ID {
+						t.Errorf("Thread returned %s, want %s", gotThread.ID, thread.ID)
+					}
+					_, err = policy.ThreadBySlug(ctx, viewer.ID, thread.Slug)
+					checkFound(t, "ThreadBySlug", err, ErrThreadNotFound, visible)
+
+					gotPost, err := policy.Post(ctx, viewer.ID, post.ID)
+					checkFound(t, "Post", err, ErrPostNotFound, visible)
+					if visible && gotPost.Thread.ID != thread.ID {
+						t.Errorf("Post returned thread %s, want %s", gotPost.Thread.ID, thread.ID)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestVisibilityMissing(t *testing.T) {
+	ctx := context.Background()
+	viewer := &model.User{ID: uuid.New(), Role: model.Role{Name: "admin"}}
+	orphan := &model.Post{ID: uuid.New(), ThreadID: uuid.New()}
+	policy := NewVisibilityPolicy(
+		&fakeUserRepo{users: map[string]*model.User{viewer.ID.String(): viewer}},
+		&fakeThreadRepo{threads: map[uuid.UUID]*model.Thread{}},
+		&fakePostRepo{posts: map[uuid.UUID]*model.Post{orphan.ID: orphan}},
+	)
+
+	_, err := policy.Thread(ctx, viewer.ID, uuid.New())
+	checkFound(t, "Thread", err, ErrThreadNotFound, false)
+	_, err = policy.ThreadBySlug(ctx, viewer.ID, "missing")
+	checkFound(t, "ThreadBySlug", err, ErrThreadNotFound, false)
+	_, err = policy.Post(ctx, viewer.ID, uuid.New())
+	checkFound(t, "Post", err, ErrPostNotFound, false)
+	// A post whose thread is gone is missing too
+	_, err = policy.Post(ctx, viewer.ID, orphan.ID)
+	checkFound(t, "Post of a missing thread", err, ErrPostNotFound, false)
+}
+
+func checkFound(t *testing.T, name string, err, notFound error, want bool) {
+	t.Helper()
+	switch {
+	case want && err != nil:
+		t.Errorf("%s: unexpected error %v", name, err)
+	case !want && !errors.Is(err, notFound):
+		t.Errorf("%s: error %v, want %v", name, err, notFound)
+	}
+}
@@ -0,0 +1,520 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Queue is a reliable work queue on Redis lists.
+//
+// Workers move a task from <name> into their own <name>:processing:<consumer>
+// list with BLMOVE and only remove it once it has been handled, so a crash
+// never loses a task: the consumer puts whatever is left in its processing
+// list back on the queue when it starts again. Consumers that never come back
+// (another hostname after a redeploy, fewer workers) are found through their
+// <name>:heartbeat:<consumer> key expiring, and any running instance reaps
+// their processing lists. Failed tasks are retried with exponential backoff
+// through the <name>:delayed sorted set and end up in <name>:dead once
+// MaxAttempts is reached.
+type Queue struct {
+	client *redis.Client
+	name   string
+	opts   Options
+}
+
+type Options struct {
+	MaxAttempts int           // Default 5
+	BaseBackoff time.Duration // Default 1s, doubled on every attempt
+	MaxBackoff  time.Duration // Default 5m
+
+	// OnDone, when set, is called after every attempt
+	OnDone func(Result)
+}
+
+// Result describes one attempt at a task.
+type Result struct {
+	Envelope Envelope
+	Attempt  int
+	Err      error
+	Dead     bool // Out of attempts or permanent, moved to the dead-letter list
+	Duration time.Duration
+}
+
+// Envelope wraps a payload with its delivery state.
+type Envelope struct {
+	ID        string          `json:"id"`
+	Attempts  int             `json:"attempts"`
+	Payload   json.RawMessage `json:"payload"`
+	LastError string          `json:"last_error,omitempty"`
+	FailedAt  *time.Time      `json:"failed_at,omitempty"`
+}
+
+type Stats struct {
+	Pending    int64 `json:"pending"`
+	Processing int64 `json:"processing"`
+	Delayed    int64 `json:"delayed"`
+	Dead       int64 `json:"dead"`
+}
+
+// Handler processes one payload. Returning an error retries the task unless
+// it is wrapped with Permanent.
+type Handler func(ctx context.Context, payload []byte) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying cannot fix, such as a malformed
+// payload. The task goes straight to the dead-letter list.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Running consumers refresh their heartbeat every heartbeatInterval; one
+// missing for heartbeatTTL is considered gone. Reaping runs every
+// reapInterval.
+const (
+	heartbeatInterval = 10 * time.Second
+	heartbeatTTL      = 30 * time.Second
+	reapInterval      = 30 * time.Second
+)
+
+// reapScript moves the processing list of a consumer back onto the queue,
+// unless its heartbeat came back in the meantime.
+var reapScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local moved = 0
+while redis.call('LMOVE', KEYS[2], KEYS[3], 'RIGHT', 'LEFT') do
+	moved = moved + 1
+end
+return moved
+`)
+
+// promoteScript moves due tasks from the delayed set back onto the queue.
+var promoteScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, task in ipairs(due) do
+	redis.call('ZREM', KEYS[1], task)
+	redis.call('RPUSH', KEYS[2], task)
+end
+return #due
+`)
+
+// replayScript moves one dead task back onto the queue, unless another
+// replay already took it.
+var replayScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('RPUSH', KEYS[2], ARGV[2])
+return 1
+`)
+
+func New(client *redis.Client, name string, opts Options) *Queue {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 5
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+	return &Queue{client: client, name: name, opts: opts}
+}
+
+func (q *Queue) Name() string {
+	return q.name
+}
+
+func (q *Queue) delayedKey() string { return q.name + ":delayed" }
+func (q *Queue) deadKey() string    { return q.name + ":dead" }
+
+func (q *Queue) processingKey(consumer string) string {
+	return q.name + ":processing:" + consumer
+}
+
+func (q *Queue) heartbeatKey(consumer string) string {
+	return q.name + ":heartbeat:" + consumer
+}
+
+// Push enqueues v as JSON.
+func (q *Queue) Push(ctx context.Context, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(Envelope{ID: id.String(), Payload: payload})
+	if err != nil {
+		return err
+	}
+	return q.client.RPush(ctx, q.name, raw).Err()
+}
+
+// decode reads an envelope. Tasks pushed before the queue used envelopes are
+// bare payloads and are wrapped on the fly.
+func decode(raw string) Envelope {
+	var env Envelope
+	if err := json.Unmarshal([]byte(raw), &env); err != nil || env.ID == "" || len(env.Payload) == 0 {
+		return Envelope{ID: uuid.NewString(), Payload: json.RawMessage(raw)}
+	}
+	return env
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.opts.BaseBackoff
+	for i := 1; i < attempts && d < q.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > q.opts.MaxBackoff {
+		d = q.opts.MaxBackoff
+	}
+	return d
+}
+
+// Run starts workers consumers and blocks until ctx is cancelled and every
+// in-flight task has finished. Consumer names derive from the hostname, so a
+// restarted instance picks up the tasks its previous run left behind; tasks
+// of consumers that do not come back are reaped, see reap.
+func (q *Queue) Run(ctx context.Context, workers int, handler Handler) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	consumers := consumerNames(workers)
+
+	// The heartbeat outlives ctx until the last in-flight task is done, so
+	// the tasks are not reaped while still being handled
+	beating := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	q.beat(context.Background(), consumers)
+	go func() {
+		defer close(heartbeatDone)
+		q.heartbeat(beating, consumers)
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		q.promote(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		q.reap(ctx)
+	}()
+
+	for _, consumer := range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.consume(ctx, consumer, handler)
+		}()
+	}
+
+	wg.Wait()
+	close(beating)
+	<-heartbeatDone
+	log.Printf("Queue %s drained and stopped", q.name)
+}
+
+// consumerNames names the consumers of this instance after its hostname.
+func consumerNames(workers int) []string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "local"
+	}
+	consumers := make([]string, workers)
+	for i := range consumers {
+		consumers[i] = host + "-" + strconv.Itoa(i)
+	}
+	return consumers
+}
+
+// heartbeat keeps the consumers' heartbeat keys alive until stop is closed,
+// then removes them.
+func (q *Queue) heartbeat(stop <-chan struct{}, consumers []string) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	ctx := context.Background()
+	for {
+		select {
+		case <-stop:
+			keys := make([]string, len(consumers))
+			for i, consumer := range consumers {
+				keys[i] = q.heartbeatKey(consumer)
+			}
+			if err := q.client.Del(ctx, keys...).Err(); err != nil {
+				log.Printf("Queue %s: failed to remove heartbeats: %v", q.name, err)
+			}
+			return
+		case <-ticker.C:
+			q.beat(ctx, consumers)
+		}
+	}
+}
+
+func (q *Queue) beat(ctx context.Context, consumers []string) {
+	pipe := q.client.Pipeline()
+	for _, consumer := range consumers {
+		pipe.Set(ctx, q.heartbeatKey(consumer), time.Now().Unix(), heartbeatTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Queue %s: failed to send heartbeat: %v", q.name, err)
+	}
+}
+
+// reap periodically puts the tasks of consumers without a heartbeat back on
+// the queue. Their handler may have run already, so like any redelivery the
+// task can be handled twice.
+func (q *Queue) reap(ctx context.Context) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := q.reapOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Queue %s: failed to reap processing lists: %v", q.name, err)
+			}
+		}
+	}
+}
+
+func (q *Queue) reapOnce(ctx context.Context) error {
+	prefix := q.processingKey("")
+	var cursor uint64
+	for {
+		keys, next, err := q.client.Scan(ctx, cursor, q.processingKey("*"), 100).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			consumer := strings.TrimPrefix(key, prefix)
+			moved, err := reapScript.Run(ctx, q.client, []string{q.heartbeatKey(consumer), key, q.name}).Int()
+			if err != nil {
+				return err
+			}
+			if moved > 0 {
+				log.Printf("Queue %s: requeued %d tasks of consumer %s, which has no heartbeat", q.name, moved, consumer)
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func (q *Queue) consume(ctx context.Context, consumer string, handler Handler) {
+	processing := q.processingKey(consumer)
+
+	// Put back whatever a previous run of this consumer was handling
+	for {
+		err := q.client.LMove(ctx, processing, q.name, "RIGHT", "LEFT").Err()
+		if err == redis.Nil {
+			break
+		}
+		if err != nil {
+			log.Printf("Queue %s: failed to recover %s: %v", q.name, processing, err)
+			break
+		}
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A short timeout keeps the loop responsive to shutdown
+		raw, err := q.client.BLMove(ctx, q.name, processing, "LEFT", "RIGHT", time.Second).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Queue %s: BLMOVE error: %v, retrying in 1s...", q.name, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		// The task runs to completion even during shutdown
+		q.handle(context.Background(), processing, raw, handler)
+	}
+}
+
+func (q *Queue) handle(ctx context.Context, processing string, raw string, handler Handler) {
+	env := decode(raw)
+	result := Result{Envelope: env, Attempt: env.Attempts + 1}
+
+	start := time.Now()
+	herr := q.safeCall(ctx, handler, env.Payload)
+	result.Err = herr
+	result.Duration = time.Since(start)
+
+	pipe := q.client.TxPipeline()
+	pipe.LRem(ctx, processing, 1, raw)
+	if herr != nil {
+		now := time.Now()
+		env.Attempts++
+		env.LastError = herr.Error()
+		env.FailedAt = &now
+
+		next, _ := json.Marshal(env)
+		var perm *permanentError
+		if errors.As(herr, &perm) || env.Attempts >= q.opts.MaxAttempts {
+			log.Printf("Queue %s: task %s dead after %d attempts: %v", q.name, env.ID, env.Attempts, herr)
+			pipe.LPush(ctx, q.deadKey(), next)
+			result.Dead = true
+		} else {
+			retryAt := now.Add(q.backoff(env.Attempts))
+			pipe.ZAdd(ctx, q.delayedKey(), redis.Z{Score: float64(retryAt.UnixMilli()), Member: next})
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		// The task stays in the processing list and is recovered on restart
+		log.Printf("Queue %s: failed to acknowledge task %s: %v", q.name, env.ID, err)
+	}
+
+	if q.opts.OnDone != nil {
+		q.opts.OnDone(result)
+	}
+}
+
+func (q *Queue) safeCall(ctx context.Context, handler Handler, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, payload)
+}
+
+func (q *Queue) promote(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			if err := promoteScript.Run(ctx, q.client, []string{q.delayedKey(), q.name}, now, 100).Err(); err != nil && ctx.Err() == nil {
+				log.Printf("Queue %s: failed to promote delayed tasks: %v", q.name, err)
+			}
+		}
+	}
+}
+
+// DeadLetters returns up to limit dead tasks, newest first.
+func (q *Queue) DeadLetters(ctx context.Context, limit int) ([]Envelope, error) {
+	raws, err := q.client.LRange(ctx, q.deadKey(), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make([]Envelope, 0, len(raws))
+	for _, raw := range raws {
+		envs = append(envs, decode(raw))
+	}
+	return envs, nil
+}
+
+// Replay moves dead tasks back onto the queue with a fresh attempt budget.
+// An empty ids replays every dead task. It returns how many were moved.
+func (q *Queue) Replay(ctx context.Context, ids []string) (int, error) {
+	raws, err := q.client.LRange(ctx, q.deadKey(), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	replayed := 0
+	for _, raw := range raws {
+		env := decode(raw)
+		if len(ids) > 0 && !wanted[env.ID] {
+			continue
+		}
+
+		env.Attempts = 0
+		next, err := json.Marshal(env)
+		if err != nil {
+			return replayed, err
+		}
+
+		moved, err := replayScript.Run(ctx, q.client, []string{q.deadKey(), q.name}, raw, next).Int()
+		if err != nil {
+			return replayed, err
+		}
+		replayed += moved
+	}
+	return replayed, nil
+}
+
+func (q *Queue) Stats(ctx context.Context) (*Stats, error) {
+	stats := &Stats{}
+
+	pipe := q.client.Pipeline()
+	pending := pipe.LLen(ctx, q.name)
+	delayed := pipe.ZCard(ctx, q.delayedKey())
+	dead := pipe.LLen(ctx, q.deadKey())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	stats.Pending = pending.Val()
+	stats.Delayed = delayed.Val()
+	stats.Dead = dead.Val()
+
+	var cursor uint64
+	for {
+		keys, next, err := q.client.Scan(ctx, cursor, q.processingKey("*"), 100).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			n, err := q.client.LLen(ctx, key).Result()
+			if err != nil {
+				return nil, err
+			}
+			stats.Processing += n
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	return stats, nil
+}
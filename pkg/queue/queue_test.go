@@ -0,0 +1,330 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestQueue(t *testing.T, opts Options) (*Queue, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(client, "test", opts), client
+}
+
+// runQueue runs q until the returned stop is called, which waits for Run to
+// return.
+func runQueue(t *testing.T, q *Queue, handler Handler) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx, 1, handler)
+	}()
+	stop = func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not return after cancel")
+		}
+	}
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return stop
+}
+
+// waitFor polls cond until it holds or the deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func stats(t *testing.T, q *Queue) Stats {
+	t.Helper()
+	s, err := q.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	return *s
+}
+
+type task struct {
+	N int `json:"n"`
+}
+
+func TestAck(t *testing.T) {
+	q, _ := newTestQueue(t, Options{})
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var got []int
+	stop := runQueue(t, q, func(ctx context.Context, payload []byte) error {
+		var tk task
+		if err := json.Unmarshal(payload, &tk); err != nil {
+			return Permanent(err)
+		}
+		mu.Lock()
+		got = append(got, tk.N)
+		mu.Unlock()
+		return nil
+	})
+
+	for i := 1; i <= 3; i++ {
+		if err := q.Push(ctx, task{N: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "three tasks", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 3
+	})
+	stop()
+
+	if got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("handled %v, want [1 2 3]", got)
+	}
+	if s := stats(t, q); s != (Stats{}) {
+		t.Errorf("stats after ack %+v, want all zero", s)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var results []Result
+	var mu sync.Mutex
+	q, client := newTestQueue(t, Options{
+		// Long enough to look at the delayed set before the retry
+		BaseBackoff: 300 * time.Millisecond,
+		OnDone: func(r Result) {
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		},
+	})
+	ctx := context.Background()
+
+	var calls int
+	stop := runQueue(t, q, func(ctx context.Context, payload []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+	if err := q.Push(ctx, task{N: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed attempt waits in the delayed set with its error
+	waitFor(t, "the failed attempt", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(results) >= 1
+	})
+	delayed, err := client.ZRange(ctx, q.delayedKey(), 0, -1).Result()
+	if err != nil || len(delayed) != 1 {
+		t.Fatalf("delayed set %v, %v, want the failed task", delayed, err)
+	}
+	if env := decode(delayed[0]); env.Attempts != 1 || env.LastError != "temporary" || env.FailedAt == nil {
+		t.Errorf("delayed envelope %+v, want one attempt that failed with temporary", env)
+	}
+
+	// and is promoted and handled again
+	waitFor(t, "the retry", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(results) == 2
+	})
+	stop()
+
+	if results[0].Err == nil || results[0].Dead || results[0].Attempt != 1 {
+		t.Errorf("first attempt %+v, want a retried failure", results[0])
+	}
+	if results[1].Err != nil || results[1].Attempt != 2 || results[1].Envelope.ID != results[0].Envelope.ID {
+		t.Errorf("second attempt %+v, want the same task succeeding", results[1])
+	}
+	if s := stats(t, q); s != (Stats{}) {
+		t.Errorf("stats after the retry %+v, want all zero", s)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"out of attempts", errors.New("still failing"), 2},
+		{"permanent", Permanent(errors.New("malformed")), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var results []Result
+			q, _ := newTestQueue(t, Options{
+				MaxAttempts: 2,
+				BaseBackoff: time.Millisecond,
+				OnDone: func(r Result) {
+					mu.Lock()
+					results = append(results, r)
+					mu.Unlock()
+				},
+			})
+			ctx := context.Background()
+
+			stop := runQueue(t, q, func(ctx context.Context, payload []byte) error { return tt.err })
+			if err := q.Push(ctx, task{N: 1}); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, "the dead letter", func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(results) > 0 && results[len(results)-1].Dead
+			})
+			stop()
+
+			if len(results) != tt.attempts {
+				t.Errorf("%d attempts, want %d", len(results), tt.attempts)
+			}
+			dead, err := q.DeadLetters(ctx, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dead) != 1 || dead[0].Attempts != tt.attempts || dead[0].LastError != tt.err.Error() {
+				t.Fatalf("dead letters %+v, want the task after %d attempts", dead, tt.attempts)
+			}
+			if s := stats(t, q); s.Pending != 0 || s.Processing != 0 || s.Delayed != 0 || s.Dead != 1 {
+				t.Errorf("stats %+v, want only one dead task", s)
+			}
+
+			// Replay gives it a fresh budget
+			if n, err := q.Replay(ctx, []string{dead[0].ID}); err != nil || n != 1 {
+				t.Fatalf("Replay = %d, %v, want 1", n, err)
+			}
+			if s := stats(t, q); s.Pending != 1 || s.Dead != 0 {
+				t.Errorf("stats after replay %+v, want one pending task", s)
+			}
+		})
+	}
+}
+
+func TestReap(t *testing.T) {
+	q, client := newTestQueue(t, Options{})
+	ctx := context.Background()
+
+	client.RPush(ctx, q.processingKey("gone-0"), "a", "b")
+	client.RPush(ctx, q.processingKey("alive-0"), "c")
+	client.Set(ctx, q.heartbeatKey("alive-0"), 1, heartbeatTTL)
+
+	if err := q.reapOnce(ctx); err != nil {
+		t.Fatalf("reapOnce: %v", err)
+	}
+
+	if pending, _ := client.LRange(ctx, q.name, 0, -1).Result(); len(pending) != 2 || pending[0] != "a" || pending[1] != "b" {
+		t.Errorf("queue %v, want the gone consumer's [a b] in order", pending)
+	}
+	if n, _ := client.LLen(ctx, q.processingKey("gone-0")).Result(); n != 0 {
+		t.Errorf("gone consumer still holds %d tasks", n)
+	}
+	if n, _ := client.LLen(ctx, q.processingKey("alive-0")).Result(); n != 1 {
+		t.Errorf("live consumer holds %d tasks, want its 1 left alone", n)
+	}
+}
+
+func TestRecoverOnStart(t *testing.T) {
+	q, client := newTestQueue(t, Options{})
+	ctx := context.Background()
+
+	// A task a previous run of this instance's consumer was handling
+	if err := q.Push(ctx, task{N: 7}); err != nil {
+		t.Fatal(err)
+	}
+	consumer := consumerNames(1)[0]
+	if err := client.LMove(ctx, q.name, q.processingKey(consumer), "LEFT", "RIGHT").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	handled := make(chan struct{}, 1)
+	stop := runQueue(t, q, func(ctx context.Context, payload []byte) error {
+		handled <- struct{}{}
+		return nil
+	})
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the left over task was not handled")
+	}
+	stop()
+	if s := stats(t, q); s != (Stats{}) {
+		t.Errorf("stats %+v, want all zero", s)
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	q, client := newTestQueue(t, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx, 1, func(ctx context.Context, payload []byte) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+
+	if err := q.Push(context.Background(), task{N: 1}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	cancel()
+
+	// Run waits for the task, whose heartbeat stays up so it is not reaped
+	select {
+	case <-done:
+		t.Fatal("Run returned with a task in flight")
+	case <-time.After(200 * time.Millisecond):
+	}
+	heartbeat := q.heartbeatKey(consumerNames(1)[0])
+	if n, _ := client.Exists(context.Background(), heartbeat).Result(); n != 1 {
+		t.Error("heartbeat removed while a task is in flight")
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the task finished")
+	}
+	if s := stats(t, q); s != (Stats{}) {
+		t.Errorf("stats after shutdown %+v, want the task acknowledged", s)
+	}
+	if n, _ := client.Exists(context.Background(), heartbeat).Result(); n != 0 {
+		t.Error("heartbeat left behind after shutdown")
+	}
+}
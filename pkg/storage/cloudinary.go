@@ -15,14 +15,8 @@ import (
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 )
 
-// ImageStorage defines contract for image storage provider (Cloudinary implementation).
-type ImageStorage interface {
-	// UploadImage uploads image from reader and returns the secure URL.
-	// folder is optional logical folder in storage (e.g. "avatars").
-	UploadImage(ctx context.Context, r io.Reader, folder, fileName string) (string, error)
-	// DeleteImage deletes image from storage using its URL.
-	DeleteImage(ctx context.Context, fileURL string) error
-}
+// CloudinaryURLPrefix starts the URL of every file stored on Cloudinary.
+const CloudinaryURLPrefix = "https://res.cloudinary.com/"
 
 type cloudinaryStorage struct {
 	cld *cloudinary.Cloudinary
@@ -97,8 +91,8 @@ func (s *cloudinaryStorage) DeleteImage(ctx context.Context, fileURL string) err
 
 	publicID := s.extractPublicID(fileURL)
 	if publicID == "" {
-		// If we can't extract public ID, we can't delete it. 
-        // We could return error, but maybe just log it. Returns error for now.
+		// If we can't extract public ID, we can't delete it.
+		// We could return error, but maybe just log it. Returns error for now.
 		return fmt.Errorf("could not extract public ID from URL: %s", fileURL)
 	}
 
@@ -166,4 +160,4 @@ func (s *cloudinaryStorage) extractPublicID(fileURL string) string {
 	// Strip extension
 	ext := filepath.Ext(publicIDWithExt)
 	return strings.TrimSuffix(publicIDWithExt, ext)
-}
\ No newline at end of file
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalFilesRoute is where the server mounts the handler of the local backend.
+const LocalFilesRoute = "/files"
+
+type LocalConfig struct {
+	Root          string          // Directory the files are written to
+	BaseURL       string          // Public URL of LocalFilesRoute, e.g. https://api.example.com/files
+	SigningKey    string          // HMAC key of signed URLs, required when SignedFolders is not empty
+	SignedFolders map[string]bool // Folders only readable through a signed URL
+	SignedURLTTL  time.Duration
+}
+
+// localStorage keeps files on disk and serves them itself, see ServeHTTP.
+// Used for development and single-server deployments.
+type localStorage struct {
+	root    *os.Root
+	dir     string
+	baseURL string
+	key     []byte
+	signed  map[string]bool
+	ttl     time.Duration
+}
+
+// NewLocalStorage creates a filesystem-backed implementation of ImageStorage.
+// The returned value is also an http.Handler serving the files under
+// LocalFilesRoute.
+func NewLocalStorage(cfg LocalConfig) (ImageStorage, error) {
+	if len(cfg.SignedFolders) > 0 && cfg.SigningKey == "" {
+		return nil, fmt.Errorf("STORAGE_SIGNING_KEY is required to keep %s private", folderList(cfg.SignedFolders))
+	}
+	if err := os.MkdirAll(cfg.Root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	root, err := os.OpenRoot(cfg.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage directory: %w", err)
+	}
+
+	return &localStorage{
+		root:    root,
+		dir:     cfg.Root,
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		key:     []byte(cfg.SigningKey),
+		signed:  cfg.SignedFolders,
+		ttl:     cfg.SignedURLTTL,
+	}, nil
+}
+
+// UploadImage writes the file under a new key and returns its URL. The file
+// only becomes visible once completely written.
+func (s *localStorage) UploadImage(ctx context.Context, r io.Reader, folder, fileName string) (string, error) {
+	key := objectKey(folder, fileName)
+	dir := filepath.Join(s.dir, filepath.FromSlash(path.Dir(key)))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, filepath.FromSlash(key))); err != nil {
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return s.baseURL + "/" + key, nil
+}
+
+// DeleteImage removes the file; one that is already gone is not an error.
+func (s *localStorage) DeleteImage(ctx context.Context, fileURL string) error {
+	key, ok := s.keyFromURL(fileURL)
+	if !ok {
+		return fmt.Errorf("not a local storage URL: %s", fileURL)
+	}
+	if err := s.root.Remove(filepath.FromSlash(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// SignURL appends an expiry and its HMAC to URLs of private files.
+func (s *localStorage) SignURL(fileURL string) (string, error) {
+	key, ok := s.keyFromURL(fileURL)
+	if !ok || !isSigned(s.signed, key) {
+		return fileURL, nil
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(s.ttl).Unix(), 10)
+	q := url.Values{"expires": {expires}, "signature": {s.signature(key, expires)}}
+	return fileURL + "?" + q.Encode(), nil
+}
+
+// ServeHTTP serves the file named by the request path, which must already be
+// stripped of LocalFilesRoute. Private files need a valid, unexpired
+// signature. Files are served sandboxed, so an uploaded HTML or SVG file
+// cannot run scripts on the API's origin.
+func (s *localStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if key == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	cacheControl := "public, max-age=31536000, immutable"
+	if isSigned(s.signed, key) {
+		expires := r.URL.Query().Get("expires")
+		exp, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() > exp ||
+			!hmac.Equal([]byte(r.URL.Query().Get("signature")), []byte(s.signature(key, expires))) {
+			http.Error(w, "invalid or expired signature", http.StatusForbidden)
+			return
+		}
+		cacheControl = fmt.Sprintf("private, max-age=%d", exp-time.Now().Unix())
+	}
+
+	f, err := s.root.Open(filepath.FromSlash(key))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func (s *localStorage) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(key + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// keyFromURL returns the key of a URL handed out by this backend, signed or
+// not.
+func (s *localStorage) keyFromURL(fileURL string) (string, bool) {
+	fileURL, _, _ = strings.Cut(fileURL, "?")
+	key, ok := strings.CutPrefix(fileURL, s.baseURL+"/")
+	if !ok || key == "" {
+		return "", false
+	}
+	if key = strings.TrimPrefix(path.Clean("/"+key), "/"); key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+func folderList(folders map[string]bool) string {
+	names := make([]string, 0, len(folders))
+	for folder := range folders {
+		names = append(names, folder)
+	}
+	return strings.Join(names, ", ")
+}
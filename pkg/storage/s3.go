@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Config struct {
+	Endpoint      string // host[:port] of the S3 API, e.g. localhost:9000 for MinIO
+	AccessKey     string
+	SecretKey     string
+	Bucket        string
+	Region        string
+	UseSSL        bool
+	PublicURL     string          // URL objects are read from, default <endpoint>/<bucket>
+	SignedFolders map[string]bool // Folders only readable through a presigned URL
+	SignedURLTTL  time.Duration
+}
+
+// s3Storage keeps files in a bucket of any S3-compatible object storage. The
+// bucket should allow anonymous reads of the public folders only; the others
+// are read through presigned URLs.
+type s3Storage struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+	signed    map[string]bool
+	ttl       time.Duration
+}
+
+// NewS3Storage creates an S3-backed implementation of ImageStorage. The
+// bucket is created when missing, which is convenient with a local MinIO.
+func NewS3Storage(cfg S3Config) (ImageStorage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required")
+	}
+
+	// With the region set the client signs requests without looking it up
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize s3 client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check s3 bucket: %w", err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("failed to create s3 bucket: %w", err)
+		}
+	}
+
+	publicURL := cfg.PublicURL
+	if publicURL == "" {
+		publicURL = client.EndpointURL().String() + "/" + cfg.Bucket
+	}
+
+	return &s3Storage{
+		client:    client,
+		bucket:    cfg.Bucket,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		signed:    cfg.SignedFolders,
+		ttl:       cfg.SignedURLTTL,
+	}, nil
+}
+
+// UploadImage stores the file under a new key with its sniffed content type
+// and returns its URL.
+func (s *s3Storage) UploadImage(ctx context.Context, r io.Reader, folder, fileName string) (string, error) {
+	contentType, r, err := sniffContentType(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	key := objectKey(folder, fileName)
+	opts := minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "public, max-age=31536000, immutable",
+		PartSize:     5 << 20,
+	}
+	if isSigned(s.signed, key) {
+		opts.CacheControl = "private"
+	}
+
+	if _, err := s.client.PutObject(ctx, s.bucket, key, r, -1, opts); err != nil {
+		return "", fmt.Errorf("failed to upload file to s3: %w", err)
+	}
+	return s.publicURL + "/" + key, nil
+}
+
+// DeleteImage removes the object; S3 does not report a missing one.
+func (s *s3Storage) DeleteImage(ctx context.Context, fileURL string) error {
+	key, ok := s.keyFromURL(fileURL)
+	if !ok {
+		return fmt.Errorf("not an s3 storage URL: %s", fileURL)
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete file from s3: %w", err)
+	}
+	return nil
+}
+
+// SignURL presigns a GET of private objects. Presigning is computed locally,
+// it does not call the storage.
+func (s *s3Storage) SignURL(fileURL string) (string, error) {
+	key, ok := s.keyFromURL(fileURL)
+	if !ok || !isSigned(s.signed, key) {
+		return fileURL, nil
+	}
+
+	u, err := s.client.PresignedGetObject(context.Background(), s.bucket, key, s.ttl, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *s3Storage) keyFromURL(fileURL string) (string, bool) {
+	fileURL, _, _ = strings.Cut(fileURL, "?")
+	key, ok := strings.CutPrefix(fileURL, s.publicURL+"/")
+	if !ok || key == "" {
+		return "", false
+	}
+	if unescaped, err := url.PathUnescape(key); err == nil {
+		key = unescaped
+	}
+	return key, true
+}
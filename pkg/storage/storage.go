@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ImageStorage defines contract for the file storage provider.
+type ImageStorage interface {
+	// UploadImage uploads image from reader and returns its URL.
+	// folder is optional logical folder in storage (e.g. "avatars").
+	UploadImage(ctx context.Context, r io.Reader, folder, fileName string) (string, error)
+	// DeleteImage deletes image from storage using its URL.
+	DeleteImage(ctx context.Context, fileURL string) error
+}
+
+// URLSigner is implemented by backends that keep some folders private. The
+// stored URL of such a file does not open it; clients get a signed URL that
+// expires instead.
+type URLSigner interface {
+	// SignURL returns a URL that grants read access to the file for a limited
+	// time, or fileURL unchanged when the file is public.
+	SignURL(fileURL string) (string, error)
+}
+
+// Supported STORAGE_BACKEND values
+const (
+	BackendCloudinary = "cloudinary"
+	BackendLocal      = "local"
+	BackendS3         = "s3"
+)
+
+// NewFromEnv creates the backend selected by STORAGE_BACKEND, Cloudinary when
+// unset. Files in the folders listed in STORAGE_SIGNED_FOLDERS (default
+// "attachments") are private on the local and S3 backends and are handed out
+// as URLs signed for STORAGE_SIGNED_URL_TTL (default 1h).
+func NewFromEnv() (ImageStorage, error) {
+	backend := BackendFromEnv()
+
+	signed := signedFolders(envOr("STORAGE_SIGNED_FOLDERS", "attachments"))
+	ttl, err := time.ParseDuration(envOr("STORAGE_SIGNED_URL_TTL", "1h"))
+	if err != nil || ttl <= 0 {
+		return nil, fmt.Errorf("invalid STORAGE_SIGNED_URL_TTL: %q", os.Getenv("STORAGE_SIGNED_URL_TTL"))
+	}
+
+	switch backend {
+	case BackendCloudinary:
+		return NewCloudinaryStorage()
+	case BackendLocal:
+		return NewLocalStorage(LocalConfig{
+			Root:          envOr("LOCAL_STORAGE_DIR", "./uploads"),
+			BaseURL:       envOr("LOCAL_STORAGE_URL", "http://localhost:"+envOr("PORT", "8080")+LocalFilesRoute),
+			SigningKey:    os.Getenv("STORAGE_SIGNING_KEY"),
+			SignedFolders: signed,
+			SignedURLTTL:  ttl,
+		})
+	case BackendS3:
+		return NewS3Storage(S3Config{
+			Endpoint:      os.Getenv("S3_ENDPOINT"),
+			AccessKey:     os.Getenv("S3_ACCESS_KEY"),
+			SecretKey:     os.Getenv("S3_SECRET_KEY"),
+			Bucket:        os.Getenv("S3_BUCKET"),
+			Region:        envOr("S3_REGION", "us-east-1"),
+			UseSSL:        os.Getenv("S3_USE_SSL") == "true",
+			PublicURL:     os.Getenv("S3_PUBLIC_URL"),
+			SignedFolders: signed,
+			SignedURLTTL:  ttl,
+		})
+	}
+	return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+}
+
+// BackendFromEnv returns the backend selected by STORAGE_BACKEND.
+func BackendFromEnv() string {
+	return strings.ToLower(envOr("STORAGE_BACKEND", BackendCloudinary))
+}
+
+// SignedURL returns the URL a client should use to read the file: signed
+// when the backend keeps it private, the stored URL otherwise.
+func SignedURL(s ImageStorage, fileURL string) string {
+	signer, ok := s.(URLSigner)
+	if !ok || fileURL == "" {
+		return fileURL
+	}
+	signedURL, err := signer.SignURL(fileURL)
+	if err != nil {
+		log.Printf("Failed to sign file URL %s: %v", fileURL, err)
+		return fileURL
+	}
+	return signedURL
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// objectKey builds a unique key "folder/<nanos>-<name>" from the uploaded
+// file name, keeping only characters that are safe in paths and URLs.
+func objectKey(folder, fileName string) string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(path.Base(fileName), "-"), ".-")
+	if name == "" {
+		name = "file"
+	}
+	if len(name) > 100 {
+		name = name[len(name)-100:]
+	}
+	key := fmt.Sprintf("%d-%s", time.Now().UnixNano(), name)
+
+	folder = strings.Trim(unsafeFileChars.ReplaceAllString(folder, "-"), ".-")
+	if folder != "" {
+		key = folder + "/" + key
+	}
+	return key
+}
+
+// sniffContentType reads the start of r to detect its content type and
+// returns a reader that still yields the whole content.
+func sniffContentType(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	return http.DetectContentType(head), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+func signedFolders(list string) map[string]bool {
+	folders := make(map[string]bool)
+	for _, folder := range strings.Split(list, ",") {
+		if folder = strings.TrimSpace(folder); folder != "" {
+			folders[folder] = true
+		}
+	}
+	return folders
+}
+
+// isSigned reports whether the key lies in one of the private folders.
+func isSigned(folders map[string]bool, key string) bool {
+	folder, _, found := strings.Cut(key, "/")
+	return found && folders[folder]
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}